@@ -5,44 +5,94 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/auth"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/logging"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/metrics"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/repositories"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/tracing"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/config"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/workflows"
-	dapr "github.com/dapr/go-sdk/client"
+	"github.com/b-fontaine/saaster_kit/backend/platform/health"
+	"github.com/b-fontaine/saaster_kit/backend/platform/idempotency"
+	"github.com/b-fontaine/saaster_kit/backend/platform/lifecycle"
+	platformlogging "github.com/b-fontaine/saaster_kit/backend/platform/logging"
+	platformtracing "github.com/b-fontaine/saaster_kit/backend/platform/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
+	"github.com/uptrace/opentelemetry-go-extra/otelsql"
+	"go.temporal.io/sdk/interceptor"
 )
 
 func main() {
-	// Get environment variables
-	dbHost := getEnv("DB_HOST", "localhost")
-	dbPort := getEnv("DB_PORT", "5432")
-	dbUser := getEnv("DB_USER", "postgres")
-	dbPassword := getEnv("DB_PASSWORD", "password")
-	dbName := getEnv("DB_NAME", "client_manager_db")
-	serverPort := getEnv("SERVER_PORT", "8080")
-	temporalAddress := getEnv("TEMPORAL_ADDRESS", "localhost:7233")
-	temporalNamespace := getEnv("TEMPORAL_NAMESPACE", "client-namespace")
-	temporalTaskQueue := getEnv("TEMPORAL_TASK_QUEUE", "client-manager-task-queue")
+	// Load the configuration, layered from the defaults, CONFIG_FILE, the
+	// environment and the Dapr secret store
+	cfg, err := config.Load()
+
+	// Print the effective configuration when run as "client_manager config print"
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfigCommand(cfg, err, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Log as JSON records carrying the request, trace, tenant and user IDs.
+	// The standard logger, still used by libraries, writes through it too.
+	logger, err := platformlogging.New(platformlogging.Config{Level: cfg.Log.Level, Format: cfg.Log.Format}, cfg.Tracing.ServiceName, os.Stdout)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	// The lifecycle manager runs the server and the worker until SIGINT or
+	// SIGTERM, then drains them and closes the clients opened below in
+	// reverse order
+	manager := lifecycle.NewManager(lifecycle.Config{ShutdownTimeout: cfg.Shutdown.Timeout})
+
+	// Initialize tracing before anything that starts spans. The spans are
+	// flushed last, after the components ended theirs.
+	shutdownTracing, err := platformtracing.Setup(context.Background(), platformtracing.Config{
+		Endpoint:    cfg.Tracing.Endpoint,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	manager.Close("tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
 
 	// Connect to the database
 	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		dbUser, dbPassword, dbHost, dbPort, dbName)
+		cfg.Database.User, cfg.Database.Password, cfg.Database.Host, cfg.Database.Port, cfg.Database.Name)
 
-	db, err := sql.Open("postgres", dbURL)
+	db, err := otelsql.Open("postgres", dbURL,
+		otelsql.WithDBSystem("postgresql"),
+		otelsql.WithDBName(cfg.Database.Name),
+		otelsql.WithTracerProvider(platformtracing.SQLTracerProvider()))
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
-	defer db.Close()
+	manager.Close("database", db.Close)
 
 	// Set connection pool parameters
 	db.SetMaxOpenConns(25)
@@ -54,97 +104,219 @@ func main() {
 	defer cancel()
 
 	if err := db.PingContext(ctx); err != nil {
-		log.Fatalf("Failed to ping database: %v", err)
+		fatal("Failed to ping database", err)
 	}
-	log.Println("Connected to database successfully")
+	slog.Info("Connected to database successfully")
+
+	// Initialize the Prometheus metrics, including the pool stats
+	serviceMetrics := metrics.New()
+	serviceMetrics.RegisterDB(db, "client_manager")
 
 	// Run database migrations
 	if err := runMigrations(db); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		fatal("Failed to run migrations", err)
 	}
-	log.Println("Migrations completed successfully")
+	slog.Info("Migrations completed successfully")
 
-	// Initialize Dapr client
-	daprClient, err := dapr.NewClient()
-	if err != nil {
-		log.Fatalf("Failed to create Dapr client: %v", err)
+	// Initialize the token verifier
+	var verifier auth.TokenVerifier
+	switch cfg.Auth.Mode {
+	case "jwks":
+		verifier = auth.NewJWKSVerifier(auth.Config{
+			IssuerURL:    cfg.Auth.IssuerURL,
+			JWKSURL:      cfg.Auth.JWKSURL,
+			Audience:     cfg.Auth.Audience,
+			ClientID:     cfg.Auth.ClientID,
+			TenantClaim:  cfg.Auth.TenantClaim,
+			JWKSCacheTTL: cfg.Auth.JWKSCacheTTL,
+			Leeway:       cfg.Auth.Leeway,
+		})
+	case "introspection":
+		verifier = auth.NewIntrospectionVerifier(auth.IntrospectionConfig{
+			URL:          cfg.Auth.IntrospectionURL,
+			ClientID:     cfg.Auth.ClientID,
+			ClientSecret: cfg.Auth.ClientSecret,
+			TenantClaim:  cfg.Auth.TenantClaim,
+		})
+	default:
+		fatal("Unknown AUTH_MODE, expected jwks or introspection", fmt.Errorf("unknown mode %q", cfg.Auth.Mode))
 	}
-	defer daprClient.Close()
+	slog.Info("Token verification mode", "mode", cfg.Auth.Mode)
+
+	// Client of the Dapr sidecar, shared by the idempotency store and the
+	// readiness probe. Its calls continue the trace of their request.
+	daprClient := &http.Client{Timeout: 10 * time.Second, Transport: platformtracing.Transport(http.DefaultTransport)}
+	manager.Close("dapr client", func() error {
+		daprClient.CloseIdleConnections()
+		return nil
+	})
 
 	// Initialize repositories
-	clientRepo := repositories.NewClientRepository(db)
+	clientRepo := tracing.ClientRepository(serviceMetrics.ClientRepository(repositories.NewClientRepository(db)))
+
+	// Initialize the store of the Idempotency-Key responses
+	var idempotencyStore out.IdempotencyStore
+	switch cfg.Idempotency.Store {
+	case "postgres":
+		idempotencyStore = idempotency.NewPostgresStore(db)
+	case "dapr":
+		idempotencyStore = idempotency.NewDaprStore(idempotency.DaprConfig{
+			BaseURL:    cfg.Dapr.HTTPEndpoint,
+			StoreName:  cfg.Dapr.StateStoreName,
+			APIToken:   cfg.Dapr.APIToken,
+			HTTPClient: daprClient,
+		})
+	default:
+		fatal("Unknown IDEMPOTENCY_STORE, expected postgres or dapr", fmt.Errorf("unknown store %q", cfg.Idempotency.Store))
+	}
+	idempotencyStore = serviceMetrics.IdempotencyStore(idempotencyStore)
 
 	// Initialize services
 	clientService := services.NewClientService(clientRepo)
+	userEventService := services.NewUserEventService(clientRepo)
 
 	// Initialize Temporal client with retries
 	var temporalClient *temporal.TemporalClient
+	var clientWorkflows out.ClientWorkflows
 	var temporalErr error
 	for i := 0; i < 5; i++ {
-		temporalClient, temporalErr = temporal.NewTemporalClient(temporalAddress, temporalNamespace, temporalTaskQueue)
+		temporalClient, temporalErr = temporal.NewTemporalClient(cfg.Temporal.Address, cfg.Temporal.Namespace, cfg.Temporal.TaskQueue, serviceMetrics.TemporalHandler(), platformtracing.TemporalInterceptor())
 		if temporalErr == nil {
 			break
 		}
-		log.Printf("Attempt %d: Failed to create Temporal client: %v. Retrying in 5 seconds...", i+1, temporalErr)
+		slog.Warn("Failed to create Temporal client, retrying in 5 seconds", "attempt", i+1, "error", temporalErr)
 		time.Sleep(5 * time.Second)
 	}
 
+	// Liveness and readiness probes, the latter checking the dependencies
+	// the requests need
+	probes := health.NewRegistry(health.Config{Timeout: cfg.Health.CheckTimeout, CacheTTL: cfg.Health.CacheTTL})
+	probes.Register("postgres", health.Database(db))
+	probes.Register("dapr", health.DaprSidecar(daprClient, cfg.Dapr.HTTPEndpoint, cfg.Dapr.APIToken))
+	if cfg.Auth.Mode == "jwks" {
+		probes.Register("keycloak", health.JWKS(http.DefaultClient, cfg.Auth.JWKSURL))
+	}
+
 	if temporalErr != nil {
-		log.Printf("WARNING: Could not connect to Temporal after multiple attempts: %v", temporalErr)
-		log.Printf("The application will start without Temporal integration")
+		slog.Warn("Could not connect to Temporal after multiple attempts, starting without Temporal integration", "error", temporalErr)
+		// The workflows cannot run, so the instance never gets ready
+		probes.Register("temporal", health.Unavailable(fmt.Errorf("not connected: %w", temporalErr)))
 	} else {
-		defer temporalClient.Close()
+		manager.Close("temporal client", func() error {
+			temporalClient.Close()
+			return nil
+		})
+		clientWorkflows = temporalClient
+		probes.Register("temporal", temporalClient.CheckHealth)
 
-		// Start Temporal worker
+		// Run the Temporal worker, stopped once the server stopped
+		// starting workflows
 		workerConfig := workflows.WorkerConfig{
-			TemporalAddress: temporalAddress,
-			Namespace:       temporalNamespace,
-			TaskQueue:       temporalTaskQueue,
+			TemporalAddress: cfg.Temporal.Address,
+			Namespace:       cfg.Temporal.Namespace,
+			TaskQueue:       cfg.Temporal.TaskQueue,
 			ClientService:   clientService,
+			MetricsHandler:  serviceMetrics.TemporalHandler(),
+			Interceptors:    []interceptor.ClientInterceptor{platformtracing.TemporalInterceptor()},
+			StopTimeout:     cfg.Shutdown.Timeout,
 		}
 
-		_, workerErr := workflows.StartWorker(workerConfig)
+		temporalWorker, workerErr := workflows.NewWorker(workerConfig)
 		if workerErr != nil {
-			log.Printf("WARNING: Failed to start Temporal worker: %v", workerErr)
-			log.Printf("The application will start without Temporal worker")
+			slog.Warn("Failed to create Temporal worker, starting without it", "error", workerErr)
 		} else {
-			log.Printf("Temporal worker started successfully")
+			manager.Close("temporal worker client", temporalWorker.Close)
+			manager.Add(lifecycle.Worker("temporal worker", temporalWorker.Run))
 		}
 	}
 
 	// Initialize handlers
-	clientHandler := handlers.NewClientHandler(clientService, temporalClient)
+	clientHandler := handlers.NewClientHandler(clientService, clientWorkflows)
+	clientHandler.RequireIfMatch(cfg.Server.RequireIfMatch)
+	clientHandler.SetSyncWait(cfg.Server.SyncWait)
+	operationHandler := handlers.NewOperationHandler(clientWorkflows)
+	eventHandler := handlers.NewEventHandler(userEventService, cfg.Dapr.PubSubName)
 
 	// Set up Gin router
-	router := gin.Default()
+	router := gin.New()
 
-	// Add middleware
-	router.Use(gin.Recovery())
+	// Add middleware. Requests are traced and logged with their ID, and
+	// errors, including panics and unknown routes, are answered with problem
+	// details.
+	router.Use(tracing.Middleware(), logging.Middleware(), gin.CustomRecovery(handlers.Recovered), serviceMetrics.Middleware())
+	router.NoRoute(handlers.NoRoute)
+
+	// Dapr subscriptions to the user events. The sidecar calls these routes
+	// itself, with the app API token instead of a Keycloak token.
+	router.GET("/dapr/subscribe", eventHandler.Subscribe)
+	events := router.Group("", handlers.RequireDaprAppToken(cfg.Dapr.AppAPIToken))
+	{
+		events.POST(handlers.UserCreatedRoute, eventHandler.UserCreated)
+		events.POST(handlers.UserLinkedRoute, eventHandler.UserLinked)
+		events.POST(handlers.UserDeletedRoute, eventHandler.UserDeleted)
+	}
 
 	// Define API routes
 	api := router.Group("/api/v1")
 	{
 		// Protected routes
 		protected := api.Group("/clients")
-		protected.Use(handlers.KeycloakAuthMiddleware(daprClient))
+		protected.Use(handlers.KeycloakAuthMiddleware(verifier), handlers.RequireTenant(), handlers.Idempotency(idempotencyStore, cfg.Idempotency.TTL, cfg.Idempotency.Lease))
 		{
-			protected.POST("", clientHandler.AddClient)
-			protected.GET("", clientHandler.GetClient)
+			protected.GET("", clientHandler.ListClients)
+			protected.POST("", clientHandler.CreateClient)
+
+			// Profile of the caller
+			protected.GET("/me", clientHandler.GetClient)
+			protected.POST("/me", clientHandler.AddClient)
+			protected.PUT("/me", clientHandler.AddClient)
+
+			protected.GET("/:id", clientHandler.GetClientByID)
+			protected.PUT("/:id", clientHandler.UpdateClient)
+			protected.PATCH("/:id", clientHandler.PatchClient)
+			protected.DELETE("/:id", clientHandler.DeleteClient)
 		}
 
-		// Health check
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"status": "ok"})
-		})
+		// Status of the asynchronous operations
+		operations := api.Group("/operations")
+		operations.Use(handlers.KeycloakAuthMiddleware(verifier), handlers.RequireTenant())
+		{
+			operations.GET("/:id", operationHandler.GetOperation)
+		}
+
+		// Kept for existing clients, with the readiness report
+		api.GET("/health", gin.WrapH(probes.ReadyHandler()))
 	}
 
-	// Start the server
-	log.Printf("Starting server on port %s", serverPort)
-	if err := router.Run(":" + serverPort); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// API document
+	router.GET(handlers.OpenAPIPath, handlers.OpenAPI)
+
+	// Prometheus metrics
+	router.GET(metrics.Path, serviceMetrics.Handler())
+
+	// Probes
+	router.GET(health.LivePath, gin.WrapH(probes.LiveHandler()))
+	router.GET(health.ReadyPath, gin.WrapH(probes.ReadyHandler()))
+
+	// Serve until SIGINT or SIGTERM, letting the in-flight requests finish
+	manager.Add(lifecycle.HTTPServer("http server", &http.Server{
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}))
+	if err := manager.Run(context.Background()); err != nil {
+		fatal("Service stopped", err)
 	}
 }
 
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
 // runMigrations runs database migrations
 func runMigrations(db *sql.DB) error {
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
@@ -165,12 +337,3 @@ func runMigrations(db *sql.DB) error {
 
 	return nil
 }
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
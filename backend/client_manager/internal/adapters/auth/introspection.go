@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+)
+
+// IntrospectionConfig holds the settings for RFC 7662 token introspection
+type IntrospectionConfig struct {
+	// URL is the introspection endpoint, e.g. .../protocol/openid-connect/token/introspect
+	URL string
+	// ClientID and ClientSecret authenticate the service to the endpoint
+	ClientID     string
+	ClientSecret string
+	// TenantClaim is the name of the claim holding the caller's tenant
+	TenantClaim string
+	// HTTPClient is used to reach Keycloak
+	HTTPClient *http.Client
+}
+
+// IntrospectionVerifier verifies tokens by asking Keycloak whether they are active.
+// Unlike JWKSVerifier it notices revoked sessions, at the cost of a call per request.
+type IntrospectionVerifier struct {
+	cfg IntrospectionConfig
+}
+
+// NewIntrospectionVerifier creates a new IntrospectionVerifier
+func NewIntrospectionVerifier(cfg IntrospectionConfig) *IntrospectionVerifier {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	return &IntrospectionVerifier{
+		cfg: cfg,
+	}
+}
+
+// Verify introspects the token and maps the response to a Principal
+func (v *IntrospectionVerifier) Verify(ctx context.Context, token string) (*entities.Principal, error) {
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.cfg.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create introspection request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(v.cfg.ClientID), url.QueryEscape(v.cfg.ClientSecret))
+
+	resp, err := v.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to introspect token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to introspect token: unexpected status %d", resp.StatusCode)
+	}
+
+	var claims map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode introspection response: %w", err)
+	}
+
+	if active, _ := claims["active"].(bool); !active {
+		return nil, fmt.Errorf("%w: token is not active", ErrInvalidToken)
+	}
+
+	return principalFromClaims(claims, v.cfg.ClientID, v.cfg.TenantClaim)
+}
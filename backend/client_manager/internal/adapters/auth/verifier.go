@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/platform/jwks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken is returned when a bearer token cannot be verified
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenVerifier verifies a bearer token and returns the authenticated principal
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (*entities.Principal, error)
+}
+
+// Config holds the settings needed to verify Keycloak tokens
+type Config struct {
+	// IssuerURL is the expected "iss" claim, e.g. http://localhost:8080/realms/saaster
+	IssuerURL string
+	// JWKSURL is where the realm keys are fetched from. Defaults to the issuer's certs endpoint.
+	JWKSURL string
+	// Audience is the expected "aud" claim. Audience validation is skipped when empty.
+	Audience string
+	// ClientID is the Keycloak client whose roles are added to the principal
+	ClientID string
+	// TenantClaim is the name of the claim holding the caller's tenant
+	TenantClaim string
+	// JWKSCacheTTL is how long fetched keys are trusted before being refreshed
+	JWKSCacheTTL time.Duration
+	// Leeway is the clock skew tolerated when validating exp, nbf and iat
+	Leeway time.Duration
+	// HTTPClient is used to reach Keycloak
+	HTTPClient *http.Client
+}
+
+// JWKSVerifier verifies Keycloak access tokens locally using the realm JWKS
+type JWKSVerifier struct {
+	cfg    Config
+	keys   *jwks.Cache
+	parser *jwt.Parser
+}
+
+// NewJWKSVerifier creates a new JWKSVerifier
+func NewJWKSVerifier(cfg Config) *JWKSVerifier {
+	cfg.IssuerURL = strings.TrimSuffix(cfg.IssuerURL, "/")
+	if cfg.JWKSURL == "" {
+		cfg.JWKSURL = cfg.IssuerURL + "/protocol/openid-connect/certs"
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(cfg.IssuerURL),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &JWKSVerifier{
+		cfg:    cfg,
+		keys:   jwks.NewCache(cfg.JWKSURL, cfg.JWKSCacheTTL, cfg.HTTPClient),
+		parser: jwt.NewParser(options...),
+	}
+}
+
+// Verify checks the token signature, issuer, audience, expiry and not-before claims
+func (v *JWKSVerifier) Verify(ctx context.Context, token string) (*entities.Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token header has no kid")
+		}
+		return v.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return principalFromClaims(claims, v.cfg.ClientID, v.cfg.TenantClaim)
+}
+
+// principalFromClaims maps Keycloak token claims to a Principal
+func principalFromClaims(claims map[string]interface{}, clientID, tenantClaim string) (*entities.Principal, error) {
+	sub, _ := claims["sub"].(string)
+	subject, err := uuid.Parse(sub)
+	if err != nil {
+		return nil, fmt.Errorf("%w: subject is not a UUID", ErrInvalidToken)
+	}
+
+	principal := &entities.Principal{
+		Subject: subject,
+		Roles:   []string{},
+	}
+	principal.Email, _ = claims["email"].(string)
+	if tenantClaim != "" {
+		principal.Tenant, _ = claims[tenantClaim].(string)
+	}
+
+	if realmAccess, ok := claims["realm_access"].(map[string]interface{}); ok {
+		principal.Roles = append(principal.Roles, stringSlice(realmAccess["roles"])...)
+	}
+	if resourceAccess, ok := claims["resource_access"].(map[string]interface{}); ok && clientID != "" {
+		if clientAccess, ok := resourceAccess[clientID].(map[string]interface{}); ok {
+			principal.Roles = append(principal.Roles, stringSlice(clientAccess["roles"])...)
+		}
+	}
+
+	return principal, nil
+}
+
+// stringSlice converts a decoded JSON array into a slice of strings
+func stringSlice(value interface{}) []string {
+	items, _ := value.([]interface{})
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -1,66 +1,89 @@
 package handlers
 
 import (
-	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
-	dapr "github.com/dapr/go-sdk/client"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/auth"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/platform/logging"
 	"github.com/gin-gonic/gin"
 )
 
-// KeycloakAuthMiddleware validates JWT tokens using Dapr and Keycloak
-func KeycloakAuthMiddleware(daprClient dapr.Client) gin.HandlerFunc {
+// PrincipalKey is the gin context key holding the authenticated principal
+const PrincipalKey = "principal"
+
+// KeycloakAuthMiddleware validates Keycloak bearer tokens and stores the
+// authenticated principal in the gin context
+func KeycloakAuthMiddleware(verifier auth.TokenVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			abortUnauthorized(c, "Authorization header is required")
 			return
 		}
 
 		// Check if the header has the Bearer prefix
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must be Bearer token"})
-			c.Abort()
+			abortUnauthorized(c, "Authorization header must be Bearer token")
 			return
 		}
 
 		// Extract the token
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// Call Dapr sidecar to validate the token with Keycloak
-		// The component name should match the Dapr component for Keycloak
-		content := &dapr.DataContent{
-			ContentType: "application/json",
-			Data:        []byte(token),
-		}
-
-		resp, err := daprClient.InvokeBinding(context.Background(), &dapr.InvokeBindingRequest{
-			Name:      "keycloak-auth",
-			Operation: "validate",
-			Data:      content.Data,
-		})
-
+		principal, err := verifier.Verify(c.Request.Context(), token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to validate token"})
-			c.Abort()
+			if errors.Is(err, auth.ErrInvalidToken) {
+				abortUnauthorized(c, "Invalid token")
+				return
+			}
+			slog.ErrorContext(c.Request.Context(), "Token verification failed", "error", err)
+			abortWithError(c, http.StatusServiceUnavailable, "Failed to validate token")
 			return
 		}
 
-		// Parse the response to get user ID
-		// This is a simplified example - in a real implementation, you would parse the JWT
-		// or the response from Keycloak to get the user ID
-		userID := string(resp.Data)
-		if userID == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
+		// Set the principal in the context, and bind its tenant to the request
+		// context so that repositories only see the caller's organization. The
+		// logs of the request name the caller.
+		c.Set(PrincipalKey, principal)
+		ctx := logging.WithUserID(c.Request.Context(), principal.Subject.String())
+		if principal.Tenant != "" {
+			ctx = tenancy.WithTenant(ctx, principal.Tenant)
 		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
 
-		// Set the user ID in the context
-		c.Set("userID", userID)
+// RequireTenant rejects callers whose token is not bound to an organization.
+// It must run after KeycloakAuthMiddleware.
+func RequireTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := tenancy.TenantFromContext(c.Request.Context()); !ok {
+			abortWithError(c, http.StatusForbidden, "Token is not bound to an organization")
+			return
+		}
 		c.Next()
 	}
 }
+
+// PrincipalFromContext returns the principal set by KeycloakAuthMiddleware
+func PrincipalFromContext(c *gin.Context) (*entities.Principal, bool) {
+	value, exists := c.Get(PrincipalKey)
+	if !exists {
+		return nil, false
+	}
+	principal, ok := value.(*entities.Principal)
+	return principal, ok && principal != nil
+}
+
+// abortUnauthorized aborts the request with a 401 and a Bearer challenge
+func abortUnauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	abortWithError(c, http.StatusUnauthorized, message)
+}
@@ -1,56 +1,92 @@
 package handlers
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/validation"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/in"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// AdminRole is the role of the organization admins, who may manage every
+// client of their organization. Other users only reach the clients they own.
+const AdminRole = "admin"
+
 // ClientHandler handles HTTP requests for client operations
 type ClientHandler struct {
-	clientService in.ClientService
-	temporalClient *temporal.TemporalClient
+	clientService  in.ClientService
+	workflows      out.ClientWorkflows
+	requireIfMatch bool
+	syncWait       time.Duration
 }
 
-// NewClientHandler creates a new client handler
-func NewClientHandler(clientService in.ClientService, temporalClient *temporal.TemporalClient) *ClientHandler {
+// NewClientHandler creates a new client handler. workflows is nil when
+// Temporal is not available.
+func NewClientHandler(clientService in.ClientService, workflows out.ClientWorkflows) *ClientHandler {
 	return &ClientHandler{
 		clientService: clientService,
-		temporalClient: temporalClient,
+		workflows:     workflows,
 	}
 }
 
-// AddClient handles the request to add a new client
+// SetSyncWait bounds the time a synchronous creation waits for its workflow.
+// Once it is over, the response is 202 Accepted with the operation to poll.
+func (h *ClientHandler) SetSyncWait(wait time.Duration) {
+	h.syncWait = wait
+}
+
+// RequireIfMatch makes the If-Match header mandatory on the updates and
+// deletions of /clients/{id}, so that clients cannot overwrite changes they
+// have not seen. Profiles can still be created without it.
+func (h *ClientHandler) RequireIfMatch(required bool) {
+	h.requireIfMatch = required
+}
+
+// clientRequest is the body of requests setting every detail of a client
+type clientRequest struct {
+	FirstName    string `json:"firstName"`
+	LastName     string `json:"lastName"`
+	ContactEmail string `json:"contactEmail"`
+	PhoneNumber  string `json:"phoneNumber"`
+}
+
+// patchClientRequest is the body of requests changing some details of a client
+type patchClientRequest struct {
+	FirstName    *string `json:"firstName"`
+	LastName     *string `json:"lastName"`
+	ContactEmail *string `json:"contactEmail"`
+	PhoneNumber  *string `json:"phoneNumber"`
+}
+
+// AddClient handles the request to add or update the profile of the caller
 func (h *ClientHandler) AddClient(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	// Get the principal from context (set by auth middleware)
+	principal, ok := PrincipalFromContext(c)
+	if !ok {
+		abortWithError(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
+	userUUID := principal.Subject
 
-	// Parse user ID as UUID
-	userUUID, err := uuid.Parse(userID.(string))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+	version, ok := ifMatchVersion(c, false)
+	if !ok {
 		return
 	}
 
 	// Parse request body
-	var clientRequest struct {
-		FirstName    string `json:"firstName" binding:"required"`
-		LastName     string `json:"lastName" binding:"required"`
-		ContactEmail string `json:"contactEmail" binding:"required,email"`
-		PhoneNumber  string `json:"phoneNumber" binding:"required"`
-	}
-
+	var clientRequest clientRequest
 	if err := c.ShouldBindJSON(&clientRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		abortWithBindingError(c, err)
 		return
 	}
 
@@ -62,65 +98,396 @@ func (h *ClientHandler) AddClient(c *gin.Context) {
 		clientRequest.ContactEmail,
 		clientRequest.PhoneNumber,
 	)
+	client.Version = version
+	if err := validation.ValidateClient(client); err != nil {
+		writeClientError(c, err, "Invalid client")
+		return
+	}
 
-	// Try to save client using Temporal workflow if available
-	if h.temporalClient != nil {
-		result, err := h.temporalClient.AddClient(c.Request.Context(), client)
+	client, err := h.saveClient(c, client)
+	var pending *out.OperationPendingError
+	if errors.As(err, &pending) {
+		acceptOperation(c, pending.OperationID)
+		return
+	}
+	if err != nil {
+		writeClientError(c, err, "Failed to save client")
+		return
+	}
+
+	setClientETag(c, client)
+	c.JSON(http.StatusOK, client)
+}
+
+// GetClient handles the request to get the profile of the caller
+func (h *ClientHandler) GetClient(c *gin.Context) {
+	// Get the principal from context (set by auth middleware)
+	principal, ok := PrincipalFromContext(c)
+	if !ok {
+		abortWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userUUID := principal.Subject
+
+	// Try to get client using Temporal workflow if available
+	if h.workflows != nil {
+		client, err := h.workflows.GetClient(c.Request.Context(), userUUID)
 		if err != nil {
 			// If Temporal fails, fall back to direct service call
-			log.Printf("Temporal workflow failed, falling back to direct service call: %v", err)
+			slog.WarnContext(c.Request.Context(), "Temporal workflow failed, falling back to direct service call", "error", err)
 		} else {
-			// Update client with result from workflow
-			client = result
+			setClientETag(c, client)
 			c.JSON(http.StatusOK, client)
 			return
 		}
 	}
 
 	// Fall back to direct service call if Temporal is not available or failed
-	err = h.clientService.AddClient(c.Request.Context(), client)
+	client, err := h.clientService.GetClient(c.Request.Context(), userUUID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save client"})
+		abortWithError(c, http.StatusInternalServerError, "Failed to retrieve client")
 		return
 	}
 
+	setClientETag(c, client)
 	c.JSON(http.StatusOK, client)
 }
 
-// GetClient handles the request to get a client
-func (h *ClientHandler) GetClient(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+// CreateClient handles the request to create a new client owned by the caller.
+// With a "Prefer: respond-async" header, the client is created by a workflow
+// and the response is 202 Accepted with the operation to poll.
+func (h *ClientHandler) CreateClient(c *gin.Context) {
+	principal, ok := PrincipalFromContext(c)
+	if !ok {
+		abortWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var clientRequest clientRequest
+	if err := c.ShouldBindJSON(&clientRequest); err != nil {
+		abortWithBindingError(c, err)
+		return
+	}
+
+	client := entities.NewOwnedClient(
+		principal.Subject,
+		clientRequest.FirstName,
+		clientRequest.LastName,
+		clientRequest.ContactEmail,
+		clientRequest.PhoneNumber,
+	)
+	// Checked before starting a workflow, whose failure the caller would
+	// only see by polling the operation
+	if err := validation.ValidateClient(client); err != nil {
+		writeClientError(c, err, "Invalid client")
 		return
 	}
 
-	// Parse user ID as UUID
-	userUUID, err := uuid.Parse(userID.(string))
+	if prefersAsync(c) && h.workflows != nil {
+		operationID, err := h.workflows.StartAddClient(c.Request.Context(), client)
+		if err == nil {
+			c.Header("Preference-Applied", "respond-async")
+			acceptOperation(c, operationID)
+			return
+		}
+		if !errors.Is(err, out.ErrWorkflowNotStarted) {
+			abortWithError(c, http.StatusInternalServerError, "Failed to save client")
+			return
+		}
+		// Nothing was started: create the client synchronously instead
+		slog.WarnContext(c.Request.Context(), "Temporal workflow not started, creating the client synchronously", "error", err)
+	}
+
+	client, err := h.saveClient(c, client)
+	var pending *out.OperationPendingError
+	if errors.As(err, &pending) {
+		acceptOperation(c, pending.OperationID)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		writeClientError(c, err, "Failed to save client")
 		return
 	}
 
-	// Try to get client using Temporal workflow if available
-	if h.temporalClient != nil {
-		client, err := h.temporalClient.GetClient(c.Request.Context(), userUUID)
+	c.Header("Location", c.Request.URL.Path+"/"+client.UUID.String())
+	setClientETag(c, client)
+	c.JSON(http.StatusCreated, client)
+}
+
+// ListClients handles the request to list the clients of the organization.
+// Query parameters: q (search), owner (a user UUID or "me"), limit and cursor.
+// Only admins may list the clients of other users; the others list their own.
+func (h *ClientHandler) ListClients(c *gin.Context) {
+	principal, ok := PrincipalFromContext(c)
+	if !ok {
+		abortWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	query := in.ListClientsQuery{
+		Search: c.Query("q"),
+		Cursor: c.Query("cursor"),
+	}
+
+	switch owner := c.Query("owner"); owner {
+	case "":
+	case "me":
+		query.OwnerID = principal.Subject
+	default:
+		ownerID, err := uuid.Parse(owner)
 		if err != nil {
-			// If Temporal fails, fall back to direct service call
-			log.Printf("Temporal workflow failed, falling back to direct service call: %v", err)
-		} else {
-			c.JSON(http.StatusOK, client)
+			abortWithError(c, http.StatusBadRequest, "owner must be a UUID or me")
 			return
 		}
+		query.OwnerID = ownerID
+	}
+	if !principal.HasRole(AdminRole) {
+		if query.OwnerID != uuid.Nil && query.OwnerID != principal.Subject {
+			abortWithError(c, http.StatusForbidden, "Only admins may list the clients of other users")
+			return
+		}
+		query.OwnerID = principal.Subject
 	}
 
-	// Fall back to direct service call if Temporal is not available or failed
-	client, err := h.clientService.GetClient(c.Request.Context(), userUUID)
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			abortWithError(c, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		query.Limit = value
+	}
+
+	clients, err := h.clientService.ListClients(c.Request.Context(), query)
+	if err != nil {
+		writeClientError(c, err, "Failed to list clients")
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// GetClientByID handles the request to get a client of the organization
+func (h *ClientHandler) GetClientByID(c *gin.Context) {
+	id, ok := clientIDParam(c)
+	if !ok {
+		return
+	}
+
+	client, ok := h.authorizeClient(c, id)
+	if !ok {
+		return
+	}
+
+	setClientETag(c, client)
+	c.JSON(http.StatusOK, client)
+}
+
+// UpdateClient handles the request to replace the details of a client
+func (h *ClientHandler) UpdateClient(c *gin.Context) {
+	id, ok := clientIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := h.authorizeClient(c, id); !ok {
+		return
+	}
+	version, ok := ifMatchVersion(c, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
+	var clientRequest clientRequest
+	if err := c.ShouldBindJSON(&clientRequest); err != nil {
+		abortWithBindingError(c, err)
+		return
+	}
+
+	client := entities.NewClient(
+		id,
+		clientRequest.FirstName,
+		clientRequest.LastName,
+		clientRequest.ContactEmail,
+		clientRequest.PhoneNumber,
+	)
+	client.Version = version
+
+	client, err := h.clientService.UpdateClient(c.Request.Context(), client)
+	if err != nil {
+		writeClientError(c, err, "Failed to update client")
+		return
+	}
+
+	setClientETag(c, client)
+	c.JSON(http.StatusOK, client)
+}
+
+// PatchClient handles the request to change some details of a client
+func (h *ClientHandler) PatchClient(c *gin.Context) {
+	id, ok := clientIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := h.authorizeClient(c, id); !ok {
+		return
+	}
+	version, ok := ifMatchVersion(c, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
+	var patchRequest patchClientRequest
+	if err := c.ShouldBindJSON(&patchRequest); err != nil {
+		abortWithBindingError(c, err)
+		return
+	}
+
+	client, err := h.clientService.PatchClient(c.Request.Context(), id, version, in.ClientPatch{
+		FirstName:    patchRequest.FirstName,
+		LastName:     patchRequest.LastName,
+		ContactEmail: patchRequest.ContactEmail,
+		PhoneNumber:  patchRequest.PhoneNumber,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve client"})
+		writeClientError(c, err, "Failed to update client")
 		return
 	}
 
+	setClientETag(c, client)
 	c.JSON(http.StatusOK, client)
 }
+
+// DeleteClient handles the request to delete a client
+func (h *ClientHandler) DeleteClient(c *gin.Context) {
+	id, ok := clientIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := h.authorizeClient(c, id); !ok {
+		return
+	}
+	version, ok := ifMatchVersion(c, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
+	if err := h.clientService.DeleteClient(c.Request.Context(), id, version); err != nil {
+		writeClientError(c, err, "Failed to delete client")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// saveClient saves a client through the AddClient workflow, or directly
+// through the service when Temporal is not available or the workflow could
+// not be started. A workflow that started and failed may have saved the
+// client already, so it is not retried. A workflow still running after the
+// sync wait is returned as an *out.OperationPendingError.
+func (h *ClientHandler) saveClient(c *gin.Context, client *entities.Client) (*entities.Client, error) {
+	// Try to save client using Temporal workflow if available
+	if h.workflows != nil {
+		ctx := c.Request.Context()
+		if h.syncWait > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, h.syncWait)
+			defer cancel()
+		}
+		result, err := h.workflows.AddClient(ctx, client)
+		if err == nil || !errors.Is(err, out.ErrWorkflowNotStarted) {
+			return result, err
+		}
+		// If the workflow was not started, fall back to direct service call
+		slog.WarnContext(c.Request.Context(), "Temporal workflow not started, falling back to direct service call", "error", err)
+	}
+
+	// Fall back to direct service call if Temporal is not available
+	if err := h.clientService.AddClient(c.Request.Context(), client); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// acceptOperation answers 202 Accepted with the running operation
+func acceptOperation(c *gin.Context, operationID string) {
+	c.Header("Location", OperationsPath+"/"+operationID)
+	c.Header("Retry-After", "1")
+	c.JSON(http.StatusAccepted, &entities.Operation{
+		ID:        operationID,
+		Status:    entities.OperationRunning,
+		StartedAt: time.Now().UTC(),
+	})
+}
+
+// prefersAsync reports whether the request asks for an asynchronous response
+// (RFC 7240)
+func prefersAsync(c *gin.Context) bool {
+	for _, header := range c.Request.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			if strings.EqualFold(strings.TrimSpace(preference), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authorizeClient returns the client id when the caller owns it or is an
+// admin, and writes the problem otherwise. The owner of a client never
+// changes, so the check holds for the change that follows.
+func (h *ClientHandler) authorizeClient(c *gin.Context, id uuid.UUID) (*entities.Client, bool) {
+	principal, ok := PrincipalFromContext(c)
+	if !ok {
+		abortWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return nil, false
+	}
+
+	client, err := h.clientService.FindClient(c.Request.Context(), id)
+	if err != nil {
+		writeClientError(c, err, "Failed to retrieve client")
+		return nil, false
+	}
+	if client.OwnerID != principal.Subject && !principal.HasRole(AdminRole) {
+		abortWithError(c, http.StatusForbidden, "Client belongs to another user")
+		return nil, false
+	}
+
+	return client, true
+}
+
+// clientIDParam parses the client UUID of the path, rejecting the request if it is invalid
+func clientIDParam(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, "Invalid client ID")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// writeClientError writes the problem matching a failed client operation,
+// whose error may wrap a domain error
+func writeClientError(c *gin.Context, err error, message string) {
+	var validationErrs validation.Errors
+	switch {
+	case errors.Is(err, entities.ErrClientNotFound):
+		abortWithError(c, http.StatusNotFound, "Client not found")
+	case errors.Is(err, entities.ErrVersionMismatch):
+		problem := NewProblem(http.StatusPreconditionFailed, "Client has changed since the version in If-Match")
+		problem.Type = ProblemTypeVersionMismatch
+		abortWithProblem(c, problem)
+	case errors.As(err, &validationErrs):
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, FieldError{Field: fieldErr.Field, Message: fieldErr.Message})
+		}
+		abortWithProblem(c, ValidationProblem("The request has invalid fields", fields...))
+	case errors.Is(err, in.ErrInvalidQuery):
+		abortWithProblem(c, ValidationProblem(err.Error()))
+	case errors.Is(err, tenancy.ErrMissingTenant):
+		abortWithError(c, http.StatusForbidden, "Token is not bound to an organization")
+	default:
+		abortWithError(c, http.StatusInternalServerError, message)
+	}
+}
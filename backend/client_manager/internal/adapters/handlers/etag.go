@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/gin-gonic/gin"
+)
+
+// etag returns the strong entity tag of a resource at version
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setClientETag sets the ETag of a client read from the repository
+func setClientETag(c *gin.Context, client *entities.Client) {
+	if client.Version != 0 {
+		c.Header("ETag", etag(client.Version))
+	}
+}
+
+// ifMatchVersion returns the version required by the If-Match header, or 0
+// when any version may be changed. It aborts the request and returns false
+// when the header is missing but required, with 428 Precondition Required, or
+// names no version of the resource, with 412 Precondition Failed. Only a
+// single entity tag or "*" is understood.
+func ifMatchVersion(c *gin.Context, required bool) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	switch header {
+	case "":
+		if required {
+			abortWithError(c, http.StatusPreconditionRequired, "If-Match header is required")
+			return 0, false
+		}
+		return 0, true
+	case "*":
+		return 0, true
+	}
+
+	// Weak tags never match with If-Match, and versions start at 1
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || err != nil || version < 1 {
+		abortWithError(c, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return 0, false
+	}
+	return version, true
+}
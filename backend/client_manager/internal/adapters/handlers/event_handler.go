@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/in"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Topics of the user events published by user_manager
+const (
+	UserCreatedTopic = "user-created"
+	UserLinkedTopic  = "user-linked"
+	UserDeletedTopic = "user-deleted"
+)
+
+// Routes the Dapr sidecar delivers the user events to
+const (
+	UserCreatedRoute = "/events/user-created"
+	UserLinkedRoute  = "/events/user-linked"
+	UserDeletedRoute = "/events/user-deleted"
+)
+
+// Statuses of a delivery, telling the Dapr sidecar what to do with the event
+const (
+	deliverySuccess = "SUCCESS"
+	deliveryRetry   = "RETRY"
+	deliveryDrop    = "DROP"
+)
+
+// daprSubscription is an entry of the programmatic subscriptions of the service
+type daprSubscription struct {
+	PubSubName string `json:"pubsubname"`
+	Topic      string `json:"topic"`
+	Route      string `json:"route"`
+}
+
+// userCloudEvent holds the fields of the user CloudEvents the service needs
+type userCloudEvent struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	TenantID string `json:"tenantid"`
+	Data     struct {
+		ID         string `json:"id"`
+		TenantID   string `json:"tenantId"`
+		ExternalID string `json:"externalId"`
+	} `json:"data"`
+}
+
+// EventHandler handles the events delivered by the Dapr sidecar
+type EventHandler struct {
+	userEvents in.UserEventHandler
+	pubSubName string
+}
+
+// NewEventHandler creates a new event handler subscribing to pubSubName
+func NewEventHandler(userEvents in.UserEventHandler, pubSubName string) *EventHandler {
+	return &EventHandler{
+		userEvents: userEvents,
+		pubSubName: pubSubName,
+	}
+}
+
+// Subscribe lists the topics the service subscribes to
+func (h *EventHandler) Subscribe(c *gin.Context) {
+	c.JSON(http.StatusOK, []daprSubscription{
+		{PubSubName: h.pubSubName, Topic: UserCreatedTopic, Route: UserCreatedRoute},
+		{PubSubName: h.pubSubName, Topic: UserLinkedTopic, Route: UserLinkedRoute},
+		{PubSubName: h.pubSubName, Topic: UserDeletedTopic, Route: UserDeletedRoute},
+	})
+}
+
+// UserCreated handles the delivery of a user created event
+func (h *EventHandler) UserCreated(c *gin.Context) {
+	h.deliver(c, h.userEvents.HandleUserCreated)
+}
+
+// UserLinked handles the delivery of a user linked event
+func (h *EventHandler) UserLinked(c *gin.Context) {
+	h.deliver(c, h.userEvents.HandleUserLinked)
+}
+
+// UserDeleted handles the delivery of a user deleted event
+func (h *EventHandler) UserDeleted(c *gin.Context) {
+	h.deliver(c, h.userEvents.HandleUserDeleted)
+}
+
+// deliver parses a user CloudEvent and passes it to handle within the tenant
+// of the event. Malformed events are dropped, since redelivering them cannot
+// succeed; failures are retried by the sidecar. Users are identified by their
+// Keycloak account: the events of users not linked to one yet are skipped.
+func (h *EventHandler) deliver(c *gin.Context, handle func(ctx context.Context, event entities.UserEvent) error) {
+	var cloudEvent userCloudEvent
+	if err := json.NewDecoder(c.Request.Body).Decode(&cloudEvent); err != nil {
+		slog.WarnContext(c.Request.Context(), "Dropping malformed event", "error", err)
+		c.JSON(http.StatusOK, gin.H{"status": deliveryDrop})
+		return
+	}
+
+	tenantID := cloudEvent.TenantID
+	if tenantID == "" {
+		tenantID = cloudEvent.Data.TenantID
+	}
+	if cloudEvent.ID == "" || tenantID == "" || cloudEvent.Data.ID == "" {
+		slog.WarnContext(c.Request.Context(), "Dropping event: it has no ID, tenant or user", "event.id", cloudEvent.ID, "event.type", cloudEvent.Type)
+		c.JSON(http.StatusOK, gin.H{"status": deliveryDrop})
+		return
+	}
+	if cloudEvent.Data.ExternalID == "" {
+		slog.InfoContext(c.Request.Context(), "Skipping event of a user not linked to an account", "event.id", cloudEvent.ID, "event.type", cloudEvent.Type, "user.id", cloudEvent.Data.ID)
+		c.JSON(http.StatusOK, gin.H{"status": deliverySuccess})
+		return
+	}
+	subject, err := uuid.Parse(cloudEvent.Data.ExternalID)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "Dropping event: its account is not a UUID", "event.id", cloudEvent.ID, "event.type", cloudEvent.Type)
+		c.JSON(http.StatusOK, gin.H{"status": deliveryDrop})
+		return
+	}
+
+	ctx := tenancy.WithTenant(c.Request.Context(), tenantID)
+	event := entities.UserEvent{ID: cloudEvent.ID, TenantID: tenantID, Subject: subject}
+	if err := handle(ctx, event); err != nil {
+		slog.ErrorContext(ctx, "Failed to handle event, it will be retried", "event.id", cloudEvent.ID, "event.type", cloudEvent.Type, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": deliveryRetry})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": deliverySuccess})
+}
+
+// RequireDaprAppToken rejects the requests not coming from the Dapr sidecar,
+// identified by the app API token it sends. An empty token disables the check.
+func RequireDaprAppToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("dapr-api-token")), []byte(token)) != 1 {
+			abortWithError(c, http.StatusUnauthorized, "Invalid Dapr app token")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/b-fontaine/saaster_kit/backend/platform/idempotency"
+	"github.com/gin-gonic/gin"
+)
+
+// Headers of idempotent requests
+const (
+	IdempotencyKeyHeader     = idempotency.KeyHeader
+	IdempotentReplayedHeader = idempotency.ReplayedHeader
+)
+
+// MaxIdempotencyKeyLength is the longest Idempotency-Key accepted
+const MaxIdempotencyKeyLength = idempotency.MaxKeyLength
+
+// replayedHeaders are the response headers stored with the response
+var replayedHeaders = []string{"Content-Type", "Location", "ETag", "Retry-After", "Preference-Applied"}
+
+// Idempotency makes the POST, PUT, PATCH and DELETE requests sent with an
+// Idempotency-Key safe to retry, as described by idempotency.Guard. Keys are
+// scoped to the principal, so the middleware must run after
+// KeycloakAuthMiddleware.
+func Idempotency(store out.IdempotencyStore, ttl, lease time.Duration) gin.HandlerFunc {
+	guard := idempotency.NewGuard(store, ttl, lease, replayedHeaders...)
+	return func(c *gin.Context) {
+		var tenantID, subject string
+		if principal, ok := PrincipalFromContext(c); ok {
+			tenantID, subject = principal.Tenant, principal.Subject.String()
+		}
+
+		call, err := guard.Begin(c.Writer, c.Request, tenantID, subject)
+		var rejection *idempotency.Rejection
+		switch {
+		case errors.As(err, &rejection):
+			if rejection.RetryAfter != "" {
+				c.Header("Retry-After", rejection.RetryAfter)
+			}
+			abortWithError(c, rejection.Status, rejection.Detail)
+			return
+		case err != nil:
+			// Replayed
+			c.Abort()
+			return
+		case call == nil:
+			c.Next()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, recorder: idempotency.NewRecorder(c.Writer)}
+		c.Writer = recorder
+		defer call.Recover()
+		c.Next()
+		call.Finish(recorder)
+	}
+}
+
+// responseRecorder is the gin.ResponseWriter of an idempotency.Recorder. The
+// status is the one gin keeps, which may change until the body is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	recorder *idempotency.Recorder
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	return r.recorder.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	return r.recorder.Write([]byte(s))
+}
+
+// Body returns the body written so far
+func (r *responseRecorder) Body() []byte {
+	return r.recorder.Body()
+}
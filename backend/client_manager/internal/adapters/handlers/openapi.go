@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIPath is where the OpenAPI document is served
+const OpenAPIPath = "/openapi.json"
+
+// openAPIDocument is the OpenAPI 3.1 document of the HTTP API. It is written
+// by hand: every route registered in cmd/main.go must be described here.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// OpenAPI serves the OpenAPI document
+func OpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openAPIDocument)
+}
+
+// LoadOpenAPI parses the OpenAPI document and checks that it is well formed
+func LoadOpenAPI(ctx context.Context) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(openAPIDocument)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
+
+// OpenAPIValidator checks the requests and responses of the documented
+// operations against the OpenAPI document. It is meant for tests, where it
+// keeps the document from drifting from the handlers.
+type OpenAPIValidator struct {
+	router  routers.Router
+	options *openapi3filter.Options
+}
+
+// NewOpenAPIValidator creates a validator for the OpenAPI document
+func NewOpenAPIValidator(ctx context.Context) (*OpenAPIValidator, error) {
+	doc, err := LoadOpenAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to route OpenAPI document: %w", err)
+	}
+
+	return &OpenAPIValidator{
+		router: router,
+		options: &openapi3filter.Options{
+			// Tokens are checked by the authentication middlewares
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+			MultiError:            true,
+		},
+	}, nil
+}
+
+// Middleware validates the requests of documented operations, answering
+// the invalid ones with a validation problem, and replaces the responses
+// that do not match the document with a 500 problem. Requests matching no
+// operation are served as they are. It must run before the other
+// middlewares, which may answer the request themselves.
+func (v *OpenAPIValidator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, pathParams, err := v.router.FindRoute(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		requestInput := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    v.options,
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), requestInput); err != nil {
+			abortWithProblem(c, ValidationProblem("The request does not match the API document", openAPIFieldErrors(err)...))
+			return
+		}
+
+		writer := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: requestInput,
+			Status:                 writer.status,
+			Header:                 c.Writer.Header(),
+			Options:                v.options,
+		}
+		responseInput.SetBodyBytes(writer.body.Bytes())
+		if err := openapi3filter.ValidateResponse(c.Request.Context(), responseInput); err != nil {
+			for key := range c.Writer.Header() {
+				c.Writer.Header().Del(key)
+			}
+			abortWithError(c, http.StatusInternalServerError, "The response does not match the API document: "+err.Error())
+			return
+		}
+
+		c.Writer.WriteHeader(writer.status)
+		c.Writer.WriteHeaderNow()
+		c.Writer.Write(writer.body.Bytes())
+	}
+}
+
+// openAPIFieldErrors lists the parameters and body fields of a request error
+func openAPIFieldErrors(err error) []FieldError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var fields []FieldError
+		for _, inner := range e {
+			fields = append(fields, openAPIFieldErrors(inner)...)
+		}
+		return fields
+	case *openapi3filter.RequestError:
+		if e.Parameter != nil {
+			return []FieldError{{Field: e.Parameter.Name, Message: openAPIReason(e)}}
+		}
+		if fields := openAPIFieldErrors(e.Err); len(fields) > 0 {
+			return fields
+		}
+		return []FieldError{{Field: "body", Message: openAPIReason(e)}}
+	case *openapi3.SchemaError:
+		field := strings.Join(e.JSONPointer(), ".")
+		if field == "" {
+			field = "body"
+		}
+		return []FieldError{{Field: field, Message: e.Reason}}
+	}
+	return nil
+}
+
+// openAPIReason describes a request error without repeating the request
+func openAPIReason(e *openapi3filter.RequestError) string {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(e.Err, &schemaErr) {
+		return schemaErr.Reason
+	}
+	if e.Reason != "" {
+		return e.Reason
+	}
+	return e.Err.Error()
+}
+
+// bufferedWriter holds a response until it is validated. The headers are
+// set on the wrapped writer, which sends them only once the status is written.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	written bool
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if status > 0 && !w.written {
+		w.status = status
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/gin-gonic/gin"
+)
+
+// OperationsPath is the path of the operation status resources
+const OperationsPath = "/api/v1/operations"
+
+// MaxOperationWait is the longest a request may wait for an operation to complete
+const MaxOperationWait = 30 * time.Second
+
+// OperationHandler handles HTTP requests for asynchronous operations
+type OperationHandler struct {
+	workflows out.ClientWorkflows
+}
+
+// NewOperationHandler creates a new operation handler. workflows is nil when
+// Temporal is not available.
+func NewOperationHandler(workflows out.ClientWorkflows) *OperationHandler {
+	return &OperationHandler{
+		workflows: workflows,
+	}
+}
+
+// GetOperation handles the request to get the status of an operation.
+// The wait query parameter (in seconds, up to 30) holds the request while
+// the operation runs, so that clients can long-poll. Like clients,
+// operations are only visible to their owner and to admins.
+func (h *OperationHandler) GetOperation(c *gin.Context) {
+	if h.workflows == nil {
+		abortWithError(c, http.StatusServiceUnavailable, "Operations are not available")
+		return
+	}
+
+	principal, ok := PrincipalFromContext(c)
+	if !ok {
+		abortWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var wait time.Duration
+	if value := c.Query("wait"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > MaxOperationWait {
+			abortWithError(c, http.StatusBadRequest, "wait must be a number of seconds between 0 and 30")
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	operation, err := h.workflows.GetOperation(c.Request.Context(), c.Param("id"), wait)
+	if errors.Is(err, entities.ErrOperationNotFound) {
+		abortWithError(c, http.StatusNotFound, "Operation not found")
+		return
+	}
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "Failed to retrieve operation")
+		return
+	}
+	if operation.OwnerID != principal.Subject && !principal.HasRole(AdminRole) {
+		abortWithError(c, http.StatusForbidden, "Operation belongs to another user")
+		return
+	}
+
+	if !operation.IsDone() {
+		c.Header("Retry-After", "1")
+	}
+	c.JSON(http.StatusOK, operation)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of problem details (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// Problem types, besides the ones derived from the status by NewProblem
+const (
+	ProblemTypeValidation      = "urn:saaster:problem:validation-error"
+	ProblemTypeVersionMismatch = "urn:saaster:problem:version-mismatch"
+)
+
+// Problem is the body of an error response, as RFC 7807 problem details
+type Problem struct {
+	// Type identifies the kind of problem
+	Type string `json:"type"`
+	// Title summarizes the kind of problem, and is the same for every
+	// occurrence of a type
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed
+	Instance string `json:"instance,omitempty"`
+	// TraceID identifies the request in traces and logs
+	TraceID string `json:"traceId,omitempty"`
+	// Errors lists the invalid fields of a validation problem
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is an invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NewProblem creates a problem whose type and title are derived from the status
+func NewProblem(status int, detail string) *Problem {
+	title := http.StatusText(status)
+	return &Problem{
+		Type:   "urn:saaster:problem:" + strings.ReplaceAll(strings.ToLower(title), " ", "-"),
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// ValidationProblem creates a 400 problem listing the invalid fields
+func ValidationProblem(detail string, fields ...FieldError) *Problem {
+	problem := NewProblem(http.StatusBadRequest, detail)
+	problem.Type = ProblemTypeValidation
+	problem.Title = "Validation failed"
+	problem.Errors = fields
+	return problem
+}
+
+// abortWithProblem aborts the request with the problem, completed with the
+// instance and trace ID of the request
+func abortWithProblem(c *gin.Context, problem *Problem) {
+	if problem.Instance == "" {
+		problem.Instance = c.Request.URL.Path
+	}
+	if problem.TraceID == "" {
+		problem.TraceID = traceID(c.Request)
+	}
+
+	// gin keeps a Content-Type that is already set
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+// abortWithError aborts the request with a problem of status and detail
+func abortWithError(c *gin.Context, status int, detail string) {
+	abortWithProblem(c, NewProblem(status, detail))
+}
+
+// NoRoute answers the requests matching no route
+func NoRoute(c *gin.Context) {
+	abortWithError(c, http.StatusNotFound, "No route matches "+c.Request.URL.Path)
+}
+
+// Recovered answers the requests whose handler panicked, for gin.CustomRecovery
+func Recovered(c *gin.Context, recovered any) {
+	abortWithError(c, http.StatusInternalServerError, "Internal server error")
+}
+
+// abortWithBindingError aborts a request whose body could not be decoded,
+// naming the field of the wrong type when there is one
+func abortWithBindingError(c *gin.Context, err error) {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		abortWithProblem(c, ValidationProblem("Invalid request body", FieldError{
+			Field:   typeErr.Field,
+			Message: "must be a " + typeErr.Type.String(),
+		}))
+		return
+	}
+	abortWithError(c, http.StatusBadRequest, "Invalid request body")
+}
+
+// traceparentPattern matches a W3C traceparent header, capturing the trace ID
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// traceID returns the trace ID of the request, from its W3C traceparent
+// header, or else its X-Request-ID header
+func traceID(r *http.Request) string {
+	if match := traceparentPattern.FindStringSubmatch(r.Header.Get("traceparent")); match != nil {
+		return match[1]
+	}
+	return r.Header.Get("X-Request-ID")
+}
@@ -0,0 +1,23 @@
+// Package logging logs the HTTP requests of the service. The records are
+// written by the platform logging package, which reads the tenant of their
+// correlation from the context once this package is imported; the user is
+// bound by the authentication middleware.
+package logging
+
+import (
+	"context"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	platformlogging "github.com/b-fontaine/saaster_kit/backend/platform/logging"
+)
+
+func init() {
+	platformlogging.SetCorrelationSource(correlationOfTenant)
+}
+
+// correlationOfTenant completes correlation with the tenant bound to ctx
+func correlationOfTenant(ctx context.Context, correlation *platformlogging.Correlation) {
+	if tenantID, ok := tenancy.TenantFromContext(ctx); ok {
+		correlation.TenantID = tenantID
+	}
+}
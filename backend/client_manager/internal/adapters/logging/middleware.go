@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	platformlogging "github.com/b-fontaine/saaster_kit/backend/platform/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware gives every request an ID, the one of its X-Request-ID header
+// when it is valid or a new one, echoes it in the response and logs the
+// request once answered, with the caller bound by the authentication
+// middleware
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := platformlogging.RequestIDOrNew(c.GetHeader(platformlogging.RequestIDHeader))
+		c.Header(platformlogging.RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(platformlogging.WithRequestID(c.Request.Context(), requestID))
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request completed",
+			slog.String("http.request.method", c.Request.Method),
+			slog.String("http.route", route),
+			slog.String("url.path", c.Request.URL.Path),
+			slog.Int("http.response.status_code", status),
+			slog.Int64("event.duration", time.Since(start).Nanoseconds()))
+	}
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/google/uuid"
+)
+
+// ClientRepository records the duration of the operations of repo
+func (m *Metrics) ClientRepository(repo out.ClientRepository) out.ClientRepository {
+	return &clientRepository{next: repo, metrics: m}
+}
+
+// IdempotencyStore records the duration of the operations of store
+func (m *Metrics) IdempotencyStore(store out.IdempotencyStore) out.IdempotencyStore {
+	return &idempotencyStore{next: store, metrics: m}
+}
+
+type clientRepository struct {
+	next    out.ClientRepository
+	metrics *Metrics
+}
+
+func (r *clientRepository) Save(ctx context.Context, client *entities.Client) (err error) {
+	defer r.metrics.observeRepository("client", "save", time.Now(), &err)
+	return r.next.Save(ctx, client)
+}
+
+func (r *clientRepository) FindByID(ctx context.Context, id uuid.UUID) (_ *entities.Client, err error) {
+	defer r.metrics.observeRepository("client", "find_by_id", time.Now(), &err)
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *clientRepository) List(ctx context.Context, opts entities.ClientListOptions) (_ *entities.ClientPage, err error) {
+	defer r.metrics.observeRepository("client", "list", time.Now(), &err)
+	return r.next.List(ctx, opts)
+}
+
+func (r *clientRepository) Update(ctx context.Context, client *entities.Client) (err error) {
+	defer r.metrics.observeRepository("client", "update", time.Now(), &err)
+	return r.next.Update(ctx, client)
+}
+
+func (r *clientRepository) Delete(ctx context.Context, id uuid.UUID, version int64) (err error) {
+	defer r.metrics.observeRepository("client", "delete", time.Now(), &err)
+	return r.next.Delete(ctx, id, version)
+}
+
+func (r *clientRepository) CreateProfileOnce(ctx context.Context, eventID string, client *entities.Client) (_ bool, err error) {
+	defer r.metrics.observeRepository("client", "create_profile_once", time.Now(), &err)
+	return r.next.CreateProfileOnce(ctx, eventID, client)
+}
+
+func (r *clientRepository) DeleteProfileOnce(ctx context.Context, eventID string, id uuid.UUID) (_ bool, err error) {
+	defer r.metrics.observeRepository("client", "delete_profile_once", time.Now(), &err)
+	return r.next.DeleteProfileOnce(ctx, eventID, id)
+}
+
+type idempotencyStore struct {
+	next    out.IdempotencyStore
+	metrics *Metrics
+}
+
+func (s *idempotencyStore) Reserve(ctx context.Context, record *out.IdempotencyRecord) (_ *out.IdempotencyRecord, err error) {
+	defer s.metrics.observeRepository("idempotency", "reserve", time.Now(), &err)
+	return s.next.Reserve(ctx, record)
+}
+
+func (s *idempotencyStore) Complete(ctx context.Context, record *out.IdempotencyRecord) (err error) {
+	defer s.metrics.observeRepository("idempotency", "complete", time.Now(), &err)
+	return s.next.Complete(ctx, record)
+}
+
+func (s *idempotencyStore) Release(ctx context.Context, record *out.IdempotencyRecord) (err error) {
+	defer s.metrics.observeRepository("idempotency", "release", time.Now(), &err)
+	return s.next.Release(ctx, record)
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
 	"github.com/google/uuid"
 )
 
-// ClientRepository implements the client repository interface
+// clientColumns are the columns scanned by scanClient, in order
+const clientColumns = "uuid, tenant_id, owner_id, first_name, last_name, contact_email, phone_number, created_at, updated_at, version"
+
+// ClientRepository implements the client repository interface.
+// Every query runs in a transaction bound to the tenant of the request context,
+// and Postgres row-level security hides the clients of other tenants.
 type ClientRepository struct {
 	db *sql.DB
 }
@@ -21,63 +27,323 @@ func NewClientRepository(db *sql.DB) *ClientRepository {
 	}
 }
 
-// Save persists a client to the database
+// Save persists a client to the database. The owner of an existing client is
+// kept, and it is only changed at client.Version unless that is 0. Missing
+// clients are created whatever the version.
 func (r *ClientRepository) Save(ctx context.Context, client *entities.Client) error {
 	query := `
-		INSERT INTO clients (uuid, first_name, last_name, contact_email, phone_number)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO clients (uuid, tenant_id, owner_id, first_name, last_name, contact_email, phone_number)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		ON CONFLICT (uuid) 
 		DO UPDATE SET 
-			first_name = $2,
-			last_name = $3,
-			contact_email = $4,
-			phone_number = $5,
-			updated_at = CURRENT_TIMESTAMP
+			first_name = $4,
+			last_name = $5,
+			contact_email = $6,
+			phone_number = $7,
+			updated_at = CURRENT_TIMESTAMP,
+			version = clients.version + 1
+		WHERE $8::bigint = 0 OR clients.version = $8
+		RETURNING owner_id, created_at, updated_at, version
 	`
-	
-	_, err := r.db.ExecContext(
-		ctx,
-		query,
-		client.UUID,
-		client.FirstName,
-		client.LastName,
-		client.ContactEmail,
-		client.PhoneNumber,
-	)
-	
+
+	err := withTenantTx(ctx, r.db, func(tx *sql.Tx, tenantID string) error {
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			client.UUID,
+			tenantID,
+			client.OwnerID,
+			client.FirstName,
+			client.LastName,
+			client.ContactEmail,
+			client.PhoneNumber,
+			client.Version,
+		).Scan(&client.OwnerID, &client.CreatedAt, &client.UpdatedAt, &client.Version)
+		// The client exists at another version
+		if err == sql.ErrNoRows {
+			return entities.ErrVersionMismatch
+		}
+		if err != nil {
+			return err
+		}
+		client.TenantID = tenantID
+		return nil
+	})
+
 	if err != nil {
 		return fmt.Errorf("error saving client: %w", err)
 	}
-	
+
 	return nil
 }
 
 // FindByID retrieves a client by UUID
 func (r *ClientRepository) FindByID(ctx context.Context, id uuid.UUID) (*entities.Client, error) {
+	query := `SELECT ` + clientColumns + ` FROM clients WHERE uuid = $1`
+
+	var client *entities.Client
+	err := withTenantTx(ctx, r.db, func(tx *sql.Tx, tenantID string) error {
+		found, err := scanClient(tx.QueryRowContext(ctx, query, id))
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		client = found
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error finding client: %w", err)
+	}
+
+	return client, nil
+}
+
+// List retrieves a page of clients, ordered by creation time then UUID, newest first
+func (r *ClientRepository) List(ctx context.Context, opts entities.ClientListOptions) (*entities.ClientPage, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if opts.Filter.OwnerID != uuid.Nil {
+		conditions = append(conditions, "owner_id = "+arg(opts.Filter.OwnerID))
+	}
+	if opts.Filter.Search != "" {
+		pattern := arg(escapeLike(opts.Filter.Search) + "%")
+		conditions = append(conditions, fmt.Sprintf(
+			"(first_name ILIKE %[1]s OR last_name ILIKE %[1]s OR contact_email ILIKE %[1]s)", pattern))
+	}
+	if opts.After != nil {
+		conditions = append(conditions, fmt.Sprintf(
+			"(created_at, uuid) < (%s, %s)", arg(opts.After.CreatedAt), arg(opts.After.UUID)))
+	}
+
+	query := `SELECT ` + clientColumns + ` FROM clients`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// One extra row tells whether there is a next page
+	query += " ORDER BY created_at DESC, uuid DESC LIMIT " + arg(opts.Limit+1)
+
+	page := &entities.ClientPage{}
+	err := withTenantTx(ctx, r.db, func(tx *sql.Tx, tenantID string) error {
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			client, err := scanClient(rows)
+			if err != nil {
+				return err
+			}
+			page.Clients = append(page.Clients, client)
+		}
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing clients: %w", err)
+	}
+
+	if len(page.Clients) > opts.Limit {
+		page.Clients = page.Clients[:opts.Limit]
+		last := page.Clients[len(page.Clients)-1]
+		page.Next = &entities.ClientCursor{UUID: last.UUID, CreatedAt: last.CreatedAt}
+	}
+
+	return page, nil
+}
+
+// Update overwrites the details of an existing client, at client.Version
+// unless that is 0
+func (r *ClientRepository) Update(ctx context.Context, client *entities.Client) error {
 	query := `
-		SELECT uuid, first_name, last_name, contact_email, phone_number
-		FROM clients
-		WHERE uuid = $1
+		UPDATE clients
+		SET first_name = $2, last_name = $3, contact_email = $4, phone_number = $5, updated_at = CURRENT_TIMESTAMP,
+			version = version + 1
+		WHERE uuid = $1 AND ($6::bigint = 0 OR version = $6)
+		RETURNING ` + clientColumns
+
+	err := withTenantTx(ctx, r.db, func(tx *sql.Tx, tenantID string) error {
+		updated, err := scanClient(tx.QueryRowContext(
+			ctx,
+			query,
+			client.UUID,
+			client.FirstName,
+			client.LastName,
+			client.ContactEmail,
+			client.PhoneNumber,
+			client.Version,
+		))
+		if err == sql.ErrNoRows {
+			return missingOrChanged(ctx, tx, client.UUID)
+		}
+		if err != nil {
+			return err
+		}
+		*client = *updated
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("error updating client: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a client, at version unless that is 0
+func (r *ClientRepository) Delete(ctx context.Context, id uuid.UUID, version int64) error {
+	query := `DELETE FROM clients WHERE uuid = $1 AND ($2::bigint = 0 OR version = $2)`
+
+	err := withTenantTx(ctx, r.db, func(tx *sql.Tx, tenantID string) error {
+		result, err := tx.ExecContext(ctx, query, id, version)
+		if err != nil {
+			return err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return missingOrChanged(ctx, tx, id)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("error deleting client: %w", err)
+	}
+
+	return nil
+}
+
+// missingOrChanged explains why a conditional change matched no client:
+// either the client does not exist, or it is at another version
+func missingOrChanged(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM clients WHERE uuid = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return entities.ErrClientNotFound
+	}
+	return entities.ErrVersionMismatch
+}
+
+// CreateProfileOnce creates a client unless it exists, once per event
+func (r *ClientRepository) CreateProfileOnce(ctx context.Context, eventID string, client *entities.Client) (bool, error) {
+	query := `
+		INSERT INTO clients (uuid, tenant_id, owner_id, first_name, last_name, contact_email, phone_number)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (uuid) DO NOTHING
 	`
-	
-	row := r.db.QueryRowContext(ctx, query, id)
-	
+
+	var applied bool
+	err := withTenantTx(ctx, r.db, func(tx *sql.Tx, tenantID string) error {
+		var err error
+		applied, err = markEventProcessed(ctx, tx, tenantID, eventID)
+		if err != nil || !applied {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			query,
+			client.UUID,
+			tenantID,
+			client.OwnerID,
+			client.FirstName,
+			client.LastName,
+			client.ContactEmail,
+			client.PhoneNumber,
+		)
+		return err
+	})
+
+	if err != nil {
+		return false, fmt.Errorf("error creating profile: %w", err)
+	}
+
+	return applied, nil
+}
+
+// DeleteProfileOnce removes a client if it exists, once per event
+func (r *ClientRepository) DeleteProfileOnce(ctx context.Context, eventID string, id uuid.UUID) (bool, error) {
+	var applied bool
+	err := withTenantTx(ctx, r.db, func(tx *sql.Tx, tenantID string) error {
+		var err error
+		applied, err = markEventProcessed(ctx, tx, tenantID, eventID)
+		if err != nil || !applied {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM clients WHERE uuid = $1", id)
+		return err
+	})
+
+	if err != nil {
+		return false, fmt.Errorf("error deleting profile: %w", err)
+	}
+
+	return applied, nil
+}
+
+// markEventProcessed records an event as processed within tx. It returns
+// false if the event was already processed.
+func markEventProcessed(ctx context.Context, tx *sql.Tx, tenantID, eventID string) (bool, error) {
+	result, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO processed_events (event_id, tenant_id) VALUES ($1, $2) ON CONFLICT (event_id) DO NOTHING",
+		eventID,
+		tenantID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return inserted == 1, nil
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanClient scans the clientColumns of a row
+func scanClient(row rowScanner) (*entities.Client, error) {
 	var client entities.Client
 	err := row.Scan(
 		&client.UUID,
+		&client.TenantID,
+		&client.OwnerID,
 		&client.FirstName,
 		&client.LastName,
 		&client.ContactEmail,
 		&client.PhoneNumber,
+		&client.CreatedAt,
+		&client.UpdatedAt,
+		&client.Version,
 	)
-	
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	
 	if err != nil {
-		return nil, fmt.Errorf("error finding client: %w", err)
+		return nil, err
 	}
-	
 	return &client, nil
 }
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,42 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+)
+
+// tenantRole is the unprivileged role every repository transaction runs as,
+// so that row-level security applies whatever role the service connects with
+const tenantRole = "client_manager_tenant"
+
+// withTenantTx runs fn in a transaction bound to the tenant of ctx. The tenant
+// is exposed to the row-level security policies through app.tenant_id, which
+// only lives as long as the transaction.
+func withTenantTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx, tenantID string) error) error {
+	tenantID, ok := tenancy.TenantFromContext(ctx)
+	if !ok {
+		return tenancy.ErrMissingTenant
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SET LOCAL ROLE "+tenantRole); err != nil {
+		return fmt.Errorf("error switching to tenant role: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenantID); err != nil {
+		return fmt.Errorf("error setting tenant: %w", err)
+	}
+
+	if err := fn(tx, tenantID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
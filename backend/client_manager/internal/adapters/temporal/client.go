@@ -2,16 +2,30 @@ package temporal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/temporal"
+	"log/slog"
 	"time"
 
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/validation"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/b-fontaine/saaster_kit/backend/platform/logging"
 	"github.com/google/uuid"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 )
 
+// VersionMismatchError is the type of the application errors reporting
+// entities.ErrVersionMismatch across workflows
+const VersionMismatchError = "VersionMismatch"
+
+// ValidationError is the type of the application errors reporting invalid
+// client fields across workflows, with the validation.Errors as details
+const ValidationError = "ValidationError"
+
 // TemporalClient is a wrapper for the Temporal client
 type TemporalClient struct {
 	client    client.Client
@@ -19,8 +33,10 @@ type TemporalClient struct {
 	taskQueue string
 }
 
-// NewTemporalClient creates a new Temporal client
-func NewTemporalClient(temporalAddress, namespace, taskQueue string) (*TemporalClient, error) {
+// NewTemporalClient creates a new Temporal client, reporting its metrics to
+// metricsHandler when it is not nil and passing its calls through
+// interceptors, such as the tracing one
+func NewTemporalClient(temporalAddress, namespace, taskQueue string, metricsHandler client.MetricsHandler, interceptors ...interceptor.ClientInterceptor) (*TemporalClient, error) {
 	nsClient, err := client.NewNamespaceClient(client.Options{
 		HostPort: temporalAddress,
 	})
@@ -29,8 +45,12 @@ func NewTemporalClient(temporalAddress, namespace, taskQueue string) (*TemporalC
 	})
 
 	c, err := client.Dial(client.Options{
-		HostPort:  temporalAddress,
-		Namespace: namespace,
+		HostPort:           temporalAddress,
+		Namespace:          namespace,
+		ContextPropagators: ContextPropagators(),
+		MetricsHandler:     metricsHandler,
+		Interceptors:       interceptors,
+		Logger:             logging.TemporalLogger(slog.Default()),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Temporal client: %w", err)
@@ -43,6 +63,15 @@ func NewTemporalClient(temporalAddress, namespace, taskQueue string) (*TemporalC
 	}, nil
 }
 
+// NewTemporalClientFromClient wraps a connected Temporal client
+func NewTemporalClientFromClient(c client.Client, namespace, taskQueue string) *TemporalClient {
+	return &TemporalClient{
+		client:    c,
+		namespace: namespace,
+		taskQueue: taskQueue,
+	}
+}
+
 // Close closes the Temporal client
 func (c *TemporalClient) Close() {
 	if c.client != nil {
@@ -50,28 +79,39 @@ func (c *TemporalClient) Close() {
 	}
 }
 
+// CheckHealth checks that the Temporal frontend serves the client
+func (c *TemporalClient) CheckHealth(ctx context.Context) error {
+	_, err := c.client.CheckHealth(ctx, &client.CheckHealthRequest{})
+	return err
+}
+
 // AddClient starts the AddClient workflow
 func (c *TemporalClient) AddClient(ctx context.Context, clientEntity *entities.Client) (*entities.Client, error) {
-	workflowID := fmt.Sprintf("add-client-%s", clientEntity.UUID.String())
-	workflowOptions := client.StartWorkflowOptions{
-		ID:        workflowID,
-		TaskQueue: c.taskQueue,
-		RetryPolicy: &temporal.RetryPolicy{
-			InitialInterval:    time.Second,
-			BackoffCoefficient: 2.0,
-			MaximumAttempts:    3,
-		},
-	}
-
 	// Start workflow
-	run, err := c.client.ExecuteWorkflow(ctx, workflowOptions, "AddClientWorkflow", clientEntity)
+	run, err := c.client.ExecuteWorkflow(ctx, c.addClientOptions(ctx, clientEntity), "AddClientWorkflow", clientEntity)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start AddClient workflow: %w", err)
+		return nil, fmt.Errorf("failed to start AddClient workflow: %w: %w", out.ErrWorkflowNotStarted, err)
 	}
 
 	// Wait for workflow completion
 	var result entities.Client
 	if err := run.Get(ctx, &result); err != nil {
+		if ctx.Err() != nil {
+			// The caller stopped waiting, the workflow goes on as an operation
+			return nil, &out.OperationPendingError{OperationID: run.GetID()}
+		}
+		var applicationErr *temporal.ApplicationError
+		if errors.As(err, &applicationErr) {
+			switch applicationErr.Type() {
+			case VersionMismatchError:
+				return nil, fmt.Errorf("workflow execution failed: %w", entities.ErrVersionMismatch)
+			case ValidationError:
+				var validationErrs validation.Errors
+				if applicationErr.Details(&validationErrs) == nil && len(validationErrs) > 0 {
+					return nil, validationErrs
+				}
+			}
+		}
 		return nil, fmt.Errorf("workflow execution failed: %w", err)
 	}
 
@@ -95,7 +135,7 @@ func (c *TemporalClient) GetClient(ctx context.Context, id uuid.UUID) (*entities
 	// Start workflow
 	run, err := c.client.ExecuteWorkflow(ctx, workflowOptions, "GetClientWorkflow", id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start GetClient workflow: %w", err)
+		return nil, fmt.Errorf("failed to start GetClient workflow: %w: %w", out.ErrWorkflowNotStarted, err)
 	}
 
 	// Wait for workflow completion
@@ -0,0 +1,177 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/google/uuid"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+)
+
+// StepQuery is the query returning the current step of a running operation
+const StepQuery = "step"
+
+// Memo fields of an operation. The tenant makes operations only visible to
+// their tenant, the owner to the user who started them and to admins.
+const (
+	tenantMemo = "tenantId"
+	ownerMemo  = "ownerId"
+)
+
+// stepQueryTimeout bounds the step query, which needs a worker to answer
+const stepQueryTimeout = 2 * time.Second
+
+// addClientOptions returns the options of the AddClient workflow of a client
+func (c *TemporalClient) addClientOptions(ctx context.Context, clientEntity *entities.Client) client.StartWorkflowOptions {
+	options := client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("add-client-%s", clientEntity.UUID.String()),
+		TaskQueue: c.taskQueue,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumAttempts:    3,
+		},
+	}
+	options.Memo = map[string]interface{}{ownerMemo: clientEntity.OwnerID.String()}
+	if tenantID, ok := tenancy.TenantFromContext(ctx); ok {
+		options.Memo[tenantMemo] = tenantID
+	}
+	return options
+}
+
+// StartAddClient starts the AddClient workflow without waiting for it
+func (c *TemporalClient) StartAddClient(ctx context.Context, clientEntity *entities.Client) (string, error) {
+	run, err := c.client.ExecuteWorkflow(ctx, c.addClientOptions(ctx, clientEntity), "AddClientWorkflow", clientEntity)
+	if err != nil {
+		return "", fmt.Errorf("failed to start AddClient workflow: %w: %w", out.ErrWorkflowNotStarted, err)
+	}
+
+	return run.GetID(), nil
+}
+
+// GetOperation describes the workflow of an operation
+func (c *TemporalClient) GetOperation(ctx context.Context, id string, wait time.Duration) (*entities.Operation, error) {
+	operation, err := c.describeOperation(ctx, id)
+	if err != nil || operation.IsDone() || wait <= 0 {
+		return operation, err
+	}
+
+	// Long poll: wait for the workflow to close, then describe it again
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	if err := c.client.GetWorkflow(waitCtx, id, "").Get(waitCtx, nil); err != nil && waitCtx.Err() != nil {
+		// Still running when the wait is over
+		return operation, nil
+	}
+
+	return c.describeOperation(ctx, id)
+}
+
+// describeOperation returns the state of the workflow of an operation
+func (c *TemporalClient) describeOperation(ctx context.Context, id string) (*entities.Operation, error) {
+	description, err := c.client.DescribeWorkflowExecution(ctx, id, "")
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return nil, entities.ErrOperationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe operation: %w", err)
+	}
+
+	info := description.GetWorkflowExecutionInfo()
+	memo := info.GetMemo().GetFields()
+	tenantID, _ := tenancy.TenantFromContext(ctx)
+	if tenantID == "" || memoString(memo, tenantMemo) != tenantID {
+		return nil, entities.ErrOperationNotFound
+	}
+
+	// Operations started before owners were recorded belong to no one, so
+	// only admins can read them
+	ownerID, _ := uuid.Parse(memoString(memo, ownerMemo))
+	operation := &entities.Operation{ID: id, OwnerID: ownerID}
+	if startTime := info.GetStartTime(); startTime != nil {
+		operation.StartedAt = *startTime
+	}
+	if closeTime := info.GetCloseTime(); closeTime != nil {
+		operation.ClosedAt = closeTime
+	}
+
+	run := c.client.GetWorkflow(ctx, id, info.GetExecution().GetRunId())
+	switch info.GetStatus() {
+	case enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		operation.Status = entities.OperationRunning
+		operation.Step = c.queryStep(ctx, id)
+	case enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		var result entities.Client
+		if err := run.Get(ctx, &result); err != nil {
+			return nil, fmt.Errorf("failed to read operation result: %w", err)
+		}
+		operation.Status = entities.OperationCompleted
+		operation.Result = &result
+	default:
+		// Failed, timed out, canceled or terminated
+		operation.Status = entities.OperationFailed
+		operation.Error = operationError(run.Get(ctx, nil), info.GetStatus())
+	}
+
+	return operation, nil
+}
+
+// queryStep returns the current step of a running workflow, or an empty
+// string when no worker answers in time
+func (c *TemporalClient) queryStep(ctx context.Context, id string) string {
+	queryCtx, cancel := context.WithTimeout(ctx, stepQueryTimeout)
+	defer cancel()
+
+	value, err := c.client.QueryWorkflow(queryCtx, id, "", StepQuery)
+	if err != nil {
+		return ""
+	}
+	var step string
+	if err := value.Get(&step); err != nil {
+		return ""
+	}
+	return step
+}
+
+// memoString returns the string recorded in the memo field of a workflow
+func memoString(fields map[string]*commonpb.Payload, field string) string {
+	payload, ok := fields[field]
+	if !ok {
+		return ""
+	}
+	var value string
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &value); err != nil {
+		return ""
+	}
+	return value
+}
+
+// operationError describes the failure of a workflow, preferring the message
+// of the application error that caused it over the Temporal wrapping
+func operationError(err error, status enumspb.WorkflowExecutionStatus) string {
+	var applicationErr *temporal.ApplicationError
+	switch {
+	case errors.As(err, &applicationErr):
+		return applicationErr.Message()
+	case status == enumspb.WORKFLOW_EXECUTION_STATUS_TIMED_OUT:
+		return "operation timed out"
+	case status == enumspb.WORKFLOW_EXECUTION_STATUS_CANCELED:
+		return "operation canceled"
+	case status == enumspb.WORKFLOW_EXECUTION_STATUS_TERMINATED:
+		return "operation terminated"
+	case err != nil:
+		return err.Error()
+	}
+	return "operation failed"
+}
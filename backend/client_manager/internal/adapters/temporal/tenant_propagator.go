@@ -0,0 +1,88 @@
+package temporal
+
+import (
+	"context"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/platform/logging"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+// tenantHeader is the Temporal header carrying the tenant ID
+const tenantHeader = "tenant-id"
+
+// tenantWorkflowKey is the workflow context key holding the tenant ID
+type tenantWorkflowKey struct{}
+
+// TenantPropagator carries the tenant bound to the caller's context through
+// workflow headers, so that activities run against the same tenant.
+type TenantPropagator struct{}
+
+// NewTenantPropagator creates a new TenantPropagator
+func NewTenantPropagator() workflow.ContextPropagator {
+	return &TenantPropagator{}
+}
+
+// ContextPropagators returns the propagators of the clients and workers: the
+// tenant, and the correlation of the logs of the request starting a workflow
+func ContextPropagators() []workflow.ContextPropagator {
+	return []workflow.ContextPropagator{NewTenantPropagator(), logging.TemporalPropagator()}
+}
+
+// Inject writes the tenant of a Go context to the headers
+func (p *TenantPropagator) Inject(ctx context.Context, writer workflow.HeaderWriter) error {
+	tenantID, ok := tenancy.TenantFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return writeTenant(writer, tenantID)
+}
+
+// InjectFromWorkflow writes the tenant of a workflow context to the headers
+func (p *TenantPropagator) InjectFromWorkflow(ctx workflow.Context, writer workflow.HeaderWriter) error {
+	tenantID, ok := ctx.Value(tenantWorkflowKey{}).(string)
+	if !ok || tenantID == "" {
+		return nil
+	}
+	return writeTenant(writer, tenantID)
+}
+
+// Extract binds the tenant found in the headers to a Go context
+func (p *TenantPropagator) Extract(ctx context.Context, reader workflow.HeaderReader) (context.Context, error) {
+	tenantID, err := readTenant(reader)
+	if err != nil || tenantID == "" {
+		return ctx, err
+	}
+	return tenancy.WithTenant(ctx, tenantID), nil
+}
+
+// ExtractToWorkflow binds the tenant found in the headers to a workflow context
+func (p *TenantPropagator) ExtractToWorkflow(ctx workflow.Context, reader workflow.HeaderReader) (workflow.Context, error) {
+	tenantID, err := readTenant(reader)
+	if err != nil || tenantID == "" {
+		return ctx, err
+	}
+	return workflow.WithValue(ctx, tenantWorkflowKey{}, tenantID), nil
+}
+
+func writeTenant(writer workflow.HeaderWriter, tenantID string) error {
+	payload, err := converter.GetDefaultDataConverter().ToPayload(tenantID)
+	if err != nil {
+		return err
+	}
+	writer.Set(tenantHeader, payload)
+	return nil
+}
+
+func readTenant(reader workflow.HeaderReader) (string, error) {
+	payload, ok := reader.Get(tenantHeader)
+	if !ok {
+		return "", nil
+	}
+	var tenantID string
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &tenantID); err != nil {
+		return "", err
+	}
+	return tenantID, nil
+}
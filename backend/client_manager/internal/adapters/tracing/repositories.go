@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/google/uuid"
+)
+
+// ClientRepository starts a span for the operations of repo
+func ClientRepository(repo out.ClientRepository) out.ClientRepository {
+	return &clientRepository{next: repo}
+}
+
+type clientRepository struct {
+	next out.ClientRepository
+}
+
+func (r *clientRepository) Save(ctx context.Context, client *entities.Client) (err error) {
+	ctx, end := startSpan(ctx, "ClientRepository.Save")
+	defer end(&err)
+	return r.next.Save(ctx, client)
+}
+
+func (r *clientRepository) FindByID(ctx context.Context, id uuid.UUID) (_ *entities.Client, err error) {
+	ctx, end := startSpan(ctx, "ClientRepository.FindByID")
+	defer end(&err)
+	return r.next.FindByID(ctx, id)
+}
+
+func (r *clientRepository) List(ctx context.Context, opts entities.ClientListOptions) (_ *entities.ClientPage, err error) {
+	ctx, end := startSpan(ctx, "ClientRepository.List")
+	defer end(&err)
+	return r.next.List(ctx, opts)
+}
+
+func (r *clientRepository) Update(ctx context.Context, client *entities.Client) (err error) {
+	ctx, end := startSpan(ctx, "ClientRepository.Update")
+	defer end(&err)
+	return r.next.Update(ctx, client)
+}
+
+func (r *clientRepository) Delete(ctx context.Context, id uuid.UUID, version int64) (err error) {
+	ctx, end := startSpan(ctx, "ClientRepository.Delete")
+	defer end(&err)
+	return r.next.Delete(ctx, id, version)
+}
+
+func (r *clientRepository) CreateProfileOnce(ctx context.Context, eventID string, client *entities.Client) (_ bool, err error) {
+	ctx, end := startSpan(ctx, "ClientRepository.CreateProfileOnce")
+	defer end(&err)
+	return r.next.CreateProfileOnce(ctx, eventID, client)
+}
+
+func (r *clientRepository) DeleteProfileOnce(ctx context.Context, eventID string, id uuid.UUID) (_ bool, err error) {
+	ctx, end := startSpan(ctx, "ClientRepository.DeleteProfileOnce")
+	defer end(&err)
+	return r.next.DeleteProfileOnce(ctx, eventID, id)
+}
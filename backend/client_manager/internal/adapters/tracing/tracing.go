@@ -0,0 +1,77 @@
+// Package tracing sets up the OpenTelemetry tracing of the service: the spans
+// of the HTTP requests and Dapr events, of the Temporal workflows and
+// activities, of the repository operations and of the SQL statements, linked
+// by the W3C trace context received from callers and passed on to Temporal.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	platformtracing "github.com/b-fontaine/saaster_kit/backend/platform/tracing"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer of the spans started by the service
+const instrumentationName = "github.com/b-fontaine/saaster_kit/backend/client_manager"
+
+// tracer returns the tracer of the global provider
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Middleware starts a span for the requests of the router, continuing the
+// trace of the traceparent header if any, as sent by callers and by the Dapr
+// sidecar delivering events. Spans are named after the matched route rather
+// than the path.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+
+		ctx := platformtracing.Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.URLPath(c.Request.URL.Path),
+			))
+		defer span.End()
+		if route != "" {
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// startSpan starts an internal span, ended by the returned function with the
+// error of the traced operation
+func startSpan(ctx context.Context, name string) (context.Context, func(err *error)) {
+	ctx, span := tracer().Start(ctx, name)
+	return ctx, func(err *error) {
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
@@ -2,9 +2,15 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/validation"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/in"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
 	"github.com/google/uuid"
 )
@@ -23,18 +29,15 @@ func NewClientService(clientRepo out.ClientRepository) *ClientService {
 
 // AddClient adds a new client to the system
 func (s *ClientService) AddClient(ctx context.Context, client *entities.Client) error {
-	// Check if client already exists
-	existingClient, err := s.clientRepo.FindByID(ctx, client.UUID)
-	if err != nil {
-		return fmt.Errorf("error checking existing client: %w", err)
+	if err := validation.ValidateClient(client); err != nil {
+		return err
 	}
-	
-	if existingClient != nil && !existingClient.IsEmpty() {
-		// Update existing client
-		return s.clientRepo.Save(ctx, client)
+
+	// A client without owner is the profile of the user it is keyed by
+	if client.OwnerID == uuid.Nil {
+		client.OwnerID = client.UUID
 	}
-	
-	// Save new client
+
 	return s.clientRepo.Save(ctx, client)
 }
 
@@ -44,11 +47,158 @@ func (s *ClientService) GetClient(ctx context.Context, id uuid.UUID) (*entities.
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving client: %w", err)
 	}
-	
+
 	// If client not found, return empty client
 	if client == nil {
 		return &entities.Client{UUID: id}, nil
 	}
-	
+
 	return client, nil
 }
+
+// FindClient retrieves a client by UUID, failing if it does not exist
+func (s *ClientService) FindClient(ctx context.Context, id uuid.UUID) (*entities.Client, error) {
+	client, err := s.clientRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving client: %w", err)
+	}
+	if client == nil {
+		return nil, entities.ErrClientNotFound
+	}
+
+	return client, nil
+}
+
+// ListClients retrieves a page of clients, newest first
+func (s *ClientService) ListClients(ctx context.Context, query in.ListClientsQuery) (*in.ClientList, error) {
+	opts := entities.ClientListOptions{
+		Filter: entities.ClientFilter{
+			OwnerID: query.OwnerID,
+			Search:  strings.TrimSpace(query.Search),
+		},
+		Limit: query.Limit,
+	}
+	if opts.Limit == 0 {
+		opts.Limit = in.DefaultListClientsLimit
+	}
+	if opts.Limit < 0 || opts.Limit > in.MaxListClientsLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", in.ErrInvalidQuery, in.MaxListClientsLimit)
+	}
+	if query.Cursor != "" {
+		after, err := decodeClientCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		opts.After = after
+	}
+
+	page, err := s.clientRepo.List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing clients: %w", err)
+	}
+
+	result := &in.ClientList{Clients: page.Clients}
+	if result.Clients == nil {
+		result.Clients = []*entities.Client{}
+	}
+	if page.Next != nil {
+		result.NextCursor = encodeClientCursor(page.Next)
+	}
+
+	return result, nil
+}
+
+// UpdateClient replaces the details of an existing client. The owner and
+// tenant of a client never change.
+func (s *ClientService) UpdateClient(ctx context.Context, client *entities.Client) (*entities.Client, error) {
+	if err := validation.ValidateClient(client); err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, client)
+}
+
+// update saves the details of an existing client
+func (s *ClientService) update(ctx context.Context, client *entities.Client) (*entities.Client, error) {
+	if err := s.clientRepo.Update(ctx, client); err != nil {
+		return nil, fmt.Errorf("error updating client: %w", err)
+	}
+
+	return client, nil
+}
+
+// PatchClient changes the given details of an existing client. The change
+// is saved at the version that was read, so that it does not overwrite a
+// concurrent one.
+func (s *ClientService) PatchClient(ctx context.Context, id uuid.UUID, version int64, patch in.ClientPatch) (*entities.Client, error) {
+	client, err := s.FindClient(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && client.Version != version {
+		return nil, entities.ErrVersionMismatch
+	}
+
+	// Only the patched details are checked, so that profiles created
+	// without details can be completed one detail at a time
+	v := validation.New()
+	if patch.FirstName != nil {
+		client.FirstName = *patch.FirstName
+		v.Name("firstName", client.FirstName)
+	}
+	if patch.LastName != nil {
+		client.LastName = *patch.LastName
+		v.Name("lastName", client.LastName)
+	}
+	if patch.ContactEmail != nil {
+		client.ContactEmail = *patch.ContactEmail
+		v.ContactEmail(client.ContactEmail)
+	}
+	if patch.PhoneNumber != nil {
+		client.PhoneNumber = *patch.PhoneNumber
+		v.PhoneNumber(client.PhoneNumber)
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, client)
+}
+
+// DeleteClient removes a client
+func (s *ClientService) DeleteClient(ctx context.Context, id uuid.UUID, version int64) error {
+	if err := s.clientRepo.Delete(ctx, id, version); err != nil {
+		return fmt.Errorf("error deleting client: %w", err)
+	}
+
+	return nil
+}
+
+// clientCursorToken is the content of an opaque listing cursor
+type clientCursorToken struct {
+	UUID      uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"c"`
+}
+
+// encodeClientCursor returns the opaque form of a cursor
+func encodeClientCursor(cursor *entities.ClientCursor) string {
+	data, _ := json.Marshal(clientCursorToken{UUID: cursor.UUID, CreatedAt: cursor.CreatedAt})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeClientCursor parses an opaque cursor
+func decodeClientCursor(cursor string) (*entities.ClientCursor, error) {
+	invalid := fmt.Errorf("%w: cursor is invalid", in.ErrInvalidQuery)
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var token clientCursorToken
+	if err := json.Unmarshal(data, &token); err != nil || token.UUID == uuid.Nil {
+		return nil, invalid
+	}
+
+	return &entities.ClientCursor{UUID: token.UUID, CreatedAt: token.CreatedAt}, nil
+}
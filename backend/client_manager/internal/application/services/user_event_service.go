@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+)
+
+// UserEventService implements the user event handler interface. The profile
+// of a user is the client keyed by the subject of their access tokens, so that
+// it is the one the user reaches through /api/v1/clients/me.
+type UserEventService struct {
+	clientRepo out.ClientRepository
+}
+
+// NewUserEventService creates a new user event service
+func NewUserEventService(clientRepo out.ClientRepository) *UserEventService {
+	return &UserEventService{
+		clientRepo: clientRepo,
+	}
+}
+
+// HandleUserCreated provisions an empty profile for the new user. A profile
+// the user already filled in is kept as is.
+func (s *UserEventService) HandleUserCreated(ctx context.Context, event entities.UserEvent) error {
+	return s.provisionProfile(ctx, event)
+}
+
+// HandleUserLinked provisions an empty profile for a user linked to their
+// account after their creation. A profile the user already filled in is kept
+// as is.
+func (s *UserEventService) HandleUserLinked(ctx context.Context, event entities.UserEvent) error {
+	return s.provisionProfile(ctx, event)
+}
+
+// provisionProfile creates the empty profile of the user of event unless it
+// exists, once per event
+func (s *UserEventService) provisionProfile(ctx context.Context, event entities.UserEvent) error {
+	profile := entities.NewClient(event.Subject, "", "", "", "")
+
+	applied, err := s.clientRepo.CreateProfileOnce(ctx, event.ID, profile)
+	if err != nil {
+		return fmt.Errorf("error provisioning profile: %w", err)
+	}
+	if !applied {
+		slog.InfoContext(ctx, "Skipping duplicate user event", "event.id", event.ID)
+	}
+
+	return nil
+}
+
+// HandleUserDeleted deletes the profile of the user, with its personal data
+func (s *UserEventService) HandleUserDeleted(ctx context.Context, event entities.UserEvent) error {
+	applied, err := s.clientRepo.DeleteProfileOnce(ctx, event.ID, event.Subject)
+	if err != nil {
+		return fmt.Errorf("error deleting profile: %w", err)
+	}
+	if !applied {
+		slog.InfoContext(ctx, "Skipping duplicate user deleted event", "event.id", event.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,260 @@
+// Package config holds the configuration of the service, layered from
+// Defaults, the YAML file named by CONFIG_FILE, the environment variables of
+// the env tags and the Dapr secret store.
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	platformconfig "github.com/b-fontaine/saaster_kit/backend/platform/config"
+)
+
+// maxOperationWait is the longest a request may long poll an operation, as
+// handlers.MaxOperationWait
+const maxOperationWait = 30 * time.Second
+
+// Config holds all configuration for the service
+type Config struct {
+	Server      ServerConfig                     `yaml:"server"`
+	Database    DatabaseConfig                   `yaml:"database"`
+	Temporal    TemporalConfig                   `yaml:"temporal"`
+	Auth        AuthConfig                       `yaml:"auth"`
+	Dapr        DaprConfig                       `yaml:"dapr"`
+	Idempotency IdempotencyConfig                `yaml:"idempotency"`
+	Tracing     TracingConfig                    `yaml:"tracing"`
+	Log         LogConfig                        `yaml:"log"`
+	Health      HealthConfig                     `yaml:"health"`
+	Shutdown    ShutdownConfig                   `yaml:"shutdown"`
+	Secrets     platformconfig.SecretStoreConfig `yaml:"secrets"`
+}
+
+// ServerConfig holds HTTP server configuration
+type ServerConfig struct {
+	Port string `yaml:"port" env:"SERVER_PORT" required:"true"`
+	// RequireIfMatch rejects updates and deletions sent without If-Match. It
+	// is off by default, so that existing clients keep working until the
+	// deployment opts in.
+	RequireIfMatch bool `yaml:"requireIfMatch" env:"SERVER_REQUIRE_IF_MATCH"`
+	// ReadTimeout bounds the time taken to read a request, body included
+	ReadTimeout time.Duration `yaml:"readTimeout" env:"SERVER_READ_TIMEOUT"`
+	// WriteTimeout bounds the time taken to handle a request and write its
+	// response. It must exceed SyncWait and the 30s long poll of operations.
+	WriteTimeout time.Duration `yaml:"writeTimeout" env:"SERVER_WRITE_TIMEOUT"`
+	// IdleTimeout is how long an idle keep-alive connection is kept open
+	IdleTimeout time.Duration `yaml:"idleTimeout" env:"SERVER_IDLE_TIMEOUT"`
+	// SyncWait is how long a synchronous creation waits for its workflow
+	// before answering 202 Accepted with the operation to poll. It must be
+	// below the idempotency lease, so that retries are not run twice.
+	SyncWait time.Duration `yaml:"syncWait" env:"SERVER_SYNC_WAIT"`
+}
+
+// DatabaseConfig holds database configuration
+type DatabaseConfig struct {
+	Host     string `yaml:"host" env:"DB_HOST" required:"true"`
+	Port     string `yaml:"port" env:"DB_PORT" required:"true"`
+	User     string `yaml:"user" env:"DB_USER" required:"true"`
+	Password string `yaml:"password" env:"DB_PASSWORD" required:"true" secret:"db-password"`
+	Name     string `yaml:"name" env:"DB_NAME" required:"true"`
+}
+
+// TemporalConfig holds Temporal configuration
+type TemporalConfig struct {
+	Address   string `yaml:"address" env:"TEMPORAL_ADDRESS" required:"true"`
+	Namespace string `yaml:"namespace" env:"TEMPORAL_NAMESPACE" required:"true"`
+	TaskQueue string `yaml:"taskQueue" env:"TEMPORAL_TASK_QUEUE" required:"true"`
+}
+
+// AuthConfig holds the configuration of the verification of the Keycloak
+// tokens
+type AuthConfig struct {
+	// Mode is jwks, to check the signatures locally, or introspection, to
+	// ask Keycloak about each token
+	Mode             string        `yaml:"mode" env:"AUTH_MODE" oneof:"jwks introspection"`
+	IssuerURL        string        `yaml:"issuerUrl" env:"KEYCLOAK_ISSUER_URL"`
+	JWKSURL          string        `yaml:"jwksUrl" env:"KEYCLOAK_JWKS_URL"`
+	Audience         string        `yaml:"audience" env:"KEYCLOAK_AUDIENCE"`
+	ClientID         string        `yaml:"clientId" env:"KEYCLOAK_CLIENT_ID"`
+	ClientSecret     string        `yaml:"clientSecret" env:"KEYCLOAK_CLIENT_SECRET" secret:"keycloak-client-secret"`
+	IntrospectionURL string        `yaml:"introspectionUrl" env:"KEYCLOAK_INTROSPECTION_URL"`
+	TenantClaim      string        `yaml:"tenantClaim" env:"KEYCLOAK_TENANT_CLAIM" required:"true"`
+	JWKSCacheTTL     time.Duration `yaml:"jwksCacheTTL" env:"KEYCLOAK_JWKS_CACHE_TTL"`
+	Leeway           time.Duration `yaml:"leeway" env:"KEYCLOAK_LEEWAY"`
+}
+
+// DaprConfig holds the configuration of the Dapr sidecar
+type DaprConfig struct {
+	HTTPEndpoint   string `yaml:"httpEndpoint" env:"DAPR_HTTP_ENDPOINT" required:"true"`
+	PubSubName     string `yaml:"pubSubName" env:"DAPR_PUBSUB_NAME" required:"true"`
+	StateStoreName string `yaml:"stateStoreName" env:"DAPR_STATE_STORE_NAME"`
+	// APIToken is sent to the sidecar, if it requires one
+	APIToken string `yaml:"apiToken" env:"DAPR_API_TOKEN" secret:"-"`
+	// AppAPIToken is expected from the sidecar with the event deliveries,
+	// which are not checked when it is empty
+	AppAPIToken string `yaml:"appApiToken" env:"APP_API_TOKEN" secret:"app-api-token"`
+}
+
+// IdempotencyConfig holds the configuration of Idempotency-Key support
+type IdempotencyConfig struct {
+	// Store is where responses are stored: postgres or dapr
+	Store string `yaml:"store" env:"IDEMPOTENCY_STORE" oneof:"postgres dapr"`
+	// TTL is how long a response is replayed to the retries of its request
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL"`
+	// Lease is how long a request holds its key while it runs, so that the
+	// retries of a request that crashed or timed out are served once it ran
+	// out. It must exceed the time a request may take.
+	Lease time.Duration `yaml:"lease" env:"IDEMPOTENCY_LEASE"`
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	// Endpoint is the base URL of the OTLP/HTTP collector, spans are not
+	// exported when it is empty
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string  `yaml:"serviceName" env:"OTEL_SERVICE_NAME" required:"true"`
+	SampleRatio float64 `yaml:"sampleRatio" env:"OTEL_TRACES_SAMPLE_RATIO"`
+}
+
+// LogConfig holds logging configuration
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" oneof:"debug info warn error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" oneof:"json text"`
+}
+
+// HealthConfig holds the configuration of the readiness probe
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"checkTimeout" env:"HEALTH_CHECK_TIMEOUT"`
+	CacheTTL     time.Duration `yaml:"cacheTTL" env:"HEALTH_CACHE_TTL"`
+}
+
+// ShutdownConfig holds the configuration of the graceful shutdown
+type ShutdownConfig struct {
+	// Timeout bounds the time given to the server and the worker to drain
+	// their work on SIGTERM
+	Timeout time.Duration `yaml:"timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+// Defaults returns the configuration used when nothing overrides it. The
+// database password has no default.
+func Defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:         "8080",
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 45 * time.Second,
+			IdleTimeout:  2 * time.Minute,
+			SyncWait:     10 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host: "localhost",
+			Port: "5432",
+			User: "postgres",
+			Name: "client_manager_db",
+		},
+		Temporal: TemporalConfig{
+			Address:   "localhost:7233",
+			Namespace: "client-namespace",
+			TaskQueue: "client-manager-task-queue",
+		},
+		Auth: AuthConfig{
+			Mode:             "jwks",
+			IssuerURL:        "http://localhost:8080/realms/saaster",
+			JWKSURL:          "http://keycloak:8080/realms/saaster/protocol/openid-connect/certs",
+			Audience:         "client-manager",
+			ClientID:         "client-manager",
+			IntrospectionURL: "http://keycloak:8080/realms/saaster/protocol/openid-connect/token/introspect",
+			TenantClaim:      "tenant_id",
+			JWKSCacheTTL:     10 * time.Minute,
+			Leeway:           30 * time.Second,
+		},
+		Dapr: DaprConfig{
+			HTTPEndpoint:   "http://localhost:3500",
+			PubSubName:     "pubsub",
+			StateStoreName: "postgres-state",
+		},
+		Idempotency: IdempotencyConfig{
+			Store: "postgres",
+			TTL:   24 * time.Hour,
+			Lease: 30 * time.Second,
+		},
+		Tracing: TracingConfig{
+			ServiceName: "client-manager",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Health: HealthConfig{
+			CheckTimeout: 2 * time.Second,
+			CacheTTL:     5 * time.Second,
+		},
+		Shutdown: ShutdownConfig{
+			Timeout: 20 * time.Second,
+		},
+		Secrets: platformconfig.SecretStoreConfig{
+			WaitTimeout: 30 * time.Second,
+		},
+	}
+}
+
+// Load loads the configuration over Defaults and validates it. The
+// configuration is returned along with the report of its problems, if any,
+// so that it can still be printed.
+func Load() (*Config, error) {
+	cfg := Defaults()
+	err := platformconfig.Load(context.Background(), cfg, platformconfig.Options{File: os.Getenv("CONFIG_FILE")})
+	return cfg, err
+}
+
+// SecretStore returns the Dapr secret store named by SECRET_STORE_NAME, if
+// any, behind the sidecar of the service
+func (c *Config) SecretStore() platformconfig.SecretStore {
+	return c.Secrets.Store(c.Dapr.HTTPEndpoint, c.Dapr.APIToken)
+}
+
+// Validate checks the rules the field tags cannot express
+func (c *Config) Validate() error {
+	var errs platformconfig.Errors
+	// The fields each verification mode needs
+	required := map[string][]struct{ name, value string }{
+		"jwks": {
+			{"auth.issuerUrl (KEYCLOAK_ISSUER_URL)", c.Auth.IssuerURL},
+			{"auth.jwksUrl (KEYCLOAK_JWKS_URL)", c.Auth.JWKSURL},
+		},
+		"introspection": {
+			{"auth.introspectionUrl (KEYCLOAK_INTROSPECTION_URL)", c.Auth.IntrospectionURL},
+			{"auth.clientSecret (KEYCLOAK_CLIENT_SECRET)", c.Auth.ClientSecret},
+		},
+	}
+	for _, field := range required[c.Auth.Mode] {
+		if field.value == "" {
+			errs = append(errs, fmt.Errorf("%s: is required by the %s mode", field.name, c.Auth.Mode))
+		}
+	}
+	if c.Idempotency.Store == "dapr" && c.Dapr.StateStoreName == "" {
+		errs = append(errs, errors.New("dapr.stateStoreName (DAPR_STATE_STORE_NAME): is required by the dapr idempotency store"))
+	}
+	if c.Idempotency.Lease <= 0 || c.Idempotency.Lease > c.Idempotency.TTL {
+		errs = append(errs, errors.New("idempotency.lease (IDEMPOTENCY_LEASE): must be positive and not exceed idempotency.ttl (IDEMPOTENCY_TTL)"))
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("server.readTimeout (SERVER_READ_TIMEOUT) and server.idleTimeout (SERVER_IDLE_TIMEOUT): must be positive"))
+	}
+	if c.Server.SyncWait <= 0 || c.Server.SyncWait >= c.Idempotency.Lease {
+		errs = append(errs, errors.New("server.syncWait (SERVER_SYNC_WAIT): must be positive and below idempotency.lease (IDEMPOTENCY_LEASE)"))
+	}
+	if c.Server.WriteTimeout <= c.Server.SyncWait || c.Server.WriteTimeout <= maxOperationWait {
+		errs = append(errs, errors.New("server.writeTimeout (SERVER_WRITE_TIMEOUT): must exceed server.syncWait (SERVER_SYNC_WAIT) and the 30s long poll of operations"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sampleRatio (OTEL_TRACES_SAMPLE_RATIO): must be between 0 and 1"))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -1,22 +1,47 @@
 package entities
 
 import (
+	"errors"
+	"time"
+
 	"github.com/google/uuid"
 )
 
-// Client represents a client in the system
+// ErrClientNotFound is returned when a client does not exist in the tenant of the caller
+var ErrClientNotFound = errors.New("client not found")
+
+// ErrVersionMismatch is returned when a client changed since the version the caller read
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// Lengths of the client fields, as limited by the clients table
+const (
+	MaxNameLength        = 100
+	MaxEmailLength       = 255
+	MaxPhoneNumberLength = 20
+)
+
+// Client represents a client in the system.
+// The profile of a user is the client whose UUID is the user's subject.
 type Client struct {
 	UUID         uuid.UUID `json:"uuid"`
+	TenantID     string    `json:"tenantId"`
+	OwnerID      uuid.UUID `json:"ownerId"`
 	FirstName    string    `json:"firstName"`
 	LastName     string    `json:"lastName"`
 	ContactEmail string    `json:"contactEmail"`
 	PhoneNumber  string    `json:"phoneNumber"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	// Version is incremented by every change of the client, starting at 1.
+	// It is 0 for clients that were not read from the repository.
+	Version int64 `json:"version"`
 }
 
-// NewClient creates a new client with the given UUID
+// NewClient creates a new client with the given UUID, owned by the user of the same UUID
 func NewClient(id uuid.UUID, firstName, lastName, contactEmail, phoneNumber string) *Client {
 	return &Client{
 		UUID:         id,
+		OwnerID:      id,
 		FirstName:    firstName,
 		LastName:     lastName,
 		ContactEmail: contactEmail,
@@ -24,6 +49,13 @@ func NewClient(id uuid.UUID, firstName, lastName, contactEmail, phoneNumber stri
 	}
 }
 
+// NewOwnedClient creates a new client with a generated UUID, owned by the given user
+func NewOwnedClient(ownerID uuid.UUID, firstName, lastName, contactEmail, phoneNumber string) *Client {
+	client := NewClient(uuid.New(), firstName, lastName, contactEmail, phoneNumber)
+	client.OwnerID = ownerID
+	return client
+}
+
 // IsEmpty checks if the client has any data
 func (c *Client) IsEmpty() bool {
 	return c.FirstName == "" && c.LastName == "" && c.ContactEmail == "" && c.PhoneNumber == ""
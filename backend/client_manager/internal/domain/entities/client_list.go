@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ClientFilter restricts the clients of a listing. Zero values match every client.
+type ClientFilter struct {
+	OwnerID uuid.UUID
+	// Search is a case-insensitive prefix of the first name, last name or contact email
+	Search string
+}
+
+// ClientCursor holds the sort keys of the last client of a page. The next
+// page starts right after it.
+type ClientCursor struct {
+	UUID      uuid.UUID
+	CreatedAt time.Time
+}
+
+// ClientListOptions describes a page of clients to list, newest first
+type ClientListOptions struct {
+	Filter ClientFilter
+	// Limit is the maximum number of clients of the page
+	Limit int
+	// After is the cursor of the previous page, nil for the first page
+	After *ClientCursor
+}
+
+// ClientPage is a page of clients
+type ClientPage struct {
+	Clients []*Client
+	// Next is the cursor of the next page, nil on the last page
+	Next *ClientCursor
+}
@@ -0,0 +1,44 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrOperationNotFound is returned when an operation does not exist in the tenant of the caller
+var ErrOperationNotFound = errors.New("operation not found")
+
+// OperationStatus is the state of an asynchronous operation
+type OperationStatus string
+
+// States of an operation. Completed and failed operations are final.
+const (
+	OperationRunning   OperationStatus = "running"
+	OperationCompleted OperationStatus = "completed"
+	OperationFailed    OperationStatus = "failed"
+)
+
+// Operation is an asynchronous change of a client, run by a workflow whose
+// ID is the operation ID
+type Operation struct {
+	ID     string          `json:"id"`
+	Status OperationStatus `json:"status"`
+	// OwnerID is the owner of the client the operation changes. Only they
+	// and admins may read the operation.
+	OwnerID uuid.UUID `json:"-"`
+	// Step is the current step of a running operation, when known
+	Step string `json:"step,omitempty"`
+	// Result is the client of a completed operation
+	Result *Client `json:"result,omitempty"`
+	// Error describes why a failed operation failed
+	Error     string     `json:"error,omitempty"`
+	StartedAt time.Time  `json:"startedAt"`
+	ClosedAt  *time.Time `json:"closedAt,omitempty"`
+}
+
+// IsDone reports whether the operation reached a final state
+func (o *Operation) IsDone() bool {
+	return o.Status != OperationRunning
+}
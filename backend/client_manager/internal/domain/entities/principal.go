@@ -0,0 +1,23 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+)
+
+// Principal represents the authenticated caller of a request
+type Principal struct {
+	Subject uuid.UUID `json:"subject"`
+	Email   string    `json:"email"`
+	Roles   []string  `json:"roles"`
+	Tenant  string    `json:"tenant"`
+}
+
+// HasRole checks if the principal holds the given role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+package entities
+
+import (
+	"github.com/google/uuid"
+)
+
+// UserEvent is a user lifecycle event published by user_manager
+type UserEvent struct {
+	// ID is the CloudEvent ID, the same for every delivery of the event
+	ID       string
+	TenantID string
+	// Subject is the Keycloak account of the user, the subject of their
+	// access tokens and the ID of their profile
+	Subject uuid.UUID
+}
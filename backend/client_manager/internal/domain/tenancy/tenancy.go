@@ -0,0 +1,23 @@
+package tenancy
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrMissingTenant is returned when an operation needs a tenant and the context has none
+var ErrMissingTenant = errors.New("tenant is missing from context")
+
+// tenantContextKey is the context key holding the tenant ID
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of ctx bound to the given tenant (organization) ID
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant ID bound to ctx
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
@@ -0,0 +1,43 @@
+package validation
+
+import (
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/google/uuid"
+)
+
+// Client checks the fields of a client, named after its JSON fields
+func (v *Validator) Client(client *entities.Client) {
+	v.Check(client.UUID != uuid.Nil, "uuid", "is required")
+	v.Name("firstName", client.FirstName)
+	v.Name("lastName", client.LastName)
+	v.ContactEmail(client.ContactEmail)
+	v.PhoneNumber(client.PhoneNumber)
+}
+
+// Name checks the first or last name of a client
+func (v *Validator) Name(field, value string) {
+	v.Required(field, value)
+	v.MaxLength(field, value, entities.MaxNameLength)
+}
+
+// ContactEmail checks the contact email of a client
+func (v *Validator) ContactEmail(value string) {
+	v.Required("contactEmail", value)
+	v.Email("contactEmail", value)
+	v.MaxLength("contactEmail", value, entities.MaxEmailLength)
+}
+
+// PhoneNumber checks the phone number of a client
+func (v *Validator) PhoneNumber(value string) {
+	v.Required("phoneNumber", value)
+	v.Phone("phoneNumber", value)
+	v.MaxLength("phoneNumber", value, entities.MaxPhoneNumberLength)
+}
+
+// ValidateClient returns the invalid fields of a client as Errors, or nil
+// when it is valid
+func ValidateClient(client *entities.Client) error {
+	v := New()
+	v.Client(client)
+	return v.Err()
+}
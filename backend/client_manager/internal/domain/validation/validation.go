@@ -0,0 +1,24 @@
+// Package validation checks the fields of the clients, reporting every
+// invalid field at once rather than the first one. The checks themselves are
+// the ones of the platform validation package.
+package validation
+
+import (
+	platformvalidation "github.com/b-fontaine/saaster_kit/backend/platform/validation"
+)
+
+// FieldError is a field that failed validation
+type FieldError = platformvalidation.FieldError
+
+// Errors lists every invalid field found by a validation
+type Errors = platformvalidation.Errors
+
+// Validator collects the invalid fields of the clients
+type Validator struct {
+	*platformvalidation.Validator
+}
+
+// New creates a validator without errors
+func New() *Validator {
+	return &Validator{Validator: platformvalidation.New()}
+}
@@ -2,16 +2,71 @@ package in
 
 import (
 	"context"
+	"errors"
 
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
 	"github.com/google/uuid"
 )
 
-// ClientService defines the interface for client operations
+// Page sizes of client listings
+const (
+	DefaultListClientsLimit = 50
+	MaxListClientsLimit     = 200
+)
+
+// ErrInvalidQuery is returned when a listing is requested with invalid parameters
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ClientService defines the interface for client operations.
+// Changes given a version other than 0, including client.Version, fail with
+// entities.ErrVersionMismatch if the client is at another version.
 type ClientService interface {
-	// AddClient adds a new client to the system
+	// AddClient adds a new client to the system, or updates it if it exists
 	AddClient(ctx context.Context, client *entities.Client) error
-	
-	// GetClient retrieves a client by UUID
+
+	// GetClient retrieves a client by UUID, an empty client if it does not exist
 	GetClient(ctx context.Context, id uuid.UUID) (*entities.Client, error)
+
+	// FindClient retrieves a client by UUID.
+	// It returns entities.ErrClientNotFound if the client does not exist.
+	FindClient(ctx context.Context, id uuid.UUID) (*entities.Client, error)
+
+	// ListClients retrieves a page of clients
+	ListClients(ctx context.Context, query ListClientsQuery) (*ClientList, error)
+
+	// UpdateClient replaces the details of an existing client
+	UpdateClient(ctx context.Context, client *entities.Client) (*entities.Client, error)
+
+	// PatchClient changes the given details of an existing client
+	PatchClient(ctx context.Context, id uuid.UUID, version int64, patch ClientPatch) (*entities.Client, error)
+
+	// DeleteClient removes a client
+	DeleteClient(ctx context.Context, id uuid.UUID, version int64) error
+}
+
+// ListClientsQuery describes a page of clients to list, newest first
+type ListClientsQuery struct {
+	// OwnerID restricts the listing to the clients of a user, if set
+	OwnerID uuid.UUID
+	// Search is a case-insensitive prefix of the first name, last name or contact email
+	Search string
+	// Limit defaults to DefaultListClientsLimit
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// ClientList is a page of clients
+type ClientList struct {
+	Clients []*entities.Client `json:"clients"`
+	// NextCursor is the opaque cursor of the next page, empty on the last page
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ClientPatch holds the details to change; nil fields are left untouched
+type ClientPatch struct {
+	FirstName    *string `json:"firstName"`
+	LastName     *string `json:"lastName"`
+	ContactEmail *string `json:"contactEmail"`
+	PhoneNumber  *string `json:"phoneNumber"`
 }
@@ -0,0 +1,22 @@
+package in
+
+import (
+	"context"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+)
+
+// UserEventHandler reacts to the lifecycle of the users of user_manager.
+// Events are delivered at least once; handlers ignore the events they already
+// processed. The context must be bound to the tenant of the event.
+type UserEventHandler interface {
+	// HandleUserCreated provisions an empty profile for the new user
+	HandleUserCreated(ctx context.Context, event entities.UserEvent) error
+
+	// HandleUserLinked provisions an empty profile for a user linked to their
+	// account after their creation
+	HandleUserLinked(ctx context.Context, event entities.UserEvent) error
+
+	// HandleUserDeleted deletes the profile of the user
+	HandleUserDeleted(ctx context.Context, event entities.UserEvent) error
+}
@@ -0,0 +1,43 @@
+package out
+
+import (
+	"context"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/google/uuid"
+)
+
+// ClientRepository defines the interface for client persistence.
+//
+// Changes are conditional on the version of the client: when the version
+// given to Save, Update or Delete is not 0, they return
+// entities.ErrVersionMismatch, changing nothing, if the stored client is at
+// another version. Save and Update set client.Version to the new version.
+type ClientRepository interface {
+	// Save persists a client, creating it if it does not exist
+	Save(ctx context.Context, client *entities.Client) error
+
+	// FindByID retrieves a client by UUID, nil if it does not exist
+	FindByID(ctx context.Context, id uuid.UUID) (*entities.Client, error)
+
+	// List retrieves a page of clients, newest first
+	List(ctx context.Context, opts entities.ClientListOptions) (*entities.ClientPage, error)
+
+	// Update overwrites the details of an existing client.
+	// It returns entities.ErrClientNotFound if the client does not exist.
+	Update(ctx context.Context, client *entities.Client) error
+
+	// Delete removes a client.
+	// It returns entities.ErrClientNotFound if the client does not exist.
+	Delete(ctx context.Context, id uuid.UUID, version int64) error
+
+	// CreateProfileOnce records eventID as processed and creates the client
+	// unless it exists, atomically. It returns false, changing nothing, if
+	// eventID was already processed.
+	CreateProfileOnce(ctx context.Context, eventID string, client *entities.Client) (bool, error)
+
+	// DeleteProfileOnce records eventID as processed and removes the client
+	// if it exists, atomically. It returns false, changing nothing, if
+	// eventID was already processed.
+	DeleteProfileOnce(ctx context.Context, eventID string, id uuid.UUID) (bool, error)
+}
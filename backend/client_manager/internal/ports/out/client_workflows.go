@@ -0,0 +1,43 @@
+package out
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/google/uuid"
+)
+
+// ErrWorkflowNotStarted is returned when a workflow could not be started.
+// Nothing was changed, so the operation can safely be run another way.
+var ErrWorkflowNotStarted = errors.New("workflow not started")
+
+// OperationPendingError is returned when ctx is done before the workflow
+// completes. The workflow keeps running as the operation to poll.
+type OperationPendingError struct {
+	OperationID string
+}
+
+func (e *OperationPendingError) Error() string {
+	return "workflow still running as operation " + e.OperationID
+}
+
+// ClientWorkflows defines the interface for running client operations as workflows
+type ClientWorkflows interface {
+	// AddClient saves a client through a workflow and waits for the result.
+	// It returns an *OperationPendingError if ctx is done first.
+	AddClient(ctx context.Context, client *entities.Client) (*entities.Client, error)
+
+	// GetClient retrieves a client through a workflow and waits for the result
+	GetClient(ctx context.Context, id uuid.UUID) (*entities.Client, error)
+
+	// StartAddClient starts saving a client through a workflow, in the tenant
+	// of ctx, and returns the ID of the operation without waiting
+	StartAddClient(ctx context.Context, client *entities.Client) (string, error)
+
+	// GetOperation returns the state of an operation of the tenant of ctx.
+	// While the operation runs it waits up to wait for it to complete.
+	// It returns entities.ErrOperationNotFound if the operation does not exist.
+	GetOperation(ctx context.Context, id string, wait time.Duration) (*entities.Operation, error)
+}
@@ -0,0 +1,11 @@
+package out
+
+import "github.com/b-fontaine/saaster_kit/backend/platform/idempotency"
+
+// IdempotencyRecord is the outcome of a request sent with an Idempotency-Key
+type IdempotencyRecord = idempotency.Record
+
+// IdempotencyStore stores the responses of the requests sent with an
+// Idempotency-Key. Expired records, including reservations whose lease ran
+// out, are treated as missing.
+type IdempotencyStore = idempotency.Store
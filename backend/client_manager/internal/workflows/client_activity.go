@@ -2,12 +2,16 @@ package workflows
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	temporaladapter "github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/validation"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/in"
 	"github.com/google/uuid"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
 // ClientActivity defines the activities for client operations
@@ -27,22 +31,17 @@ func (a *ClientActivity) AddClientActivity(ctx context.Context, client *entities
 	logger := activity.GetLogger(ctx)
 	logger.Info("AddClientActivity started", "clientUUID", client.UUID)
 
-	// Validate client data
-	if client.UUID == uuid.Nil {
-		return nil, fmt.Errorf("client UUID is required")
-	}
-	if client.FirstName == "" {
-		return nil, fmt.Errorf("client first name is required")
-	}
-	if client.LastName == "" {
-		return nil, fmt.Errorf("client last name is required")
-	}
-	if client.ContactEmail == "" {
-		return nil, fmt.Errorf("client contact email is required")
-	}
-
 	// Add client
 	err := a.clientService.AddClient(ctx, client)
+	var validationErrs validation.Errors
+	if errors.As(err, &validationErrs) {
+		// Invalid fields stay invalid, so the error is not retried
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), temporaladapter.ValidationError, err, validationErrs)
+	}
+	if errors.Is(err, entities.ErrVersionMismatch) {
+		// Retrying would fail the same way; the caller must read the client again
+		return nil, temporal.NewNonRetryableApplicationError("client has changed since it was read", temporaladapter.VersionMismatchError, err)
+	}
 	if err != nil {
 		logger.Error("Failed to add client", "error", err)
 		return nil, fmt.Errorf("failed to add client: %w", err)
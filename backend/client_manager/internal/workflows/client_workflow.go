@@ -4,6 +4,7 @@ import (
 	"go.temporal.io/sdk/temporal"
 	"time"
 
+	temporaladapter "github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
 	"github.com/google/uuid"
 	"go.temporal.io/sdk/workflow"
@@ -26,6 +27,13 @@ func AddClientWorkflow(ctx workflow.Context, client *entities.Client) (*entities
 	logger := workflow.GetLogger(ctx)
 	logger.Info("AddClientWorkflow started", "clientUUID", client.UUID)
 
+	// Report the current step to the operation status resource
+	if err := workflow.SetQueryHandler(ctx, temporaladapter.StepQuery, func() (string, error) {
+		return "saving", nil
+	}); err != nil {
+		return nil, err
+	}
+
 	activityOptions := workflow.ActivityOptions{
 		StartToCloseTimeout: 10 * time.Second,
 		RetryPolicy: &temporal.RetryPolicy{
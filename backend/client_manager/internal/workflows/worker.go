@@ -2,10 +2,14 @@ package workflows
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/in"
+	"github.com/b-fontaine/saaster_kit/backend/platform/logging"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
 )
 
@@ -15,21 +19,47 @@ type WorkerConfig struct {
 	Namespace       string
 	TaskQueue       string
 	ClientService   in.ClientService
+	// MetricsHandler receives the metrics of the worker, if not nil
+	MetricsHandler client.MetricsHandler
+	// Interceptors wrap the workflows and activities run by the worker
+	Interceptors []interceptor.ClientInterceptor
+	// StopTimeout is how long the running activities are given to finish
+	// when the worker stops
+	StopTimeout time.Duration
 }
 
-// StartWorker starts a Temporal worker
-func StartWorker(config WorkerConfig) (client.Client, error) {
+// Worker runs the client workflows and activities, with a Temporal client
+// of its own
+type Worker struct {
+	client    client.Client
+	worker    worker.Worker
+	namespace string
+	taskQueue string
+}
+
+// NewWorker creates a Temporal worker registering the client workflows and
+// activities. It polls the task queue once run.
+func NewWorker(config WorkerConfig) (*Worker, error) {
 	// Create Temporal client
 	c, err := client.Dial(client.Options{
-		HostPort:  config.TemporalAddress,
-		Namespace: config.Namespace,
+		HostPort:           config.TemporalAddress,
+		Namespace:          config.Namespace,
+		ContextPropagators: temporal.ContextPropagators(),
+		MetricsHandler:     config.MetricsHandler,
+		Interceptors:       config.Interceptors,
+		Logger:             logging.TemporalLogger(slog.Default()),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Temporal client: %w", err)
 	}
 
 	// Create worker
-	w := worker.New(c, config.TaskQueue, worker.Options{})
+	w := worker.New(c, config.TaskQueue, worker.Options{
+		// Logs the workflows and activities with the correlation of the
+		// request that started them
+		Interceptors:      []interceptor.WorkerInterceptor{logging.TemporalInterceptor()},
+		WorkerStopTimeout: config.StopTimeout,
+	})
 
 	// Register workflows
 	w.RegisterWorkflow(AddClientWorkflow)
@@ -40,13 +70,27 @@ func StartWorker(config WorkerConfig) (client.Client, error) {
 	w.RegisterActivity(activities.AddClientActivity)
 	w.RegisterActivity(activities.GetClientActivity)
 
-	// Start worker
-	err = w.Start()
-	if err != nil {
-		c.Close()
-		return nil, fmt.Errorf("failed to start worker: %w", err)
+	return &Worker{
+		client:    c,
+		worker:    w,
+		namespace: config.Namespace,
+		taskQueue: config.TaskQueue,
+	}, nil
+}
+
+// Run runs the worker until interruptCh is closed, then waits for the
+// running activities for up to the stop timeout
+func (w *Worker) Run(interruptCh <-chan interface{}) error {
+	slog.Info("Temporal worker started", "namespace", w.namespace, "task_queue", w.taskQueue)
+	if err := w.worker.Run(interruptCh); err != nil {
+		return fmt.Errorf("failed to run worker: %w", err)
 	}
+	slog.Info("Temporal worker stopped", "namespace", w.namespace, "task_queue", w.taskQueue)
+	return nil
+}
 
-	log.Printf("Temporal worker started. Namespace: %s, Task Queue: %s", config.Namespace, config.TaskQueue)
-	return c, nil
+// Close closes the Temporal client of the worker, once it stopped
+func (w *Worker) Close() error {
+	w.client.Close()
+	return nil
 }
@@ -0,0 +1,6 @@
+DROP POLICY IF EXISTS tenant_isolation ON clients;
+ALTER TABLE clients NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE clients DISABLE ROW LEVEL SECURITY;
+REVOKE ALL ON clients FROM client_manager_tenant;
+DROP INDEX IF EXISTS clients_tenant_id_idx;
+ALTER TABLE clients DROP COLUMN IF EXISTS tenant_id;
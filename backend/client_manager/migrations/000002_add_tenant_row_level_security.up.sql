@@ -0,0 +1,24 @@
+-- Every client belongs to a tenant (the organization managed by user_manager).
+-- Rows created before this migration have no tenant and are visible to nobody.
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS tenant_id UUID;
+CREATE INDEX IF NOT EXISTS clients_tenant_id_idx ON clients (tenant_id);
+
+-- Repositories switch to this role for each transaction, so that row-level
+-- security applies even when the service connects as a superuser.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'client_manager_tenant') THEN
+        CREATE ROLE client_manager_tenant NOLOGIN;
+    END IF;
+END
+$$;
+GRANT client_manager_tenant TO CURRENT_USER;
+GRANT SELECT, INSERT, UPDATE, DELETE ON clients TO client_manager_tenant;
+
+-- Only rows of the tenant set in app.tenant_id are visible or writable.
+-- Without the setting the policy matches nothing.
+ALTER TABLE clients ENABLE ROW LEVEL SECURITY;
+ALTER TABLE clients FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON clients
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS clients_owner_id_idx;
+DROP INDEX IF EXISTS clients_tenant_id_created_at_uuid_idx;
+ALTER TABLE clients ALTER COLUMN updated_at DROP NOT NULL;
+ALTER TABLE clients ALTER COLUMN created_at DROP NOT NULL;
+ALTER TABLE clients DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,15 @@
+-- Clients have their own UUID and are owned by the user who created them.
+-- Clients created before this migration are the profile of the user they are
+-- keyed by, so they are owned by that user.
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS owner_id UUID;
+UPDATE clients SET owner_id = uuid WHERE owner_id IS NULL;
+ALTER TABLE clients ALTER COLUMN owner_id SET NOT NULL;
+
+UPDATE clients SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE clients SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE clients ALTER COLUMN created_at SET NOT NULL;
+ALTER TABLE clients ALTER COLUMN updated_at SET NOT NULL;
+
+-- Listings are ordered by creation time then UUID, newest first
+CREATE INDEX IF NOT EXISTS clients_tenant_id_created_at_uuid_idx ON clients (tenant_id, created_at DESC, uuid DESC);
+CREATE INDEX IF NOT EXISTS clients_owner_id_idx ON clients (owner_id);
@@ -0,0 +1,16 @@
+-- IDs of the CloudEvents already processed, so that redelivered events are
+-- ignored. Rows are written in the same transaction as the change the event
+-- caused.
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id VARCHAR(255) PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+GRANT SELECT, INSERT ON processed_events TO client_manager_tenant;
+
+ALTER TABLE processed_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE processed_events FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON processed_events
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
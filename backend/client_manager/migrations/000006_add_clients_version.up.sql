@@ -0,0 +1,3 @@
+-- Version of each client, incremented by every change, so that concurrent
+-- updates based on the same version do not overwrite each other
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/auth"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "http://keycloak.test/realms/saaster"
+	testClientID = "client-manager"
+	testSubject  = "8f14e45f-ceea-467f-a0a4-2c1b8bcd3a11"
+)
+
+// jwksStandIn serves a JWKS document for the keys it holds
+type jwksStandIn struct {
+	server   *httptest.Server
+	keys     map[string]*rsa.PrivateKey
+	requests atomic.Int32
+}
+
+func newJWKSStandIn(t *testing.T) *jwksStandIn {
+	s := &jwksStandIn{keys: map[string]*rsa.PrivateKey{}}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		var keys []map[string]string
+		for kid, key := range s.keys {
+			keys = append(keys, map[string]string{
+				"kid": kid,
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *jwksStandIn) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	s.keys[kid] = key
+	return key
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   testIssuer,
+		"aud":   testClientID,
+		"sub":   testSubject,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"email": "jane.doe@example.com",
+		"realm_access": map[string]interface{}{
+			"roles": []string{"user"},
+		},
+		"resource_access": map[string]interface{}{
+			testClientID: map[string]interface{}{"roles": []string{"clients:write"}},
+		},
+		"tenant_id": "acme",
+	}
+}
+
+func newJWKSVerifier(jwks *jwksStandIn) *auth.JWKSVerifier {
+	return auth.NewJWKSVerifier(auth.Config{
+		IssuerURL:   testIssuer,
+		JWKSURL:     jwks.server.URL,
+		Audience:    testClientID,
+		ClientID:    testClientID,
+		TenantClaim: "tenant_id",
+	})
+}
+
+// newPrincipalRouter returns a router exposing the principal seen by handlers
+func newPrincipalRouter(verifier auth.TokenVerifier) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handlers.KeycloakAuthMiddleware(verifier))
+	router.GET("/whoami", func(c *gin.Context) {
+		principal, ok := handlers.PrincipalFromContext(c)
+		if !ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.JSON(http.StatusOK, principal)
+	})
+	return router
+}
+
+func callWithToken(router http.Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestAuth_JWKSVerifier tests local token verification against the realm JWKS
+func TestAuth_JWKSVerifier(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	key := jwks.addKey(t, "key-1")
+	router := newPrincipalRouter(newJWKSVerifier(jwks))
+
+	t.Run("ValidToken", func(t *testing.T) {
+		rec := callWithToken(router, signToken(t, key, "key-1", validClaims()))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var principal entities.Principal
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&principal))
+		assert.Equal(t, testSubject, principal.Subject.String())
+		assert.Equal(t, "jane.doe@example.com", principal.Email)
+		assert.Equal(t, "acme", principal.Tenant)
+		assert.ElementsMatch(t, []string{"user", "clients:write"}, principal.Roles)
+	})
+
+	invalid := map[string]func(jwt.MapClaims){
+		"Expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"NotYetValid":    func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"WrongIssuer":    func(c jwt.MapClaims) { c["iss"] = "http://evil.test/realms/saaster" },
+		"WrongAudience":  func(c jwt.MapClaims) { c["aud"] = "another-service" },
+		"SubjectNotUUID": func(c jwt.MapClaims) { c["sub"] = "service-account" },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims()
+			mutate(claims)
+			rec := callWithToken(router, signToken(t, key, "key-1", claims))
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		})
+	}
+
+	t.Run("ForeignSignature", func(t *testing.T) {
+		foreign, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		rec := callWithToken(router, signToken(t, foreign, "key-1", validClaims()))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("MissingToken", func(t *testing.T) {
+		rec := callWithToken(router, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+}
+
+// TestAuth_KeyRotation tests that a token signed with a new key triggers a JWKS refresh
+func TestAuth_KeyRotation(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	oldKey := jwks.addKey(t, "key-1")
+	router := newPrincipalRouter(newJWKSVerifier(jwks))
+
+	require.Equal(t, http.StatusOK, callWithToken(router, signToken(t, oldKey, "key-1", validClaims())).Code)
+	require.Equal(t, http.StatusOK, callWithToken(router, signToken(t, oldKey, "key-1", validClaims())).Code)
+	assert.Equal(t, int32(1), jwks.requests.Load(), "keys should be served from the cache")
+
+	newKey := jwks.addKey(t, "key-2")
+	require.Equal(t, http.StatusOK, callWithToken(router, signToken(t, newKey, "key-2", validClaims())).Code)
+	assert.Equal(t, int32(2), jwks.requests.Load())
+}
+
+// TestAuth_IntrospectionVerifier tests verification through RFC 7662 token introspection
+func TestAuth_IntrospectionVerifier(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		if !ok || clientID != testClientID || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.NoError(t, r.ParseForm())
+
+		response := map[string]interface{}{"active": false}
+		if r.PostForm.Get("token") == "active-token" {
+			response = validClaims()
+			response["active"] = true
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	router := newPrincipalRouter(auth.NewIntrospectionVerifier(auth.IntrospectionConfig{
+		URL:          server.URL,
+		ClientID:     testClientID,
+		ClientSecret: "s3cret",
+		TenantClaim:  "tenant_id",
+	}))
+
+	t.Run("ActiveToken", func(t *testing.T) {
+		rec := callWithToken(router, "active-token")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var principal entities.Principal
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&principal))
+		assert.Equal(t, testSubject, principal.Subject.String())
+		assert.Equal(t, "acme", principal.Tenant)
+	})
+
+	t.Run("InactiveToken", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, callWithToken(router, "revoked-token").Code)
+	})
+}
@@ -0,0 +1,473 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/in"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryClientRepository is an in-memory stand-in for the Postgres repository.
+// Like row-level security, it only shows the clients of the tenant of the context.
+type memoryClientRepository struct {
+	mu        sync.Mutex
+	clients   map[uuid.UUID]entities.Client
+	processed map[string]bool
+	now       time.Time
+}
+
+func newMemoryClientRepository() *memoryClientRepository {
+	return &memoryClientRepository{
+		clients:   map[uuid.UUID]entities.Client{},
+		processed: map[string]bool{},
+		now:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (r *memoryClientRepository) find(ctx context.Context, id uuid.UUID) (entities.Client, string, bool) {
+	tenantID, _ := tenancy.TenantFromContext(ctx)
+	client, ok := r.clients[id]
+	return client, tenantID, ok && client.TenantID == tenantID
+}
+
+func (r *memoryClientRepository) Save(ctx context.Context, client *entities.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, tenantID, ok := r.find(ctx, client.UUID)
+	if ok && client.Version != 0 && client.Version != existing.Version {
+		return entities.ErrVersionMismatch
+	}
+	r.now = r.now.Add(time.Minute)
+	client.TenantID = tenantID
+	client.CreatedAt, client.UpdatedAt = r.now, r.now
+	client.Version = 1
+	if ok {
+		client.OwnerID, client.CreatedAt = existing.OwnerID, existing.CreatedAt
+		client.Version = existing.Version + 1
+	}
+	r.clients[client.UUID] = *client
+	return nil
+}
+
+func (r *memoryClientRepository) FindByID(ctx context.Context, id uuid.UUID) (*entities.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, _, ok := r.find(ctx, id)
+	if !ok {
+		return nil, nil
+	}
+	return &client, nil
+}
+
+func (r *memoryClientRepository) List(ctx context.Context, opts entities.ClientListOptions) (*entities.ClientPage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenantID, _ := tenancy.TenantFromContext(ctx)
+	search := strings.ToLower(opts.Filter.Search)
+	var clients []*entities.Client
+	for _, client := range r.clients {
+		client := client
+		if client.TenantID != tenantID {
+			continue
+		}
+		if opts.Filter.OwnerID != uuid.Nil && client.OwnerID != opts.Filter.OwnerID {
+			continue
+		}
+		if search != "" &&
+			!strings.HasPrefix(strings.ToLower(client.FirstName), search) &&
+			!strings.HasPrefix(strings.ToLower(client.LastName), search) &&
+			!strings.HasPrefix(strings.ToLower(client.ContactEmail), search) {
+			continue
+		}
+		clients = append(clients, &client)
+	}
+
+	after := func(a, b *entities.Client) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.UUID.String() > b.UUID.String()
+	}
+	sort.Slice(clients, func(i, j int) bool { return after(clients[i], clients[j]) })
+	if opts.After != nil {
+		cursor := &entities.Client{UUID: opts.After.UUID, CreatedAt: opts.After.CreatedAt}
+		start := sort.Search(len(clients), func(i int) bool { return after(cursor, clients[i]) })
+		clients = clients[start:]
+	}
+
+	page := &entities.ClientPage{Clients: clients}
+	if len(clients) > opts.Limit {
+		page.Clients = clients[:opts.Limit]
+		last := page.Clients[opts.Limit-1]
+		page.Next = &entities.ClientCursor{UUID: last.UUID, CreatedAt: last.CreatedAt}
+	}
+	return page, nil
+}
+
+func (r *memoryClientRepository) Update(ctx context.Context, client *entities.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, _, ok := r.find(ctx, client.UUID)
+	if !ok {
+		return entities.ErrClientNotFound
+	}
+	if client.Version != 0 && client.Version != existing.Version {
+		return entities.ErrVersionMismatch
+	}
+	r.now = r.now.Add(time.Minute)
+	existing.Version++
+	existing.FirstName = client.FirstName
+	existing.LastName = client.LastName
+	existing.ContactEmail = client.ContactEmail
+	existing.PhoneNumber = client.PhoneNumber
+	existing.UpdatedAt = r.now
+	r.clients[client.UUID] = existing
+	*client = existing
+	return nil
+}
+
+func (r *memoryClientRepository) Delete(ctx context.Context, id uuid.UUID, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, _, ok := r.find(ctx, id)
+	if !ok {
+		return entities.ErrClientNotFound
+	}
+	if version != 0 && version != existing.Version {
+		return entities.ErrVersionMismatch
+	}
+	delete(r.clients, id)
+	return nil
+}
+
+func (r *memoryClientRepository) CreateProfileOnce(ctx context.Context, eventID string, client *entities.Client) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.processed[eventID] {
+		return false, nil
+	}
+	r.processed[eventID] = true
+	if _, ok := r.clients[client.UUID]; ok {
+		return true, nil
+	}
+	r.now = r.now.Add(time.Minute)
+	client.TenantID, _ = tenancy.TenantFromContext(ctx)
+	client.CreatedAt, client.UpdatedAt = r.now, r.now
+	client.Version = 1
+	r.clients[client.UUID] = *client
+	return true, nil
+}
+
+func (r *memoryClientRepository) DeleteProfileOnce(ctx context.Context, eventID string, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.processed[eventID] {
+		return false, nil
+	}
+	r.processed[eventID] = true
+	if _, _, ok := r.find(ctx, id); ok {
+		delete(r.clients, id)
+	}
+	return true, nil
+}
+
+// clientCaller is the authenticated user of the requests of a test
+type clientCaller struct {
+	subject uuid.UUID
+	tenant  string
+	roles   []string
+}
+
+// newClientRouter serves the client routes of cmd/main.go for the caller, without Temporal
+func newClientRouter(repo *memoryClientRepository, caller *clientCaller) *gin.Engine {
+	return newWorkflowRouter(services.NewClientService(repo), nil, caller)
+}
+
+// newWorkflowRouter serves the client and operation routes of cmd/main.go for
+// the caller, with the middlewares run after authentication on the client and
+// operation routes
+func newWorkflowRouter(service in.ClientService, workflows out.ClientWorkflows, caller *clientCaller, middlewares ...gin.HandlerFunc) *gin.Engine {
+	return newHandlerRouter(handlers.NewClientHandler(service, workflows), workflows, caller, middlewares...)
+}
+
+// newHandlerRouter is newWorkflowRouter for an already configured client handler
+func newHandlerRouter(handler *handlers.ClientHandler, workflows out.ClientWorkflows, caller *clientCaller, middlewares ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	operationHandler := handlers.NewOperationHandler(workflows)
+
+	authenticate := func(c *gin.Context) {
+		c.Set(handlers.PrincipalKey, &entities.Principal{Subject: caller.subject, Tenant: caller.tenant, Roles: caller.roles})
+		c.Request = c.Request.WithContext(tenancy.WithTenant(c.Request.Context(), caller.tenant))
+		c.Next()
+	}
+
+	router := gin.New()
+	operations := router.Group(handlers.OperationsPath)
+	operations.Use(authenticate)
+	operations.Use(middlewares...)
+	operations.GET("/:id", operationHandler.GetOperation)
+	clients := router.Group("/api/v1/clients")
+	clients.Use(authenticate)
+	clients.Use(middlewares...)
+	clients.GET("", handler.ListClients)
+	clients.POST("", handler.CreateClient)
+	clients.GET("/me", handler.GetClient)
+	clients.POST("/me", handler.AddClient)
+	clients.PUT("/me", handler.AddClient)
+	clients.GET("/:id", handler.GetClientByID)
+	clients.PUT("/:id", handler.UpdateClient)
+	clients.PATCH("/:id", handler.PatchClient)
+	clients.DELETE("/:id", handler.DeleteClient)
+	return router
+}
+
+func serveClients(router http.Handler, method, target string, body interface{}) *httptest.ResponseRecorder {
+	return serveClientsWithHeaders(router, method, target, body, nil)
+}
+
+func serveClientsWithHeaders(router http.Handler, method, target string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	var reader bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&reader).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func clientBody(firstName, lastName string) map[string]string {
+	return map[string]string{
+		"firstName":    firstName,
+		"lastName":     lastName,
+		"contactEmail": strings.ToLower(firstName) + "@example.com",
+		"phoneNumber":  "+33612345678",
+	}
+}
+
+func createClient(t *testing.T, router http.Handler, firstName, lastName string) entities.Client {
+	rec := serveClients(router, http.MethodPost, "/api/v1/clients", clientBody(firstName, lastName))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var client entities.Client
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&client))
+	assert.Equal(t, "/api/v1/clients/"+client.UUID.String(), rec.Header().Get("Location"))
+	return client
+}
+
+func listClients(t *testing.T, router http.Handler, params url.Values) in.ClientList {
+	rec := serveClients(router, http.MethodGet, "/api/v1/clients?"+params.Encode(), nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var list in.ClientList
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&list))
+	return list
+}
+
+func firstNames(list in.ClientList) []string {
+	names := make([]string, 0, len(list.Clients))
+	for _, client := range list.Clients {
+		names = append(names, client.FirstName)
+	}
+	return names
+}
+
+// TestClients_CRUD tests creating, reading, updating and deleting a client
+func TestClients_CRUD(t *testing.T) {
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	router := newClientRouter(newMemoryClientRepository(), caller)
+
+	created := createClient(t, router, "Jane", "Doe")
+	assert.NotEqual(t, caller.subject, created.UUID)
+	assert.Equal(t, caller.subject, created.OwnerID)
+	assert.Equal(t, caller.tenant, created.TenantID)
+	path := "/api/v1/clients/" + created.UUID.String()
+
+	rec := serveClients(router, http.MethodGet, path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"firstName":"Jane"`)
+
+	rec = serveClients(router, http.MethodPut, path, clientBody("Janet", "Smith"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var updated entities.Client
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&updated))
+	assert.Equal(t, "Smith", updated.LastName)
+	assert.Equal(t, caller.subject, updated.OwnerID)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+	rec = serveClients(router, http.MethodPatch, path, map[string]string{"phoneNumber": "+33700000000"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var patched entities.Client
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&patched))
+	assert.Equal(t, "Janet", patched.FirstName)
+	assert.Equal(t, "+33700000000", patched.PhoneNumber)
+
+	rec = serveClients(router, http.MethodDelete, path, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		rec = serveClients(router, method, path, map[string]string{})
+		assert.Equal(t, http.StatusNotFound, rec.Code, method)
+	}
+	rec = serveClients(router, http.MethodPut, path, clientBody("Jane", "Doe"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// TestClients_Profile tests that the profile of the caller is the client keyed by its subject
+func TestClients_Profile(t *testing.T) {
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	router := newClientRouter(newMemoryClientRepository(), caller)
+
+	rec := serveClients(router, http.MethodGet, "/api/v1/clients/me", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"uuid":"`+testSubject+`"`)
+	assert.Contains(t, rec.Body.String(), `"firstName":""`)
+
+	rec = serveClients(router, http.MethodPut, "/api/v1/clients/me", clientBody("John", "Doe"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = serveClients(router, http.MethodGet, "/api/v1/clients/"+testSubject, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"ownerId":"`+testSubject+`"`)
+}
+
+// TestClients_List tests searching and paginating the clients of the organization
+func TestClients_List(t *testing.T) {
+	repo := newMemoryClientRepository()
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString(), roles: []string{handlers.AdminRole}}
+	router := newClientRouter(repo, caller)
+
+	assert.JSONEq(t, `{"clients": []}`,
+		serveClients(router, http.MethodGet, "/api/v1/clients", nil).Body.String())
+
+	for _, name := range []string{"Alice", "Bob", "Carol", "Dave", "Eve"} {
+		createClient(t, router, name, "Doe")
+	}
+	colleague := &clientCaller{subject: uuid.New(), tenant: caller.tenant}
+	createClient(t, newClientRouter(repo, colleague), "Albert", "Martin")
+	outsider := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	createClient(t, newClientRouter(repo, outsider), "Alan", "Smith")
+
+	assert.Equal(t, []string{"Albert", "Eve", "Dave", "Carol", "Bob", "Alice"}, firstNames(listClients(t, router, nil)))
+	assert.Equal(t, []string{"Albert", "Alice"}, firstNames(listClients(t, router, url.Values{"q": {"al"}})))
+	assert.Equal(t, []string{"Albert"}, firstNames(listClients(t, router, url.Values{"q": {"MARTIN"}})))
+	assert.Equal(t, []string{"Albert"}, firstNames(listClients(t, router, url.Values{"owner": {colleague.subject.String()}})))
+	assert.Len(t, listClients(t, router, url.Values{"owner": {"me"}}).Clients, 5)
+
+	params := url.Values{"limit": {"4"}}
+	var seen []string
+	for pages := 1; ; pages++ {
+		list := listClients(t, router, params)
+		seen = append(seen, firstNames(list)...)
+		if list.NextCursor == "" {
+			assert.Equal(t, 2, pages)
+			break
+		}
+		params.Set("cursor", list.NextCursor)
+	}
+	assert.Equal(t, []string{"Albert", "Eve", "Dave", "Carol", "Bob", "Alice"}, seen)
+
+	// Other users only list their own clients
+	colleagueRouter := newClientRouter(repo, colleague)
+	assert.Equal(t, []string{"Albert"}, firstNames(listClients(t, colleagueRouter, nil)))
+	assert.Equal(t, []string{"Albert"}, firstNames(listClients(t, colleagueRouter, url.Values{"owner": {"me"}})))
+	rec := serveClients(colleagueRouter, http.MethodGet, "/api/v1/clients?owner="+testSubject, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+// TestClients_Ownership tests that users only reach the clients they own,
+// and admins every client of their organization
+func TestClients_Ownership(t *testing.T) {
+	repo := newMemoryClientRepository()
+	owner := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	other := &clientCaller{subject: uuid.New(), tenant: owner.tenant}
+	admin := &clientCaller{subject: uuid.New(), tenant: owner.tenant, roles: []string{handlers.AdminRole}}
+
+	ownerRouter := newClientRouter(repo, owner)
+	client := createClient(t, ownerRouter, "Jane", "Doe")
+	require.Equal(t, http.StatusOK, serveClients(ownerRouter, http.MethodPut, "/api/v1/clients/me", clientBody("John", "Doe")).Code)
+
+	otherRouter := newClientRouter(repo, other)
+	for _, path := range []string{"/api/v1/clients/" + client.UUID.String(), "/api/v1/clients/" + owner.subject.String()} {
+		t.Run("NonOwner"+path, func(t *testing.T) {
+			assert.Equal(t, http.StatusForbidden, serveClients(otherRouter, http.MethodGet, path, nil).Code)
+			assert.Equal(t, http.StatusForbidden, serveClients(otherRouter, http.MethodPut, path, clientBody("Eve", "Doe")).Code)
+			assert.Equal(t, http.StatusForbidden, serveClients(otherRouter, http.MethodPatch, path, map[string]string{"firstName": "Eve"}).Code)
+			assert.Equal(t, http.StatusForbidden, serveClients(otherRouter, http.MethodDelete, path, nil).Code)
+		})
+	}
+
+	// Nothing was changed by the denied requests
+	rec := serveClients(ownerRouter, http.MethodGet, "/api/v1/clients/"+client.UUID.String(), nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got entities.Client
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, "Jane", got.FirstName)
+	assert.Equal(t, client.Version, got.Version)
+
+	adminRouter := newClientRouter(repo, admin)
+	path := "/api/v1/clients/" + client.UUID.String()
+	assert.Equal(t, http.StatusOK, serveClients(adminRouter, http.MethodGet, path, nil).Code)
+	assert.Equal(t, http.StatusOK, serveClients(adminRouter, http.MethodPatch, path, map[string]string{"firstName": "Janet"}).Code)
+	assert.Equal(t, http.StatusNoContent, serveClients(adminRouter, http.MethodDelete, path, nil).Code)
+}
+
+// TestClients_InvalidRequests tests that malformed requests are rejected
+func TestClients_InvalidRequests(t *testing.T) {
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	router := newClientRouter(newMemoryClientRepository(), caller)
+	created := createClient(t, router, "Jane", "Doe")
+	path := "/api/v1/clients/" + created.UUID.String()
+
+	invalidEmail := clientBody("Jane", "Doe")
+	invalidEmail["contactEmail"] = "not-an-email"
+
+	for name, req := range map[string]struct {
+		method, target string
+		body           interface{}
+	}{
+		"CreateMissingField": {http.MethodPost, "/api/v1/clients", map[string]string{"firstName": "Jane"}},
+		"CreateInvalidEmail": {http.MethodPost, "/api/v1/clients", invalidEmail},
+		"InvalidID":          {http.MethodGet, "/api/v1/clients/not-a-uuid", nil},
+		"PutInvalidEmail":    {http.MethodPut, path, invalidEmail},
+		"PatchEmptyName":     {http.MethodPatch, path, map[string]string{"firstName": ""}},
+		"ListOwner":          {http.MethodGet, "/api/v1/clients?owner=someone", nil},
+		"ListLimit":          {http.MethodGet, "/api/v1/clients?limit=0", nil},
+		"ListLimitTooLarge":  {http.MethodGet, "/api/v1/clients?limit=201", nil},
+		"ListCursor":         {http.MethodGet, "/api/v1/clients?cursor=not-a-cursor", nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := serveClients(router, req.method, req.target, req.body)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/repositories"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/mocks"
+	sdktemporal "go.temporal.io/sdk/temporal"
+)
+
+func ifMatch(tag string) map[string]string {
+	return map[string]string{"If-Match": tag}
+}
+
+// TestClients_Concurrency tests that ETag and If-Match guard writes against lost updates
+func TestClients_Concurrency(t *testing.T) {
+	caller := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	router := newClientRouter(newMemoryClientRepository(), caller)
+
+	client := createClient(t, router, "Jane", "Doe")
+	target := "/api/v1/clients/" + client.UUID.String()
+
+	t.Run("ETag", func(t *testing.T) {
+		rec := serveClients(router, http.MethodGet, target, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("MatchingVersion", func(t *testing.T) {
+		rec := serveClientsWithHeaders(router, http.MethodPut, target, clientBody("Janet", "Doe"), ifMatch(`"1"`))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("StaleVersion", func(t *testing.T) {
+		rec := serveClientsWithHeaders(router, http.MethodPut, target, clientBody("Jenny", "Doe"), ifMatch(`"1"`))
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		rec = serveClientsWithHeaders(router, http.MethodPatch, target, map[string]string{"lastName": "Roe"}, ifMatch(`"1"`))
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		rec = serveClientsWithHeaders(router, http.MethodDelete, target, nil, ifMatch(`"1"`))
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+		rec = serveClients(router, http.MethodGet, target, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Janet")
+	})
+
+	t.Run("InvalidTag", func(t *testing.T) {
+		rec := serveClientsWithHeaders(router, http.MethodPatch, target, map[string]string{"lastName": "Roe"}, ifMatch(`W/"2"`))
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	})
+
+	t.Run("AnyVersion", func(t *testing.T) {
+		rec := serveClientsWithHeaders(router, http.MethodPatch, target, map[string]string{"lastName": "Roe"}, ifMatch("*"))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	})
+
+	t.Run("OwnProfile", func(t *testing.T) {
+		rec := serveClientsWithHeaders(router, http.MethodPut, "/api/v1/clients/me", clientBody("Jane", "Doe"), nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+		rec = serveClientsWithHeaders(router, http.MethodPut, "/api/v1/clients/me", clientBody("Janet", "Doe"), ifMatch(`"5"`))
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	})
+
+	t.Run("RequiredIfMatch", func(t *testing.T) {
+		handler := handlers.NewClientHandler(services.NewClientService(newMemoryClientRepository()), nil)
+		handler.RequireIfMatch(true)
+		strict := newHandlerRouter(handler, nil, caller)
+
+		created := createClient(t, strict, "Jane", "Doe")
+		target := "/api/v1/clients/" + created.UUID.String()
+
+		rec := serveClients(strict, http.MethodPut, target, clientBody("Janet", "Doe"))
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+		rec = serveClients(strict, http.MethodDelete, target, nil)
+		assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+
+		rec = serveClientsWithHeaders(strict, http.MethodDelete, target, nil, ifMatch(`"1"`))
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+}
+
+// TestTemporalClient_AddClientVersionMismatch tests that a stale save in the workflow is reported as a version mismatch
+func TestTemporalClient_AddClientVersionMismatch(t *testing.T) {
+	ctx := tenancy.WithTenant(context.Background(), uuid.NewString())
+	client := entities.NewClient(uuid.New(), "Jane", "Doe", "jane@example.com", "+33612345678")
+	client.Version = 3
+
+	run := &mocks.WorkflowRun{}
+	run.On("Get", mock.Anything, mock.Anything).
+		Return(sdktemporal.NewNonRetryableApplicationError("client has changed since it was read", temporal.VersionMismatchError, nil))
+	temporalClient := &mocks.Client{}
+	temporalClient.On("ExecuteWorkflow", mock.Anything, mock.Anything, "AddClientWorkflow", client).Return(run, nil)
+
+	_, err := temporal.NewTemporalClientFromClient(temporalClient, "default", "queue").AddClient(ctx, client)
+	assert.ErrorIs(t, err, entities.ErrVersionMismatch)
+}
+
+// TestClientRepository_Version tests that only one of two writers holding the same version succeeds
+func TestClientRepository_Version(t *testing.T) {
+	db := setupRLSDatabase(t)
+	repo := repositories.NewClientRepository(db)
+	ctx := tenancy.WithTenant(context.Background(), uuid.NewString())
+
+	client := entities.NewClient(uuid.New(), "Jane", "Doe", "jane.doe@example.com", "+33612345678")
+	require.NoError(t, repo.Save(ctx, client))
+	assert.Equal(t, int64(1), client.Version)
+	t.Cleanup(func() { repo.Delete(ctx, client.UUID, 0) })
+
+	first := *client
+	first.FirstName = "Janet"
+	require.NoError(t, repo.Update(ctx, &first))
+	assert.Equal(t, int64(2), first.Version)
+
+	second := *client
+	second.FirstName = "Jenny"
+	assert.ErrorIs(t, repo.Update(ctx, &second), entities.ErrVersionMismatch)
+	assert.ErrorIs(t, repo.Save(ctx, &second), entities.ErrVersionMismatch)
+	assert.ErrorIs(t, repo.Delete(ctx, client.UUID, 1), entities.ErrVersionMismatch)
+
+	found, err := repo.FindByID(ctx, client.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "Janet", found.FirstName)
+	assert.Equal(t, int64(2), found.Version)
+
+	require.NoError(t, repo.Delete(ctx, client.UUID, 2))
+	assert.ErrorIs(t, repo.Delete(ctx, client.UUID, 2), entities.ErrClientNotFound)
+}
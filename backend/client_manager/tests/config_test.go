@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/config"
+	platformconfig "github.com/b-fontaine/saaster_kit/backend/platform/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConfig_Load tests that the database password has no default and that
+// the problems of the configuration are reported at once
+func TestConfig_Load(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_PASSWORD", "")
+	t.Setenv("AUTH_MODE", "introspection")
+	t.Setenv("IDEMPOTENCY_TTL", "one day")
+
+	_, err := config.Load()
+	assert.EqualError(t, err, `invalid configuration:
+  - idempotency.ttl (IDEMPOTENCY_TTL): invalid value "one day": time: invalid duration "one day"
+  - database.password (DB_PASSWORD): is required
+  - auth.clientSecret (KEYCLOAK_CLIENT_SECRET): is required by the introspection mode`)
+
+	t.Setenv("DB_PASSWORD", "s3cr3t")
+	t.Setenv("AUTH_MODE", "jwks")
+	t.Setenv("IDEMPOTENCY_TTL", "1h")
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", cfg.Database.Password)
+	// Existing clients are not required to send If-Match until opted in
+	assert.False(t, cfg.Server.RequireIfMatch)
+	// Synchronous creations answer with their operation before the lease runs out
+	assert.Less(t, cfg.Server.SyncWait, cfg.Idempotency.Lease)
+
+	t.Setenv("SERVER_SYNC_WAIT", "1m")
+	_, err = config.Load()
+	assert.EqualError(t, err, `invalid configuration:
+  - server.syncWait (SERVER_SYNC_WAIT): must be positive and below idempotency.lease (IDEMPOTENCY_LEASE)
+  - server.writeTimeout (SERVER_WRITE_TIMEOUT): must exceed server.syncWait (SERVER_SYNC_WAIT) and the 30s long poll of operations`)
+
+	// The secrets are redacted from the printed configuration
+	var buf bytes.Buffer
+	require.NoError(t, platformconfig.Print(&buf, cfg))
+	assert.Contains(t, buf.String(), "password: '********' # DB_PASSWORD")
+	assert.Contains(t, buf.String(), "ttl: 1h0m0s # IDEMPOTENCY_TTL")
+	assert.NotContains(t, buf.String(), "s3cr3t")
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/health"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newProbeRouter serves the probes of registry
+func newProbeRouter(registry *health.Registry) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(health.LivePath, gin.WrapH(registry.LiveHandler()))
+	router.GET(health.ReadyPath, gin.WrapH(registry.ReadyHandler()))
+	return router
+}
+
+// probe calls a probe and decodes its report
+func probe(t *testing.T, router *gin.Engine, path string) (int, health.Report) {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	var report health.Report
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	return rec.Code, report
+}
+
+// TestHealth_Probes tests that readiness fails while Temporal is not
+// connected, or while a dependency does not answer in time, and that
+// liveness does not depend on them
+func TestHealth_Probes(t *testing.T) {
+	registry := health.NewRegistry(health.Config{Timeout: time.Second})
+	registry.Register("postgres", func(ctx context.Context) error { return nil })
+	registry.Register("temporal", health.Unavailable(errors.New("not connected")))
+	registry.Register("dapr", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, health.WithTimeout(10*time.Millisecond))
+	router := newProbeRouter(registry)
+
+	status, report := probe(t, router, health.ReadyPath)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.StatusDown, report.Status)
+	require.Len(t, report.Checks, 3)
+	assert.Equal(t, "postgres", report.Checks[0].Name)
+	assert.Equal(t, health.StatusUp, report.Checks[0].Status)
+	assert.Empty(t, report.Checks[0].Error)
+	assert.Equal(t, "temporal", report.Checks[1].Name)
+	assert.Equal(t, health.StatusDown, report.Checks[1].Status)
+	assert.Equal(t, "not connected", report.Checks[1].Error)
+	assert.Equal(t, "dapr", report.Checks[2].Name)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[2].Error)
+
+	status, report = probe(t, router, health.LivePath)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Empty(t, report.Checks)
+}
+
+// TestHealth_Cache tests that the checks run again only once the last report
+// expired
+func TestHealth_Cache(t *testing.T) {
+	var calls atomic.Int32
+	registry := health.NewRegistry(health.Config{Timeout: time.Second, CacheTTL: 50 * time.Millisecond})
+	registry.Register("postgres", func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+	router := newProbeRouter(registry)
+
+	for i := 0; i < 3; i++ {
+		status, report := probe(t, router, health.ReadyPath)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, health.StatusUp, report.Status)
+	}
+	assert.EqualValues(t, 1, calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	registry.Ready(context.Background())
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+// TestHealth_DaprSidecar tests the check of the Dapr sidecar
+func TestHealth_DaprSidecar(t *testing.T) {
+	var daprToken string
+	sidecar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.0/metadata" {
+			http.NotFound(w, r)
+			return
+		}
+		daprToken = r.Header.Get("dapr-api-token")
+		w.Write([]byte(`{"id":"client-manager"}`))
+	}))
+	defer sidecar.Close()
+
+	assert.NoError(t, health.DaprSidecar(sidecar.Client(), sidecar.URL, "token")(context.Background()))
+	assert.Equal(t, "token", daprToken)
+
+	sidecar.Close()
+	assert.Error(t, health.DaprSidecar(sidecar.Client(), sidecar.URL, "token")(context.Background()))
+}
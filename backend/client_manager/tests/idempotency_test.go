@@ -0,0 +1,269 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/b-fontaine/saaster_kit/backend/platform/idempotency"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryIdempotencyStore is an in-memory stand-in for the Postgres and Dapr idempotency stores
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]out.IdempotencyRecord
+	now     func() time.Time
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: map[string]out.IdempotencyRecord{}, now: time.Now}
+}
+
+func (s *memoryIdempotencyStore) Reserve(ctx context.Context, record *out.IdempotencyRecord) (*out.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.records[record.Key]; ok && s.now().Before(existing.ExpiresAt) {
+		return &existing, nil
+	}
+	s.records[record.Key] = *record
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, record *out.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.holds(record) {
+		return idempotency.ErrReservationLost
+	}
+	s.records[record.Key] = *record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, record *out.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.holds(record) {
+		delete(s.records, record.Key)
+	}
+	return nil
+}
+
+// holds reports whether the key of record is reserved with its token
+func (s *memoryIdempotencyStore) holds(record *out.IdempotencyRecord) bool {
+	existing, ok := s.records[record.Key]
+	return ok && existing.Token == record.Token && !existing.Completed
+}
+
+func serveWithIdempotencyKey(router http.Handler, method, target, key string, body interface{}, headers map[string]string) *http.Response {
+	all := map[string]string{handlers.IdempotencyKeyHeader: key}
+	for name, value := range headers {
+		all[name] = value
+	}
+	return serveClientsWithHeaders(router, method, target, body, all).Result()
+}
+
+func countClients(t *testing.T, router http.Handler) int {
+	return len(listClients(t, router, nil).Clients)
+}
+
+// TestClients_Idempotency tests that retried requests are answered with the first response
+func TestClients_Idempotency(t *testing.T) {
+	repo := newMemoryClientRepository()
+	store := newMemoryIdempotencyStore()
+	caller := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	router := newWorkflowRouter(services.NewClientService(repo), nil, caller, handlers.Idempotency(store, time.Hour, time.Minute))
+
+	first := serveWithIdempotencyKey(router, http.MethodPost, "/api/v1/clients", "create-jane", clientBody("Jane", "Doe"), nil)
+	require.Equal(t, http.StatusCreated, first.StatusCode)
+	assert.Empty(t, first.Header.Get(handlers.IdempotentReplayedHeader))
+
+	retry := serveWithIdempotencyKey(router, http.MethodPost, "/api/v1/clients", "create-jane", clientBody("Jane", "Doe"), nil)
+	require.Equal(t, http.StatusCreated, retry.StatusCode)
+	assert.Equal(t, "true", retry.Header.Get(handlers.IdempotentReplayedHeader))
+	assert.Equal(t, first.Header.Get("Location"), retry.Header.Get("Location"))
+	assert.Equal(t, 1, countClients(t, router))
+
+	var created, replayed entities.Client
+	require.NoError(t, json.NewDecoder(first.Body).Decode(&created))
+	require.NoError(t, json.NewDecoder(retry.Body).Decode(&replayed))
+	assert.Equal(t, created.UUID, replayed.UUID)
+
+	t.Run("OtherPayload", func(t *testing.T) {
+		rec := serveWithIdempotencyKey(router, http.MethodPost, "/api/v1/clients", "create-jane", clientBody("John", "Doe"), nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.StatusCode)
+		assert.Equal(t, 1, countClients(t, router))
+	})
+
+	t.Run("OtherCaller", func(t *testing.T) {
+		other := &clientCaller{subject: uuid.New(), tenant: caller.tenant}
+		otherRouter := newWorkflowRouter(services.NewClientService(repo), nil, other, handlers.Idempotency(store, time.Hour, time.Minute))
+		rec := serveWithIdempotencyKey(otherRouter, http.MethodPost, "/api/v1/clients", "create-jane", clientBody("Jane", "Doe"), nil)
+		assert.Equal(t, http.StatusCreated, rec.StatusCode)
+		assert.Empty(t, rec.Header.Get(handlers.IdempotentReplayedHeader))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		target := "/api/v1/clients/" + created.UUID.String()
+		rec := serveWithIdempotencyKey(router, http.MethodDelete, target, "delete-jane", nil, nil)
+		assert.Equal(t, http.StatusNoContent, rec.StatusCode)
+
+		rec = serveWithIdempotencyKey(router, http.MethodDelete, target, "delete-jane", nil, nil)
+		assert.Equal(t, http.StatusNoContent, rec.StatusCode)
+		assert.Equal(t, "true", rec.Header.Get(handlers.IdempotentReplayedHeader))
+	})
+
+	t.Run("KeyTooLong", func(t *testing.T) {
+		key := strings.Repeat("k", handlers.MaxIdempotencyKeyLength+1)
+		rec := serveWithIdempotencyKey(router, http.MethodPost, "/api/v1/clients", key, clientBody("Jane", "Doe"), nil)
+		assert.Equal(t, http.StatusBadRequest, rec.StatusCode)
+	})
+}
+
+// TestClients_IdempotentAsyncCreate tests that a retried asynchronous creation does not start another workflow
+func TestClients_IdempotentAsyncCreate(t *testing.T) {
+	service := services.NewClientService(newMemoryClientRepository())
+	workflows := newWorkflowStandIn(service)
+	caller := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	router := newWorkflowRouter(service, workflows, caller, handlers.Idempotency(newMemoryIdempotencyStore(), time.Hour, time.Minute))
+	async := map[string]string{"Prefer": "respond-async"}
+
+	first := serveWithIdempotencyKey(router, http.MethodPost, "/api/v1/clients", "create-jane", clientBody("Jane", "Doe"), async)
+	require.Equal(t, http.StatusAccepted, first.StatusCode)
+	retry := serveWithIdempotencyKey(router, http.MethodPost, "/api/v1/clients", "create-jane", clientBody("Jane", "Doe"), async)
+	require.Equal(t, http.StatusAccepted, retry.StatusCode)
+
+	assert.Equal(t, first.Header.Get("Location"), retry.Header.Get("Location"))
+	assert.Equal(t, "respond-async", retry.Header.Get("Preference-Applied"))
+	assert.Len(t, workflows.operations, 1)
+}
+
+// unreleasedStore forgets to release the keys, as a request that crashed
+// before it could
+type unreleasedStore struct {
+	out.IdempotencyStore
+}
+
+func (s unreleasedStore) Release(ctx context.Context, record *out.IdempotencyRecord) error {
+	return nil
+}
+
+// TestClients_IdempotencyLease tests that the key of a request that crashed
+// is only held for the lease, while its response is kept for the TTL
+func TestClients_IdempotencyLease(t *testing.T) {
+	now := time.Now()
+	store := newMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+	calls := 0
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/things", handlers.Idempotency(unreleasedStore{store}, time.Hour, time.Minute), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusCreated, "call %d", calls)
+	})
+
+	rec := serveWithIdempotencyKey(router, http.MethodPost, "/things", "key", nil, nil)
+	assert.Equal(t, http.StatusInternalServerError, rec.StatusCode)
+	rec = serveWithIdempotencyKey(router, http.MethodPost, "/things", "key", nil, nil)
+	assert.Equal(t, http.StatusConflict, rec.StatusCode)
+
+	now = now.Add(2 * time.Minute)
+	rec = serveWithIdempotencyKey(router, http.MethodPost, "/things", "key", nil, nil)
+	assert.Equal(t, http.StatusCreated, rec.StatusCode)
+
+	now = now.Add(30 * time.Minute)
+	rec = serveWithIdempotencyKey(router, http.MethodPost, "/things", "key", nil, nil)
+	assert.Equal(t, http.StatusCreated, rec.StatusCode)
+	assert.Equal(t, "true", rec.Header.Get(handlers.IdempotentReplayedHeader))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "call 2", string(body))
+}
+
+// TestIdempotencyStore_Postgres tests that keys are reserved once, completed, released and taken over once expired
+func TestIdempotencyStore_Postgres(t *testing.T) {
+	db := setupRLSDatabase(t)
+	store := idempotency.NewPostgresStore(db)
+	ctx := context.Background()
+
+	key := uuid.NewString()
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM idempotency_keys WHERE key = $1", key)
+		assert.NoError(t, err)
+	})
+
+	record := &out.IdempotencyRecord{Key: key, RequestHash: "hash", Token: "first", ExpiresAt: time.Now().Add(time.Hour)}
+	existing, err := store.Reserve(ctx, record)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	existing, err = store.Reserve(ctx, &out.IdempotencyRecord{Key: key, RequestHash: "other", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.False(t, existing.Completed)
+
+	record.Completed, record.StatusCode, record.Body = true, http.StatusCreated, []byte(`{"uuid":"1"}`)
+	record.Header = map[string]string{"Location": "/api/v1/clients/1"}
+	require.NoError(t, store.Complete(ctx, record))
+
+	// Completed keys are not released
+	require.NoError(t, store.Release(ctx, record))
+	existing, err = store.Reserve(ctx, &out.IdempotencyRecord{Key: key, RequestHash: "hash", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, http.StatusCreated, existing.StatusCode)
+	assert.Equal(t, "/api/v1/clients/1", existing.Header["Location"])
+	assert.Equal(t, `{"uuid":"1"}`, string(existing.Body))
+
+	// Expired keys are taken over
+	_, err = db.Exec("UPDATE idempotency_keys SET expires_at = now() - interval '1 minute' WHERE key = $1", key)
+	require.NoError(t, err)
+	existing, err = store.Reserve(ctx, &out.IdempotencyRecord{Key: key, RequestHash: "new", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// So are the reservations whose lease ran out, left by a request that
+	// crashed before it could release them
+	crashed := &out.IdempotencyRecord{Key: uuid.NewString(), RequestHash: "hash", Token: "first", ExpiresAt: time.Now().Add(-time.Minute)}
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM idempotency_keys WHERE key = $1", crashed.Key)
+		assert.NoError(t, err)
+	})
+	existing, err = store.Reserve(ctx, crashed)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+	existing, err = store.Reserve(ctx, &out.IdempotencyRecord{Key: crashed.Key, RequestHash: "hash", Token: "second", ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// The request that lost its reservation can neither complete nor
+	// release it
+	crashed.Completed, crashed.StatusCode = true, http.StatusCreated
+	assert.ErrorIs(t, store.Complete(ctx, crashed), idempotency.ErrReservationLost)
+	require.NoError(t, store.Release(ctx, crashed))
+	existing, err = store.Reserve(ctx, &out.IdempotencyRecord{Key: crashed.Key, RequestHash: "hash", Token: "third", ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "second", existing.Token)
+	assert.False(t, existing.Completed)
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/logging"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/workflows"
+	platformlogging "github.com/b-fontaine/saaster_kit/backend/platform/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+)
+
+// principalVerifier accepts any token as the principal
+type principalVerifier struct {
+	principal *entities.Principal
+}
+
+func (v principalVerifier) Verify(ctx context.Context, token string) (*entities.Principal, error) {
+	return v.principal, nil
+}
+
+// captureLogs makes the default logger write JSON records to the returned
+// buffer for the duration of the test
+func captureLogs(t *testing.T) (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger, err := platformlogging.New(platformlogging.Config{Level: "debug", Format: "json"}, "client-manager", &buf)
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return logger, &buf
+}
+
+// findLogRecord returns the first JSON record of buf with the given message
+func findLogRecord(t *testing.T, buf *bytes.Buffer, message string) map[string]interface{} {
+	decoder := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for decoder.More() {
+		var record map[string]interface{}
+		require.NoError(t, decoder.Decode(&record))
+		if record["message"] == message {
+			return record
+		}
+	}
+	return nil
+}
+
+// TestLogging_RequestID tests that requests are given an ID, echoed in the
+// response and logged with the request and its caller
+func TestLogging_RequestID(t *testing.T) {
+	_, buf := captureLogs(t)
+	caller := &entities.Principal{Subject: uuid.MustParse(testSubject), Tenant: uuid.NewString()}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(logging.Middleware(), handlers.KeycloakAuthMiddleware(principalVerifier{caller}))
+	router.GET("/api/v1/clients/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	get := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/clients/"+uuid.NewString(), nil)
+		req.Header.Set("Authorization", "Bearer token")
+		if requestID != "" {
+			req.Header.Set(platformlogging.RequestIDHeader, requestID)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The ID of the caller is kept, missing and unsafe IDs are replaced
+	assert.Equal(t, "req-1", get("req-1").Header().Get(platformlogging.RequestIDHeader))
+	_, err := uuid.Parse(get("").Header().Get(platformlogging.RequestIDHeader))
+	assert.NoError(t, err)
+	assert.NotEqual(t, "bad id\n", get("bad id\n").Header().Get(platformlogging.RequestIDHeader))
+
+	record := findLogRecord(t, buf, "request completed")
+	require.NotNil(t, record)
+	assert.Equal(t, "req-1", record[platformlogging.RequestIDKey])
+	assert.Equal(t, caller.Tenant, record[platformlogging.TenantIDKey])
+	assert.Equal(t, testSubject, record[platformlogging.UserIDKey])
+	assert.Equal(t, "/api/v1/clients/:id", record["http.route"])
+	assert.Equal(t, "info", record["log.level"])
+	assert.Equal(t, "client-manager", record["service.name"])
+}
+
+// TestLogging_Workflow tests that the logs of a workflow and of its
+// activities carry the correlation of the request that started it
+func TestLogging_Workflow(t *testing.T) {
+	logger, buf := captureLogs(t)
+	tenantID := uuid.NewString()
+
+	// The headers the propagators of the client write for the request
+	ctx := platformlogging.WithUserID(platformlogging.WithRequestID(tenancy.WithTenant(context.Background(), tenantID), "req-1"), testSubject)
+	header := &commonpb.Header{Fields: map[string]*commonpb.Payload{}}
+	for _, propagator := range temporal.ContextPropagators() {
+		require.NoError(t, propagator.Inject(ctx, headerWriter{header}))
+	}
+
+	var suite testsuite.WorkflowTestSuite
+	suite.SetLogger(platformlogging.TemporalLogger(logger))
+	suite.SetContextPropagators(temporal.ContextPropagators())
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{platformlogging.TemporalInterceptor()}})
+	env.SetHeader(header)
+
+	activities := workflows.NewClientActivity(services.NewClientService(newMemoryClientRepository()))
+	env.RegisterActivityWithOptions(activities.AddClientActivity, activity.RegisterOptions{Name: "AddClientActivity"})
+	env.ExecuteWorkflow(workflows.AddClientWorkflow, entities.NewOwnedClient(uuid.New(), "Jane", "Doe", "jane.doe@example.com", "+33612345678"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	for _, message := range []string{"AddClientWorkflow started", "AddClientActivity started"} {
+		record := findLogRecord(t, buf, message)
+		require.NotNil(t, record, message)
+		assert.Equal(t, "req-1", record[platformlogging.RequestIDKey], message)
+		assert.Equal(t, tenantID, record[platformlogging.TenantIDKey], message)
+		assert.Equal(t, testSubject, record[platformlogging.UserIDKey], message)
+	}
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/metrics"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClients_Metrics(t *testing.T) {
+	m := metrics.New()
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	service := services.NewClientService(m.ClientRepository(newMemoryClientRepository()))
+	router := newWorkflowRouter(service, nil, caller, m.Middleware())
+	router.GET(metrics.Path, m.Handler())
+
+	client := createClient(t, router, "Jane", "Doe")
+	missing := uuid.NewString()
+	serveClients(router, http.MethodGet, "/api/v1/clients/"+client.UUID.String(), nil)
+	serveClients(router, http.MethodGet, "/api/v1/clients/"+missing, nil)
+
+	rec := serveClients(router, http.MethodGet, metrics.Path, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+
+	// Requests are labelled with the route template, not the path
+	assert.Contains(t, body, `client_manager_http_requests_total{method="POST",route="/api/v1/clients",status="201"} 1`)
+	assert.Contains(t, body, `client_manager_http_requests_total{method="GET",route="/api/v1/clients/:id",status="200"} 1`)
+	assert.Contains(t, body, `client_manager_http_requests_total{method="GET",route="/api/v1/clients/:id",status="404"} 1`)
+	assert.NotContains(t, body, missing)
+
+	// Repository operations are timed by operation and outcome
+	assert.Contains(t, body, `client_manager_repository_operation_duration_seconds_count{operation="save",outcome="ok",repository="client"} 1`)
+	assert.Contains(t, body, `client_manager_repository_operation_duration_seconds_count{operation="find_by_id",outcome="ok",repository="client"} 2`)
+}
+
+func TestTemporalClient_Metrics(t *testing.T) {
+	m := metrics.New()
+	handler := m.TemporalHandler().WithTags(map[string]string{
+		"activity_type": "AddClientActivity",
+		"unknown_tag":   "dropped",
+	})
+	handler.Counter("temporal_activity_execution_failed").Inc(1)
+	handler.Timer("temporal_activity_execution_latency").Record(20 * time.Millisecond)
+
+	router := newClientRouter(newMemoryClientRepository(), &clientCaller{subject: uuid.MustParse(testSubject)})
+	router.GET(metrics.Path, m.Handler())
+	body := serveClients(router, http.MethodGet, metrics.Path, nil).Body.String()
+
+	assert.Contains(t, body, `temporal_activity_execution_failed_total{activity_type="AddClientActivity",cause="",client_name="",failure_reason="",namespace="",operation="",poller_type="",task_queue="",worker_type="",workflow_type=""} 1`)
+	assert.Contains(t, body, `temporal_activity_execution_latency_seconds_count{activity_type="AddClientActivity"`)
+	assert.NotContains(t, body, "unknown_tag")
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newValidatedRouter is newWorkflowRouter with the requests and responses
+// validated against the OpenAPI document; responses that do not match it
+// are turned into 500 problems
+func newValidatedRouter(t *testing.T, repo *memoryClientRepository, workflows *workflowStandIn, caller *clientCaller) *gin.Engine {
+	validator, err := handlers.NewOpenAPIValidator(context.Background())
+	require.NoError(t, err)
+	return newWorkflowRouter(services.NewClientService(repo), workflows, caller, validator.Middleware())
+}
+
+// ginParam matches the path parameters of gin routes, such as :id
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+// TestClients_OpenAPIDocument tests that the served document is a valid OpenAPI 3.1 document
+func TestClients_OpenAPIDocument(t *testing.T) {
+	doc, err := handlers.LoadOpenAPI(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(handlers.OpenAPIPath, handlers.OpenAPI)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, handlers.OpenAPIPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var served map[string]interface{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&served))
+	assert.Equal(t, "3.1.0", served["openapi"])
+}
+
+// TestClients_OpenAPIRoutes tests that the document describes exactly the client and operation routes
+func TestClients_OpenAPIRoutes(t *testing.T) {
+	doc, err := handlers.LoadOpenAPI(context.Background())
+	require.NoError(t, err)
+	router := newClientRouter(newMemoryClientRepository(), &clientCaller{subject: uuid.New()})
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		registered[route.Method+" "+path] = true
+		pathItem := doc.Paths.Value(path)
+		if assert.NotNil(t, pathItem, "%s is not documented", path) {
+			assert.NotNil(t, pathItem.GetOperation(route.Method), "%s %s is not documented", route.Method, path)
+		}
+	}
+
+	for path, pathItem := range doc.Paths.Map() {
+		if !strings.HasPrefix(path, "/api/v1/clients") && !strings.HasPrefix(path, handlers.OperationsPath) {
+			continue
+		}
+		for method := range pathItem.Operations() {
+			assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", method, path)
+		}
+	}
+}
+
+// TestClients_OpenAPIConformance tests that the responses of every operation match the document
+func TestClients_OpenAPIConformance(t *testing.T) {
+	caller := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	repo := newMemoryClientRepository()
+	workflows := newWorkflowStandIn(services.NewClientService(repo))
+	router := newValidatedRouter(t, repo, workflows, caller)
+
+	client := createClient(t, router, "Jane", "Doe")
+	clientPath := "/api/v1/clients/" + client.UUID.String()
+
+	for _, step := range []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		body    interface{}
+		status  int
+	}{
+		{"List", http.MethodGet, "/api/v1/clients?owner=me&limit=1", nil, nil, http.StatusOK},
+		{"ListInvalid", http.MethodGet, "/api/v1/clients?cursor=garbage", nil, nil, http.StatusBadRequest},
+		{"Get", http.MethodGet, clientPath, nil, nil, http.StatusOK},
+		{"GetMissing", http.MethodGet, "/api/v1/clients/" + uuid.NewString(), nil, nil, http.StatusNotFound},
+		{"Update", http.MethodPut, clientPath, ifMatch(`"1"`), clientBody("Janet", "Doe"), http.StatusOK},
+		{"UpdateStale", http.MethodPut, clientPath, ifMatch(`"1"`), clientBody("Jenny", "Doe"), http.StatusPreconditionFailed},
+		{"Patch", http.MethodPatch, clientPath, nil, map[string]string{"lastName": "Smith"}, http.StatusOK},
+		{"GetEmptyProfile", http.MethodGet, "/api/v1/clients/me", nil, nil, http.StatusOK},
+		{"SaveProfile", http.MethodPut, "/api/v1/clients/me", nil, clientBody("Jane", "Doe"), http.StatusOK},
+		{"GetProfile", http.MethodGet, "/api/v1/clients/me", nil, nil, http.StatusOK},
+		{"Delete", http.MethodDelete, clientPath, nil, nil, http.StatusNoContent},
+		{"GetMissingOperation", http.MethodGet, handlers.OperationsPath + "/add-client-" + uuid.NewString(), nil, nil, http.StatusNotFound},
+	} {
+		t.Run(step.name, func(t *testing.T) {
+			rec := serveClientsWithHeaders(router, step.method, step.target, step.body, step.headers)
+			assert.Equal(t, step.status, rec.Code, rec.Body.String())
+		})
+	}
+
+	t.Run("CreateAsync", func(t *testing.T) {
+		operation := startCreateClient(t, router, "John", "Doe")
+		rec := serveClients(router, http.MethodGet, handlers.OperationsPath+"/"+operation.ID, nil)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		workflows.complete(t, operation.ID)
+		rec = serveClients(router, http.MethodGet, handlers.OperationsPath+"/"+operation.ID+"?wait=1", nil)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+}
+
+// TestClients_OpenAPIRequestValidation tests that requests not matching the document are rejected with their invalid fields
+func TestClients_OpenAPIRequestValidation(t *testing.T) {
+	caller := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	repo := newMemoryClientRepository()
+	router := newValidatedRouter(t, repo, newWorkflowStandIn(services.NewClientService(repo)), caller)
+
+	body := clientBody("Jane", "Doe")
+	body["phoneNumber"] = "0612345678"
+	delete(body, "lastName")
+	rec := serveClients(router, http.MethodPost, "/api/v1/clients", body)
+	problem := decodeProblem(t, rec, http.StatusBadRequest)
+	assert.Equal(t, handlers.ProblemTypeValidation, problem.Type)
+	var fields []string
+	for _, fieldErr := range problem.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	assert.ElementsMatch(t, []string{"lastName", "phoneNumber"}, fields)
+
+	rec = serveClients(router, http.MethodGet, handlers.OperationsPath+"/any?wait=60", nil)
+	problem = decodeProblem(t, rec, http.StatusBadRequest)
+	assert.Equal(t, []handlers.FieldError{{Field: "wait", Message: "number must be at most 30"}}, problem.Errors)
+}
+
+// TestClients_OpenAPIResponseValidation tests that responses drifting from the document are reported
+func TestClients_OpenAPIResponseValidation(t *testing.T) {
+	validator, err := handlers.NewOpenAPIValidator(context.Background())
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(validator.Middleware())
+	router.GET("/api/v1/clients/:id", func(c *gin.Context) {
+		c.Header("ETag", `"1"`)
+		c.JSON(http.StatusOK, gin.H{"uuid": c.Param("id"), "first_name": "Jane"})
+	})
+
+	rec := serveClients(router, http.MethodGet, "/api/v1/clients/"+uuid.NewString(), nil)
+	problem := decodeProblem(t, rec, http.StatusInternalServerError)
+	assert.Contains(t, problem.Detail, "does not match the API document")
+	assert.Empty(t, rec.Header().Get("ETag"))
+}
@@ -0,0 +1,323 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/in"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// workflowStandIn runs client workflows in memory. Started operations stay
+// running until the test completes or fails them.
+type workflowStandIn struct {
+	mu         sync.Mutex
+	service    in.ClientService
+	operations map[string]*standInOperation
+	// startErr fails the start of every workflow, addErr every started AddClient workflow
+	startErr error
+	addErr   error
+	// hold keeps AddClient workflows running until they are completed
+	hold bool
+}
+
+type standInOperation struct {
+	tenantID  string
+	client    *entities.Client
+	operation entities.Operation
+	done      chan struct{}
+}
+
+func newWorkflowStandIn(service in.ClientService) *workflowStandIn {
+	return &workflowStandIn{
+		service:    service,
+		operations: map[string]*standInOperation{},
+	}
+}
+
+func (w *workflowStandIn) AddClient(ctx context.Context, client *entities.Client) (*entities.Client, error) {
+	if w.startErr != nil {
+		return nil, fmt.Errorf("%w: %w", out.ErrWorkflowNotStarted, w.startErr)
+	}
+	if w.addErr != nil {
+		return nil, w.addErr
+	}
+	if w.hold {
+		id, _ := w.StartAddClient(ctx, client)
+		w.mu.Lock()
+		done := w.operations[id].done
+		w.mu.Unlock()
+		select {
+		case <-done:
+			return client, nil
+		case <-ctx.Done():
+			return nil, &out.OperationPendingError{OperationID: id}
+		}
+	}
+	if err := w.service.AddClient(ctx, client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (w *workflowStandIn) GetClient(ctx context.Context, id uuid.UUID) (*entities.Client, error) {
+	return w.service.GetClient(ctx, id)
+}
+
+func (w *workflowStandIn) StartAddClient(ctx context.Context, client *entities.Client) (string, error) {
+	if w.startErr != nil {
+		return "", fmt.Errorf("%w: %w", out.ErrWorkflowNotStarted, w.startErr)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := "add-client-" + client.UUID.String()
+	tenantID, _ := tenancy.TenantFromContext(ctx)
+	w.operations[id] = &standInOperation{
+		tenantID: tenantID,
+		client:   client,
+		operation: entities.Operation{
+			ID:        id,
+			OwnerID:   client.OwnerID,
+			Status:    entities.OperationRunning,
+			Step:      "saving",
+			StartedAt: time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	return id, nil
+}
+
+func (w *workflowStandIn) GetOperation(ctx context.Context, id string, wait time.Duration) (*entities.Operation, error) {
+	w.mu.Lock()
+	op, ok := w.operations[id]
+	w.mu.Unlock()
+
+	tenantID, _ := tenancy.TenantFromContext(ctx)
+	if !ok || op.tenantID != tenantID {
+		return nil, entities.ErrOperationNotFound
+	}
+
+	select {
+	case <-op.done:
+	case <-time.After(wait):
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	operation := op.operation
+	return &operation, nil
+}
+
+// complete runs a started operation to completion
+func (w *workflowStandIn) complete(t *testing.T, id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	op := w.operations[id]
+	assert.NoError(t, w.service.AddClient(tenancy.WithTenant(context.Background(), op.tenantID), op.client))
+	closedAt := time.Now()
+	op.operation.Status, op.operation.Step = entities.OperationCompleted, ""
+	op.operation.Result, op.operation.ClosedAt = op.client, &closedAt
+	close(op.done)
+}
+
+// fail fails a started operation
+func (w *workflowStandIn) fail(id, message string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	op := w.operations[id]
+	closedAt := time.Now()
+	op.operation.Status, op.operation.Step = entities.OperationFailed, ""
+	op.operation.Error, op.operation.ClosedAt = message, &closedAt
+	close(op.done)
+}
+
+// startCreateClient creates a client asynchronously and returns its operation
+func startCreateClient(t *testing.T, router http.Handler, firstName, lastName string) entities.Operation {
+	rec := serveClientsWithHeaders(router, http.MethodPost, "/api/v1/clients", clientBody(firstName, lastName),
+		map[string]string{"Prefer": "respond-async"})
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Equal(t, "respond-async", rec.Header().Get("Preference-Applied"))
+
+	var operation entities.Operation
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&operation))
+	assert.Equal(t, entities.OperationRunning, operation.Status)
+	assert.Equal(t, "/api/v1/operations/"+operation.ID, rec.Header().Get("Location"))
+	return operation
+}
+
+func getOperation(t *testing.T, router http.Handler, id, query string) entities.Operation {
+	rec := serveClients(router, http.MethodGet, "/api/v1/operations/"+id+query, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var operation entities.Operation
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&operation))
+	return operation
+}
+
+// TestClients_AsyncCreate tests creating a client through an operation resource
+func TestClients_AsyncCreate(t *testing.T) {
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	repo := newMemoryClientRepository()
+	service := services.NewClientService(repo)
+	workflows := newWorkflowStandIn(service)
+	router := newWorkflowRouter(service, workflows, caller)
+
+	operation := startCreateClient(t, router, "Jane", "Doe")
+	assert.Empty(t, listClients(t, router, nil).Clients)
+
+	running := getOperation(t, router, operation.ID, "")
+	assert.Equal(t, entities.OperationRunning, running.Status)
+	assert.Equal(t, "saving", running.Step)
+	assert.Nil(t, running.Result)
+
+	workflows.complete(t, operation.ID)
+	completed := getOperation(t, router, operation.ID, "")
+	assert.Equal(t, entities.OperationCompleted, completed.Status)
+	require.NotNil(t, completed.Result)
+	assert.Equal(t, "Jane", completed.Result.FirstName)
+	assert.Equal(t, caller.subject, completed.Result.OwnerID)
+	assert.NotNil(t, completed.ClosedAt)
+	assert.Equal(t, []string{"Jane"}, firstNames(listClients(t, router, nil)))
+
+	failed := startCreateClient(t, router, "John", "Doe")
+	workflows.fail(failed.ID, "client contact email is required")
+	operation = getOperation(t, router, failed.ID, "")
+	assert.Equal(t, entities.OperationFailed, operation.Status)
+	assert.Equal(t, "client contact email is required", operation.Error)
+}
+
+// TestClients_AsyncCreateLongPoll tests that a wait returns as soon as the operation completes
+func TestClients_AsyncCreateLongPoll(t *testing.T) {
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	service := services.NewClientService(newMemoryClientRepository())
+	workflows := newWorkflowStandIn(service)
+	router := newWorkflowRouter(service, workflows, caller)
+
+	operation := startCreateClient(t, router, "Jane", "Doe")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		workflows.complete(t, operation.ID)
+	}()
+
+	started := time.Now()
+	completed := getOperation(t, router, operation.ID, "?wait=30")
+	assert.Equal(t, entities.OperationCompleted, completed.Status)
+	assert.Less(t, time.Since(started), 10*time.Second)
+
+	for _, wait := range []string{"-1", "31", "soon"} {
+		rec := serveClients(router, http.MethodGet, "/api/v1/operations/"+operation.ID+"?wait="+wait, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, wait)
+	}
+}
+
+// TestClients_AsyncCreateOtherTenant tests that operations are only visible to their tenant
+func TestClients_AsyncCreateOtherTenant(t *testing.T) {
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	service := services.NewClientService(newMemoryClientRepository())
+	workflows := newWorkflowStandIn(service)
+	operation := startCreateClient(t, newWorkflowRouter(service, workflows, caller), "Jane", "Doe")
+
+	other := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	rec := serveClients(newWorkflowRouter(service, workflows, other), http.MethodGet, "/api/v1/operations/"+operation.ID, nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// TestClients_AsyncCreateOtherOwner tests that operations are only visible to
+// their owner and to admins
+func TestClients_AsyncCreateOtherOwner(t *testing.T) {
+	owner := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	service := services.NewClientService(newMemoryClientRepository())
+	workflows := newWorkflowStandIn(service)
+	operation := startCreateClient(t, newWorkflowRouter(service, workflows, owner), "Jane", "Doe")
+
+	other := &clientCaller{subject: uuid.New(), tenant: owner.tenant}
+	rec := serveClients(newWorkflowRouter(service, workflows, other), http.MethodGet, "/api/v1/operations/"+operation.ID, nil)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	admin := &clientCaller{subject: uuid.New(), tenant: owner.tenant, roles: []string{handlers.AdminRole}}
+	assert.Equal(t, entities.OperationRunning, getOperation(t, newWorkflowRouter(service, workflows, admin), operation.ID, "").Status)
+}
+
+// TestClients_SyncCreateTimeout tests that a synchronous creation whose
+// workflow outlives the sync wait is answered with its operation
+func TestClients_SyncCreateTimeout(t *testing.T) {
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	service := services.NewClientService(newMemoryClientRepository())
+	workflows := newWorkflowStandIn(service)
+	workflows.hold = true
+	handler := handlers.NewClientHandler(service, workflows)
+	handler.SetSyncWait(50 * time.Millisecond)
+	router := newHandlerRouter(handler, workflows, caller)
+
+	rec := serveClients(router, http.MethodPost, "/api/v1/clients", clientBody("Jane", "Doe"))
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Preference-Applied"))
+
+	var operation entities.Operation
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&operation))
+	assert.Equal(t, entities.OperationRunning, operation.Status)
+	assert.Equal(t, "/api/v1/operations/"+operation.ID, rec.Header().Get("Location"))
+
+	workflows.complete(t, operation.ID)
+	completed := getOperation(t, router, operation.ID, "")
+	assert.Equal(t, entities.OperationCompleted, completed.Status)
+	assert.Equal(t, []string{"Jane"}, firstNames(listClients(t, router, nil)))
+}
+
+// TestClients_AsyncCreateFallbacks tests creations when workflows cannot run
+func TestClients_AsyncCreateFallbacks(t *testing.T) {
+	caller := &clientCaller{subject: uuid.MustParse(testSubject), tenant: uuid.NewString()}
+	async := map[string]string{"Prefer": "respond-async"}
+
+	t.Run("WithoutTemporal", func(t *testing.T) {
+		router := newClientRouter(newMemoryClientRepository(), caller)
+		rec := serveClientsWithHeaders(router, http.MethodPost, "/api/v1/clients", clientBody("Jane", "Doe"), async)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		rec = serveClients(router, http.MethodGet, "/api/v1/operations/add-client-1", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+
+	t.Run("WorkflowNotStarted", func(t *testing.T) {
+		repo := newMemoryClientRepository()
+		service := services.NewClientService(repo)
+		workflows := newWorkflowStandIn(service)
+		workflows.startErr = errors.New("connection refused")
+		router := newWorkflowRouter(service, workflows, caller)
+
+		rec := serveClientsWithHeaders(router, http.MethodPost, "/api/v1/clients", clientBody("Jane", "Doe"), async)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		createClient(t, router, "John", "Doe")
+		assert.Len(t, listClients(t, router, nil).Clients, 2)
+	})
+
+	t.Run("WorkflowFailed", func(t *testing.T) {
+		repo := newMemoryClientRepository()
+		service := services.NewClientService(repo)
+		workflows := newWorkflowStandIn(service)
+		workflows.addErr = errors.New("workflow execution failed")
+		router := newWorkflowRouter(service, workflows, caller)
+
+		// The workflow may have saved the client, so it is not saved again
+		rec := serveClients(router, http.MethodPost, "/api/v1/clients", clientBody("Jane", "Doe"))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Empty(t, listClients(t, router, nil).Clients)
+	})
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeProblem checks that a response is a problem with status, and returns it
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder, status int) handlers.Problem {
+	require.Equal(t, status, rec.Code, rec.Body.String())
+	assert.Equal(t, handlers.ProblemContentType, rec.Header().Get("Content-Type"))
+
+	var problem handlers.Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&problem))
+	assert.Equal(t, status, problem.Status)
+	assert.NotEmpty(t, problem.Type)
+	assert.NotEmpty(t, problem.Title)
+	return problem
+}
+
+// TestClients_Problems tests that errors are answered with problem details
+func TestClients_Problems(t *testing.T) {
+	caller := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	router := newClientRouter(newMemoryClientRepository(), caller)
+
+	t.Run("InvalidFields", func(t *testing.T) {
+		body := clientBody("Jane", "Doe")
+		delete(body, "firstName")
+		body["contactEmail"] = "not an email"
+
+		rec := serveClientsWithHeaders(router, http.MethodPost, "/api/v1/clients", body, map[string]string{
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		})
+		problem := decodeProblem(t, rec, http.StatusBadRequest)
+		assert.Equal(t, handlers.ProblemTypeValidation, problem.Type)
+		assert.Equal(t, "/api/v1/clients", problem.Instance)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", problem.TraceID)
+		assert.ElementsMatch(t, []handlers.FieldError{
+			{Field: "firstName", Message: "is required"},
+			{Field: "contactEmail", Message: "must be an email address"},
+		}, problem.Errors)
+	})
+
+	t.Run("WrongType", func(t *testing.T) {
+		rec := serveClients(router, http.MethodPost, "/api/v1/clients", map[string]interface{}{"firstName": 42})
+		problem := decodeProblem(t, rec, http.StatusBadRequest)
+		assert.Equal(t, []handlers.FieldError{{Field: "firstName", Message: "must be a string"}}, problem.Errors)
+	})
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		rec := serveClients(router, http.MethodGet, "/api/v1/clients?cursor=garbage", nil)
+		problem := decodeProblem(t, rec, http.StatusBadRequest)
+		assert.Equal(t, handlers.ProblemTypeValidation, problem.Type)
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		rec := serveClients(router, http.MethodGet, "/api/v1/clients/not-a-uuid", nil)
+		problem := decodeProblem(t, rec, http.StatusBadRequest)
+		assert.Equal(t, "Invalid client ID", problem.Detail)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		target := "/api/v1/clients/" + uuid.NewString()
+		rec := serveClientsWithHeaders(router, http.MethodGet, target, nil, map[string]string{"X-Request-ID": "request-1"})
+		problem := decodeProblem(t, rec, http.StatusNotFound)
+		assert.Equal(t, "Not Found", problem.Title)
+		assert.Equal(t, target, problem.Instance)
+		assert.Equal(t, "request-1", problem.TraceID)
+	})
+
+	t.Run("VersionMismatch", func(t *testing.T) {
+		client := createClient(t, router, "Jane", "Doe")
+		rec := serveClientsWithHeaders(router, http.MethodDelete, "/api/v1/clients/"+client.UUID.String(), nil, ifMatch(`"9"`))
+		problem := decodeProblem(t, rec, http.StatusPreconditionFailed)
+		assert.Equal(t, handlers.ProblemTypeVersionMismatch, problem.Type)
+	})
+
+	t.Run("NoRoute", func(t *testing.T) {
+		router.NoRoute(handlers.NoRoute)
+		rec := serveClients(router, http.MethodGet, "/api/v2/clients", nil)
+		decodeProblem(t, rec, http.StatusNotFound)
+	})
+}
@@ -14,13 +14,16 @@ import (
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/repositories"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
 	"github.com/cucumber/godog"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
-	"github.com/stretchr/testify/assert"
 )
 
+// testTenantID is the organization the test caller belongs to
+const testTenantID = "0b0e7a3c-4a8e-4d59-9a36-7c1c5b0a2f11"
+
 // ClientTestContext holds the test context
 type ClientTestContext struct {
 	router       *gin.Engine
@@ -53,19 +56,20 @@ func InitializeScenario(ctx *godog.ScenarioContext) {
 	// Set up API routes for testing
 	clientRepo := repositories.NewClientRepository(testCtx.db)
 	clientService := services.NewClientService(clientRepo)
-	clientHandler := handlers.NewClientHandler(clientService)
+	clientHandler := handlers.NewClientHandler(clientService, nil)
 
 	api := testCtx.router.Group("/api/v1")
 	clients := api.Group("/clients")
-	
+
 	// Mock auth middleware for testing
 	clients.Use(func(c *gin.Context) {
-		c.Set("userID", testCtx.clientUUID.String())
+		c.Set(handlers.PrincipalKey, &entities.Principal{Subject: testCtx.clientUUID, Tenant: testTenantID})
+		c.Request = c.Request.WithContext(tenancy.WithTenant(c.Request.Context(), testTenantID))
 		c.Next()
 	})
-	
-	clients.POST("", clientHandler.AddClient)
-	clients.GET("", clientHandler.GetClient)
+
+	clients.POST("/me", clientHandler.AddClient)
+	clients.GET("/me", clientHandler.GetClient)
 
 	// Define step definitions
 	ctx.Before(func(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
@@ -73,13 +77,13 @@ func InitializeScenario(ctx *godog.ScenarioContext) {
 		testCtx.response = httptest.NewRecorder()
 		testCtx.clientData = make(map[string]string)
 		testCtx.responseBody = nil
-		
+
 		// Clean up database before each test
 		_, err := testCtx.db.Exec("DELETE FROM clients")
 		if err != nil {
 			return ctx, fmt.Errorf("failed to clean up database: %w", err)
 		}
-		
+
 		return ctx, nil
 	})
 
@@ -120,14 +124,14 @@ func (ctx *ClientTestContext) iAddAClientWithTheFollowingDetails(table *godog.Ta
 	if len(table.Rows) < 2 {
 		return fmt.Errorf("table must have at least one data row")
 	}
-	
+
 	headers := table.Rows[0].Cells
 	data := table.Rows[1].Cells
-	
+
 	for i, header := range headers {
 		ctx.clientData[header.Value] = data[i].Value
 	}
-	
+
 	// Create request body
 	requestBody, err := json.Marshal(map[string]string{
 		"firstName":    ctx.clientData["firstName"],
@@ -138,38 +142,38 @@ func (ctx *ClientTestContext) iAddAClientWithTheFollowingDetails(table *godog.Ta
 	if err != nil {
 		return fmt.Errorf("failed to marshal request body: %w", err)
 	}
-	
+
 	// Create request
-	req, err := http.NewRequest("POST", "/api/v1/clients", bytes.NewBuffer(requestBody))
+	req, err := http.NewRequest("POST", "/api/v1/clients/me", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	
+
 	// Perform request
 	ctx.router.ServeHTTP(ctx.response, req)
 	ctx.responseBody = ctx.response.Body.Bytes()
-	
+
 	return nil
 }
 
 func (ctx *ClientTestContext) theClientShouldBeSavedSuccessfully() error {
 	if ctx.response.Code != http.StatusOK {
-		return fmt.Errorf("expected status code %d but got %d: %s", 
+		return fmt.Errorf("expected status code %d but got %d: %s",
 			http.StatusOK, ctx.response.Code, ctx.response.Body.String())
 	}
-	
+
 	// Check if client was saved in the database
 	var count int
 	err := ctx.db.QueryRow("SELECT COUNT(*) FROM clients WHERE uuid = $1", ctx.clientUUID).Scan(&count)
 	if err != nil {
 		return fmt.Errorf("failed to query database: %w", err)
 	}
-	
+
 	if count != 1 {
 		return fmt.Errorf("expected 1 client record but found %d", count)
 	}
-	
+
 	return nil
 }
 
@@ -178,46 +182,47 @@ func (ctx *ClientTestContext) iShouldReceiveTheClientDetailsInTheResponse() erro
 	if err := json.Unmarshal(ctx.responseBody, &client); err != nil {
 		return fmt.Errorf("failed to unmarshal response: %w", err)
 	}
-	
+
 	// Verify client details
 	if client.UUID != ctx.clientUUID {
 		return fmt.Errorf("expected UUID %s but got %s", ctx.clientUUID, client.UUID)
 	}
-	
+
 	if client.FirstName != ctx.clientData["firstName"] {
 		return fmt.Errorf("expected firstName %s but got %s", ctx.clientData["firstName"], client.FirstName)
 	}
-	
+
 	if client.LastName != ctx.clientData["lastName"] {
 		return fmt.Errorf("expected lastName %s but got %s", ctx.clientData["lastName"], client.LastName)
 	}
-	
+
 	if client.ContactEmail != ctx.clientData["contactEmail"] {
 		return fmt.Errorf("expected contactEmail %s but got %s", ctx.clientData["contactEmail"], client.ContactEmail)
 	}
-	
+
 	if client.PhoneNumber != ctx.clientData["phoneNumber"] {
 		return fmt.Errorf("expected phoneNumber %s but got %s", ctx.clientData["phoneNumber"], client.PhoneNumber)
 	}
-	
+
 	return nil
 }
 
 func (ctx *ClientTestContext) iHaveAClientRecordInTheSystem() error {
 	// Insert a client record
 	_, err := ctx.db.Exec(
-		"INSERT INTO clients (uuid, first_name, last_name, contact_email, phone_number) VALUES ($1, $2, $3, $4, $5)",
+		"INSERT INTO clients (uuid, tenant_id, owner_id, first_name, last_name, contact_email, phone_number) VALUES ($1, $2, $1, $3, $4, $5, $6)",
 		ctx.clientUUID,
+		testTenantID,
 		"Test",
 		"User",
 		"test.user@example.com",
 		"+9876543210",
 	)
-	
+
 	if err != nil {
 		return fmt.Errorf("failed to insert client record: %w", err)
 	}
-	
+
 	return nil
 }
 
@@ -227,78 +232,78 @@ func (ctx *ClientTestContext) iDoNotHaveAClientRecordInTheSystem() error {
 	if err != nil {
 		return fmt.Errorf("failed to delete client record: %w", err)
 	}
-	
+
 	return nil
 }
 
 func (ctx *ClientTestContext) iRequestMyClientInformation() error {
 	// Create request
-	req, err := http.NewRequest("GET", "/api/v1/clients", nil)
+	req, err := http.NewRequest("GET", "/api/v1/clients/me", nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
-	
+
 	// Perform request
 	ctx.router.ServeHTTP(ctx.response, req)
 	ctx.responseBody = ctx.response.Body.Bytes()
-	
+
 	return nil
 }
 
 func (ctx *ClientTestContext) iShouldReceiveMyClientDetails() error {
 	if ctx.response.Code != http.StatusOK {
-		return fmt.Errorf("expected status code %d but got %d: %s", 
+		return fmt.Errorf("expected status code %d but got %d: %s",
 			http.StatusOK, ctx.response.Code, ctx.response.Body.String())
 	}
-	
+
 	var client entities.Client
 	if err := json.Unmarshal(ctx.responseBody, &client); err != nil {
 		return fmt.Errorf("failed to unmarshal response: %w", err)
 	}
-	
+
 	// Verify client details
 	if client.UUID != ctx.clientUUID {
 		return fmt.Errorf("expected UUID %s but got %s", ctx.clientUUID, client.UUID)
 	}
-	
+
 	if client.FirstName != "Test" {
 		return fmt.Errorf("expected firstName %s but got %s", "Test", client.FirstName)
 	}
-	
+
 	if client.LastName != "User" {
 		return fmt.Errorf("expected lastName %s but got %s", "User", client.LastName)
 	}
-	
+
 	if client.ContactEmail != "test.user@example.com" {
 		return fmt.Errorf("expected contactEmail %s but got %s", "test.user@example.com", client.ContactEmail)
 	}
-	
+
 	if client.PhoneNumber != "+9876543210" {
 		return fmt.Errorf("expected phoneNumber %s but got %s", "+9876543210", client.PhoneNumber)
 	}
-	
+
 	return nil
 }
 
 func (ctx *ClientTestContext) iShouldReceiveAnEmptyClientWithMyUUID() error {
 	if ctx.response.Code != http.StatusOK {
-		return fmt.Errorf("expected status code %d but got %d: %s", 
+		return fmt.Errorf("expected status code %d but got %d: %s",
 			http.StatusOK, ctx.response.Code, ctx.response.Body.String())
 	}
-	
+
 	var client entities.Client
 	if err := json.Unmarshal(ctx.responseBody, &client); err != nil {
 		return fmt.Errorf("failed to unmarshal response: %w", err)
 	}
-	
+
 	// Verify client details
 	if client.UUID != ctx.clientUUID {
 		return fmt.Errorf("expected UUID %s but got %s", ctx.clientUUID, client.UUID)
 	}
-	
+
 	if !client.IsEmpty() {
 		return fmt.Errorf("expected empty client but got %+v", client)
 	}
-	
+
 	return nil
 }
@@ -4,12 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"time"
 
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
 	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
 	"github.com/cucumber/godog"
 	"github.com/google/uuid"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/workflow"
 )
 
 // TemporalTestContext holds the test context for Temporal workflows
@@ -84,8 +86,9 @@ func InitializeTemporalScenario(ctx *godog.ScenarioContext) {
 func (ctx *TemporalTestContext) iHaveATemporalClientConnectedTo(namespace string) error {
 	var err error
 	ctx.temporalClient, err = client.Dial(client.Options{
-		HostPort:  "localhost:7233",
-		Namespace: namespace,
+		HostPort:           "localhost:7233",
+		Namespace:          namespace,
+		ContextPropagators: []workflow.ContextPropagator{temporal.NewTenantPropagator()},
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create Temporal client: %w", err)
@@ -114,7 +117,7 @@ func (ctx *TemporalTestContext) iExecuteTheAddClientWorkflowWithTheFollowingDeta
 	}
 
 	// Create client entity
-	client := &entities.Client{
+	newClient := &entities.Client{
 		UUID:         ctx.clientUUID,
 		FirstName:    ctx.clientData["firstName"],
 		LastName:     ctx.clientData["lastName"],
@@ -131,7 +134,7 @@ func (ctx *TemporalTestContext) iExecuteTheAddClientWorkflowWithTheFollowingDeta
 		TaskQueue: "client-manager-task-queue",
 	}
 
-	run, err := ctx.temporalClient.ExecuteWorkflow(context.Background(), workflowOptions, "AddClientWorkflow", client)
+	run, err := ctx.temporalClient.ExecuteWorkflow(tenancy.WithTenant(context.Background(), testTenantID), workflowOptions, "AddClientWorkflow", newClient)
 	if err != nil {
 		return fmt.Errorf("failed to execute workflow: %w", err)
 	}
@@ -164,7 +167,7 @@ func (ctx *TemporalTestContext) iExecuteTheGetClientWorkflowWithUUID(uuidStr str
 		TaskQueue: "client-manager-task-queue",
 	}
 
-	run, err := ctx.temporalClient.ExecuteWorkflow(context.Background(), workflowOptions, "GetClientWorkflow", ctx.clientUUID)
+	run, err := ctx.temporalClient.ExecuteWorkflow(tenancy.WithTenant(context.Background(), testTenantID), workflowOptions, "GetClientWorkflow", ctx.clientUUID)
 	if err != nil {
 		return fmt.Errorf("failed to execute workflow: %w", err)
 	}
@@ -252,8 +255,9 @@ func (ctx *TemporalTestContext) aClientExistsWithUUID(uuidStr string) error {
 
 	// Insert client into database
 	_, err = ctx.db.Exec(
-		"INSERT INTO clients (uuid, first_name, last_name, contact_email, phone_number) VALUES ($1, $2, $3, $4, $5)",
+		"INSERT INTO clients (uuid, tenant_id, owner_id, first_name, last_name, contact_email, phone_number) VALUES ($1, $2, $1, $3, $4, $5, $6)",
 		ctx.clientUUID,
+		testTenantID,
 		"Test",
 		"User",
 		"test.user@example.com",
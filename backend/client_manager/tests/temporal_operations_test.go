@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/ports/out"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/mocks"
+	sdktemporal "go.temporal.io/sdk/temporal"
+)
+
+// operationOwner is the owner recorded in the memo of described workflows
+var operationOwner = uuid.New()
+
+// describedWorkflow returns the description of a workflow started in a tenant
+func describedWorkflow(t *testing.T, tenantID string, status enumspb.WorkflowExecutionStatus) *workflowservice.DescribeWorkflowExecutionResponse {
+	memo, err := converter.GetDefaultDataConverter().ToPayload(tenantID)
+	require.NoError(t, err)
+	owner, err := converter.GetDefaultDataConverter().ToPayload(operationOwner.String())
+	require.NoError(t, err)
+
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	info := &workflowpb.WorkflowExecutionInfo{
+		Execution: &commonpb.WorkflowExecution{WorkflowId: "add-client-1", RunId: "run-1"},
+		Status:    status,
+		StartTime: &startTime,
+		Memo:      &commonpb.Memo{Fields: map[string]*commonpb.Payload{"tenantId": memo, "ownerId": owner}},
+	}
+	if status != enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		closeTime := startTime.Add(time.Second)
+		info.CloseTime = &closeTime
+	}
+	return &workflowservice.DescribeWorkflowExecutionResponse{WorkflowExecutionInfo: info}
+}
+
+// TestTemporalClient_GetOperation tests that workflow executions are reported as operations
+func TestTemporalClient_GetOperation(t *testing.T) {
+	tenantID := uuid.NewString()
+	ctx := tenancy.WithTenant(context.Background(), tenantID)
+
+	t.Run("Running", func(t *testing.T) {
+		temporalClient := &mocks.Client{}
+		temporalClient.On("DescribeWorkflowExecution", mock.Anything, "add-client-1", "").
+			Return(describedWorkflow(t, tenantID, enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING), nil)
+		temporalClient.On("GetWorkflow", mock.Anything, "add-client-1", "run-1").Return(&mocks.WorkflowRun{})
+		step := &mocks.Value{}
+		step.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*string) = "saving"
+		}).Return(nil)
+		temporalClient.On("QueryWorkflow", mock.Anything, "add-client-1", "", temporal.StepQuery).Return(step, nil)
+
+		operation, err := temporal.NewTemporalClientFromClient(temporalClient, "default", "queue").GetOperation(ctx, "add-client-1", 0)
+		require.NoError(t, err)
+		assert.Equal(t, entities.OperationRunning, operation.Status)
+		assert.Equal(t, "saving", operation.Step)
+		assert.Equal(t, operationOwner, operation.OwnerID)
+		assert.Nil(t, operation.ClosedAt)
+	})
+
+	t.Run("Completed", func(t *testing.T) {
+		temporalClient := &mocks.Client{}
+		temporalClient.On("DescribeWorkflowExecution", mock.Anything, "add-client-1", "").
+			Return(describedWorkflow(t, tenantID, enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED), nil)
+		run := &mocks.WorkflowRun{}
+		run.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(1).(*entities.Client) = entities.Client{FirstName: "Jane"}
+		}).Return(nil)
+		temporalClient.On("GetWorkflow", mock.Anything, "add-client-1", "run-1").Return(run)
+
+		operation, err := temporal.NewTemporalClientFromClient(temporalClient, "default", "queue").GetOperation(ctx, "add-client-1", 0)
+		require.NoError(t, err)
+		assert.Equal(t, entities.OperationCompleted, operation.Status)
+		require.NotNil(t, operation.Result)
+		assert.Equal(t, "Jane", operation.Result.FirstName)
+		assert.NotNil(t, operation.ClosedAt)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		temporalClient := &mocks.Client{}
+		temporalClient.On("DescribeWorkflowExecution", mock.Anything, "add-client-1", "").
+			Return(describedWorkflow(t, tenantID, enumspb.WORKFLOW_EXECUTION_STATUS_FAILED), nil)
+		run := &mocks.WorkflowRun{}
+		run.On("Get", mock.Anything, mock.Anything).
+			Return(sdktemporal.NewApplicationError("client contact email is required", "ValidationError"))
+		temporalClient.On("GetWorkflow", mock.Anything, "add-client-1", "run-1").Return(run)
+
+		operation, err := temporal.NewTemporalClientFromClient(temporalClient, "default", "queue").GetOperation(ctx, "add-client-1", 0)
+		require.NoError(t, err)
+		assert.Equal(t, entities.OperationFailed, operation.Status)
+		assert.Equal(t, "client contact email is required", operation.Error)
+	})
+
+	t.Run("OtherTenant", func(t *testing.T) {
+		temporalClient := &mocks.Client{}
+		temporalClient.On("DescribeWorkflowExecution", mock.Anything, "add-client-1", "").
+			Return(describedWorkflow(t, uuid.NewString(), enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED), nil)
+
+		_, err := temporal.NewTemporalClientFromClient(temporalClient, "default", "queue").GetOperation(ctx, "add-client-1", 0)
+		assert.ErrorIs(t, err, entities.ErrOperationNotFound)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		temporalClient := &mocks.Client{}
+		temporalClient.On("DescribeWorkflowExecution", mock.Anything, "add-client-1", "").
+			Return(nil, serviceerror.NewNotFound("workflow not found"))
+
+		_, err := temporal.NewTemporalClientFromClient(temporalClient, "default", "queue").GetOperation(ctx, "add-client-1", 0)
+		assert.ErrorIs(t, err, entities.ErrOperationNotFound)
+	})
+}
+
+// TestTemporalClient_StartAddClient tests that operations record the tenant and the owner they belong to
+func TestTemporalClient_StartAddClient(t *testing.T) {
+	tenantID := uuid.NewString()
+	ctx := tenancy.WithTenant(context.Background(), tenantID)
+	newClient := entities.NewOwnedClient(uuid.New(), "Jane", "Doe", "jane@example.com", "+33612345678")
+
+	run := &mocks.WorkflowRun{}
+	run.On("GetID").Return("add-client-" + newClient.UUID.String())
+	temporalClient := &mocks.Client{}
+	temporalClient.On("ExecuteWorkflow", mock.Anything, mock.MatchedBy(func(options client.StartWorkflowOptions) bool {
+		return options.ID == "add-client-"+newClient.UUID.String() &&
+			options.TaskQueue == "queue" &&
+			options.Memo["tenantId"] == tenantID &&
+			options.Memo["ownerId"] == newClient.OwnerID.String()
+	}), "AddClientWorkflow", newClient).Return(run, nil)
+
+	id, err := temporal.NewTemporalClientFromClient(temporalClient, "default", "queue").StartAddClient(ctx, newClient)
+	require.NoError(t, err)
+	assert.Equal(t, "add-client-"+newClient.UUID.String(), id)
+}
+
+// TestTemporalClient_AddClientPending tests that a workflow still running when
+// the caller stops waiting is reported as an operation
+func TestTemporalClient_AddClientPending(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	newClient := entities.NewOwnedClient(uuid.New(), "Jane", "Doe", "jane@example.com", "+33612345678")
+
+	run := &mocks.WorkflowRun{}
+	run.On("GetID").Return("add-client-" + newClient.UUID.String())
+	run.On("Get", mock.Anything, mock.Anything).Return(context.DeadlineExceeded)
+	temporalClient := &mocks.Client{}
+	temporalClient.On("ExecuteWorkflow", mock.Anything, mock.Anything, "AddClientWorkflow", newClient).Return(run, nil)
+
+	_, err := temporal.NewTemporalClientFromClient(temporalClient, "default", "queue").AddClient(ctx, newClient)
+	var pending *out.OperationPendingError
+	require.ErrorAs(t, err, &pending)
+	assert.Equal(t, "add-client-"+newClient.UUID.String(), pending.OperationID)
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/workflows"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// headerWriter writes propagated values into a Temporal header
+type headerWriter struct {
+	header *commonpb.Header
+}
+
+func (w headerWriter) Set(key string, value *commonpb.Payload) {
+	w.header.Fields[key] = value
+}
+
+// TestTenantPropagator tests that the caller's tenant reaches the activities of a workflow
+func TestTenantPropagator(t *testing.T) {
+	propagator := temporal.NewTenantPropagator()
+	tenantID := uuid.NewString()
+
+	header := &commonpb.Header{Fields: map[string]*commonpb.Payload{}}
+	require.NoError(t, propagator.Inject(tenancy.WithTenant(context.Background(), tenantID), headerWriter{header}))
+
+	var suite testsuite.WorkflowTestSuite
+	suite.SetContextPropagators([]workflow.ContextPropagator{propagator})
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetHeader(header)
+
+	var seenTenant string
+	env.RegisterActivityWithOptions(func(ctx context.Context, client *entities.Client) (*entities.Client, error) {
+		seenTenant, _ = tenancy.TenantFromContext(ctx)
+		return client, nil
+	}, activity.RegisterOptions{Name: "AddClientActivity"})
+
+	env.ExecuteWorkflow(workflows.AddClientWorkflow, entities.NewClient(uuid.New(), "Jane", "Doe", "jane.doe@example.com", "+33612345678"))
+
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	assert.Equal(t, tenantID, seenTenant)
+}
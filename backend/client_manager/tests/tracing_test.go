@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/tracing"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/workflows"
+	platformtracing "github.com/b-fontaine/saaster_kit/backend/platform/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// The trace context sent by the callers of the tests
+const (
+	callerTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	callerTraceparent = "00-" + callerTraceID + "-00f067aa0ba902b7-01"
+)
+
+// recordSpans installs a tracer provider recording the spans of the test
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return exporter
+}
+
+// spanAncestry returns the names of the longest chain of spans ending with
+// a span named name, from that span up to its root
+func spanAncestry(spans tracetest.SpanStubs, name string) []string {
+	byID := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		byID[span.SpanContext.SpanID().String()] = span
+	}
+
+	var ancestry []string
+	for _, span := range spans {
+		if span.Name != name {
+			continue
+		}
+		var names []string
+		for current, ok := span, true; ok; current, ok = byID[current.Parent.SpanID().String()] {
+			names = append(names, current.Name)
+		}
+		if len(names) > len(ancestry) {
+			ancestry = names
+		}
+	}
+	return ancestry
+}
+
+// TestTracing_DaprEvent tests that the handling of an event delivered by the
+// Dapr sidecar continues the trace of the publisher
+func TestTracing_DaprEvent(t *testing.T) {
+	exporter := recordSpans(t)
+
+	repo := newMemoryClientRepository()
+	handler := handlers.NewEventHandler(services.NewUserEventService(tracing.ClientRepository(repo)), "pubsub")
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.Middleware())
+	events := router.Group("", handlers.RequireDaprAppToken(testAppAPIToken))
+	events.POST(handlers.UserCreatedRoute, handler.UserCreated)
+
+	event := userCloudEvent(uuid.NewString(), "com.saaster.user.created", uuid.NewString(), uuid.New())
+	rec := serveClientsWithHeaders(router, http.MethodPost, handlers.UserCreatedRoute, event, map[string]string{
+		"dapr-api-token": testAppAPIToken,
+		"traceparent":    callerTraceparent,
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	spans := exporter.GetSpans()
+	for _, span := range spans {
+		assert.Equal(t, callerTraceID, span.SpanContext.TraceID().String(), span.Name)
+	}
+	assert.Equal(t, []string{
+		"ClientRepository.CreateProfileOnce",
+		"POST " + handlers.UserCreatedRoute,
+	}, spanAncestry(spans, "ClientRepository.CreateProfileOnce"))
+}
+
+// TestTracing_Workflow tests that a workflow continues the trace written in
+// its Temporal header by the client that started it, down to the repository
+// operations of its activities
+func TestTracing_Workflow(t *testing.T) {
+	exporter := recordSpans(t)
+
+	// The header the tracing interceptor of the client writes
+	traceData, err := converter.GetDefaultDataConverter().ToPayload(map[string]string(propagation.MapCarrier{"traceparent": callerTraceparent}))
+	require.NoError(t, err)
+	tenantID := uuid.NewString()
+	header := &commonpb.Header{Fields: map[string]*commonpb.Payload{"_tracer-data": traceData}}
+	propagator := temporal.NewTenantPropagator()
+	require.NoError(t, propagator.Inject(tenancy.WithTenant(context.Background(), tenantID), headerWriter{header}))
+
+	var suite testsuite.WorkflowTestSuite
+	suite.SetContextPropagators([]workflow.ContextPropagator{propagator})
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{platformtracing.TemporalInterceptor()}})
+	env.SetHeader(header)
+
+	activities := workflows.NewClientActivity(services.NewClientService(tracing.ClientRepository(newMemoryClientRepository())))
+	env.RegisterActivityWithOptions(activities.AddClientActivity, activity.RegisterOptions{Name: "AddClientActivity"})
+	env.ExecuteWorkflow(workflows.AddClientWorkflow, entities.NewOwnedClient(uuid.New(), "Jane", "Doe", "jane.doe@example.com", "+33612345678"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	spans := exporter.GetSpans()
+	for _, span := range spans {
+		assert.Equal(t, callerTraceID, span.SpanContext.TraceID().String(), span.Name)
+	}
+	assert.Equal(t, []string{
+		"ClientRepository.Save",
+		"RunActivity:AddClientActivity",
+		"StartActivity:AddClientActivity",
+		"RunWorkflow:AddClientWorkflow",
+	}, spanAncestry(spans, "ClientRepository.Save"))
+}
@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAppAPIToken = "app-token"
+
+// newEventRouter serves the Dapr routes of cmd/main.go
+func newEventRouter(repo *memoryClientRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewEventHandler(services.NewUserEventService(repo), "pubsub")
+
+	router := gin.New()
+	router.GET("/dapr/subscribe", handler.Subscribe)
+	events := router.Group("", handlers.RequireDaprAppToken(testAppAPIToken))
+	events.POST(handlers.UserCreatedRoute, handler.UserCreated)
+	events.POST(handlers.UserLinkedRoute, handler.UserLinked)
+	events.POST(handlers.UserDeletedRoute, handler.UserDeleted)
+	return router
+}
+
+// userCloudEvent builds a CloudEvent as published by user_manager for the
+// user linked to the Keycloak account subject. The user has its own ID, as
+// user_manager generates it.
+func userCloudEvent(eventID, eventType, tenantID string, subject uuid.UUID) map[string]interface{} {
+	return map[string]interface{}{
+		"specversion":     "1.0",
+		"id":              eventID,
+		"source":          "user-manager",
+		"type":            eventType,
+		"tenantid":        tenantID,
+		"datacontenttype": "application/json",
+		"data": map[string]string{
+			"id":         uuid.NewString(),
+			"tenantId":   tenantID,
+			"externalId": subject.String(),
+		},
+	}
+}
+
+// serveDapr posts an event with the app API token the sidecar sends
+func serveDapr(router http.Handler, route string, event interface{}, token string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(event)
+	req := httptest.NewRequest(http.MethodPost, route, &body)
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	if token != "" {
+		req.Header.Set("dapr-api-token", token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// deliverEvent posts an event the way the Dapr sidecar does and returns the delivery status
+func deliverEvent(t *testing.T, router http.Handler, route string, event interface{}) string {
+	rec := serveDapr(router, route, event, testAppAPIToken)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var status struct {
+		Status string `json:"status"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	return status.Status
+}
+
+// TestUserEvents_Subscribe tests the subscriptions the sidecar reads at startup
+func TestUserEvents_Subscribe(t *testing.T) {
+	router := newEventRouter(newMemoryClientRepository())
+
+	rec := serveClients(router, http.MethodGet, "/dapr/subscribe", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[
+		{"pubsubname": "pubsub", "topic": "user-created", "route": "/events/user-created"},
+		{"pubsubname": "pubsub", "topic": "user-linked", "route": "/events/user-linked"},
+		{"pubsubname": "pubsub", "topic": "user-deleted", "route": "/events/user-deleted"}
+	]`, rec.Body.String())
+}
+
+// TestUserEvents_Lifecycle tests that profiles follow the users, whatever the redeliveries
+func TestUserEvents_Lifecycle(t *testing.T) {
+	repo := newMemoryClientRepository()
+	router := newEventRouter(repo)
+	tenantID := uuid.NewString()
+	subject := uuid.New()
+	ctx := tenancy.WithTenant(context.Background(), tenantID)
+
+	created := userCloudEvent(uuid.NewString(), "com.saaster.user.created", tenantID, subject)
+	assert.Equal(t, "SUCCESS", deliverEvent(t, router, handlers.UserCreatedRoute, created))
+
+	profile, err := repo.FindByID(ctx, subject)
+	require.NoError(t, err)
+	require.NotNil(t, profile)
+	assert.Equal(t, subject, profile.OwnerID)
+	assert.Equal(t, tenantID, profile.TenantID)
+	assert.Empty(t, profile.FirstName)
+
+	// A profile filled in since is kept by a redelivery
+	profile.FirstName = "Jane"
+	require.NoError(t, repo.Update(ctx, profile))
+	assert.Equal(t, "SUCCESS", deliverEvent(t, router, handlers.UserCreatedRoute, created))
+	profile, err = repo.FindByID(ctx, subject)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", profile.FirstName)
+
+	deleted := userCloudEvent(uuid.NewString(), "com.saaster.user.deleted", tenantID, subject)
+	assert.Equal(t, "SUCCESS", deliverEvent(t, router, handlers.UserDeletedRoute, deleted))
+	assert.Equal(t, "SUCCESS", deliverEvent(t, router, handlers.UserDeletedRoute, deleted))
+	profile, err = repo.FindByID(ctx, subject)
+	require.NoError(t, err)
+	assert.Nil(t, profile)
+
+	// A late redelivery of the creation does not bring the profile back
+	assert.Equal(t, "SUCCESS", deliverEvent(t, router, handlers.UserCreatedRoute, created))
+	profile, err = repo.FindByID(ctx, subject)
+	require.NoError(t, err)
+	assert.Nil(t, profile)
+}
+
+// TestUserEvents_ProfileOfCaller tests that the profile provisioned for a
+// user is the one they reach through /api/v1/clients/me, and that the deletion
+// of the user removes it
+func TestUserEvents_ProfileOfCaller(t *testing.T) {
+	repo := newMemoryClientRepository()
+	events := newEventRouter(repo)
+	caller := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	clients := newClientRouter(repo, caller)
+
+	created := userCloudEvent(uuid.NewString(), "com.saaster.user.created", caller.tenant, caller.subject)
+	assert.Equal(t, "SUCCESS", deliverEvent(t, events, handlers.UserCreatedRoute, created))
+
+	rec := serveClients(clients, http.MethodGet, "/api/v1/clients/me", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var profile entities.Client
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &profile))
+	assert.Equal(t, caller.subject, profile.UUID)
+	assert.Equal(t, caller.tenant, profile.TenantID, "the profile of the caller was not provisioned")
+	assert.Equal(t, 1, countClients(t, clients))
+
+	// Filling the profile in does not create another one
+	rec = serveClients(clients, http.MethodPut, "/api/v1/clients/me", clientBody("Jane", "Doe"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 1, countClients(t, clients))
+
+	deleted := userCloudEvent(uuid.NewString(), "com.saaster.user.deleted", caller.tenant, caller.subject)
+	assert.Equal(t, "SUCCESS", deliverEvent(t, events, handlers.UserDeletedRoute, deleted))
+	assert.Zero(t, countClients(t, clients))
+	rec = serveClients(clients, http.MethodGet, "/api/v1/clients/me", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	profile = entities.Client{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &profile))
+	assert.Empty(t, profile.TenantID)
+	assert.Empty(t, profile.FirstName)
+}
+
+// TestUserEvents_Linking tests that users get a profile once they are linked
+// to their Keycloak account
+func TestUserEvents_Linking(t *testing.T) {
+	repo := newMemoryClientRepository()
+	router := newEventRouter(repo)
+	tenantID := uuid.NewString()
+	subject := uuid.New()
+	ctx := tenancy.WithTenant(context.Background(), tenantID)
+
+	// Users created without an account have no profile yet
+	unlinked := userCloudEvent(uuid.NewString(), "com.saaster.user.created", tenantID, subject)
+	delete(unlinked["data"].(map[string]string), "externalId")
+	assert.Equal(t, "SUCCESS", deliverEvent(t, router, handlers.UserCreatedRoute, unlinked))
+	profile, err := repo.FindByID(ctx, subject)
+	require.NoError(t, err)
+	assert.Nil(t, profile)
+
+	linked := userCloudEvent(uuid.NewString(), "com.saaster.user.linked", tenantID, subject)
+	assert.Equal(t, "SUCCESS", deliverEvent(t, router, handlers.UserLinkedRoute, linked))
+	profile, err = repo.FindByID(ctx, subject)
+	require.NoError(t, err)
+	require.NotNil(t, profile)
+	assert.Equal(t, subject, profile.OwnerID)
+}
+
+// TestUserEvents_OtherTenant tests that a deletion only reaches the profile of its tenant
+func TestUserEvents_OtherTenant(t *testing.T) {
+	repo := newMemoryClientRepository()
+	router := newEventRouter(repo)
+	tenantID := uuid.NewString()
+	subject := uuid.New()
+
+	created := userCloudEvent(uuid.NewString(), "com.saaster.user.created", tenantID, subject)
+	assert.Equal(t, "SUCCESS", deliverEvent(t, router, handlers.UserCreatedRoute, created))
+
+	deleted := userCloudEvent(uuid.NewString(), "com.saaster.user.deleted", uuid.NewString(), subject)
+	assert.Equal(t, "SUCCESS", deliverEvent(t, router, handlers.UserDeletedRoute, deleted))
+
+	profile, err := repo.FindByID(tenancy.WithTenant(context.Background(), tenantID), subject)
+	require.NoError(t, err)
+	assert.NotNil(t, profile)
+}
+
+// TestUserEvents_Malformed tests that events which can never be handled are dropped
+func TestUserEvents_Malformed(t *testing.T) {
+	router := newEventRouter(newMemoryClientRepository())
+	subject := uuid.New()
+
+	withoutTenant := userCloudEvent(uuid.NewString(), "com.saaster.user.created", "", subject)
+	withoutID := userCloudEvent("", "com.saaster.user.created", uuid.NewString(), subject)
+	withoutUser := userCloudEvent(uuid.NewString(), "com.saaster.user.created", uuid.NewString(), subject)
+	withoutUser["data"] = map[string]string{"externalId": subject.String()}
+	invalidAccount := userCloudEvent(uuid.NewString(), "com.saaster.user.created", uuid.NewString(), subject)
+	invalidAccount["data"].(map[string]string)["externalId"] = "not-a-uuid"
+
+	for name, event := range map[string]interface{}{
+		"NotJSON":        "not an event",
+		"WithoutTenant":  withoutTenant,
+		"WithoutID":      withoutID,
+		"WithoutUser":    withoutUser,
+		"InvalidAccount": invalidAccount,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, "DROP", deliverEvent(t, router, handlers.UserCreatedRoute, event))
+		})
+	}
+}
+
+// TestUserEvents_AppToken tests that only the sidecar can deliver events
+func TestUserEvents_AppToken(t *testing.T) {
+	router := newEventRouter(newMemoryClientRepository())
+	event := userCloudEvent(uuid.NewString(), "com.saaster.user.created", uuid.NewString(), uuid.New())
+
+	rec := serveDapr(router, handlers.UserCreatedRoute, event, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = serveDapr(router, handlers.UserCreatedRoute, event, "wrong-token")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/application/services"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/entities"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/tenancy"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/domain/validation"
+	"github.com/b-fontaine/saaster_kit/backend/client_manager/internal/workflows"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/mocks"
+	sdktemporal "go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// validationFields returns the invalid fields of a validation error, by name
+func validationFields(t *testing.T, err error) map[string]string {
+	var validationErrs validation.Errors
+	require.ErrorAs(t, err, &validationErrs)
+
+	byField := make(map[string]string, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		byField[fieldErr.Field] = fieldErr.Message
+	}
+	return byField
+}
+
+// TestClients_Validation tests that every invalid field of a client is reported
+func TestClients_Validation(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		client := entities.NewClient(uuid.New(), "Jane", "Doe", "jane@example.com", "+33612345678")
+		assert.NoError(t, validation.ValidateClient(client))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		client := entities.NewClient(uuid.Nil, " ", "", "", "")
+		assert.Equal(t, map[string]string{
+			"uuid":         "is required",
+			"firstName":    "is required",
+			"lastName":     "is required",
+			"contactEmail": "is required",
+			"phoneNumber":  "is required",
+		}, validationFields(t, validation.ValidateClient(client)))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		client := entities.NewClient(uuid.New(), strings.Repeat("é", entities.MaxNameLength+1), "Doe", "Jane <jane@example.com>", "06 12 34 56 78")
+		assert.Equal(t, map[string]string{
+			"firstName":    "must be at most 100 characters",
+			"contactEmail": "must be an email address",
+			"phoneNumber":  "must be an E.164 phone number, such as +33612345678",
+		}, validationFields(t, validation.ValidateClient(client)))
+	})
+
+	t.Run("PhoneNumbers", func(t *testing.T) {
+		for phone, valid := range map[string]bool{
+			"+33612345678":      true,
+			"+1202555014":       true,
+			"+123456789012345":  true,
+			"+1234567890123456": false,
+			"+0612345678":       false,
+			"0612345678":        false,
+			"+33 6 12 34 56 78": false,
+		} {
+			v := validation.New()
+			v.PhoneNumber(phone)
+			assert.Equal(t, valid, v.Err() == nil, phone)
+		}
+	})
+}
+
+// TestClients_ValidationRoutes tests that the invalid fields of a request are listed in the problem
+func TestClients_ValidationRoutes(t *testing.T) {
+	caller := &clientCaller{subject: uuid.New(), tenant: uuid.NewString()}
+	repo := newMemoryClientRepository()
+	router := newClientRouter(repo, caller)
+
+	t.Run("Create", func(t *testing.T) {
+		body := clientBody("", "Doe")
+		body["contactEmail"] = "jane@example.com"
+		body["phoneNumber"] = "0612345678"
+
+		rec := serveClientsWithHeaders(router, http.MethodPost, "/api/v1/clients", body, map[string]string{"Prefer": "respond-async"})
+		problem := decodeProblem(t, rec, http.StatusBadRequest)
+		assert.ElementsMatch(t, []handlers.FieldError{
+			{Field: "firstName", Message: "is required"},
+			{Field: "phoneNumber", Message: "must be an E.164 phone number, such as +33612345678"},
+		}, problem.Errors)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		client := createClient(t, router, "Jane", "Doe")
+		body := clientBody("Jane", strings.Repeat("x", entities.MaxNameLength+1))
+
+		rec := serveClients(router, http.MethodPut, "/api/v1/clients/"+client.UUID.String(), body)
+		problem := decodeProblem(t, rec, http.StatusBadRequest)
+		assert.Equal(t, []handlers.FieldError{{Field: "lastName", Message: "must be at most 100 characters"}}, problem.Errors)
+	})
+
+	t.Run("PatchEventProfile", func(t *testing.T) {
+		// Profiles created from user events have no details yet
+		id := caller.subject
+		ctx := tenancy.WithTenant(context.Background(), caller.tenant)
+		_, err := repo.CreateProfileOnce(ctx, uuid.NewString(), entities.NewClient(id, "", "", "", ""))
+		require.NoError(t, err)
+
+		rec := serveClients(router, http.MethodPatch, "/api/v1/clients/"+id.String(), map[string]string{"firstName": "Jane"})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = serveClients(router, http.MethodPatch, "/api/v1/clients/"+id.String(), map[string]string{"lastName": "", "contactEmail": "jane"})
+		problem := decodeProblem(t, rec, http.StatusBadRequest)
+		assert.ElementsMatch(t, []handlers.FieldError{
+			{Field: "lastName", Message: "is required"},
+			{Field: "contactEmail", Message: "must be an email address"},
+		}, problem.Errors)
+	})
+}
+
+// TestClients_ValidationActivity tests that the AddClient activity does not retry invalid clients
+func TestClients_ValidationActivity(t *testing.T) {
+	activity := workflows.NewClientActivity(services.NewClientService(newMemoryClientRepository()))
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(activity.AddClientActivity)
+
+	client := entities.NewClient(uuid.New(), "Jane", "", "jane@example.com", "")
+	_, err := env.ExecuteActivity(activity.AddClientActivity, client)
+
+	var applicationErr *sdktemporal.ApplicationError
+	require.True(t, errors.As(err, &applicationErr), "%v", err)
+	assert.Equal(t, temporal.ValidationError, applicationErr.Type())
+	assert.True(t, applicationErr.NonRetryable())
+
+	var details validation.Errors
+	require.NoError(t, applicationErr.Details(&details))
+	assert.Equal(t, map[string]string{"lastName": "is required", "phoneNumber": "is required"}, validationFields(t, details))
+}
+
+// TestTemporalClient_AddClientValidation tests that invalid fields reported by the workflow are returned as validation errors
+func TestTemporalClient_AddClientValidation(t *testing.T) {
+	ctx := tenancy.WithTenant(context.Background(), uuid.NewString())
+	client := entities.NewClient(uuid.New(), "Jane", "Doe", "jane@example.com", "+33612345678")
+	invalid := validation.Errors{{Field: "phoneNumber", Message: "is required"}}
+
+	run := &mocks.WorkflowRun{}
+	run.On("Get", mock.Anything, mock.Anything).
+		Return(sdktemporal.NewNonRetryableApplicationError(invalid.Error(), temporal.ValidationError, nil, invalid))
+	temporalClient := &mocks.Client{}
+	temporalClient.On("ExecuteWorkflow", mock.Anything, mock.Anything, "AddClientWorkflow", client).Return(run, nil)
+
+	_, err := temporal.NewTemporalClientFromClient(temporalClient, "default", "queue").AddClient(ctx, client)
+	assert.Equal(t, map[string]string{"phoneNumber": "is required"}, validationFields(t, err))
+}
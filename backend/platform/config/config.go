@@ -0,0 +1,269 @@
+// Package config loads the typed configuration of a service. A
+// configuration is a struct, filled with its defaults by the service, whose
+// fields are overridden in order by:
+//
+//   - the YAML file given in Options.File, with the keys of the yaml tags
+//   - the environment variables named by the env tags
+//   - the secrets of a secret store, for the fields with a secret tag, when
+//     the configuration implements SecretSource
+//
+// The configuration is then validated, and every problem found on the way,
+// such as an unparsable variable or a missing required field, is reported
+// at once in an Errors.
+//
+// The tags understood on the fields are:
+//
+//	yaml:"readTimeout"          key in the YAML file and in Print
+//	env:"SERVER_READ_TIMEOUT"   environment variable
+//	required:"true"             the field must not be empty
+//	oneof:"postgres dapr"       the values allowed
+//	secret:"db-password"        key in the secret store, the value being
+//	                            redacted by Print; secret:"-" only redacts it
+//
+// Nested structs are walked. Leaves are strings, booleans, integers, floats,
+// durations and comma-separated string slices.
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Options configures Load
+type Options struct {
+	// File is the YAML file applied over the defaults, if not empty
+	File string
+	// LookupEnv reads the environment, os.LookupEnv by default
+	LookupEnv func(key string) (string, bool)
+}
+
+// Validator is implemented by the configurations checking rules the tags
+// cannot express. Validate may return an Errors to report several problems.
+type Validator interface {
+	Validate() error
+}
+
+// Errors is the report of all the problems found while loading a
+// configuration
+type Errors []error
+
+func (e Errors) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, err := range e {
+		b.WriteString("\n  - ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the problems of the report
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Load applies the file, the environment and the secrets to target, a
+// pointer to a struct holding the defaults, then validates it. The fields
+// are set even when an Errors is returned, so that they can be printed.
+func Load(ctx context.Context, target interface{}, options Options) error {
+	root := reflect.ValueOf(target)
+	if root.Kind() != reflect.Pointer || root.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: target must be a pointer to a struct, not %T", target)
+	}
+	if options.LookupEnv == nil {
+		options.LookupEnv = os.LookupEnv
+	}
+
+	var errs Errors
+	if options.File != "" {
+		if err := applyFile(target, options.File); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	fields := walk(root.Elem(), "")
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if raw, ok := options.LookupEnv(f.env); ok {
+			if err := set(f.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value %q: %w", f, raw, err))
+			}
+		}
+	}
+
+	if source, ok := target.(SecretSource); ok {
+		if store := source.SecretStore(); store != nil {
+			errs = append(errs, resolveSecrets(ctx, store, fields)...)
+		}
+	}
+
+	for _, f := range fields {
+		if f.required && f.value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s: is required", f))
+		}
+		if len(f.oneof) > 0 && !f.value.IsZero() {
+			if value := fmt.Sprint(f.value.Interface()); !contains(f.oneof, value) {
+				errs = append(errs, fmt.Errorf("%s: %q is not one of %s", f, value, strings.Join(f.oneof, ", ")))
+			}
+		}
+	}
+	if validator, ok := target.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			var report Errors
+			if errors.As(err, &report) {
+				errs = append(errs, report...)
+			} else {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// applyFile decodes the YAML file over target, rejecting unknown keys
+func applyFile(target interface{}, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", file, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(target); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decoding %s: %w", file, err)
+	}
+	return nil
+}
+
+// field is a leaf of a configuration
+type field struct {
+	path     string
+	env      string
+	required bool
+	oneof    []string
+	secret   string
+	value    reflect.Value
+}
+
+// String names the field after its YAML path and its variable
+func (f field) String() string {
+	if f.env == "" {
+		return f.path
+	}
+	return f.path + " (" + f.env + ")"
+}
+
+// redacted tells whether the value of the field is hidden when printed
+func (f field) redacted() bool {
+	return f.secret != ""
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// walk returns the leaves of the struct v, in order
+func walk(v reflect.Value, prefix string) []field {
+	var fields []field
+	for i := 0; i < v.NumField(); i++ {
+		structField := v.Type().Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		path := prefix + key(structField)
+		if structField.Type.Kind() == reflect.Struct {
+			fields = append(fields, walk(v.Field(i), path+".")...)
+			continue
+		}
+		f := field{
+			path:     path,
+			env:      structField.Tag.Get("env"),
+			required: structField.Tag.Get("required") == "true",
+			oneof:    strings.Fields(structField.Tag.Get("oneof")),
+			secret:   structField.Tag.Get("secret"),
+			value:    v.Field(i),
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// key returns the YAML key of a field
+func key(structField reflect.StructField) string {
+	name, _, _ := strings.Cut(structField.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(structField.Name)
+	}
+	return name
+}
+
+// set parses raw into the leaf v
+func set(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(duration))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
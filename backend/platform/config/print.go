@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces the secrets in Print
+const redactedValue = "********"
+
+// Print writes the configuration as YAML, in the format of the file read by
+// Load, each value commented with its environment variable. Secrets that
+// are set are redacted.
+func Print(w io.Writer, target interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(target))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("config: target must be a struct, not %T", target)
+	}
+	node, err := mapping(v)
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// mapping returns the YAML mapping of the struct v
+func mapping(v reflect.Value) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i < v.NumField(); i++ {
+		structField := v.Type().Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key(structField)}
+
+		var valueNode *yaml.Node
+		if structField.Type.Kind() == reflect.Struct {
+			var err error
+			if valueNode, err = mapping(v.Field(i)); err != nil {
+				return nil, err
+			}
+		} else {
+			f := field{secret: structField.Tag.Get("secret")}
+			valueNode = &yaml.Node{}
+			value := v.Field(i).Interface()
+			if f.redacted() && !v.Field(i).IsZero() {
+				value = redactedValue
+			}
+			if err := valueNode.Encode(value); err != nil {
+				return nil, fmt.Errorf("encoding %s: %w", structField.Name, err)
+			}
+			valueNode.LineComment = structField.Tag.Get("env")
+		}
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+	return node, nil
+}
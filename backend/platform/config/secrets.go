@@ -0,0 +1,176 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSecretNotFound is returned by a SecretStore when it has no secret
+// under a key. The field then keeps the value of the other layers.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore reads secrets by key
+type SecretStore interface {
+	// Secret returns the secret stored under key, or ErrSecretNotFound
+	Secret(ctx context.Context, key string) (string, error)
+}
+
+// SecretSource is implemented by the configurations reading their secrets
+// from a store, once the file and the environment are applied
+type SecretSource interface {
+	// SecretStore returns the store of the secrets, or nil to keep the
+	// secrets of the other layers
+	SecretStore() SecretStore
+}
+
+// SecretStoreConfig selects the Dapr secret store of a service
+type SecretStoreConfig struct {
+	// Name is the Dapr secret store component the secrets are read from.
+	// The secrets are read from the file and the environment when empty.
+	Name string `yaml:"name" env:"SECRET_STORE_NAME"`
+	// WaitTimeout is how long the sidecar is waited for at startup
+	WaitTimeout time.Duration `yaml:"waitTimeout" env:"SECRET_STORE_WAIT_TIMEOUT"`
+}
+
+// Store returns the store named by the configuration, behind the Dapr
+// sidecar at daprEndpoint, or nil when no store is named
+func (c SecretStoreConfig) Store(daprEndpoint, daprAPIToken string) SecretStore {
+	if c.Name == "" {
+		return nil
+	}
+	return &DaprSecretStore{
+		BaseURL:     daprEndpoint,
+		StoreName:   c.Name,
+		APIToken:    daprAPIToken,
+		WaitTimeout: c.WaitTimeout,
+	}
+}
+
+// DaprSecretStore reads secrets through the secrets API of a Dapr sidecar
+type DaprSecretStore struct {
+	// BaseURL is the HTTP endpoint of the sidecar, e.g. http://localhost:3500
+	BaseURL string
+	// StoreName is the secret store component
+	StoreName string
+	// APIToken authenticates the service to the sidecar, if it requires it
+	APIToken string
+	// WaitTimeout is how long the sidecar is waited for before the first
+	// secret is read, as it may start after the service. It is waited for
+	// once, and its failure is returned for every secret.
+	WaitTimeout time.Duration
+	// HTTPClient is used to call the sidecar, a client with a 10s timeout
+	// by default
+	HTTPClient *http.Client
+
+	wait    sync.Once
+	waitErr error
+}
+
+// Secret returns the secret stored under key
+func (s *DaprSecretStore) Secret(ctx context.Context, key string) (string, error) {
+	s.wait.Do(func() { s.waitErr = s.waitForSidecar(ctx) })
+	if s.waitErr != nil {
+		return "", s.waitErr
+	}
+
+	endpoint := fmt.Sprintf("%s/v1.0/secrets/%s/%s", strings.TrimSuffix(s.BaseURL, "/"), url.PathEscape(s.StoreName), url.PathEscape(key))
+	resp, err := s.get(ctx, endpoint)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNoContent:
+		return "", fmt.Errorf("secret store %s: %w: %s", s.StoreName, ErrSecretNotFound, key)
+	case resp.StatusCode != http.StatusOK:
+		// The sidecar reports the keys its store does not have as failures
+		// to get them, telling them apart by their message only
+		var daprErr struct {
+			ErrorCode string `json:"errorCode"`
+			Message   string `json:"message"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&daprErr) == nil && daprErr.ErrorCode == "ERR_SECRET_GET" && strings.Contains(daprErr.Message, "not found") {
+			return "", fmt.Errorf("secret store %s: %w: %s", s.StoreName, ErrSecretNotFound, key)
+		}
+		return "", fmt.Errorf("secret store %s answered %d", s.StoreName, resp.StatusCode)
+	}
+
+	var secrets map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&secrets); err != nil {
+		return "", fmt.Errorf("decoding secret: %w", err)
+	}
+	value, ok := secrets[key]
+	if !ok {
+		return "", fmt.Errorf("secret store %s: %w: %s", s.StoreName, ErrSecretNotFound, key)
+	}
+	return value, nil
+}
+
+// waitForSidecar waits until the sidecar can reach its components
+func (s *DaprSecretStore) waitForSidecar(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.WaitTimeout)
+	defer cancel()
+	endpoint := strings.TrimSuffix(s.BaseURL, "/") + "/v1.0/healthz/outbound"
+	for {
+		resp, err := s.get(ctx, endpoint)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < http.StatusMultipleChoices {
+				return nil
+			}
+			err = fmt.Errorf("sidecar answered %d", resp.StatusCode)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for the Dapr sidecar: %w", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func (s *DaprSecretStore) get(ctx context.Context, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.APIToken != "" {
+		req.Header.Set("dapr-api-token", s.APIToken)
+	}
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return httpClient.Do(req)
+}
+
+// resolveSecrets reads the secret fields from store. Values found override
+// the other layers, and secrets the store does not have leave them as they
+// are, so that required fields are reported when no layer sets them. Other
+// failures, such as an unreachable sidecar or a denied access, are reported.
+func resolveSecrets(ctx context.Context, store SecretStore, fields []field) Errors {
+	var errs Errors
+	for _, f := range fields {
+		if f.secret == "" || f.secret == "-" {
+			continue
+		}
+		value, err := store.Secret(ctx, f.secret)
+		if errors.Is(err, ErrSecretNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: reading secret %s: %w", f, f.secret, err))
+			continue
+		}
+		if value != "" {
+			f.value.SetString(value)
+		}
+	}
+	return errs
+}
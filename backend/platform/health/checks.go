@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.temporal.io/sdk/client"
+)
+
+// Database checks that the database accepts connections
+func Database(db *sql.DB) Check {
+	return db.PingContext
+}
+
+// Unavailable always fails with err, for the dependencies the service
+// started without
+func Unavailable(err error) Check {
+	return func(ctx context.Context) error {
+		return err
+	}
+}
+
+// Temporal checks that the Temporal frontend serves the client
+func Temporal(temporalClient client.Client) Check {
+	return func(ctx context.Context) error {
+		_, err := temporalClient.CheckHealth(ctx, &client.CheckHealthRequest{})
+		return err
+	}
+}
+
+// DaprSidecar checks that the Dapr sidecar at baseURL is up, by reading its
+// metadata
+func DaprSidecar(httpClient *http.Client, baseURL, apiToken string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/v1.0/metadata", nil)
+		if err != nil {
+			return err
+		}
+		if apiToken != "" {
+			req.Header.Set("dapr-api-token", apiToken)
+		}
+		return expectOK(httpClient, req)
+	}
+}
+
+// JWKS checks that the keys verifying the tokens can be fetched from url
+func JWKS(httpClient *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		return expectOK(httpClient, req)
+	}
+}
+
+// expectOK sends req and fails unless it is answered with 200 OK
+func expectOK(httpClient *http.Client, req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Redacted())
+	}
+	return nil
+}
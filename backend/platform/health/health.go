@@ -0,0 +1,186 @@
+// Package health serves the liveness and readiness probes of the services.
+// Liveness only tells that the process answers; readiness runs the checks of
+// the dependencies registered in a Registry, so that orchestrators stop
+// routing requests to an instance whose dependencies are unavailable.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Paths of the probes
+const (
+	LivePath  = "/livez"
+	ReadyPath = "/readyz"
+)
+
+// Statuses of the service and of its dependencies
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+// Check reports whether a dependency is available, returning why not
+type Check func(ctx context.Context) error
+
+// CheckResult is the status of a dependency
+type CheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Duration int64  `json:"durationMs"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the status of the service and of its dependencies
+type Report struct {
+	Status string        `json:"status"`
+	Time   time.Time     `json:"time"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// Config holds the settings of a Registry
+type Config struct {
+	// Timeout bounds the checks registered without a timeout of their own
+	Timeout time.Duration
+	// CacheTTL is how long a readiness report is served before the checks
+	// run again, so that frequent probes do not load the dependencies
+	CacheTTL time.Duration
+}
+
+// Registry holds the checks run by the readiness probe
+type Registry struct {
+	cfg    Config
+	checks []registeredCheck
+
+	mutex    sync.Mutex
+	cached   *Report
+	cachedAt time.Time
+}
+
+type registeredCheck struct {
+	name    string
+	check   Check
+	timeout time.Duration
+}
+
+// CheckOption configures a registered check
+type CheckOption func(*registeredCheck)
+
+// WithTimeout bounds a check with timeout instead of the registry timeout
+func WithTimeout(timeout time.Duration) CheckOption {
+	return func(c *registeredCheck) {
+		c.timeout = timeout
+	}
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry(cfg Config) *Registry {
+	return &Registry{cfg: cfg}
+}
+
+// Register adds the check of a dependency, reported under name
+func (r *Registry) Register(name string, check Check, options ...CheckOption) {
+	registered := registeredCheck{name: name, check: check, timeout: r.cfg.Timeout}
+	for _, option := range options {
+		option(&registered)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.checks = append(r.checks, registered)
+	r.cached = nil
+}
+
+// Ready returns the readiness report, running the checks concurrently
+// unless the last report is recent enough. The service is up when every
+// check passed.
+func (r *Registry) Ready(ctx context.Context) Report {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cached != nil && time.Since(r.cachedAt) < r.cfg.CacheTTL {
+		return *r.cached
+	}
+
+	// The report is shared by the probes until it expires, so it does not
+	// depend on the cancellation of the probe running the checks
+	ctx = context.WithoutCancel(ctx)
+	report := Report{Status: StatusUp, Time: time.Now().UTC(), Checks: make([]CheckResult, len(r.checks))}
+	var wg sync.WaitGroup
+	for i, registered := range r.checks {
+		wg.Add(1)
+		go func(i int, registered registeredCheck) {
+			defer wg.Done()
+			report.Checks[i] = run(ctx, registered)
+		}(i, registered)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	r.cached = &report
+	r.cachedAt = time.Now()
+	return report
+}
+
+// run runs a check within its timeout
+func run(ctx context.Context, registered registeredCheck) CheckResult {
+	if registered.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, registered.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- registered.check(ctx)
+	}()
+
+	// Checks ignoring their context are abandoned when it is done
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Name: registered.name, Status: StatusUp, Duration: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LiveHandler answers the liveness probe
+func (r *Registry) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, Report{Status: StatusUp, Time: time.Now().UTC()})
+	})
+}
+
+// ReadyHandler answers the readiness probe, with 503 Service Unavailable
+// when a dependency is down
+func (r *Registry) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, r.Ready(req.Context()))
+	})
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if report.Status != StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
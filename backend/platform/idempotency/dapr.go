@@ -0,0 +1,239 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errETagMismatch is returned when a conditional write lost a race
+var errETagMismatch = errors.New("state changed concurrently")
+
+// DaprConfig holds the settings needed to use a Dapr state store
+type DaprConfig struct {
+	// BaseURL is the HTTP endpoint of the sidecar, e.g. http://localhost:3500
+	BaseURL string
+	// StoreName is the name of the Dapr state store component
+	StoreName string
+	// APIToken authenticates the service to the sidecar, if it requires it
+	APIToken string
+	// HTTPClient is used to call the sidecar
+	HTTPClient *http.Client
+}
+
+// stateItem is an item of a Dapr state save request
+type stateItem struct {
+	Key      string            `json:"key"`
+	Value    interface{}       `json:"value"`
+	ETag     string            `json:"etag,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// DaprStore is a Dapr state store implementation of the Store interface.
+// Reservations rely on first-write concurrency, so the state store must
+// support ETags.
+type DaprStore struct {
+	baseURL    string
+	storeName  string
+	apiToken   string
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewDaprStore creates a new DaprStore
+func NewDaprStore(cfg DaprConfig) *DaprStore {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &DaprStore{
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		storeName:  cfg.StoreName,
+		apiToken:   cfg.APIToken,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+}
+
+// Reserve writes a key unless it exists and is not expired. A write racing
+// with another reservation of the same key fails on its ETag, and the winner
+// is returned.
+func (s *DaprStore) Reserve(ctx context.Context, record *Record) (*Record, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		existing, etag, err := s.get(ctx, record.Key)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && s.now().Before(existing.ExpiresAt) {
+			return existing, nil
+		}
+
+		// Without an ETag, first-write only creates missing keys
+		err = s.save(ctx, record, etag, "first-write")
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, errETagMismatch) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("failed to reserve idempotency key: key %s keeps changing", record.Key)
+}
+
+// Complete stores the response of a key, provided the reservation of the
+// record is still the current one. The write is conditional on the ETag of
+// the reservation, so a takeover racing with it is detected.
+func (s *DaprStore) Complete(ctx context.Context, record *Record) error {
+	existing, etag, err := s.current(ctx, record)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrReservationLost
+	}
+
+	err = s.save(ctx, record, etag, "first-write")
+	if errors.Is(err, errETagMismatch) {
+		return ErrReservationLost
+	}
+	return err
+}
+
+// Release deletes the reservation of the record, unless it was completed or
+// taken over
+func (s *DaprStore) Release(ctx context.Context, record *Record) error {
+	existing, etag, err := s.current(ctx, record)
+	if err != nil || existing == nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, s.keyURL(record.Key), nil, etag)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		// Taken over since it was read
+		return nil
+	}
+	return stateError("release idempotency key", resp)
+}
+
+// current returns the reservation of the record and its ETag, or nil when the
+// key is no longer reserved with the token of the record
+func (s *DaprStore) current(ctx context.Context, record *Record) (*Record, string, error) {
+	existing, etag, err := s.get(ctx, record.Key)
+	if err != nil {
+		return nil, "", err
+	}
+	if existing == nil || existing.Token != record.Token || existing.Completed {
+		return nil, "", nil
+	}
+	return existing, etag, nil
+}
+
+// get returns the record of a key and its ETag, or nil if it does not exist
+func (s *DaprStore) get(ctx context.Context, key string) (*Record, string, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.keyURL(key), nil, "")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotFound:
+		return nil, "", nil
+	case http.StatusOK:
+	default:
+		return nil, "", stateError("get idempotency key", resp)
+	}
+
+	var record Record
+	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
+		return nil, "", fmt.Errorf("failed to decode idempotency key: %w", err)
+	}
+
+	return &record, resp.Header.Get("ETag"), nil
+}
+
+// save writes a record, expiring in the store along with the record
+func (s *DaprStore) save(ctx context.Context, record *Record, etag, concurrency string) error {
+	ttl := int(record.ExpiresAt.Sub(s.now()).Seconds())
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	body, err := json.Marshal([]stateItem{{
+		Key:      record.Key,
+		Value:    record,
+		ETag:     etag,
+		Options:  map[string]string{"concurrency": concurrency},
+		Metadata: map[string]string{"ttlInSeconds": strconv.Itoa(ttl)},
+	}})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1.0/state/%s", s.baseURL, url.PathEscape(s.storeName))
+	resp, err := s.do(ctx, http.MethodPost, endpoint, body, "")
+	if err != nil {
+		return fmt.Errorf("failed to save idempotency key: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK, http.StatusCreated:
+		return nil
+	case http.StatusConflict:
+		return errETagMismatch
+	}
+	return stateError("save idempotency key", resp)
+}
+
+// keyURL returns the URL of a key of the state store
+func (s *DaprStore) keyURL(key string) string {
+	return fmt.Sprintf("%s/v1.0/state/%s/%s", s.baseURL, url.PathEscape(s.storeName), url.PathEscape(key))
+}
+
+// do sends a request to the sidecar, conditional on etag when set
+func (s *DaprStore) do(ctx context.Context, method, endpoint string, body []byte, etag string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+		query := req.URL.Query()
+		query.Set("concurrency", "first-write")
+		req.URL.RawQuery = query.Encode()
+	}
+	if s.apiToken != "" {
+		req.Header.Set("dapr-api-token", s.apiToken)
+	}
+
+	return s.httpClient.Do(req)
+}
+
+// stateError describes an unexpected response of the state API
+func stateError(action string, resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("failed to %s: dapr returned %s: %s", action, resp.Status, strings.TrimSpace(string(message)))
+}
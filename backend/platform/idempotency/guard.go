@@ -0,0 +1,232 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers of idempotent requests
+const (
+	KeyHeader      = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// MaxKeyLength is the longest Idempotency-Key accepted
+const MaxKeyLength = 255
+
+// ErrReplayed is returned by Guard.Begin when the request was answered with
+// the stored response of its key
+var ErrReplayed = errors.New("idempotent response replayed")
+
+// Rejection is returned by Guard.Begin when a request must not run. It is
+// answered with a problem of Status and Detail.
+type Rejection struct {
+	Status int
+	Detail string
+	// RetryAfter is the Retry-After header of the answer, if any
+	RetryAfter string
+}
+
+func (r *Rejection) Error() string {
+	return r.Detail
+}
+
+// Guard makes the POST, PUT, PATCH and DELETE requests sent with an
+// Idempotency-Key safe to retry. The first response of a key is stored for
+// ttl and replayed to the retries; a key reused for another request is
+// rejected with 422 Unprocessable Entity, and a retry sent while the first
+// request runs with 409 Conflict. Server errors are not stored, so that the
+// request can be retried.
+//
+// While the first request runs, its key is only held for lease, so that a
+// request that crashed or timed out without releasing its key does not block
+// the retries until the ttl of the responses.
+//
+// The HTTP adapters of the services run requests through Begin, Recover and
+// Finish, and answer the rejections with their own problems.
+type Guard struct {
+	store   Store
+	ttl     time.Duration
+	lease   time.Duration
+	headers []string
+}
+
+// NewGuard creates a guard storing the responses in store, along with their
+// headers named in headers
+func NewGuard(store Store, ttl, lease time.Duration, headers ...string) *Guard {
+	return &Guard{store: store, ttl: ttl, lease: lease, headers: headers}
+}
+
+// Call is a request holding the reservation of its key
+type Call struct {
+	guard  *Guard
+	r      *http.Request
+	record *Record
+}
+
+// Begin reserves the key of r, scoped to the caller named by scope, such as
+// its tenant and subject. It returns a nil Call when r is sent without a key
+// or does not change resources, ErrReplayed when it answered r on w, and a
+// *Rejection when r must be answered with a problem.
+func (g *Guard) Begin(w http.ResponseWriter, r *http.Request, scope ...string) (*Call, error) {
+	key := r.Header.Get(KeyHeader)
+	if key == "" || !isMutating(r.Method) {
+		return nil, nil
+	}
+	if len(key) > MaxKeyLength {
+		return nil, &Rejection{Status: http.StatusBadRequest, Detail: "Idempotency-Key must be at most 255 characters"}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, &Rejection{Status: http.StatusBadRequest, Detail: "Failed to read request body"}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	record := &Record{
+		Key:         hash(append(scope[:len(scope):len(scope)], key)...),
+		RequestHash: hash(r.Method, r.URL.RequestURI(), string(body)),
+		Token:       uuid.NewString(),
+		ExpiresAt:   time.Now().Add(g.lease),
+	}
+
+	existing, err := g.store.Reserve(r.Context(), record)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to reserve idempotency key", "error", err)
+		return nil, &Rejection{Status: http.StatusInternalServerError, Detail: "Failed to check Idempotency-Key"}
+	}
+	if existing == nil {
+		return &Call{guard: g, r: r, record: record}, nil
+	}
+
+	switch {
+	case existing.RequestHash != record.RequestHash:
+		return nil, &Rejection{Status: http.StatusUnprocessableEntity, Detail: "Idempotency-Key was already used for another request"}
+	case !existing.Completed:
+		return nil, &Rejection{Status: http.StatusConflict, Detail: "A request with this Idempotency-Key is in progress", RetryAfter: "1"}
+	}
+	for name, value := range existing.Header {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.Body)
+	return nil, ErrReplayed
+}
+
+// Recover releases the key of a request that panicked, and panics again. It
+// must be deferred directly.
+func (c *Call) Recover() {
+	if recovered := recover(); recovered != nil {
+		c.release()
+		panic(recovered)
+	}
+}
+
+// Finish stores the response of the request, or releases its key when the
+// response is a server error, so that the request can be retried
+func (c *Call) Finish(response Response) {
+	if response.Status() >= http.StatusInternalServerError {
+		c.release()
+		return
+	}
+
+	record := c.record
+	record.Completed = true
+	record.ExpiresAt = time.Now().Add(c.guard.ttl)
+	record.StatusCode = response.Status()
+	record.Body = response.Body()
+	record.Header = make(map[string]string)
+	for _, name := range c.guard.headers {
+		if value := response.Header().Get(name); value != "" {
+			record.Header[name] = value
+		}
+	}
+	ctx := c.r.Context()
+	err := c.guard.store.Complete(ctx, record)
+	switch {
+	case errors.Is(err, ErrReservationLost):
+		// The request outlived its lease and a retry took the key over
+		slog.WarnContext(ctx, "Idempotency key was taken over before the response could be stored", "error", err)
+	case err != nil:
+		slog.ErrorContext(ctx, "Failed to store the response of idempotency key", "error", err)
+	}
+}
+
+// release forgets the reservation of a request that failed
+func (c *Call) release() {
+	if err := c.guard.store.Release(c.r.Context(), c.record); err != nil {
+		slog.ErrorContext(c.r.Context(), "Failed to release idempotency key", "error", err)
+	}
+}
+
+// Response is the response of a request, as stored for its key
+type Response interface {
+	Status() int
+	Header() http.Header
+	Body() []byte
+}
+
+// Recorder writes a response through while keeping a copy of it
+type Recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// NewRecorder creates a recorder of the response written to w
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// Status returns the status of the response
+func (r *Recorder) Status() int {
+	return r.status
+}
+
+// Body returns the body written so far
+func (r *Recorder) Body() []byte {
+	return r.body.Bytes()
+}
+
+// hash returns the hex SHA-256 of parts, separated so that they cannot run into each other
+func hash(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isMutating reports whether requests of the method change resources
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrReservationLost is returned when a reservation was taken over by another
+// request, after its lease ran out, before it could be completed
+var ErrReservationLost = errors.New("idempotency key reservation lost")
+
+// Record is the outcome of a request sent with an Idempotency-Key
+type Record struct {
+	// Key identifies the request among the requests of every caller
+	Key string
+	// RequestHash is the hash of the method, path and body of the request,
+	// so that a key reused for another request is detected
+	RequestHash string
+	// Token identifies the reservation of the key. Only the request holding
+	// it may complete or release the key, so that a request outliving its
+	// lease does not overwrite the reservation that took over.
+	Token string
+	// Completed is false while the first request is being processed
+	Completed  bool
+	StatusCode int
+	Header     map[string]string
+	Body       []byte
+	// ExpiresAt ends the lease of a reservation, or the retention of a
+	// completed response
+	ExpiresAt time.Time
+}
+
+// Store stores the responses of the requests sent with an Idempotency-Key.
+// Expired records are treated as missing, so a reservation whose lease ran
+// out, because its request crashed or timed out, is taken over by the next
+// retry.
+type Store interface {
+	// Reserve records that the request of record.Key is being processed,
+	// unless the key is already recorded. It returns the existing record, or
+	// nil when the key was reserved.
+	Reserve(ctx context.Context, record *Record) (*Record, error)
+	// Complete stores the response of the reservation of record.Key held by
+	// record.Token. It returns ErrReservationLost when the key is no longer
+	// held by the token.
+	Complete(ctx context.Context, record *Record) error
+	// Release forgets the reservation of record.Key held by record.Token,
+	// whose request failed, so that it can be retried. Keys that are no
+	// longer held by the token are left alone.
+	Release(ctx context.Context, record *Record) error
+}
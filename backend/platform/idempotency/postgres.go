@@ -0,0 +1,155 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// idempotencyPurgeBatch is the number of expired keys removed by each reservation
+const idempotencyPurgeBatch = 100
+
+// PostgresStore is a PostgreSQL implementation of the Store interface, over
+// the idempotency_keys table of the migrations of the service. Keys already
+// identify their caller and tenant, so its statements run as the service
+// role, outside of tenant transactions.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new PostgresStore
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{
+		db: db,
+	}
+}
+
+// Reserve inserts a key, or takes over an expired one, such as a reservation
+// whose lease ran out. Each reservation also removes a few expired keys, so
+// that the table does not grow unbounded.
+func (s *PostgresStore) Reserve(ctx context.Context, record *Record) (*Record, error) {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE key IN (
+			SELECT key FROM idempotency_keys
+			WHERE expires_at <= now()
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+	`, idempotencyPurgeBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+
+	reserve := `
+		INSERT INTO idempotency_keys (key, request_hash, token, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			token = EXCLUDED.token,
+			completed = false,
+			status_code = NULL,
+			headers = NULL,
+			body = NULL,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= now()
+		RETURNING key
+	`
+
+	// The existing key may expire or be released between both statements
+	for attempt := 0; attempt < 2; attempt++ {
+		var key string
+		err := s.db.QueryRowContext(ctx, reserve, record.Key, record.RequestHash, record.Token, record.ExpiresAt).Scan(&key)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+
+		existing, err := s.get(ctx, record.Key)
+		if err != nil || existing != nil {
+			return existing, err
+		}
+	}
+
+	return nil, fmt.Errorf("failed to reserve idempotency key: key %s keeps changing", record.Key)
+}
+
+// get returns the record of a key, or nil if it does not exist
+func (s *PostgresStore) get(ctx context.Context, key string) (*Record, error) {
+	query := `
+		SELECT key, request_hash, COALESCE(token, ''), completed, COALESCE(status_code, 0), headers, body, expires_at
+		FROM idempotency_keys
+		WHERE key = $1
+	`
+
+	var record Record
+	var header []byte
+	err := s.db.QueryRowContext(ctx, query, key).Scan(
+		&record.Key,
+		&record.RequestHash,
+		&record.Token,
+		&record.Completed,
+		&record.StatusCode,
+		&header,
+		&record.Body,
+		&record.ExpiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	if header != nil {
+		if err := json.Unmarshal(header, &record.Header); err != nil {
+			return nil, fmt.Errorf("failed to decode idempotency key headers: %w", err)
+		}
+	}
+
+	return &record, nil
+}
+
+// Complete stores the response of a key, provided the reservation of the
+// record is still the current one
+func (s *PostgresStore) Complete(ctx context.Context, record *Record) error {
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET completed = true, status_code = $3, headers = $4, body = $5, expires_at = $6
+		WHERE key = $1 AND token = $2 AND NOT completed
+	`
+
+	result, err := s.db.ExecContext(ctx, query, record.Key, record.Token, record.StatusCode, header, record.Body, record.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	if rows == 0 {
+		return ErrReservationLost
+	}
+
+	return nil
+}
+
+// Release removes the reservation of the record, unless it was completed or
+// taken over
+func (s *PostgresStore) Release(ctx context.Context, record *Record) error {
+	query := "DELETE FROM idempotency_keys WHERE key = $1 AND token = $2 AND NOT completed"
+	if _, err := s.db.ExecContext(ctx, query, record.Key, record.Token); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+// Package jwks fetches and caches the signing keys of a JSON Web Key Set, such
+// as the one Keycloak publishes for a realm, to verify access tokens locally.
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned when the key set does not contain the requested key
+var ErrKeyNotFound = errors.New("signing key not found in JWKS")
+
+// jsonWebKey represents a single key of a JSON Web Key Set
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jsonWebKeySet represents a JSON Web Key Set document
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// Cache fetches and caches the signing keys published at a JWKS URL
+type Cache struct {
+	url             string
+	httpClient      *http.Client
+	ttl             time.Duration
+	minRefreshDelay time.Duration
+
+	mutex        sync.RWMutex
+	keys         map[string]crypto.PublicKey
+	fetchedAt    time.Time
+	lastForcedAt time.Time
+}
+
+// NewCache creates a new Cache for the given JWKS URL. Keys are trusted for ttl,
+// 10 minutes if not set, before being refreshed.
+func NewCache(url string, ttl time.Duration, httpClient *http.Client) *Cache {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+
+	return &Cache{
+		url:             url,
+		httpClient:      httpClient,
+		ttl:             ttl,
+		minRefreshDelay: 10 * time.Second,
+		keys:            make(map[string]crypto.PublicKey),
+	}
+}
+
+// Key returns the public key with the given key ID.
+// The key set is refreshed when it is stale or when the key ID is unknown,
+// which allows the identity provider to rotate its keys without restarting the service.
+func (c *Cache) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mutex.RLock()
+	key, found := c.keys[kid]
+	fresh := time.Since(c.fetchedAt) < c.ttl
+	c.mutex.RUnlock()
+
+	if found && fresh {
+		return key, nil
+	}
+
+	if err := c.refresh(ctx, !found); err != nil {
+		// Serve a stale key rather than failing when the identity provider is unreachable
+		if found {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	key, found = c.keys[kid]
+	if !found {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+// refresh downloads the key set, throttling refreshes triggered by unknown key IDs
+func (c *Cache) refresh(ctx context.Context, unknownKid bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Another goroutine may have refreshed the keys while we were waiting
+	if time.Since(c.fetchedAt) < c.ttl && !unknownKid {
+		return nil
+	}
+	if unknownKid && !c.fetchedAt.IsZero() {
+		// Bound the number of fetches an attacker can trigger with random key IDs
+		if time.Since(c.lastForcedAt) < c.minRefreshDelay {
+			return nil
+		}
+		c.lastForcedAt = time.Now()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip keys we cannot use instead of rejecting the whole set
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	c.keys = keys
+	c.fetchedAt = time.Now()
+
+	return nil
+}
+
+// publicKey converts the JSON Web Key into a crypto.PublicKey
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
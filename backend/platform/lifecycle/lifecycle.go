@@ -0,0 +1,184 @@
+// Package lifecycle runs the components of a service, such as its servers,
+// workers and relays, until the service is asked to stop. On SIGINT or
+// SIGTERM, or when a component fails, the components are stopped in the
+// reverse order of their registration, letting them drain their in-flight
+// work until a deadline, then the clients they used are closed, in reverse
+// order too.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Component is a long-running part of the service
+type Component struct {
+	Name string
+	// Start runs the component, blocking until it stopped
+	Start func() error
+	// Stop asks the component to stop and waits for its in-flight work,
+	// which is abandoned when ctx is done
+	Stop func(ctx context.Context) error
+}
+
+// Config holds the settings of a Manager
+type Config struct {
+	// ShutdownTimeout bounds the time given to the components to drain
+	// their work once the service is asked to stop
+	ShutdownTimeout time.Duration
+}
+
+// Manager starts the components of the service and stops them on shutdown
+type Manager struct {
+	cfg        Config
+	components []Component
+	closers    []closer
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+// NewManager creates a manager without components
+func NewManager(cfg Config) *Manager {
+	return &Manager{cfg: cfg}
+}
+
+// Add registers components, started in order by Run
+func (m *Manager) Add(components ...Component) {
+	m.components = append(m.components, components...)
+}
+
+// Close registers the closing of a resource, such as a database or a client,
+// once every component stopped. Resources are closed in the reverse order of
+// their registration, so they are registered as soon as they are opened.
+func (m *Manager) Close(name string, close func() error) {
+	m.closers = append(m.closers, closer{name: name, close: close})
+}
+
+// Run starts the components and blocks until ctx is done, the process
+// receives SIGINT or SIGTERM or a component stops on its own. It then stops
+// the components and closes the resources, returning why the service
+// stopped when it was not asked to, and the errors met while shutting down.
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	// done[i] receives the result of components[i].Start
+	done := make([]chan error, len(m.components))
+	failed := make(chan error, len(m.components))
+	for i, component := range m.components {
+		done[i] = make(chan error, 1)
+		go func(component Component, done chan<- error) {
+			slog.Info("Starting component", "component", component.Name)
+			err := component.Start()
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+			done <- err
+			if err == nil {
+				err = errors.New("stopped unexpectedly")
+			}
+			failed <- fmt.Errorf("%s: %w", component.Name, err)
+		}(component, done[i])
+	}
+
+	var cause error
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutting down", "cause", context.Cause(ctx))
+	case cause = <-failed:
+		slog.Error("Shutting down after a component stopped", "error", cause)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.cfg.ShutdownTimeout)
+	defer cancel()
+
+	errs := []error{cause}
+	for i := len(m.components) - 1; i >= 0; i-- {
+		if err := stop(shutdownCtx, m.components[i], done[i]); err != nil {
+			slog.Error("Failed to stop component", "component", m.components[i].Name, "error", err)
+			errs = append(errs, fmt.Errorf("stopping %s: %w", m.components[i].Name, err))
+		}
+	}
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		if err := m.closers[i].close(); err != nil {
+			slog.Error("Failed to close resource", "resource", m.closers[i].name, "error", err)
+			errs = append(errs, fmt.Errorf("closing %s: %w", m.closers[i].name, err))
+		}
+	}
+	slog.Info("Shut down")
+	return errors.Join(errs...)
+}
+
+// stop stops component, unless it already stopped, and waits for its Start
+// to return until ctx is done
+func stop(ctx context.Context, component Component, done <-chan error) error {
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+
+	slog.Info("Stopping component", "component", component.Name)
+	stopErr := component.Stop(ctx)
+	select {
+	case err := <-done:
+		return errors.Join(stopErr, err)
+	case <-ctx.Done():
+		return errors.Join(stopErr, fmt.Errorf("not stopped before the deadline: %w", ctx.Err()))
+	}
+}
+
+// HTTPServer serves server, waiting for the pending requests on stop
+func HTTPServer(name string, server *http.Server) Component {
+	return Component{
+		Name: name,
+		Start: func() error {
+			slog.Info("Starting server", "address", server.Addr)
+			return server.ListenAndServe()
+		},
+		Stop: server.Shutdown,
+	}
+}
+
+// Worker runs a worker taking an interrupt channel, such as a Temporal
+// worker, which is closed on stop. The worker is expected to wait for its
+// running tasks before returning.
+func Worker(name string, run func(interruptCh <-chan interface{}) error) Component {
+	interruptCh := make(chan interface{})
+	return Component{
+		Name: name,
+		Start: func() error {
+			return run(interruptCh)
+		},
+		Stop: func(ctx context.Context) error {
+			close(interruptCh)
+			return nil
+		},
+	}
+}
+
+// Loop runs a background loop returning once its context is cancelled, such
+// as a relay polling a table, which is cancelled on stop
+func Loop(name string, run func(ctx context.Context)) Component {
+	ctx, cancel := context.WithCancel(context.Background())
+	return Component{
+		Name: name,
+		Start: func() error {
+			run(ctx)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	}
+}
@@ -0,0 +1,213 @@
+// Package logging sets up the structured logging of the services. Records
+// are written as JSON with Elastic Common Schema field names, so that they can
+// be shipped to Elasticsearch as they are, and carry the request ID, trace ID,
+// tenant and user of the context they are logged with. The correlation is
+// passed on to the Temporal workflows and activities started for a request.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Field names of the correlation attributes, from the Elastic Common Schema
+const (
+	RequestIDKey = "http.request.id"
+	TraceIDKey   = "trace.id"
+	SpanIDKey    = "span.id"
+	TenantIDKey  = "organization.id"
+	UserIDKey    = "user.id"
+)
+
+// RequestIDHeader is the header carrying the ID of a request, from the
+// caller or the gateway, and back in the response
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from callers
+const maxRequestIDLength = 128
+
+// Config holds the logging configuration
+type Config struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string
+	// Format is json, for Elasticsearch, or text, for reading in a terminal
+	Format string
+}
+
+// New creates the logger of the service, writing to w the records of
+// cfg.Level and above in cfg.Format
+func New(cfg Config, serviceName string, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
+
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: ecsAttr}
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json or text", cfg.Format)
+	}
+
+	return slog.New(contextHandler{handler}).With(slog.String("service.name", serviceName)), nil
+}
+
+// ecsAttr renames the built-in attributes of the records after the Elastic
+// Common Schema
+func ecsAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return attr
+	}
+	switch attr.Key {
+	case slog.TimeKey:
+		attr.Key = "@timestamp"
+	case slog.LevelKey:
+		attr.Key = "log.level"
+		attr.Value = slog.StringValue(strings.ToLower(attr.Value.String()))
+	case slog.MessageKey:
+		attr.Key = "message"
+	}
+	return attr
+}
+
+// contextHandler adds the correlation attributes of the context of the
+// records to them
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	record.AddAttrs(Attrs(ctx)...)
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Correlation identifies the request a piece of work is done for
+type Correlation struct {
+	RequestID string `json:"requestId,omitempty"`
+	TraceID   string `json:"traceId,omitempty"`
+	TenantID  string `json:"tenantId,omitempty"`
+	UserID    string `json:"userId,omitempty"`
+}
+
+type correlationContextKey struct{}
+
+// CorrelationSource completes a correlation with what the service bound to
+// its context, such as the tenant and the caller of its request
+type CorrelationSource func(ctx context.Context, correlation *Correlation)
+
+// source is the CorrelationSource of the service, if any
+var source atomic.Pointer[CorrelationSource]
+
+// SetCorrelationSource makes CorrelationFromContext complete the correlations
+// with correlationSource, or only with their trace when it is nil. It is
+// meant to be called once, as the service starts.
+func SetCorrelationSource(correlationSource CorrelationSource) {
+	source.Store(&correlationSource)
+}
+
+// WithCorrelation returns a copy of ctx carrying correlation, for work done
+// outside the request, such as the workflows it starts
+func WithCorrelation(ctx context.Context, correlation Correlation) context.Context {
+	return context.WithValue(ctx, correlationContextKey{}, correlation)
+}
+
+// WithRequestID returns a copy of ctx carrying the ID of its request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	correlation := CorrelationFromContext(ctx)
+	correlation.RequestID = requestID
+	return WithCorrelation(ctx, correlation)
+}
+
+// WithUserID returns a copy of ctx carrying the ID of the caller of its
+// request
+func WithUserID(ctx context.Context, userID string) context.Context {
+	correlation := CorrelationFromContext(ctx)
+	correlation.UserID = userID
+	return WithCorrelation(ctx, correlation)
+}
+
+// RequestIDOrNew returns requestID when it is a valid request ID, a new one
+// otherwise. Request IDs are logged and echoed, so only short printable ones
+// are accepted from callers.
+func RequestIDOrNew(requestID string) string {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for _, c := range requestID {
+		if c < 0x21 || c > 0x7e {
+			return uuid.NewString()
+		}
+	}
+	return requestID
+}
+
+// RequestIDFromContext returns the ID of the request of ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	return CorrelationFromContext(ctx).RequestID
+}
+
+// CorrelationFromContext returns the correlation of ctx: the one it carries,
+// completed with its current trace and with the correlation source of the
+// service
+func CorrelationFromContext(ctx context.Context) Correlation {
+	correlation, _ := ctx.Value(correlationContextKey{}).(Correlation)
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		correlation.TraceID = spanContext.TraceID().String()
+	}
+	if s := source.Load(); s != nil && *s != nil {
+		(*s)(ctx, &correlation)
+	}
+	return correlation
+}
+
+// Attrs returns the correlation attributes of ctx
+func Attrs(ctx context.Context) []slog.Attr {
+	correlation := CorrelationFromContext(ctx)
+	attrs := make([]slog.Attr, 0, 5)
+	if correlation.RequestID != "" {
+		attrs = append(attrs, slog.String(RequestIDKey, correlation.RequestID))
+	}
+	if correlation.TraceID != "" {
+		attrs = append(attrs, slog.String(TraceIDKey, correlation.TraceID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasSpanID() {
+		attrs = append(attrs, slog.String(SpanIDKey, spanContext.SpanID().String()))
+	}
+	if correlation.TenantID != "" {
+		attrs = append(attrs, slog.String(TenantIDKey, correlation.TenantID))
+	}
+	if correlation.UserID != "" {
+		attrs = append(attrs, slog.String(UserIDKey, correlation.UserID))
+	}
+	return attrs
+}
+
+// FromContext returns the default logger with the correlation attributes of
+// ctx, for code logging without a context
+func FromContext(ctx context.Context) *slog.Logger {
+	attrs := Attrs(ctx)
+	args := make([]any, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	return slog.Default().With(args...)
+}
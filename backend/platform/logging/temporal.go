@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+// temporalHeaderKey is the Temporal header holding the correlation
+const temporalHeaderKey = "correlation"
+
+// TemporalLogger adapts logger to the Temporal SDK, for the client and the
+// workers
+func TemporalLogger(logger *slog.Logger) log.Logger {
+	return log.NewStructuredLogger(logger)
+}
+
+// TemporalPropagator passes the correlation of the context starting a
+// workflow on to the workflow and its activities
+func TemporalPropagator() workflow.ContextPropagator {
+	return correlationPropagator{}
+}
+
+type correlationPropagator struct{}
+
+func (correlationPropagator) Inject(ctx context.Context, writer workflow.HeaderWriter) error {
+	return injectCorrelation(CorrelationFromContext(ctx), writer)
+}
+
+func (correlationPropagator) InjectFromWorkflow(ctx workflow.Context, writer workflow.HeaderWriter) error {
+	correlation, _ := ctx.Value(correlationContextKey{}).(Correlation)
+	return injectCorrelation(correlation, writer)
+}
+
+func (correlationPropagator) Extract(ctx context.Context, reader workflow.HeaderReader) (context.Context, error) {
+	correlation, ok, err := extractCorrelation(reader)
+	if err != nil || !ok {
+		return ctx, err
+	}
+	return WithCorrelation(ctx, correlation), nil
+}
+
+func (correlationPropagator) ExtractToWorkflow(ctx workflow.Context, reader workflow.HeaderReader) (workflow.Context, error) {
+	correlation, ok, err := extractCorrelation(reader)
+	if err != nil || !ok {
+		return ctx, err
+	}
+	return workflow.WithValue(ctx, correlationContextKey{}, correlation), nil
+}
+
+func injectCorrelation(correlation Correlation, writer workflow.HeaderWriter) error {
+	if correlation == (Correlation{}) {
+		return nil
+	}
+	payload, err := converter.GetDefaultDataConverter().ToPayload(correlation)
+	if err != nil {
+		return err
+	}
+	writer.Set(temporalHeaderKey, payload)
+	return nil
+}
+
+func extractCorrelation(reader workflow.HeaderReader) (Correlation, bool, error) {
+	payload, ok := reader.Get(temporalHeaderKey)
+	if !ok {
+		return Correlation{}, false, nil
+	}
+	var correlation Correlation
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &correlation); err != nil {
+		return Correlation{}, false, err
+	}
+	return correlation, true, nil
+}
+
+// TemporalInterceptor adds the correlation propagated by TemporalPropagator
+// to the loggers of the workflows and activities
+func TemporalInterceptor() interceptor.WorkerInterceptor {
+	return &workerInterceptor{}
+}
+
+type workerInterceptor struct {
+	interceptor.WorkerInterceptorBase
+}
+
+func (w *workerInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	i := &activityInterceptor{}
+	i.Next = next
+	return i
+}
+
+func (w *workerInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	i := &workflowInterceptor{}
+	i.Next = next
+	return i
+}
+
+type activityInterceptor struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+func (i *activityInterceptor) Init(outbound interceptor.ActivityOutboundInterceptor) error {
+	o := &activityOutboundInterceptor{}
+	o.Next = outbound
+	return i.Next.Init(o)
+}
+
+type activityOutboundInterceptor struct {
+	interceptor.ActivityOutboundInterceptorBase
+}
+
+func (o *activityOutboundInterceptor) GetLogger(ctx context.Context) log.Logger {
+	return withCorrelation(o.Next.GetLogger(ctx), CorrelationFromContext(ctx))
+}
+
+type workflowInterceptor struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (i *workflowInterceptor) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	o := &workflowOutboundInterceptor{}
+	o.Next = outbound
+	return i.Next.Init(o)
+}
+
+type workflowOutboundInterceptor struct {
+	interceptor.WorkflowOutboundInterceptorBase
+}
+
+func (o *workflowOutboundInterceptor) GetLogger(ctx workflow.Context) log.Logger {
+	correlation, _ := ctx.Value(correlationContextKey{}).(Correlation)
+	return withCorrelation(o.Next.GetLogger(ctx), correlation)
+}
+
+// withCorrelation adds the fields of correlation to logger
+func withCorrelation(logger log.Logger, correlation Correlation) log.Logger {
+	var keyvals []interface{}
+	if correlation.RequestID != "" {
+		keyvals = append(keyvals, RequestIDKey, correlation.RequestID)
+	}
+	if correlation.TraceID != "" {
+		keyvals = append(keyvals, TraceIDKey, correlation.TraceID)
+	}
+	if correlation.TenantID != "" {
+		keyvals = append(keyvals, TenantIDKey, correlation.TenantID)
+	}
+	if correlation.UserID != "" {
+		keyvals = append(keyvals, UserIDKey, correlation.UserID)
+	}
+	if len(keyvals) == 0 {
+		return logger
+	}
+	return log.With(logger, keyvals...)
+}
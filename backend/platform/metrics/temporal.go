@@ -0,0 +1,165 @@
+// Package metrics exports the metrics of the Temporal SDK to Prometheus, for
+// the Temporal clients and workers of the services.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.temporal.io/sdk/client"
+)
+
+// temporalLabels are the tags set by the Temporal SDK on its metrics. Other
+// tags are dropped and missing ones are left empty, because every series of
+// a Prometheus metric must have the same labels.
+var temporalLabels = []string{
+	"namespace",
+	"client_name",
+	"poller_type",
+	"worker_type",
+	"workflow_type",
+	"activity_type",
+	"task_queue",
+	"operation",
+	"cause",
+	"failure_reason",
+}
+
+// temporalBuckets are the buckets of the Temporal latencies, in seconds
+var temporalBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// temporalCollectors holds the collectors of the Temporal metrics, created
+// as the SDK first reports them. Counters are suffixed with _total and
+// timers, reported as histograms, with _seconds.
+type temporalCollectors struct {
+	registerer prometheus.Registerer
+
+	mu       sync.Mutex
+	counters map[string]*prometheus.CounterVec
+	gauges   map[string]*prometheus.GaugeVec
+	timers   map[string]*prometheus.HistogramVec
+}
+
+// temporalHandler adapts the collectors to the SDK's metrics handler
+type temporalHandler struct {
+	collectors *temporalCollectors
+	labels     prometheus.Labels
+}
+
+// TemporalHandler returns the handler the Temporal client and workers report
+// their metrics to, registering their collectors with registerer
+func TemporalHandler(registerer prometheus.Registerer) client.MetricsHandler {
+	labels := prometheus.Labels{}
+	for _, label := range temporalLabels {
+		labels[label] = ""
+	}
+
+	return &temporalHandler{
+		collectors: &temporalCollectors{
+			registerer: registerer,
+			counters:   map[string]*prometheus.CounterVec{},
+			gauges:     map[string]*prometheus.GaugeVec{},
+			timers:     map[string]*prometheus.HistogramVec{},
+		},
+		labels: labels,
+	}
+}
+
+// WithTags returns a handler adding the known tags to the labels of h
+func (h *temporalHandler) WithTags(tags map[string]string) client.MetricsHandler {
+	labels := prometheus.Labels{}
+	for label, value := range h.labels {
+		labels[label] = value
+	}
+	for tag, value := range tags {
+		if _, ok := labels[tag]; ok {
+			labels[tag] = value
+		}
+	}
+	return &temporalHandler{collectors: h.collectors, labels: labels}
+}
+
+// Counter returns the counter of the given name
+func (h *temporalHandler) Counter(name string) client.MetricsCounter {
+	c := h.collectors
+	c.mu.Lock()
+	vec, ok := c.counters[name]
+	if !ok {
+		vec = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: name + "_total",
+			Help: "Temporal SDK counter " + name + ".",
+		}, temporalLabels)
+		vec = register(c.registerer, vec)
+		c.counters[name] = vec
+	}
+	c.mu.Unlock()
+
+	counter := vec.With(h.labels)
+	return counterFunc(func(delta int64) { counter.Add(float64(delta)) })
+}
+
+// Gauge returns the gauge of the given name
+func (h *temporalHandler) Gauge(name string) client.MetricsGauge {
+	c := h.collectors
+	c.mu.Lock()
+	vec, ok := c.gauges[name]
+	if !ok {
+		vec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: name,
+			Help: "Temporal SDK gauge " + name + ".",
+		}, temporalLabels)
+		vec = register(c.registerer, vec)
+		c.gauges[name] = vec
+	}
+	c.mu.Unlock()
+
+	return gaugeFunc(vec.With(h.labels).Set)
+}
+
+// Timer returns the timer of the given name
+func (h *temporalHandler) Timer(name string) client.MetricsTimer {
+	c := h.collectors
+	c.mu.Lock()
+	vec, ok := c.timers[name]
+	if !ok {
+		vec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    name + "_seconds",
+			Help:    "Temporal SDK timer " + name + ".",
+			Buckets: temporalBuckets,
+		}, temporalLabels)
+		vec = register(c.registerer, vec)
+		c.timers[name] = vec
+	}
+	c.mu.Unlock()
+
+	histogram := vec.With(h.labels)
+	return timerFunc(func(d time.Duration) { histogram.Observe(d.Seconds()) })
+}
+
+// counterFunc, gaugeFunc and timerFunc adapt functions to the SDK's metrics
+type counterFunc func(int64)
+
+func (f counterFunc) Inc(delta int64) { f(delta) }
+
+type gaugeFunc func(float64)
+
+func (f gaugeFunc) Update(value float64) { f(value) }
+
+type timerFunc func(time.Duration)
+
+func (f timerFunc) Record(d time.Duration) { f(d) }
+
+// register registers a collector, or returns the one already registered
+// under the same name
+func register[C prometheus.Collector](registerer prometheus.Registerer, collector C) C {
+	if err := registerer.Register(collector); err != nil {
+		if already, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			if existing, ok := already.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return collector
+}
@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serviceConfig is the configuration of a service
+type serviceConfig struct {
+	Server struct {
+		Port        string        `yaml:"port" env:"SERVER_PORT" required:"true"`
+		ReadTimeout time.Duration `yaml:"readTimeout" env:"SERVER_READ_TIMEOUT"`
+	} `yaml:"server"`
+	Database struct {
+		Host     string `yaml:"host" env:"DB_HOST" required:"true"`
+		Password string `yaml:"password" env:"DB_PASSWORD" required:"true" secret:"db-password"`
+		MaxConns int    `yaml:"maxConns" env:"DB_MAX_CONNS"`
+	} `yaml:"database"`
+	Dapr struct {
+		HTTPEndpoint string `yaml:"httpEndpoint" env:"DAPR_HTTP_ENDPOINT"`
+		APIToken     string `yaml:"apiToken" env:"DAPR_API_TOKEN" secret:"-"`
+	} `yaml:"dapr"`
+	Store   string                   `yaml:"store" env:"STORE" oneof:"postgres dapr"`
+	Origins []string                 `yaml:"origins" env:"ORIGINS"`
+	Secrets config.SecretStoreConfig `yaml:"secrets"`
+}
+
+func (c *serviceConfig) SecretStore() config.SecretStore {
+	return c.Secrets.Store(c.Dapr.HTTPEndpoint, c.Dapr.APIToken)
+}
+
+func (c *serviceConfig) Validate() error {
+	if c.Server.ReadTimeout < 0 {
+		return errors.New("server.readTimeout: must not be negative")
+	}
+	return nil
+}
+
+// defaultServiceConfig returns the defaults of the service
+func defaultServiceConfig() *serviceConfig {
+	cfg := &serviceConfig{Store: "postgres"}
+	cfg.Server.Port = "8080"
+	cfg.Server.ReadTimeout = 5 * time.Second
+	cfg.Database.Host = "localhost"
+	cfg.Secrets.WaitTimeout = time.Second
+	return cfg
+}
+
+// environment returns a LookupEnv reading vars
+func environment(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+// writeFile writes a YAML configuration file
+func writeFile(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+// TestConfig_Layers tests that the file overrides the defaults and the
+// environment overrides the file
+func TestConfig_Layers(t *testing.T) {
+	file := writeFile(t, `
+server:
+  port: "9090"
+  readTimeout: 10s
+database:
+  host: db.internal
+  password: from-file
+  maxConns: 10
+`)
+
+	cfg := defaultServiceConfig()
+	err := config.Load(context.Background(), cfg, config.Options{
+		File:      file,
+		LookupEnv: environment(map[string]string{"DB_HOST": "db.example.com", "ORIGINS": "a.example.com, b.example.com"}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "9090", cfg.Server.Port)
+	assert.Equal(t, 10*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, "db.example.com", cfg.Database.Host)
+	assert.Equal(t, "from-file", cfg.Database.Password)
+	assert.Equal(t, 10, cfg.Database.MaxConns)
+	assert.Equal(t, "postgres", cfg.Store)
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, cfg.Origins)
+}
+
+// TestConfig_Errors tests that all the problems are reported at once
+func TestConfig_Errors(t *testing.T) {
+	cfg := defaultServiceConfig()
+	err := config.Load(context.Background(), cfg, config.Options{
+		LookupEnv: environment(map[string]string{
+			"SERVER_PORT":         "",
+			"SERVER_READ_TIMEOUT": "-1s",
+			"DB_MAX_CONNS":        "many",
+			"STORE":               "redis",
+		}),
+	})
+
+	var report config.Errors
+	require.ErrorAs(t, err, &report)
+	assert.Equal(t, `invalid configuration:
+  - database.maxConns (DB_MAX_CONNS): invalid value "many": strconv.ParseInt: parsing "many": invalid syntax
+  - server.port (SERVER_PORT): is required
+  - database.password (DB_PASSWORD): is required
+  - store (STORE): "redis" is not one of postgres, dapr
+  - server.readTimeout: must not be negative`, err.Error())
+
+	// Unknown keys of the file are reported too
+	err = config.Load(context.Background(), defaultServiceConfig(), config.Options{
+		File:      writeFile(t, "database:\n  hots: db.internal\n  password: secret\n"),
+		LookupEnv: environment(nil),
+	})
+	require.ErrorAs(t, err, &report)
+	require.Len(t, report, 1)
+	assert.ErrorContains(t, report[0], "field hots not found")
+}
+
+// TestConfig_Secrets tests that the secrets are read from the Dapr secret
+// store once the sidecar is up
+func TestConfig_Secrets(t *testing.T) {
+	var healthChecks int
+	sidecar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("dapr-api-token") != "sidecar-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1.0/healthz/outbound":
+			// The sidecar starts after the service
+			healthChecks++
+			if healthChecks < 2 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "/v1.0/secrets/secretstore/db-password":
+			w.Write([]byte(`{"db-password":"from-store"}`))
+		case "/v1.0/secrets/emptystore/db-password":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errorCode":"ERR_SECRET_GET","message":"failed getting secret with key db-password from secret store emptystore: secret db-password not found"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer sidecar.Close()
+
+	cfg := defaultServiceConfig()
+	err := config.Load(context.Background(), cfg, config.Options{
+		LookupEnv: environment(map[string]string{
+			"DB_PASSWORD":        "from-env",
+			"DAPR_HTTP_ENDPOINT": sidecar.URL,
+			"DAPR_API_TOKEN":     "sidecar-token",
+			"SECRET_STORE_NAME":  "secretstore",
+		}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "from-store", cfg.Database.Password)
+	assert.Equal(t, "sidecar-token", cfg.Dapr.APIToken)
+	assert.Equal(t, 2, healthChecks)
+
+	// A secret missing from the store is reported
+	cfg = defaultServiceConfig()
+	err = config.Load(context.Background(), cfg, config.Options{
+		LookupEnv: environment(map[string]string{
+			"DAPR_HTTP_ENDPOINT": sidecar.URL,
+			"DAPR_API_TOKEN":     "sidecar-token",
+			"SECRET_STORE_NAME":  "other",
+		}),
+	})
+	assert.ErrorContains(t, err, "database.password (DB_PASSWORD): reading secret db-password: secret store other answered 500")
+
+	// A secret the store does not have keeps the value of the other layers
+	cfg = defaultServiceConfig()
+	err = config.Load(context.Background(), cfg, config.Options{
+		LookupEnv: environment(map[string]string{
+			"DB_PASSWORD":        "from-env",
+			"DAPR_HTTP_ENDPOINT": sidecar.URL,
+			"DAPR_API_TOKEN":     "sidecar-token",
+			"SECRET_STORE_NAME":  "emptystore",
+		}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", cfg.Database.Password)
+
+	// and is only reported when the field is required and no layer sets it
+	cfg = defaultServiceConfig()
+	err = config.Load(context.Background(), cfg, config.Options{
+		LookupEnv: environment(map[string]string{
+			"DAPR_HTTP_ENDPOINT": sidecar.URL,
+			"DAPR_API_TOKEN":     "sidecar-token",
+			"SECRET_STORE_NAME":  "emptystore",
+		}),
+	})
+	assert.EqualError(t, err, "invalid configuration:\n  - database.password (DB_PASSWORD): is required")
+}
+
+// TestConfig_SecretStoreWait tests that an unavailable sidecar is waited for
+// once per store rather than once per secret
+func TestConfig_SecretStoreWait(t *testing.T) {
+	var healthChecks int
+	sidecar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthChecks++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer sidecar.Close()
+
+	store := &config.DaprSecretStore{BaseURL: sidecar.URL, StoreName: "secretstore", WaitTimeout: 100 * time.Millisecond}
+	_, err := store.Secret(context.Background(), "db-password")
+	assert.ErrorContains(t, err, "waiting for the Dapr sidecar: sidecar answered 500")
+	checks := healthChecks
+
+	_, err = store.Secret(context.Background(), "app-api-token")
+	assert.ErrorContains(t, err, "waiting for the Dapr sidecar")
+	assert.Equal(t, checks, healthChecks)
+}
+
+// TestConfig_Print tests that the effective configuration is printed with
+// its secrets redacted
+func TestConfig_Print(t *testing.T) {
+	cfg := defaultServiceConfig()
+	cfg.Database.Password = "s3cr3t"
+
+	var buf bytes.Buffer
+	require.NoError(t, config.Print(&buf, cfg))
+	assert.Equal(t, `server:
+  port: "8080" # SERVER_PORT
+  readTimeout: 5s # SERVER_READ_TIMEOUT
+database:
+  host: localhost # DB_HOST
+  password: '********' # DB_PASSWORD
+  maxConns: 0 # DB_MAX_CONNS
+dapr:
+  httpEndpoint: "" # DAPR_HTTP_ENDPOINT
+  apiToken: "" # DAPR_API_TOKEN
+store: postgres # STORE
+origins: [] # ORIGINS
+secrets:
+  name: "" # SECRET_STORE_NAME
+  waitTimeout: 1s # SECRET_STORE_WAIT_TIMEOUT
+`, buf.String())
+	assert.NotContains(t, buf.String(), "s3cr3t")
+}
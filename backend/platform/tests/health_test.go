@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readReport calls a probe handler and decodes its report
+func readReport(t *testing.T, handler http.Handler) (int, health.Report) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, health.ReadyPath, nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	var report health.Report
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	return rec.Code, report
+}
+
+// TestHealth_Registry tests that readiness fails while a dependency is
+// unavailable or does not answer in time, and that liveness does not depend
+// on the dependencies
+func TestHealth_Registry(t *testing.T) {
+	probes := health.NewRegistry(health.Config{Timeout: time.Second})
+	probes.Register("postgres", func(ctx context.Context) error { return nil })
+	probes.Register("temporal", health.Unavailable(errors.New("not connected")))
+	probes.Register("dapr", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, health.WithTimeout(10*time.Millisecond))
+
+	status, report := readReport(t, probes.ReadyHandler())
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.StatusDown, report.Status)
+	require.Len(t, report.Checks, 3)
+	assert.Equal(t, health.StatusUp, report.Checks[0].Status)
+	assert.Equal(t, health.CheckResult{Name: "temporal", Status: health.StatusDown, Duration: report.Checks[1].Duration, Error: "not connected"}, report.Checks[1])
+	assert.Equal(t, health.StatusDown, report.Checks[2].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[2].Error)
+
+	status, report = readReport(t, probes.LiveHandler())
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Empty(t, report.Checks)
+}
+
+// TestHealth_Checks tests the checks of the Dapr sidecar and of the JWKS
+func TestHealth_Checks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1.0/metadata" && r.Header.Get("dapr-api-token") == "token":
+		case r.URL.Path == "/certs":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	assert.NoError(t, health.DaprSidecar(server.Client(), server.URL+"/", "token")(ctx))
+	assert.Error(t, health.DaprSidecar(server.Client(), server.URL, "other-token")(ctx))
+	assert.NoError(t, health.JWKS(server.Client(), server.URL+"/certs")(ctx))
+	assert.Error(t, health.JWKS(server.Client(), server.URL+"/other")(ctx))
+}
@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stateStoreStandIn serves the state API of a Dapr sidecar, with ETags and first-write concurrency
+type stateStoreStandIn struct {
+	server *httptest.Server
+	mutex  sync.Mutex
+	values map[string]json.RawMessage
+	etags  map[string]int
+}
+
+func newStateStoreStandIn(t *testing.T) *stateStoreStandIn {
+	s := &stateStoreStandIn{values: map[string]json.RawMessage{}, etags: map[string]int{}}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		key := strings.TrimPrefix(r.URL.Path, "/v1.0/state/statestore/")
+		switch {
+		case r.Method == http.MethodGet:
+			value, ok := s.values[key]
+			if !ok {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("ETag", fmt.Sprint(s.etags[key]))
+			w.Write(value)
+		case r.Method == http.MethodDelete:
+			if etag := r.Header.Get("If-Match"); etag != "" && etag != fmt.Sprint(s.etags[key]) {
+				http.Error(w, "possible etag mismatch", http.StatusConflict)
+				return
+			}
+			delete(s.values, key)
+			s.etags[key]++
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost && r.URL.Path == "/v1.0/state/statestore":
+			var items []struct {
+				Key     string            `json:"key"`
+				Value   json.RawMessage   `json:"value"`
+				ETag    string            `json:"etag"`
+				Options map[string]string `json:"options"`
+			}
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &items); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, item := range items {
+				_, exists := s.values[item.Key]
+				if item.Options["concurrency"] == "first-write" &&
+					((item.ETag == "" && exists) || (item.ETag != "" && item.ETag != fmt.Sprint(s.etags[item.Key]))) {
+					http.Error(w, "possible etag mismatch", http.StatusConflict)
+					return
+				}
+				s.values[item.Key] = item.Value
+				s.etags[item.Key]++
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// TestIdempotency_DaprStore tests that keys are reserved once, completed,
+// released and taken over once expired, in a Dapr state store
+func TestIdempotency_DaprStore(t *testing.T) {
+	sidecar := newStateStoreStandIn(t)
+	store := idempotency.NewDaprStore(idempotency.DaprConfig{BaseURL: sidecar.server.URL, StoreName: "statestore"})
+	ctx := context.Background()
+
+	record := &idempotency.Record{Key: "key", RequestHash: "hash", Token: "first", ExpiresAt: time.Now().Add(time.Hour)}
+	existing, err := store.Reserve(ctx, record)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	existing, err = store.Reserve(ctx, &idempotency.Record{Key: "key", RequestHash: "other", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "hash", existing.RequestHash)
+	assert.False(t, existing.Completed)
+
+	record.Completed, record.StatusCode, record.Body = true, http.StatusCreated, []byte(`{"id":"1"}`)
+	require.NoError(t, store.Complete(ctx, record))
+	existing, err = store.Reserve(ctx, &idempotency.Record{Key: "key", RequestHash: "hash", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.True(t, existing.Completed)
+	assert.Equal(t, `{"id":"1"}`, string(existing.Body))
+
+	// Completed keys are not released
+	require.NoError(t, store.Release(ctx, record))
+	existing, err = store.Reserve(ctx, &idempotency.Record{Key: "key", RequestHash: "hash", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.NotNil(t, existing)
+
+	// Reservations are released by the request holding them
+	failed := &idempotency.Record{Key: "failed", RequestHash: "hash", Token: "first", ExpiresAt: time.Now().Add(time.Hour)}
+	_, err = store.Reserve(ctx, failed)
+	require.NoError(t, err)
+	require.NoError(t, store.Release(ctx, &idempotency.Record{Key: "failed", Token: "other"}))
+	existing, err = store.Reserve(ctx, &idempotency.Record{Key: "failed", RequestHash: "hash", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.NotNil(t, existing)
+	require.NoError(t, store.Release(ctx, failed))
+	existing, err = store.Reserve(ctx, &idempotency.Record{Key: "failed", RequestHash: "hash", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// The reservations whose lease ran out, left by a request that crashed
+	// or outlived its lease, are taken over
+	crashed := &idempotency.Record{Key: "crashed", RequestHash: "hash", Token: "first", ExpiresAt: time.Now().Add(-time.Minute)}
+	existing, err = store.Reserve(ctx, crashed)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+	takeover := &idempotency.Record{Key: "crashed", RequestHash: "hash", Token: "second", ExpiresAt: time.Now().Add(time.Minute)}
+	existing, err = store.Reserve(ctx, takeover)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// The request that lost its reservation can neither complete nor
+	// release it
+	crashed.Completed, crashed.StatusCode = true, http.StatusCreated
+	assert.ErrorIs(t, store.Complete(ctx, crashed), idempotency.ErrReservationLost)
+	require.NoError(t, store.Release(ctx, crashed))
+	existing, err = store.Reserve(ctx, &idempotency.Record{Key: "crashed", RequestHash: "hash", Token: "third", ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "second", existing.Token)
+	assert.False(t, existing.Completed)
+
+	// Completed responses expire as well
+	takeover.Completed, takeover.ExpiresAt = true, time.Now().Add(-time.Minute)
+	require.NoError(t, store.Complete(ctx, takeover))
+	existing, err = store.Reserve(ctx, &idempotency.Record{Key: "crashed", RequestHash: "new", Token: "third", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+}
+
+// TestIdempotency_Guard tests that responses are replayed to the retries of
+// their key, in the scope of their caller
+func TestIdempotency_Guard(t *testing.T) {
+	stateStore := newStateStoreStandIn(t)
+	store := idempotency.NewDaprStore(idempotency.DaprConfig{BaseURL: stateStore.server.URL, StoreName: "statestore"})
+	guard := idempotency.NewGuard(store, time.Hour, time.Minute, "Location")
+
+	runs := 0
+	status := http.StatusCreated
+	serve := func(method, key, body string, scope ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/things", strings.NewReader(body))
+		req.Header.Set(idempotency.KeyHeader, key)
+		rec := httptest.NewRecorder()
+		call, err := guard.Begin(rec, req, scope...)
+		var rejection *idempotency.Rejection
+		switch {
+		case errors.As(err, &rejection):
+			rec.WriteHeader(rejection.Status)
+		case err != nil:
+			require.ErrorIs(t, err, idempotency.ErrReplayed)
+		default:
+			runs++
+			var w http.ResponseWriter = rec
+			var recorder *idempotency.Recorder
+			if call != nil {
+				recorder = idempotency.NewRecorder(rec)
+				w = recorder
+			}
+			w.Header().Set("Location", "/things/1")
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+			if call != nil {
+				call.Finish(recorder)
+			}
+		}
+		return rec
+	}
+
+	first := serve(http.MethodPost, "key", "thing", "tenant", "alice")
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(idempotency.ReplayedHeader))
+
+	retry := serve(http.MethodPost, "key", "thing", "tenant", "alice")
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(idempotency.ReplayedHeader))
+	assert.Equal(t, "/things/1", retry.Header().Get("Location"))
+	assert.Equal(t, "thing", retry.Body.String())
+	assert.Equal(t, 1, runs)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, "key", "other", "tenant", "alice").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, strings.Repeat("k", idempotency.MaxKeyLength+1), "thing").Code)
+
+	// Keys are scoped to their caller, and reads are not guarded
+	assert.Empty(t, serve(http.MethodPost, "key", "thing", "tenant", "bob").Header().Get(idempotency.ReplayedHeader))
+	serve(http.MethodGet, "key", "", "tenant", "alice")
+	assert.Equal(t, 3, runs)
+
+	// Server errors are not stored, so that the request can be retried
+	status = http.StatusInternalServerError
+	serve(http.MethodPost, "failed", "thing", "tenant", "alice")
+	status = http.StatusCreated
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "failed", "thing", "tenant", "alice").Code)
+	assert.Equal(t, 5, runs)
+}
@@ -0,0 +1,182 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/jwks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keySet serves a JWKS document for the keys it holds
+type keySet struct {
+	mutex    sync.Mutex
+	keys     []map[string]string
+	down     bool
+	requests int
+	server   *httptest.Server
+}
+
+func newKeySet(t *testing.T) *keySet {
+	s := &keySet{}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.requests++
+
+		if s.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *keySet) add(key map[string]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys = append(s.keys, key)
+}
+
+func (s *keySet) setDown(down bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.down = down
+}
+
+func (s *keySet) requestCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+func encode(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func rsaKey(t *testing.T, kid string) (*rsa.PrivateKey, map[string]string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key, map[string]string{
+		"kid": kid,
+		"kty": "RSA",
+		"use": "sig",
+		"n":   encode(key.N),
+		"e":   encode(big.NewInt(int64(key.E))),
+	}
+}
+
+// TestJWKS_Key tests that the keys of the set are parsed and served by key ID
+func TestJWKS_Key(t *testing.T) {
+	set := newKeySet(t)
+	rsaPrivate, rsaJWK := rsaKey(t, "rsa")
+	set.add(rsaJWK)
+
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	set.add(map[string]string{
+		"kid": "ec",
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   encode(ecPrivate.X),
+		"y":   encode(ecPrivate.Y),
+	})
+
+	// Keys that are not signing keys, or cannot be used, are skipped
+	_, encJWK := rsaKey(t, "enc")
+	encJWK["use"] = "enc"
+	set.add(encJWK)
+	set.add(map[string]string{"kid": "oct", "kty": "oct"})
+
+	cache := jwks.NewCache(set.server.URL, time.Minute, set.server.Client())
+	ctx := context.Background()
+
+	key, err := cache.Key(ctx, "rsa")
+	require.NoError(t, err)
+	assert.True(t, rsaPrivate.PublicKey.Equal(key))
+
+	key, err = cache.Key(ctx, "ec")
+	require.NoError(t, err)
+	assert.True(t, ecPrivate.PublicKey.Equal(key))
+
+	for _, kid := range []string{"enc", "oct"} {
+		_, err = cache.Key(ctx, kid)
+		assert.ErrorIs(t, err, jwks.ErrKeyNotFound, kid)
+	}
+}
+
+// TestJWKS_Refresh tests that the set is fetched again when it is stale or
+// for an unknown key ID, no more than once per throttling delay
+func TestJWKS_Refresh(t *testing.T) {
+	t.Run("UnknownKey", func(t *testing.T) {
+		set := newKeySet(t)
+		_, first := rsaKey(t, "first")
+		set.add(first)
+
+		cache := jwks.NewCache(set.server.URL, time.Hour, set.server.Client())
+		ctx := context.Background()
+
+		_, err := cache.Key(ctx, "first")
+		require.NoError(t, err)
+		assert.Equal(t, 1, set.requestCount())
+
+		// The key is rotated: its ID is unknown to the cache
+		rotated, second := rsaKey(t, "second")
+		set.add(second)
+		key, err := cache.Key(ctx, "second")
+		require.NoError(t, err)
+		assert.True(t, rotated.PublicKey.Equal(key))
+		assert.Equal(t, 2, set.requestCount())
+
+		// Random key IDs do not trigger a fetch each
+		for i := 0; i < 5; i++ {
+			_, err = cache.Key(ctx, "random")
+			assert.ErrorIs(t, err, jwks.ErrKeyNotFound)
+		}
+		assert.Equal(t, 2, set.requestCount())
+	})
+
+	t.Run("StaleKeyServedWhenUnreachable", func(t *testing.T) {
+		set := newKeySet(t)
+		private, jwk := rsaKey(t, "key")
+		set.add(jwk)
+
+		cache := jwks.NewCache(set.server.URL, time.Millisecond, set.server.Client())
+		ctx := context.Background()
+
+		_, err := cache.Key(ctx, "key")
+		require.NoError(t, err)
+
+		set.setDown(true)
+		time.Sleep(5 * time.Millisecond)
+		key, err := cache.Key(ctx, "key")
+		require.NoError(t, err)
+		assert.True(t, private.PublicKey.Equal(key))
+		assert.Equal(t, 2, set.requestCount())
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		set := newKeySet(t)
+		set.setDown(true)
+
+		cache := jwks.NewCache(set.server.URL, time.Minute, set.server.Client())
+		_, err := cache.Key(context.Background(), "key")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, jwks.ErrKeyNotFound)
+	})
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// events records what the components and resources did, in order
+type events struct {
+	mutex sync.Mutex
+	list  []string
+}
+
+func (e *events) add(event string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.list = append(e.list, event)
+}
+
+func (e *events) get() []string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]string(nil), e.list...)
+}
+
+// closeResource returns the closing of a resource, recorded in e
+func (e *events) closeResource(name string) func() error {
+	return func() error {
+		e.add("close " + name)
+		return nil
+	}
+}
+
+// TestLifecycle_Signal tests that SIGTERM lets the in-flight requests and
+// tasks finish, then stops the components and closes the resources in
+// reverse order
+func TestLifecycle_Signal(t *testing.T) {
+	var recorded events
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	requestStarted := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(50 * time.Millisecond)
+		recorded.add("request answered")
+		io.WriteString(w, "done")
+	})}
+
+	manager := lifecycle.NewManager(lifecycle.Config{ShutdownTimeout: 5 * time.Second})
+	manager.Close("database", recorded.closeResource("database"))
+	manager.Close("temporal client", recorded.closeResource("temporal client"))
+	manager.Add(
+		lifecycle.Worker("worker", func(interruptCh <-chan interface{}) error {
+			<-interruptCh
+			recorded.add("worker drained")
+			return nil
+		}),
+		lifecycle.Loop("relay", func(ctx context.Context) {
+			<-ctx.Done()
+			recorded.add("relay stopped")
+		}),
+		lifecycle.Component{
+			Name:  "http server",
+			Start: func() error { return server.Serve(listener) },
+			Stop: func(ctx context.Context) error {
+				recorded.add("http server stopping")
+				return server.Shutdown(ctx)
+			},
+		},
+	)
+
+	result := make(chan error, 1)
+	go func() { result <- manager.Run(context.Background()) }()
+
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-requestStarted
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	select {
+	case err := <-result:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the manager did not stop")
+	}
+	assert.Equal(t, "done", <-response)
+	assert.Equal(t, []string{
+		"http server stopping",
+		"request answered",
+		"relay stopped",
+		"worker drained",
+		"close temporal client",
+		"close database",
+	}, recorded.get())
+}
+
+// TestLifecycle_Failure tests that a failing component stops the service
+// with its error
+func TestLifecycle_Failure(t *testing.T) {
+	var recorded events
+	manager := lifecycle.NewManager(lifecycle.Config{ShutdownTimeout: time.Second})
+	manager.Close("database", recorded.closeResource("database"))
+	manager.Add(
+		lifecycle.Loop("relay", func(ctx context.Context) {
+			<-ctx.Done()
+			recorded.add("relay stopped")
+		}),
+		lifecycle.Component{
+			Name:  "grpc server",
+			Start: func() error { return errors.New("address already in use") },
+			Stop:  func(ctx context.Context) error { return nil },
+		},
+	)
+
+	err := manager.Run(context.Background())
+	assert.EqualError(t, err, "grpc server: address already in use")
+	assert.Equal(t, []string{"relay stopped", "close database"}, recorded.get())
+}
+
+// TestLifecycle_Deadline tests that the components still busy at the
+// deadline are abandoned, and the resources closed anyway
+func TestLifecycle_Deadline(t *testing.T) {
+	var recorded events
+	manager := lifecycle.NewManager(lifecycle.Config{ShutdownTimeout: 20 * time.Millisecond})
+	manager.Close("database", recorded.closeResource("database"))
+	manager.Add(lifecycle.Worker("worker", func(interruptCh <-chan interface{}) error {
+		<-interruptCh
+		time.Sleep(time.Second)
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	err := manager.Run(ctx)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stopping worker: not stopped before the deadline")
+	assert.Equal(t, []string{"close database"}, recorded.get())
+}
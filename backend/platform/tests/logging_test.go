@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+)
+
+// tenantKey binds a tenant to a context, as a service would
+type tenantKey struct{}
+
+// headerMap writes and reads a Temporal header
+type headerMap map[string]*commonpb.Payload
+
+func (h headerMap) Set(key string, value *commonpb.Payload) { h[key] = value }
+
+func (h headerMap) Get(key string) (*commonpb.Payload, bool) {
+	value, ok := h[key]
+	return value, ok
+}
+
+func (h headerMap) ForEachKey(handler func(string, *commonpb.Payload) error) error {
+	for key, value := range h {
+		if err := handler(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTenantSource reads the tenant of the correlations from tenantKey for the
+// duration of the test
+func setTenantSource(t *testing.T) {
+	logging.SetCorrelationSource(func(ctx context.Context, correlation *logging.Correlation) {
+		if tenantID, ok := ctx.Value(tenantKey{}).(string); ok {
+			correlation.TenantID = tenantID
+		}
+	})
+	t.Cleanup(func() { logging.SetCorrelationSource(nil) })
+}
+
+// TestLogging_New tests that the records use Elastic Common Schema field
+// names and carry the correlation of their context
+func TestLogging_New(t *testing.T) {
+	_, err := logging.New(logging.Config{Level: "verbose", Format: "json"}, "service", &bytes.Buffer{})
+	assert.Error(t, err)
+	_, err = logging.New(logging.Config{Level: "info", Format: "xml"}, "service", &bytes.Buffer{})
+	assert.Error(t, err)
+
+	setTenantSource(t)
+	var buf bytes.Buffer
+	logger, err := logging.New(logging.Config{Level: "info", Format: "json"}, "service", &buf)
+	require.NoError(t, err)
+
+	ctx := logging.WithRequestID(context.WithValue(context.Background(), tenantKey{}, "tenant-a"), "request-1")
+	ctx = logging.WithUserID(ctx, "user-1")
+	logger.DebugContext(ctx, "dropped")
+	logger.WarnContext(ctx, "kept")
+
+	var record map[string]interface{}
+	require.NoError(t, json.NewDecoder(&buf).Decode(&record))
+	assert.Equal(t, "kept", record["message"])
+	assert.Equal(t, "warn", record["log.level"])
+	assert.Contains(t, record, "@timestamp")
+	assert.Equal(t, "service", record["service.name"])
+	assert.Equal(t, "request-1", record[logging.RequestIDKey])
+	assert.Equal(t, "tenant-a", record[logging.TenantIDKey])
+	assert.Equal(t, "user-1", record[logging.UserIDKey])
+	assert.False(t, json.NewDecoder(&buf).More())
+}
+
+// TestLogging_RequestIDOrNew tests that only short printable request IDs are
+// accepted from callers
+func TestLogging_RequestIDOrNew(t *testing.T) {
+	assert.Equal(t, "req-42", logging.RequestIDOrNew("req-42"))
+
+	for _, requestID := range []string{"", "with space", "line\nbreak", strings.Repeat("a", 129)} {
+		generated := logging.RequestIDOrNew(requestID)
+		assert.NotEqual(t, requestID, generated)
+		assert.Len(t, generated, 36)
+	}
+}
+
+// TestLogging_TemporalPropagator tests that the correlation of the context
+// starting a workflow travels in its Temporal header
+func TestLogging_TemporalPropagator(t *testing.T) {
+	setTenantSource(t)
+	propagator := logging.TemporalPropagator()
+
+	header := headerMap{}
+	require.NoError(t, propagator.Inject(context.Background(), header))
+	assert.Empty(t, header)
+
+	ctx := logging.WithRequestID(context.WithValue(context.Background(), tenantKey{}, "tenant-a"), "request-1")
+	require.NoError(t, propagator.Inject(ctx, header))
+	assert.Len(t, header, 1)
+
+	extracted, err := propagator.Extract(context.Background(), header)
+	require.NoError(t, err)
+	assert.Equal(t, logging.Correlation{RequestID: "request-1", TenantID: "tenant-a"}, logging.CorrelationFromContext(extracted))
+}
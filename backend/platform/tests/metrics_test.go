@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape returns the metrics of registry in the exposition format
+func scrape(t *testing.T, registry *prometheus.Registry) string {
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+// TestMetrics_TemporalHandler tests that the metrics of the Temporal SDK are
+// exported with a fixed set of labels
+func TestMetrics_TemporalHandler(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	handler := metrics.TemporalHandler(registry).WithTags(map[string]string{
+		"workflow_type": "ProvisionWorkflow",
+		"task_queue":    "provisioning",
+		"unknown_tag":   "dropped",
+	})
+
+	handler.Counter("temporal_workflow_completed").Inc(3)
+	handler.Timer("temporal_workflow_endtoend_latency").Record(2 * time.Second)
+	handler.WithTags(map[string]string{"worker_type": "WorkflowWorker"}).Gauge("temporal_worker_task_slots_available").Update(7)
+	// A second handler reports to the collectors already registered
+	metrics.TemporalHandler(registry).Counter("temporal_workflow_completed").Inc(1)
+
+	body := scrape(t, registry)
+	assert.Contains(t, body, `temporal_workflow_completed_total{activity_type="",cause="",client_name="",failure_reason="",namespace="",operation="",poller_type="",task_queue="provisioning",worker_type="",workflow_type="ProvisionWorkflow"} 3`)
+	assert.Contains(t, body, `temporal_workflow_completed_total{activity_type="",cause="",client_name="",failure_reason="",namespace="",operation="",poller_type="",task_queue="",worker_type="",workflow_type=""} 1`)
+	assert.Contains(t, body, `temporal_workflow_endtoend_latency_seconds_sum{activity_type="",cause="",client_name="",failure_reason="",namespace="",operation="",poller_type="",task_queue="provisioning",worker_type="",workflow_type="ProvisionWorkflow"} 2`)
+	assert.Contains(t, body, `temporal_worker_task_slots_available{activity_type="",cause="",client_name="",failure_reason="",namespace="",operation="",poller_type="",task_queue="provisioning",worker_type="WorkflowWorker",workflow_type="ProvisionWorkflow"} 7`)
+	assert.NotContains(t, body, "unknown_tag")
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider recording the ended spans for the
+// duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+// TestTracing_Transport tests that the outgoing requests continue the trace
+// of their context and pass it on in their headers
+func TestTracing_Transport(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var received trace.SpanContext
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		received = trace.SpanContextFromContext(ctx)
+	}))
+	defer server.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	client := &http.Client{Transport: tracing.Transport(http.DefaultTransport)}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	parent.End()
+
+	assert.Equal(t, parent.SpanContext().TraceID(), received.TraceID())
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Equal(t, received.SpanID(), spans[0].SpanContext().SpanID())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+}
+
+// TestTracing_SQLTracerProvider tests that statements are only traced within
+// a trace
+func TestTracing_SQLTracerProvider(t *testing.T) {
+	recorder := recordSpans(t)
+	tracer := tracing.SQLTracerProvider().Tracer("sql")
+
+	_, span := tracer.Start(context.Background(), "SELECT")
+	span.End()
+	assert.False(t, span.SpanContext().IsValid())
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	_, span = tracer.Start(ctx, "SELECT")
+	span.End()
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "SELECT", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidation_Validator tests that every invalid field is reported once,
+// with its first error
+func TestValidation_Validator(t *testing.T) {
+	v := validation.New()
+	v.Required("email", "")
+	v.Email("email", "")
+	v.Email("contact", "Jane <jane@example.com>")
+	v.MaxLength("name", strings.Repeat("é", 11), 10)
+	v.Phone("phone", "0612345678")
+	v.OneOf("role", "owner", "admin", "user")
+	v.Required("firstName", "Jane")
+	v.Phone("mobile", "+33612345678")
+	v.OneOf("plan", "", "free", "pro")
+
+	var errs validation.Errors
+	require.ErrorAs(t, v.Err(), &errs)
+	assert.Equal(t, validation.Errors{
+		{Field: "email", Message: "is required"},
+		{Field: "contact", Message: "must be an email address"},
+		{Field: "name", Message: "must be at most 10 characters"},
+		{Field: "phone", Message: "must be an E.164 phone number, such as +33612345678"},
+		{Field: "role", Message: "must be one of admin, user"},
+	}, errs)
+	assert.Equal(t, "validation failed: email: is required; contact: must be an email address; "+
+		"name: must be at most 10 characters; phone: must be an E.164 phone number, such as +33612345678; "+
+		"role: must be one of admin, user", errs.Error())
+
+	assert.NoError(t, validation.New().Err())
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/interceptor"
+)
+
+// temporalHeaderKey is the Temporal header holding the trace context, the
+// one used by the OpenTelemetry contrib module of the SDK
+const temporalHeaderKey = "_tracer-data"
+
+// TemporalInterceptor traces the workflows and activities started by the
+// Temporal client and run by its workers. The trace context travels in the
+// Temporal headers, so that a workflow continues the trace of the request
+// that started it, and its activities the trace of the workflow.
+func TemporalInterceptor() interceptor.Interceptor {
+	return interceptor.NewTracingInterceptor(temporalTracer{})
+}
+
+// temporalSpanContextKey holds the span in the contexts of workflows
+type temporalSpanContextKey struct{}
+
+// temporalTracer adapts OpenTelemetry to the tracing interceptor of the SDK
+type temporalTracer struct {
+	interceptor.BaseTracer
+}
+
+// temporalSpan is a span started by the interceptor
+type temporalSpan struct {
+	trace.Span
+}
+
+// temporalSpanRef is a span read from a Temporal header
+type temporalSpanRef struct {
+	spanContext trace.SpanContext
+}
+
+func (temporalTracer) Options() interceptor.TracerOptions {
+	return interceptor.TracerOptions{
+		SpanContextKey: temporalSpanContextKey{},
+		HeaderKey:      temporalHeaderKey,
+	}
+}
+
+func (temporalTracer) UnmarshalSpan(header map[string]string) (interceptor.TracerSpanRef, error) {
+	spanContext := trace.SpanContextFromContext(Propagator.Extract(context.Background(), propagation.MapCarrier(header)))
+	if !spanContext.IsValid() {
+		return nil, errors.New("no trace context in the Temporal header")
+	}
+	return &temporalSpanRef{spanContext: spanContext}, nil
+}
+
+func (temporalTracer) MarshalSpan(span interceptor.TracerSpan) (map[string]string, error) {
+	header := propagation.MapCarrier{}
+	Propagator.Inject(trace.ContextWithSpan(context.Background(), span.(*temporalSpan).Span), header)
+	return header, nil
+}
+
+func (temporalTracer) SpanFromContext(ctx context.Context) interceptor.TracerSpan {
+	span := trace.SpanFromContext(ctx)
+	if !span.SpanContext().IsValid() {
+		return nil
+	}
+	return &temporalSpan{Span: span}
+}
+
+func (temporalTracer) ContextWithSpan(ctx context.Context, span interceptor.TracerSpan) context.Context {
+	return trace.ContextWithSpan(ctx, span.(*temporalSpan).Span)
+}
+
+func (t temporalTracer) StartSpan(options *interceptor.TracerStartSpanOptions) (interceptor.TracerSpan, error) {
+	ctx := context.Background()
+	switch parent := options.Parent.(type) {
+	case nil:
+	case *temporalSpan:
+		ctx = trace.ContextWithSpan(ctx, parent.Span)
+	case *temporalSpanRef:
+		ctx = trace.ContextWithRemoteSpanContext(ctx, parent.spanContext)
+	default:
+		return nil, fmt.Errorf("unknown parent span %T", parent)
+	}
+
+	attributes := make([]attribute.KeyValue, 0, len(options.Tags))
+	for key, value := range options.Tags {
+		attributes = append(attributes, attribute.String(key, value))
+	}
+
+	_, span := tracer().Start(ctx, t.SpanName(options),
+		trace.WithTimestamp(options.Time),
+		trace.WithAttributes(attributes...))
+	return &temporalSpan{Span: span}, nil
+}
+
+// Finish ends the span, recording the error of the traced operation
+func (s *temporalSpan) Finish(options *interceptor.TracerFinishSpanOptions) {
+	if options.Error != nil {
+		s.RecordError(options.Error)
+		s.SetStatus(codes.Error, options.Error.Error())
+	}
+	s.End()
+}
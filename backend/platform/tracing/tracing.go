@@ -0,0 +1,107 @@
+// Package tracing sets up the OpenTelemetry tracing shared by the services:
+// the tracer provider exporting their spans, the W3C trace context passed on
+// to Temporal, Dapr and the SQL statements, and the spans of the Temporal
+// workflows and activities.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// instrumentationName names the tracer of the spans started by the package
+const instrumentationName = "github.com/b-fontaine/saaster_kit/backend/platform/tracing"
+
+// Config holds the tracing configuration
+type Config struct {
+	// Endpoint is the base URL of the OTLP/HTTP collector the spans are
+	// exported to, e.g. http://jaeger:4318. Spans are not exported when it is
+	// empty, but the trace context is still propagated.
+	Endpoint    string
+	ServiceName string
+	// SampleRatio is the fraction of the traces started by the service that
+	// are recorded. Traces started by callers follow their sampling decision.
+	SampleRatio float64
+}
+
+// Propagator carries the trace context in the traceparent and tracestate
+// headers, along with the baggage
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// Setup installs the global tracer provider and propagator. Spans are sent
+// in batches to the OTLP/HTTP endpoint of cfg, if any. The returned function
+// flushes the pending spans and stops the provider.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	}
+	if cfg.Endpoint != "" {
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.Endpoint, "/")+"/v1/traces"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(Propagator)
+	return provider.Shutdown, nil
+}
+
+// tracer returns the tracer of the global provider
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Transport starts a span for the outgoing requests and adds their trace
+// context to their headers, so that the Dapr sidecar continues the trace of
+// the state store, secret store and publish calls
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base, otelhttp.WithPropagators(Propagator))
+}
+
+// SQLTracerProvider provides the tracer of the SQL statements. Statements
+// are only traced within a trace, so that background work such as the
+// polling of the outbox relay does not start a trace for every statement.
+func SQLTracerProvider() trace.TracerProvider {
+	return childTracerProvider{}
+}
+
+// childTracerProvider provides tracers starting no root spans
+type childTracerProvider struct {
+	embedded.TracerProvider
+}
+
+func (childTracerProvider) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	return childTracer{name: name, options: options}
+}
+
+type childTracer struct {
+	embedded.Tracer
+	name    string
+	options []trace.TracerOption
+}
+
+func (t childTracer) Start(ctx context.Context, spanName string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return otel.Tracer(t.name, t.options...).Start(ctx, spanName, options...)
+}
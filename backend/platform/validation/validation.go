@@ -0,0 +1,122 @@
+// Package validation checks the fields of values, reporting every invalid
+// field at once rather than the first one. The services build the rules of
+// their domain values on a Validator.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is a field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error returns the field and its message
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors lists every invalid field found by a validation
+type Errors []FieldError
+
+// Error returns the messages of every invalid field
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Validator collects the invalid fields of a value. A field keeps its first
+// error only, so that a missing field is not also reported as malformed.
+type Validator struct {
+	errors Errors
+}
+
+// New creates a validator without errors
+func New() *Validator {
+	return &Validator{}
+}
+
+// Check records message against field unless ok
+func (v *Validator) Check(ok bool, field, message string) {
+	if ok || v.failed(field) {
+		return
+	}
+	v.errors = append(v.errors, FieldError{Field: field, Message: message})
+}
+
+// Required checks that value is not blank
+func (v *Validator) Required(field, value string) {
+	v.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// MaxLength checks that value has at most max characters
+func (v *Validator) MaxLength(field, value string, max int) {
+	v.Check(utf8.RuneCountInString(value) <= max, field, fmt.Sprintf("must be at most %d characters", max))
+}
+
+// Email checks that value is an email address, without display name. Blank
+// values are left to Required.
+func (v *Validator) Email(field, value string) {
+	if value == "" {
+		return
+	}
+	address, err := mail.ParseAddress(value)
+	v.Check(err == nil && address.Address == value, field, "must be an email address")
+}
+
+// e164Pattern matches international phone numbers in the E.164 format
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// Phone checks that value is a phone number in the E.164 format, such as
+// +33612345678. Blank values are left to Required.
+func (v *Validator) Phone(field, value string) {
+	if value == "" {
+		return
+	}
+	v.Check(e164Pattern.MatchString(value), field, "must be an E.164 phone number, such as +33612345678")
+}
+
+// OneOf checks that value is one of allowed. Blank values are left to Required.
+func (v *Validator) OneOf(field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	v.Check(false, field, "must be one of "+strings.Join(allowed, ", "))
+}
+
+// Errors returns the invalid fields found so far
+func (v *Validator) Errors() Errors {
+	return v.errors
+}
+
+// Err returns the invalid fields as Errors, or nil when the value is valid
+func (v *Validator) Err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
+
+// failed reports whether an error was recorded against field
+func (v *Validator) failed(field string) bool {
+	for _, err := range v.errors {
+		if err.Field == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,5 @@
+// Package userv1 holds the messages and the gRPC service of the user API,
+// generated from user.proto with protoc-gen-go and protoc-gen-go-grpc.
+package userv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative user/v1/user.proto
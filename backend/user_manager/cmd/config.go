@@ -0,0 +1,24 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	platformconfig "github.com/b-fontaine/saaster_kit/backend/platform/config"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/config"
+)
+
+const configUsage = "usage: user_manager config print"
+
+// runConfigCommand runs the config subcommand with its arguments. print
+// writes the effective configuration, with its secrets redacted, and fails
+// with the problems found while loading it.
+func runConfigCommand(cfg *config.Config, loadErr error, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New(configUsage)
+	}
+	if err := platformconfig.Print(os.Stdout, cfg); err != nil {
+		return err
+	}
+	return loadErr
+}
@@ -2,102 +2,252 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
+	"github.com/b-fontaine/saaster_kit/backend/platform/health"
+	"github.com/b-fontaine/saaster_kit/backend/platform/idempotency"
+	"github.com/b-fontaine/saaster_kit/backend/platform/lifecycle"
+	platformlogging "github.com/b-fontaine/saaster_kit/backend/platform/logging"
+	platformtracing "github.com/b-fontaine/saaster_kit/backend/platform/tracing"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/dapr"
+	grpcadapter "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/grpc"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/middleware"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/keycloak"
 	temporaladapter "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/outbox"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/config"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/database"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/logging"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/metrics"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/server"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/tracing"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
 )
 
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
+
+	// Print the effective configuration when run as "user_manager config print"
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfigCommand(cfg, err, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Set up context with cancellation for graceful shutdown
-	_, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Log as JSON records carrying the request, trace, tenant and user IDs.
+	// The standard logger, still used by libraries, writes through it too.
+	logger, err := platformlogging.New(platformlogging.Config{Level: cfg.Log.Level, Format: cfg.Log.Format}, cfg.Tracing.ServiceName, os.Stdout)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	// Manage the schema instead of serving when run as "user_manager migrate ..."
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(cfg, os.Args[2:]); err != nil {
+			fatal("Migration failed", err)
+		}
+		return
+	}
+
+	// The lifecycle manager runs the servers, the worker and the relay until
+	// SIGINT or SIGTERM, then drains them and closes the clients opened
+	// below in reverse order
+	manager := lifecycle.NewManager(lifecycle.Config{ShutdownTimeout: cfg.Shutdown.Timeout})
+
+	// Initialize OpenTelemetry tracing, exported over OTLP when configured.
+	// The spans are flushed last, after the components ended theirs.
+	shutdownTracing, err := platformtracing.Setup(context.Background(), platformtracing.Config{
+		Endpoint:    cfg.Tracing.Endpoint,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	manager.Close("tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
 
 	// Initialize database connection
 	db, err := database.NewPostgresDB(cfg.Database)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
-	defer db.Close()
+	manager.Close("database", db.Close)
 
-	// Run database migrations
+	// Initialize Prometheus metrics, served at /metrics
+	serviceMetrics := metrics.New()
+	serviceMetrics.RegisterDB(db, "user_manager")
+
+	// Apply pending migrations; replicas starting together wait for each other
 	if err := database.RunMigrations(db); err != nil {
-		log.Fatalf("Failed to run database migrations: %v", err)
+		fatal("Failed to run database migrations", err)
 	}
 
-	// Initialize dependency injection container
-	container := di.NewContainer(db, false) // Use PostgreSQL in production
-
 	// Initialize Temporal client
 	temporalClient, err := client.NewClient(client.Options{
 		HostPort:  cfg.Temporal.Address,
 		Namespace: cfg.Temporal.Namespace,
+		// Reports the client, worker and activity metrics
+		MetricsHandler: serviceMetrics.TemporalHandler(),
+		// Continues the traces of the requests in the workflows and activities
+		Interceptors: []interceptor.ClientInterceptor{platformtracing.TemporalInterceptor()},
+		// Passes the correlation of the requests on to the workflow logs
+		ContextPropagators: []workflow.ContextPropagator{platformlogging.TemporalPropagator()},
+		Logger:             platformlogging.TemporalLogger(logger),
 	})
 	if err != nil {
-		log.Fatalf("Failed to create Temporal client: %v", err)
+		fatal("Failed to create Temporal client", err)
 	}
-	defer temporalClient.Close()
+	manager.Close("temporal client", func() error {
+		temporalClient.Close()
+		return nil
+	})
+
+	// Client of the Dapr sidecar, shared by the publisher, the idempotency
+	// store and the readiness probe
+	daprClient := &http.Client{Timeout: 10 * time.Second, Transport: platformtracing.Transport(http.DefaultTransport)}
+	manager.Close("dapr client", func() error {
+		daprClient.CloseIdleConnections()
+		return nil
+	})
+
+	// Initialize dependency injection container
+	statusSync := temporaladapter.NewUserStatusSynchronizer(temporalClient, cfg.Temporal.TaskQueue)
+	container := di.NewContainer(db, false, statusSync, di.WithClientID(cfg.Keycloak.ClientID), di.WithMetrics(serviceMetrics), di.WithTracing()) // Use PostgreSQL in production
+	container.UserHandler.RequireIfMatch(cfg.Server.RequireIfMatch)
+	container.UserServer.RequireVersion(cfg.Server.RequireIfMatch)
 
 	// Initialize Temporal worker
 	temporalWorker := worker.New(temporalClient, cfg.Temporal.TaskQueue, worker.Options{
-		Identity: cfg.Temporal.WorkerName,
+		Identity:     cfg.Temporal.WorkerName,
+		Interceptors: []interceptor.WorkerInterceptor{platformlogging.TemporalInterceptor()},
+		// Lets the running activities finish on shutdown
+		WorkerStopTimeout: cfg.Shutdown.Timeout,
 	})
 
 	// Initialize Temporal workflows
+	keycloakAdmin := keycloak.NewAdminClient(keycloak.AdminConfig{
+		BaseURL:      cfg.Keycloak.AdminURL,
+		Realm:        cfg.Keycloak.Realm,
+		ClientID:     cfg.Keycloak.ClientID,
+		ClientSecret: cfg.Keycloak.ClientSecret,
+	})
 	createUserWorkflow := temporaladapter.NewCreateUserWorkflow(container.CreateUserHandler)
-	workflowRegistry := temporaladapter.NewWorker(createUserWorkflow)
+	syncUserStatusWorkflow := temporaladapter.NewSyncUserStatusWorkflow(container.UserRepository, keycloakAdmin)
+	workflowRegistry := temporaladapter.NewWorker(createUserWorkflow, syncUserStatusWorkflow)
 
 	// Register workflows and activities
 	workflowRegistry.RegisterWorkflows(temporalWorker)
 	workflowRegistry.RegisterActivities(temporalWorker)
 
-	// Start Temporal worker
-	go func() {
-		if err := temporalWorker.Run(worker.InterruptCh()); err != nil {
-			log.Fatalf("Failed to start Temporal worker: %v", err)
-		}
-	}()
-
-	// Initialize HTTP server
-	httpServer := server.NewServer(cfg.Server, container.UserHandler)
-
-	// Start HTTP server
-	go func() {
-		if err := httpServer.Start(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
-		}
-	}()
+	manager.Add(lifecycle.Worker("temporal worker", temporalWorker.Run))
 
-	// Wait for interrupt signal to gracefully shut down the server
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("Shutting down server...")
+	// Run the outbox relay, publishing user events through Dapr pub/sub
+	publisher := dapr.NewPublisher(dapr.PublisherConfig{
+		BaseURL:    cfg.Dapr.HTTPEndpoint,
+		PubSubName: cfg.Dapr.PubSubName,
+		APIToken:   cfg.Dapr.APIToken,
+		HTTPClient: daprClient,
+	})
+	relay := outbox.NewRelay(container.OutboxRepository, publisher, outbox.RelayConfig{
+		PollInterval: cfg.Outbox.PollInterval,
+		BatchSize:    cfg.Outbox.BatchSize,
+		MinBackoff:   cfg.Outbox.MinBackoff,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+		Retention:    cfg.Outbox.Retention,
+	})
+	manager.Add(lifecycle.Loop("outbox relay", relay.Run))
+
+	// Initialize Keycloak token validation
+	keycloakAuth := auth.NewKeycloakAuth(auth.Config{
+		IssuerURL:    cfg.Keycloak.IssuerURL,
+		JWKSURL:      cfg.Keycloak.JWKSURL,
+		Audience:     cfg.Keycloak.Audience,
+		ClientID:     cfg.Keycloak.ClientID,
+		JWKSCacheTTL: cfg.Keycloak.JWKSCacheTTL,
+		Leeway:       cfg.Keycloak.Leeway,
+		// Reject the tokens of deactivated users
+		AccountStatus: container.AccountStatus,
+	})
 
-	// Create a deadline to wait for
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer shutdownCancel()
+	// Initialize the store of the responses replayed to retried requests
+	var idempotencyStore ports.IdempotencyStore
+	switch cfg.Idempotency.Store {
+	case "postgres":
+		idempotencyStore = idempotency.NewPostgresStore(db)
+	case "dapr":
+		idempotencyStore = idempotency.NewDaprStore(idempotency.DaprConfig{
+			BaseURL:    cfg.Dapr.HTTPEndpoint,
+			StoreName:  cfg.Idempotency.StateStoreName,
+			APIToken:   cfg.Dapr.APIToken,
+			HTTPClient: daprClient,
+		})
+	default:
+		slog.Error("Unknown IDEMPOTENCY_STORE, expected postgres or dapr", "store", cfg.Idempotency.Store)
+		os.Exit(1)
+	}
 
-	// Shut down the server
-	if err := httpServer.Stop(shutdownCtx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+	// Initialize HTTP server
+	httpServer := server.NewServer(cfg.Server, container.UserHandler, container.OrganizationHandler, container.DaprHandler, keycloakAuth.TokenValidationMiddleware,
+		logging.CaptureRequest, middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL, cfg.Idempotency.Lease))
+	httpServer.Use(tracing.Middleware, logging.Middleware, serviceMetrics.Middleware)
+	httpServer.UseDapr(middleware.DaprAppToken(cfg.Dapr.AppAPIToken))
+	httpServer.Handle(metrics.Path, serviceMetrics.Handler())
+
+	// Liveness and readiness probes, the latter checking the dependencies
+	// the requests need
+	probes := health.NewRegistry(health.Config{Timeout: cfg.Health.CheckTimeout, CacheTTL: cfg.Health.CacheTTL})
+	probes.Register("postgres", health.Database(db))
+	probes.Register("temporal", health.Temporal(temporalClient))
+	probes.Register("dapr", health.DaprSidecar(daprClient, cfg.Dapr.HTTPEndpoint, cfg.Dapr.APIToken))
+	probes.Register("keycloak", health.JWKS(http.DefaultClient, cfg.Keycloak.JWKSURL))
+	httpServer.Handle(health.LivePath, probes.LiveHandler())
+	httpServer.Handle(health.ReadyPath, probes.ReadyHandler())
+	// GET /health is kept for existing clients, with the readiness report
+	httpServer.Handle("/health", probes.ReadyHandler())
+
+	// Initialize the gRPC server, invoked through the Dapr sidecar of the
+	// user-manager-grpc app
+	grpcServer := server.NewGRPCServer(cfg.GRPC, container.UserServer,
+		tracing.UnaryServerInterceptor(),
+		logging.UnaryServerInterceptor(),
+		serviceMetrics.UnaryServerInterceptor(),
+		grpcadapter.Authenticate(keycloakAuth, container.AccountStatus))
+
+	// Serve until SIGINT or SIGTERM. The servers are stopped first, so that
+	// no workflow is started once the worker drains.
+	manager.Add(
+		lifecycle.Component{Name: "grpc server", Start: grpcServer.Start, Stop: grpcServer.Stop},
+		lifecycle.Component{Name: "http server", Start: httpServer.Start, Stop: httpServer.Stop},
+	)
+	if err := manager.Run(context.Background()); err != nil {
+		fatal("Service stopped", err)
 	}
+}
 
-	log.Println("Server exiting")
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/config"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/database"
+)
+
+const migrateUsage = "usage: user_manager migrate up | down [steps] | version | force <version>"
+
+// runMigrateCommand runs the migrate subcommand with its arguments
+func runMigrateCommand(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := database.NewPostgresDB(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+		if err := migrator.Up(); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 2 {
+			return errors.New(migrateUsage)
+		}
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid steps %q: %s", args[1], migrateUsage)
+			}
+		}
+		if err := migrator.Down(steps); err != nil {
+			return err
+		}
+	case "version":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+	case "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %s", args[1], migrateUsage)
+		}
+		if err := migrator.Force(version); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q: %s", args[0], migrateUsage)
+	}
+
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		fmt.Printf("Schema version: %d (dirty, repair it then run force)\n", version)
+	} else {
+		fmt.Printf("Schema version: %d\n", version)
+	}
+	return nil
+}
@@ -22,6 +22,9 @@ import (
 
 // UserContext holds the context for the user management feature tests
 type UserContext struct {
+	container      *di.Container
+	tenantID       string
+	foreignUser    *domain.User
 	userRepo       ports.UserRepository
 	memoryRepo     *memory.UserRepository
 	testServer     *httptest.Server
@@ -56,6 +59,8 @@ func (c *UserContext) InitializeScenario(ctx *godog.ScenarioContext) {
 	ctx.Step(`^the user should be updated successfully$`, c.theUserShouldBeUpdatedSuccessfully)
 
 	// Delete user steps
+	ctx.Step(`^I deactivate the user$`, c.iDeactivateTheUser)
+	ctx.Step(`^I activate the user$`, c.iActivateTheUser)
 	ctx.Step(`^I delete the user$`, c.iDeleteTheUser)
 	ctx.Step(`^the user should be deleted successfully$`, c.theUserShouldBeDeletedSuccessfully)
 	ctx.Step(`^the user should not exist in the system$`, c.theUserShouldNotExistInTheSystem)
@@ -66,6 +71,10 @@ func (c *UserContext) InitializeScenario(ctx *godog.ScenarioContext) {
 	ctx.Step(`^I should receive a list of (\d+) users$`, c.iShouldReceiveAListOfUsers)
 	ctx.Step(`^the list should include the following users:$`, c.theListShouldIncludeTheFollowingUsers)
 
+	// Tenant isolation steps
+	ctx.Step(`^a user exists in another organization with the following details:$`, c.aUserExistsInAnotherOrganizationWithTheFollowingDetails)
+	ctx.Step(`^the user of the other organization should not be accessible$`, c.theUserOfTheOtherOrganizationShouldNotBeAccessible)
+
 	// Common steps
 	ctx.Step(`^the user should have the following details:$`, c.theUserShouldHaveTheFollowingDetails)
 
@@ -82,15 +91,23 @@ func (c *UserContext) InitializeScenario(ctx *godog.ScenarioContext) {
 // setup sets up the test environment
 func (c *UserContext) setup() error {
 	// Initialize dependency injection container with in-memory repository
-	container := di.NewContainer(nil, true) // Use in-memory repository for tests
+	c.container = di.NewContainer(nil, true, nil) // Use in-memory repository for tests
 
 	// Get the repository from the container
-	c.userRepo = container.UserRepository
+	c.userRepo = c.container.UserRepository
 	c.memoryRepo = c.userRepo.(*memory.UserRepository)
 
+	// Every scenario runs inside its own organization
+	org, err := c.createOrganization("acme")
+	if err != nil {
+		return err
+	}
+	c.tenantID = org.ID
+	c.foreignUser = nil
+
 	// Initialize router
 	router := mux.NewRouter()
-	container.UserHandler.RegisterRoutes(router.PathPrefix("/api/v1").Subrouter())
+	c.container.UserHandler.RegisterRoutes(router.PathPrefix("/api/v1").Subrouter())
 
 	// Initialize test server
 	c.testServer = httptest.NewServer(router)
@@ -120,6 +137,14 @@ func (c *UserContext) cleanDatabase() error {
 	return nil
 }
 
+// createOrganization creates an organization through the command handler
+func (c *UserContext) createOrganization(slug string) (*domain.Organization, error) {
+	return c.container.CreateOrganizationHandler.Handle(context.Background(), commands.CreateOrganizationCommand{
+		Name: slug,
+		Slug: slug,
+	})
+}
+
 // getEnv gets an environment variable with a default value
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -158,6 +183,7 @@ func (c *UserContext) aUserExistsWithTheFollowingDetails(table *godog.Table) err
 		row.Cells[3].Value, // role
 	)
 	user.ID = uuid.New().String()
+	user.TenantID = c.tenantID
 
 	// Save the user
 	if err := c.userRepo.Create(context.Background(), user); err != nil {
@@ -183,6 +209,7 @@ func (c *UserContext) iCreateAUserWithTheFollowingDetails(table *godog.Table) er
 
 	// Create a user
 	cmd := commands.CreateUserCommand{
+		TenantID:  c.tenantID,
 		Email:     row.Cells[0].Value,
 		FirstName: row.Cells[1].Value,
 		LastName:  row.Cells[2].Value,
@@ -190,8 +217,7 @@ func (c *UserContext) iCreateAUserWithTheFollowingDetails(table *godog.Table) er
 	}
 
 	// Create the user
-	container := di.NewContainer(nil, true)
-	user, err := container.CreateUserHandler.Handle(context.Background(), cmd)
+	user, err := c.container.CreateUserHandler.Handle(context.Background(), cmd)
 	if err != nil {
 		c.lastError = err
 		return nil
@@ -220,12 +246,11 @@ func (c *UserContext) iGetTheUserByID() error {
 
 	// Get the user
 	query := queries.GetUserByIDQuery{
-		ID: c.currentUser.ID,
+		TenantID: c.tenantID,
+		ID:       c.currentUser.ID,
 	}
 
-	container := di.NewContainer(nil, true)
-	container.UserRepository = c.userRepo // Use the same repository instance
-	user, err := container.GetUserByIDHandler.Handle(context.Background(), query)
+	user, err := c.container.GetUserByIDHandler.Handle(context.Background(), query)
 	if err != nil {
 		c.lastError = err
 		return nil
@@ -264,6 +289,7 @@ func (c *UserContext) iUpdateTheUserWithTheFollowingDetails(table *godog.Table)
 
 	// Update the user
 	cmd := commands.UpdateUserCommand{
+		TenantID:  c.tenantID,
 		ID:        c.currentUser.ID,
 		Email:     row.Cells[0].Value,
 		FirstName: row.Cells[1].Value,
@@ -271,9 +297,7 @@ func (c *UserContext) iUpdateTheUserWithTheFollowingDetails(table *godog.Table)
 		Role:      row.Cells[3].Value,
 	}
 
-	container := di.NewContainer(nil, true)
-	container.UserRepository = c.userRepo // Use the same repository instance
-	user, err := container.UpdateUserHandler.Handle(context.Background(), cmd)
+	user, err := c.container.UpdateUserHandler.Handle(context.Background(), cmd)
 	if err != nil {
 		c.lastError = err
 		return nil
@@ -295,6 +319,40 @@ func (c *UserContext) theUserShouldBeUpdatedSuccessfully() error {
 	return nil
 }
 
+func (c *UserContext) iDeactivateTheUser() error {
+	if c.currentUser == nil {
+		return fmt.Errorf("no current user")
+	}
+
+	user, err := c.container.DeactivateUserHandler.Handle(context.Background(), commands.DeactivateUserCommand{
+		TenantID: c.tenantID,
+		ID:       c.currentUser.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deactivate user: %w", err)
+	}
+
+	c.currentUser = user
+	return nil
+}
+
+func (c *UserContext) iActivateTheUser() error {
+	if c.currentUser == nil {
+		return fmt.Errorf("no current user")
+	}
+
+	user, err := c.container.ActivateUserHandler.Handle(context.Background(), commands.ActivateUserCommand{
+		TenantID: c.tenantID,
+		ID:       c.currentUser.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to activate user: %w", err)
+	}
+
+	c.currentUser = user
+	return nil
+}
+
 func (c *UserContext) iDeleteTheUser() error {
 	if c.currentUser == nil {
 		return fmt.Errorf("no current user")
@@ -302,12 +360,11 @@ func (c *UserContext) iDeleteTheUser() error {
 
 	// Delete the user
 	cmd := commands.DeleteUserCommand{
-		ID: c.currentUser.ID,
+		TenantID: c.tenantID,
+		ID:       c.currentUser.ID,
 	}
 
-	container := di.NewContainer(nil, true)
-	container.UserRepository = c.userRepo // Use the same repository instance
-	c.lastError = container.DeleteUserHandler.Handle(context.Background(), cmd)
+	c.lastError = c.container.DeleteUserHandler.Handle(context.Background(), cmd)
 
 	return nil
 }
@@ -326,12 +383,11 @@ func (c *UserContext) theUserShouldNotExistInTheSystem() error {
 
 	// Check if the user exists
 	query := queries.GetUserByIDQuery{
-		ID: c.currentUser.ID,
+		TenantID: c.tenantID,
+		ID:       c.currentUser.ID,
 	}
 
-	container := di.NewContainer(nil, true)
-	container.UserRepository = c.userRepo // Use the same repository instance
-	user, err := container.GetUserByIDHandler.Handle(context.Background(), query)
+	user, err := c.container.GetUserByIDHandler.Handle(context.Background(), query)
 	if err != nil {
 		return err
 	}
@@ -366,6 +422,7 @@ func (c *UserContext) theFollowingUsersExist(table *godog.Table) error {
 			row.Cells[3].Value, // role
 		)
 		user.ID = uuid.New().String()
+		user.TenantID = c.tenantID
 
 		// Save the user
 		if err := c.userRepo.Create(context.Background(), user); err != nil {
@@ -381,18 +438,18 @@ func (c *UserContext) theFollowingUsersExist(table *godog.Table) error {
 
 func (c *UserContext) iListAllUsers() error {
 	// List the users
-	query := queries.ListUsersQuery{}
+	query := queries.ListUsersQuery{
+		TenantID: c.tenantID,
+	}
 
-	container := di.NewContainer(nil, true)
-	container.UserRepository = c.userRepo // Use the same repository instance
-	users, err := container.ListUsersHandler.Handle(context.Background(), query)
+	result, err := c.container.ListUsersHandler.Handle(context.Background(), query)
 	if err != nil {
 		c.lastError = err
 		return nil
 	}
 
 	// Store the users
-	c.users = users
+	c.users = result.Users
 
 	return nil
 }
@@ -441,6 +498,82 @@ func (c *UserContext) theListShouldIncludeTheFollowingUsers(table *godog.Table)
 	return nil
 }
 
+func (c *UserContext) aUserExistsInAnotherOrganizationWithTheFollowingDetails(table *godog.Table) error {
+	if len(table.Rows) < 2 {
+		return fmt.Errorf("table must have at least 2 rows")
+	}
+
+	// Get the first data row (skip header)
+	row := table.Rows[1]
+	if len(row.Cells) < 4 {
+		return fmt.Errorf("row must have at least 4 cells")
+	}
+
+	org, err := c.createOrganization("globex")
+	if err != nil {
+		return err
+	}
+
+	// Create the user in the other organization
+	user := domain.NewUser(
+		row.Cells[0].Value, // email
+		row.Cells[1].Value, // first_name
+		row.Cells[2].Value, // last_name
+		row.Cells[3].Value, // role
+	)
+	user.ID = uuid.New().String()
+	user.TenantID = org.ID
+
+	if err := c.userRepo.Create(context.Background(), user); err != nil {
+		return err
+	}
+
+	c.foreignUser = user
+
+	return nil
+}
+
+func (c *UserContext) theUserOfTheOtherOrganizationShouldNotBeAccessible() error {
+	if c.foreignUser == nil {
+		return fmt.Errorf("no user in another organization")
+	}
+
+	ctx := context.Background()
+
+	user, err := c.container.GetUserByIDHandler.Handle(ctx, queries.GetUserByIDQuery{
+		TenantID: c.tenantID,
+		ID:       c.foreignUser.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		return fmt.Errorf("user of another organization was returned")
+	}
+
+	_, err = c.container.UpdateUserHandler.Handle(ctx, commands.UpdateUserCommand{
+		TenantID:  c.tenantID,
+		ID:        c.foreignUser.ID,
+		Email:     c.foreignUser.Email,
+		FirstName: "Hijacked",
+		LastName:  c.foreignUser.LastName,
+		Role:      c.foreignUser.Role,
+	})
+	if err != domain.ErrUserNotFound {
+		return fmt.Errorf("expected %v when updating a user of another organization, got %v", domain.ErrUserNotFound, err)
+	}
+
+	err = c.container.DeleteUserHandler.Handle(ctx, commands.DeleteUserCommand{
+		TenantID: c.tenantID,
+		ID:       c.foreignUser.ID,
+	})
+	if err != domain.ErrUserNotFound {
+		return fmt.Errorf("expected %v when deleting a user of another organization, got %v", domain.ErrUserNotFound, err)
+	}
+
+	return nil
+}
+
 func (c *UserContext) theUserShouldHaveTheFollowingDetails(table *godog.Table) error {
 	if c.currentUser == nil {
 		return fmt.Errorf("no current user")
@@ -491,7 +624,7 @@ func TestFeatures(t *testing.T) {
 		},
 		Options: &godog.Options{
 			Format:   "pretty",
-			Paths:    []string{"features"},
+			Paths:    []string{"."},
 			TestingT: t,
 		},
 	}
@@ -38,6 +38,19 @@ Feature: User Management
       | email           | first_name | last_name | role    | active |
       | bob@example.com  | Robert     | Johnson   | admin   | true   |
 
+  Scenario: Deactivate and reactivate user
+    Given a user exists with the following details:
+      | email           | first_name | last_name | role    |
+      | carol@example.com | Carol      | White     | user    |
+    When I deactivate the user
+    Then the user should have the following details:
+      | email           | first_name | last_name | role    | active |
+      | carol@example.com | Carol      | White     | user    | false  |
+    When I activate the user
+    Then the user should have the following details:
+      | email           | first_name | last_name | role    | active |
+      | carol@example.com | Carol      | White     | user    | true   |
+
   Scenario: Delete user
     Given a user exists with the following details:
       | email           | first_name | last_name | role    |
@@ -59,3 +72,14 @@ Feature: User Management
       | user1@example.com | User       | One       | admin   |
       | user2@example.com | User       | Two       | user    |
       | user3@example.com | User       | Three     | user    |
+
+  Scenario: Users of another organization are isolated
+    Given a user exists in another organization with the following details:
+      | email           | first_name | last_name | role    |
+      | eve@example.com  | Eve        | Other     | admin   |
+    And the following users exist:
+      | email           | first_name | last_name | role    |
+      | user1@example.com | User       | One       | admin   |
+    When I list all users
+    Then I should receive a list of 1 users
+    And the user of the other organization should not be accessible
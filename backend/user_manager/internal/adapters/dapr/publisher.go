@@ -0,0 +1,127 @@
+package dapr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// EventSource is the CloudEvents source of the events of the service
+const EventSource = "user-manager"
+
+// Topics and CloudEvents types of the domain events
+var eventRoutes = map[domain.EventType]struct {
+	topic     string
+	eventType string
+}{
+	domain.UserCreatedEvent: {topic: "user-created", eventType: "com.saaster.user.created"},
+	domain.UserUpdatedEvent: {topic: "user-updated", eventType: "com.saaster.user.updated"},
+	domain.UserDeletedEvent: {topic: "user-deleted", eventType: "com.saaster.user.deleted"},
+	domain.UserLinkedEvent:  {topic: "user-linked", eventType: "com.saaster.user.linked"},
+}
+
+// PublisherConfig holds the settings needed to publish through a Dapr sidecar
+type PublisherConfig struct {
+	// BaseURL is the HTTP endpoint of the sidecar, e.g. http://localhost:3500
+	BaseURL string
+	// PubSubName is the name of the Dapr pub/sub component
+	PubSubName string
+	// APIToken authenticates the service to the sidecar, if it requires it
+	APIToken string
+	// HTTPClient is used to call the sidecar
+	HTTPClient *http.Client
+}
+
+// CloudEvent is the CloudEvents 1.0 envelope of published events
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	// TenantID is an extension attribute holding the tenant of the event
+	TenantID string `json:"tenantid,omitempty"`
+}
+
+// Publisher is a Dapr pub/sub implementation of the EventPublisher interface
+type Publisher struct {
+	baseURL    string
+	pubSubName string
+	apiToken   string
+	httpClient *http.Client
+}
+
+// NewPublisher creates a new Publisher
+func NewPublisher(cfg PublisherConfig) ports.EventPublisher {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Publisher{
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		pubSubName: cfg.PubSubName,
+		apiToken:   cfg.APIToken,
+		httpClient: httpClient,
+	}
+}
+
+// Publish publishes an event as a CloudEvent. The event ID is kept as the
+// CloudEvent ID, so that subscribers can deduplicate redeliveries.
+func (p *Publisher) Publish(ctx context.Context, event *domain.Event) error {
+	route, ok := eventRoutes[event.Type]
+	if !ok {
+		return fmt.Errorf("no topic for %s events", event.Type)
+	}
+
+	body, err := json.Marshal(CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              event.ID,
+		Source:          EventSource,
+		Type:            route.eventType,
+		Subject:         event.AggregateID,
+		Time:            event.OccurredAt.UTC(),
+		DataContentType: "application/json",
+		Data:            event.Data,
+		TenantID:        event.TenantID,
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1.0/publish/%s/%s", p.baseURL, url.PathEscape(p.pubSubName), url.PathEscape(route.topic))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	// Dapr forwards CloudEvents as is instead of wrapping them in its own envelope
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+	if p.apiToken != "" {
+		req.Header.Set("dapr-api-token", p.apiToken)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", event.Type, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to publish %s event: dapr returned %s: %s", event.Type, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return nil
+}
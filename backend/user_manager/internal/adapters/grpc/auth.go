@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	userv1 "github.com/b-fontaine/saaster_kit/backend/user_manager/api/user/v1"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenValidator validates the access tokens sent with the calls
+type TokenValidator interface {
+	ValidateToken(ctx context.Context, token string) (*auth.Claims, error)
+}
+
+// userServicePrefix starts the full method names of the UserService calls
+var userServicePrefix = "/" + userv1.UserService_ServiceDesc.ServiceName + "/"
+
+// Authenticate returns the interceptor validating the bearer token sent in
+// the authorization metadata of the UserService calls, and storing its claims
+// in the call context. The tokens of deactivated users, and of accounts not
+// linked to a user yet, are rejected when accountStatus is set. Other services, such as health and reflection, stay
+// public.
+func Authenticate(validator TokenValidator, accountStatus auth.AccountStatusChecker) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, userServicePrefix) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+		}
+
+		parts := strings.Split(values[0], " ")
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			return nil, status.Error(codes.Unauthenticated, "authorization metadata format must be Bearer {token}")
+		}
+
+		claims, err := validator.ValidateToken(ctx, parts[1])
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+
+		// Reject users deactivated since the token was issued
+		if accountStatus != nil {
+			accountState, err := accountStatus.AccountStatus(ctx, claims)
+			if err != nil {
+				return nil, status.Error(codes.Unavailable, "unable to verify account status")
+			}
+			switch accountState {
+			case auth.AccountDeactivated:
+				return nil, status.Error(codes.PermissionDenied, "account is deactivated")
+			case auth.AccountUnlinked:
+				return nil, status.Error(codes.PermissionDenied, "account is not linked to a user of the organization")
+			}
+		}
+
+		return handler(auth.WithClaims(ctx, claims), req)
+	}
+}
@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"errors"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus returns the status matching err, which may wrap a domain error.
+// Invalid fields are listed in a BadRequest detail, as the HTTP API lists
+// them in its validation problems.
+func toStatus(err error) error {
+	var validationErrs domain.ValidationErrors
+	var validationErr domain.ValidationError
+	switch {
+	case errors.As(err, &validationErrs):
+		return invalidArgument("the request has invalid fields", validationErrs...)
+	case errors.As(err, &validationErr):
+		return invalidArgument(validationErr.Error(), validationErr)
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrOrganizationNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrUserAlreadyExists), errors.Is(err, domain.ErrOrganizationAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrOrganizationSuspended), errors.Is(err, domain.ErrTenantRequired):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, domain.ErrInvalidUserData):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrVersionMismatch):
+		return status.Error(codes.Aborted, "the user has changed since the version of the request")
+	default:
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// invalidArgument returns an INVALID_ARGUMENT status listing the invalid fields
+func invalidArgument(message string, fields ...domain.ValidationError) error {
+	badRequest := &errdetails.BadRequest{}
+	for _, field := range fields {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field.Field,
+			Description: field.Message,
+		})
+	}
+
+	st, err := status.New(codes.InvalidArgument, message).WithDetails(badRequest)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, message)
+	}
+	return st.Err()
+}
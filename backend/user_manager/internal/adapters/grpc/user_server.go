@@ -0,0 +1,369 @@
+// Package grpc serves the user API over gRPC. The UserService calls dispatch
+// the same commands and queries as the HTTP handlers, with the same access
+// rules.
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	userv1 "github.com/b-fontaine/saaster_kit/backend/user_manager/api/user/v1"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/commands"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/queries"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// MaxBatchGetUsers is the maximum number of users of a BatchGetUsers call
+const MaxBatchGetUsers = queries.MaxListUsersLimit
+
+// UserServer implements the UserService
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+
+	createUserHandler     *commands.CreateUserHandler
+	updateUserHandler     *commands.UpdateUserHandler
+	deleteUserHandler     *commands.DeleteUserHandler
+	activateUserHandler   *commands.ActivateUserHandler
+	deactivateUserHandler *commands.DeactivateUserHandler
+	getUserByIDHandler    *queries.GetUserByIDHandler
+	listUsersHandler      *queries.ListUsersHandler
+	authorizer            *auth.Authorizer
+	requireVersion        bool
+}
+
+// NewUserServer creates a new UserServer
+func NewUserServer(
+	createUserHandler *commands.CreateUserHandler,
+	updateUserHandler *commands.UpdateUserHandler,
+	deleteUserHandler *commands.DeleteUserHandler,
+	activateUserHandler *commands.ActivateUserHandler,
+	deactivateUserHandler *commands.DeactivateUserHandler,
+	getUserByIDHandler *queries.GetUserByIDHandler,
+	listUsersHandler *queries.ListUsersHandler,
+	authorizer *auth.Authorizer,
+) *UserServer {
+	return &UserServer{
+		createUserHandler:     createUserHandler,
+		updateUserHandler:     updateUserHandler,
+		deleteUserHandler:     deleteUserHandler,
+		activateUserHandler:   activateUserHandler,
+		deactivateUserHandler: deactivateUserHandler,
+		getUserByIDHandler:    getUserByIDHandler,
+		listUsersHandler:      listUsersHandler,
+		authorizer:            authorizer,
+	}
+}
+
+// RequireVersion makes the version mandatory on updates and deletions, as
+// If-Match is on the HTTP API
+func (s *UserServer) RequireVersion(required bool) {
+	s.requireVersion = required
+}
+
+// GetUser returns a user
+func (s *UserServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	tenantID, _, err := s.authorize(ctx, auth.PermissionUsersRead, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.getUserByIDHandler.Handle(ctx, queries.GetUserByIDQuery{
+		TenantID: tenantID,
+		ID:       req.GetId(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if user == nil {
+		return nil, status.Error(codes.NotFound, domain.ErrUserNotFound.Error())
+	}
+
+	return &userv1.GetUserResponse{User: toUser(user)}, nil
+}
+
+// BatchGetUsers returns the requested users that exist, in the order of the request
+func (s *UserServer) BatchGetUsers(ctx context.Context, req *userv1.BatchGetUsersRequest) (*userv1.BatchGetUsersResponse, error) {
+	tenantID, _, err := s.authorize(ctx, auth.PermissionUsersRead, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(req.GetIds()) > MaxBatchGetUsers {
+		return nil, invalidArgument("too many users requested", domain.NewValidationError("ids", "at most 200 users can be requested at once"))
+	}
+
+	response := &userv1.BatchGetUsersResponse{}
+	for _, id := range req.GetIds() {
+		user, err := s.getUserByIDHandler.Handle(ctx, queries.GetUserByIDQuery{
+			TenantID: tenantID,
+			ID:       id,
+		})
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		if user == nil {
+			response.MissingIds = append(response.MissingIds, id)
+			continue
+		}
+		response.Users = append(response.Users, toUser(user))
+	}
+
+	return response, nil
+}
+
+// ListUsers returns a page of users
+func (s *UserServer) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	tenantID, _, err := s.authorize(ctx, auth.PermissionUsersRead, "")
+	if err != nil {
+		return nil, err
+	}
+
+	query := queries.ListUsersQuery{
+		TenantID: tenantID,
+		Role:     req.GetRole(),
+		Active:   req.Active,
+		Search:   req.GetQuery(),
+		Limit:    int(req.GetLimit()),
+		Cursor:   req.GetCursor(),
+	}
+	if req.CreatedAfter != nil {
+		query.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		query.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+	if sort := req.GetSort(); sort != "" {
+		query.SortBy = strings.TrimPrefix(sort, "-")
+		query.Ascending = !strings.HasPrefix(sort, "-")
+	}
+
+	result, err := s.listUsersHandler.Handle(ctx, query)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	response := &userv1.ListUsersResponse{
+		Users:      make([]*userv1.User, 0, len(result.Users)),
+		NextCursor: result.NextCursor,
+	}
+	for _, user := range result.Users {
+		response.Users = append(response.Users, toUser(user))
+	}
+
+	return response, nil
+}
+
+// CreateUser creates a user in the caller's organization
+func (s *UserServer) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.CreateUserResponse, error) {
+	tenantID, _, err := s.authorize(ctx, auth.PermissionUsersWrite, "")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.createUserHandler.Handle(ctx, commands.CreateUserCommand{
+		TenantID:   tenantID,
+		Email:      req.GetEmail(),
+		FirstName:  req.GetFirstName(),
+		LastName:   req.GetLastName(),
+		Role:       req.GetRole(),
+		ExternalID: req.GetExternalId(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.CreateUserResponse{User: toUser(user)}, nil
+}
+
+// UpdateUser replaces the fields of a user, and changes its active state when requested
+func (s *UserServer) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*userv1.UpdateUserResponse, error) {
+	tenantID, self, err := s.authorize(ctx, auth.PermissionUsersWrite, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkVersion(req.GetVersion()); err != nil {
+		return nil, err
+	}
+
+	// Users updating themselves cannot change their own role or active state
+	if self {
+		current, err := s.getUserByIDHandler.Handle(ctx, queries.GetUserByIDQuery{TenantID: tenantID, ID: req.GetId()})
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		if current != nil && current.Role != req.GetRole() {
+			return nil, permissionDenied("changing a role requires an admin role", auth.PermissionUsersWrite)
+		}
+		if current != nil && req.Active != nil && current.Active != req.GetActive() {
+			return nil, permissionDenied("changing the active state requires an admin role", auth.PermissionUsersWrite)
+		}
+	}
+
+	user, err := s.updateUserHandler.Handle(ctx, commands.UpdateUserCommand{
+		TenantID:  tenantID,
+		ID:        req.GetId(),
+		Email:     req.GetEmail(),
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Role:      req.GetRole(),
+
+		ExpectedVersion: req.GetVersion(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	if req.Active != nil && user.Active != req.GetActive() {
+		user, err = s.setActive(ctx, tenantID, req.GetId(), req.GetActive())
+		if err != nil {
+			return nil, toStatus(err)
+		}
+	}
+
+	return &userv1.UpdateUserResponse{User: toUser(user)}, nil
+}
+
+// DeleteUser deletes a user
+func (s *UserServer) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*userv1.DeleteUserResponse, error) {
+	tenantID, _, err := s.authorize(ctx, auth.PermissionUsersDelete, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkVersion(req.GetVersion()); err != nil {
+		return nil, err
+	}
+
+	err = s.deleteUserHandler.Handle(ctx, commands.DeleteUserCommand{
+		TenantID:        tenantID,
+		ID:              req.GetId(),
+		ExpectedVersion: req.GetVersion(),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.DeleteUserResponse{}, nil
+}
+
+// ActivateUser activates a user
+func (s *UserServer) ActivateUser(ctx context.Context, req *userv1.ActivateUserRequest) (*userv1.ActivateUserResponse, error) {
+	tenantID, _, err := s.authorize(ctx, auth.PermissionUsersWrite, "")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.setActive(ctx, tenantID, req.GetId(), true)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.ActivateUserResponse{User: toUser(user)}, nil
+}
+
+// DeactivateUser deactivates a user
+func (s *UserServer) DeactivateUser(ctx context.Context, req *userv1.DeactivateUserRequest) (*userv1.DeactivateUserResponse, error) {
+	tenantID, _, err := s.authorize(ctx, auth.PermissionUsersWrite, "")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.setActive(ctx, tenantID, req.GetId(), false)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.DeactivateUserResponse{User: toUser(user)}, nil
+}
+
+// setActive dispatches the command activating or deactivating a user
+func (s *UserServer) setActive(ctx context.Context, tenantID, id string, active bool) (*domain.User, error) {
+	if active {
+		return s.activateUserHandler.Handle(ctx, commands.ActivateUserCommand{
+			TenantID: tenantID,
+			ID:       id,
+		})
+	}
+	return s.deactivateUserHandler.Handle(ctx, commands.DeactivateUserCommand{
+		TenantID: tenantID,
+		ID:       id,
+	})
+}
+
+// authorize checks that the caller holds the permission, or acts on their
+// own user when selfID is set, as auth.Authorizer does for the HTTP routes.
+// It returns the caller's organization, and whether the call was only
+// authorized because the caller acts on themselves.
+func (s *UserServer) authorize(ctx context.Context, permission auth.Permission, selfID string) (string, bool, error) {
+	claims, ok := auth.ClaimsFromContext(ctx)
+	if !ok {
+		return "", false, status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	self := false
+	if !s.authorizer.Policy().HasPermission(claims, permission) {
+		isSelf, err := s.authorizer.IsSelf(ctx, claims, selfID)
+		if err != nil {
+			return "", false, status.Error(codes.Unavailable, "unable to verify the caller's user")
+		}
+		if !isSelf {
+			return "", false, permissionDenied("missing permission "+string(permission), permission)
+		}
+		self = true
+	}
+
+	if claims.TenantID == "" {
+		return "", false, permissionDenied("token is not bound to an organization", "")
+	}
+	return claims.TenantID, self, nil
+}
+
+// checkVersion rejects versions that cannot match a user, and missing
+// versions when they are required
+func (s *UserServer) checkVersion(version int64) error {
+	if version < 0 {
+		return invalidArgument("the request has invalid fields", domain.NewValidationError("version", "version must be positive"))
+	}
+	if version == 0 && s.requireVersion {
+		return status.Error(codes.FailedPrecondition, "version is required")
+	}
+	return nil
+}
+
+// permissionDenied returns a PERMISSION_DENIED status naming the missing
+// permission, if any, in an ErrorInfo detail
+func permissionDenied(message string, permission auth.Permission) error {
+	st := status.New(codes.PermissionDenied, message)
+	if permission == "" {
+		return st.Err()
+	}
+
+	withInfo, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   "MISSING_PERMISSION",
+		Domain:   userv1.UserService_ServiceDesc.ServiceName,
+		Metadata: map[string]string{"required_permission": string(permission)},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withInfo.Err()
+}
+
+func toUser(user *domain.User) *userv1.User {
+	return &userv1.User{
+		Id:         user.ID,
+		TenantId:   user.TenantID,
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Role:       user.Role,
+		Active:     user.Active,
+		CreatedAt:  timestamppb.New(user.CreatedAt),
+		UpdatedAt:  timestamppb.New(user.UpdatedAt),
+		Version:    user.Version,
+		ExternalId: user.ExternalID,
+	}
+}
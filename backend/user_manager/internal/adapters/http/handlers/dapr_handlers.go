@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// DaprSubscription is an entry of the programmatic subscriptions of the service
+type DaprSubscription struct {
+	PubSubName string `json:"pubsubname"`
+	Topic      string `json:"topic"`
+	Route      string `json:"route"`
+}
+
+// DaprHandler handles the requests of the Dapr sidecar
+type DaprHandler struct {
+	subscriptions []DaprSubscription
+}
+
+// NewDaprHandler creates a new DaprHandler subscribing to subscriptions. The
+// service publishes the user events through its outbox and subscribes to no
+// topic yet.
+func NewDaprHandler(subscriptions ...DaprSubscription) *DaprHandler {
+	return &DaprHandler{subscriptions: subscriptions}
+}
+
+// RegisterRoutes registers the routes called by the Dapr sidecar on a router
+// serving /dapr
+func (h *DaprHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/subscribe", h.Subscribe).Methods(http.MethodGet)
+}
+
+// Subscribe lists the topics the service subscribes to
+func (h *DaprHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	subscriptions := h.subscriptions
+	if subscriptions == nil {
+		subscriptions = []DaprSubscription{}
+	}
+	respondWithJSON(w, http.StatusOK, subscriptions)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+)
+
+// etag returns the strong entity tag of a resource at version
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion returns the version required by the If-Match header of a
+// request, or 0 when any version may be changed. It answers the request
+// itself and returns false when the header is missing but required, with
+// 428 Precondition Required, or names no version of the resource, with
+// 412 Precondition Failed. Only a single entity tag or "*" is understood.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request, required bool) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch header {
+	case "":
+		if required {
+			problem.Error(w, r, http.StatusPreconditionRequired, "If-Match header is required")
+			return 0, false
+		}
+		return 0, true
+	case "*":
+		return 0, true
+	}
+
+	// Weak tags never match with If-Match, and versions start at 1
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || err != nil || version < 1 {
+		problem.Error(w, r, http.StatusPreconditionFailed, "If-Match does not match the current version")
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/commands"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/queries"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// OrganizationResponse represents the response for an organization
+type OrganizationResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	Plan      string `json:"plan"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// CreateOrganizationRequest represents the request to create an organization
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+	Plan string `json:"plan"`
+}
+
+// OrganizationHandler handles HTTP requests for organizations
+type OrganizationHandler struct {
+	createOrganizationHandler *commands.CreateOrganizationHandler
+	getOrganizationHandler    *queries.GetOrganizationHandler
+	createUserHandler         *commands.CreateUserHandler
+	authorizer                *auth.Authorizer
+}
+
+// NewOrganizationHandler creates a new OrganizationHandler
+func NewOrganizationHandler(
+	createOrganizationHandler *commands.CreateOrganizationHandler,
+	getOrganizationHandler *queries.GetOrganizationHandler,
+	createUserHandler *commands.CreateUserHandler,
+	authorizer *auth.Authorizer,
+) *OrganizationHandler {
+	return &OrganizationHandler{
+		createOrganizationHandler: createOrganizationHandler,
+		getOrganizationHandler:    getOrganizationHandler,
+		createUserHandler:         createUserHandler,
+		authorizer:                authorizer,
+	}
+}
+
+// Access rules of the organization routes. Any member may read their own
+// organization; creating organizations and their first users is reserved to
+// platform admins.
+var (
+	createOrganizationRule     = auth.Rule{Permission: auth.PermissionOrganizationsWrite}
+	createOrganizationUserRule = auth.Rule{Permission: auth.PermissionOrganizationsWrite}
+	getCurrentOrganizationRule = auth.Rule{}
+)
+
+// RegisterRoutes registers the routes for the OrganizationHandler
+func (h *OrganizationHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/organizations", h.authorizer.Require(createOrganizationRule, h.CreateOrganization)).Methods(http.MethodPost)
+	router.HandleFunc("/organizations/current", h.authorizer.Require(getCurrentOrganizationRule, h.GetCurrentOrganization)).Methods(http.MethodGet)
+	router.HandleFunc("/organizations/{id}/users", h.authorizer.Require(createOrganizationUserRule, h.CreateOrganizationUser)).Methods(http.MethodPost)
+}
+
+// CreateOrganization handles the request to create an organization
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	cmd := commands.CreateOrganizationCommand{
+		Name: req.Name,
+		Slug: req.Slug,
+		Plan: req.Plan,
+	}
+
+	org, err := h.createOrganizationHandler.Handle(r.Context(), cmd)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, toOrganizationResponse(org))
+}
+
+// CreateOrganizationUser handles the request of a platform admin to create a
+// user in the organization named by the path, such as the first admin of a
+// new organization. Platform admin tokens are not bound to an organization,
+// so they cannot use POST /users.
+func (h *OrganizationHandler) CreateOrganizationUser(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)["id"]
+	if uuid.Validate(tenantID) != nil {
+		problem.Error(w, r, http.StatusNotFound, domain.ErrOrganizationNotFound.Error())
+		return
+	}
+
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	cmd := commands.CreateUserCommand{
+		TenantID:   tenantID,
+		Email:      req.Email,
+		FirstName:  req.FirstName,
+		LastName:   req.LastName,
+		Role:       req.Role,
+		ExternalID: req.ExternalID,
+	}
+
+	user, err := h.createUserHandler.Handle(r.Context(), cmd)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	respondWithUser(w, http.StatusCreated, user)
+}
+
+// GetCurrentOrganization handles the request to get the caller's organization
+func (h *OrganizationHandler) GetCurrentOrganization(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := callerTenantID(w, r)
+	if !ok {
+		return
+	}
+
+	query := queries.GetOrganizationQuery{
+		TenantID: tenantID,
+	}
+
+	org, err := h.getOrganizationHandler.Handle(r.Context(), query)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	if org == nil {
+		problem.Error(w, r, http.StatusNotFound, "Organization not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, toOrganizationResponse(org))
+}
+
+func toOrganizationResponse(org *domain.Organization) OrganizationResponse {
+	return OrganizationResponse{
+		ID:        org.ID,
+		Name:      org.Name,
+		Slug:      org.Slug,
+		Plan:      org.Plan,
+		Status:    org.Status,
+		CreatedAt: org.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt: org.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+}
@@ -2,17 +2,25 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/commands"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/queries"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain/validation"
 	"github.com/gorilla/mux"
 )
 
 // UserResponse represents the response for a user
 type UserResponse struct {
 	ID        string `json:"id"`
+	TenantID  string `json:"tenant_id"`
 	Email     string `json:"email"`
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
@@ -20,6 +28,10 @@ type UserResponse struct {
 	Active    bool   `json:"active"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+	// Version is also sent as the ETag of the user
+	Version int64 `json:"version"`
+	// ExternalID is the identity provider account of the user, once linked
+	ExternalID string `json:"external_id,omitempty"`
 }
 
 // CreateUserRequest represents the request to create a user
@@ -28,6 +40,9 @@ type CreateUserRequest struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	Role      string `json:"role"`
+	// ExternalID optionally links the user to an existing identity provider
+	// account; otherwise the user links it with POST /users/me/link
+	ExternalID string `json:"external_id,omitempty"`
 }
 
 // UpdateUserRequest represents the request to update a user
@@ -36,16 +51,23 @@ type UpdateUserRequest struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	Role      string `json:"role"`
-	Active    bool   `json:"active"`
+	// Active is optional; when set, the user is activated or deactivated
+	// exactly as with the dedicated endpoints
+	Active *bool `json:"active,omitempty"`
 }
 
 // UserHandler handles HTTP requests for users
 type UserHandler struct {
-	createUserHandler *commands.CreateUserHandler
-	updateUserHandler *commands.UpdateUserHandler
-	deleteUserHandler *commands.DeleteUserHandler
-	getUserByIDHandler *queries.GetUserByIDHandler
-	listUsersHandler *queries.ListUsersHandler
+	createUserHandler      *commands.CreateUserHandler
+	updateUserHandler      *commands.UpdateUserHandler
+	deleteUserHandler      *commands.DeleteUserHandler
+	activateUserHandler    *commands.ActivateUserHandler
+	deactivateUserHandler  *commands.DeactivateUserHandler
+	getUserByIDHandler     *queries.GetUserByIDHandler
+	listUsersHandler       *queries.ListUsersHandler
+	linkUserAccountHandler *commands.LinkUserAccountHandler
+	authorizer             *auth.Authorizer
+	requireIfMatch         bool
 }
 
 // NewUserHandler creates a new UserHandler
@@ -53,173 +75,455 @@ func NewUserHandler(
 	createUserHandler *commands.CreateUserHandler,
 	updateUserHandler *commands.UpdateUserHandler,
 	deleteUserHandler *commands.DeleteUserHandler,
+	activateUserHandler *commands.ActivateUserHandler,
+	deactivateUserHandler *commands.DeactivateUserHandler,
 	getUserByIDHandler *queries.GetUserByIDHandler,
 	listUsersHandler *queries.ListUsersHandler,
+	linkUserAccountHandler *commands.LinkUserAccountHandler,
+	authorizer *auth.Authorizer,
 ) *UserHandler {
 	return &UserHandler{
-		createUserHandler: createUserHandler,
-		updateUserHandler: updateUserHandler,
-		deleteUserHandler: deleteUserHandler,
-		getUserByIDHandler: getUserByIDHandler,
-		listUsersHandler: listUsersHandler,
+		createUserHandler:      createUserHandler,
+		updateUserHandler:      updateUserHandler,
+		deleteUserHandler:      deleteUserHandler,
+		activateUserHandler:    activateUserHandler,
+		deactivateUserHandler:  deactivateUserHandler,
+		getUserByIDHandler:     getUserByIDHandler,
+		listUsersHandler:       listUsersHandler,
+		linkUserAccountHandler: linkUserAccountHandler,
+		authorizer:             authorizer,
 	}
 }
 
+// RequireIfMatch makes the If-Match header mandatory on updates and deletions,
+// so that clients cannot overwrite changes they have not seen
+func (h *UserHandler) RequireIfMatch(required bool) {
+	h.requireIfMatch = required
+}
+
+// Access rules of the user routes. Users may read and update their own
+// record, but not change their own active state; everything else requires a
+// permission granted by their roles. Callers whose account is not linked to
+// a user yet may only link it.
+var (
+	createUserRule     = auth.Rule{Permission: auth.PermissionUsersWrite}
+	listUsersRule      = auth.Rule{Permission: auth.PermissionUsersRead}
+	getUserRule        = auth.Rule{Permission: auth.PermissionUsersRead, SelfParam: "id"}
+	updateUserRule     = auth.Rule{Permission: auth.PermissionUsersWrite, SelfParam: "id"}
+	deleteUserRule     = auth.Rule{Permission: auth.PermissionUsersDelete}
+	activateUserRule   = auth.Rule{Permission: auth.PermissionUsersWrite}
+	deactivateUserRule = auth.Rule{Permission: auth.PermissionUsersWrite}
+	linkAccountRule    = auth.Rule{AllowUnlinked: true}
+)
+
 // RegisterRoutes registers the routes for the UserHandler
 func (h *UserHandler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/users", h.CreateUser).Methods(http.MethodPost)
-	router.HandleFunc("/users", h.ListUsers).Methods(http.MethodGet)
-	router.HandleFunc("/users/{id}", h.GetUser).Methods(http.MethodGet)
-	router.HandleFunc("/users/{id}", h.UpdateUser).Methods(http.MethodPut)
-	router.HandleFunc("/users/{id}", h.DeleteUser).Methods(http.MethodDelete)
+	router.HandleFunc("/users", h.authorizer.Require(createUserRule, h.CreateUser)).Methods(http.MethodPost)
+	router.HandleFunc("/users", h.authorizer.Require(listUsersRule, h.ListUsers)).Methods(http.MethodGet)
+	router.HandleFunc("/users/me/link", h.authorizer.Require(linkAccountRule, h.LinkAccount)).Methods(http.MethodPost)
+	router.HandleFunc("/users/{id}", h.authorizer.Require(getUserRule, h.GetUser)).Methods(http.MethodGet)
+	router.HandleFunc("/users/{id}", h.authorizer.Require(updateUserRule, h.UpdateUser)).Methods(http.MethodPut)
+	router.HandleFunc("/users/{id}", h.authorizer.Require(deleteUserRule, h.DeleteUser)).Methods(http.MethodDelete)
+	router.HandleFunc("/users/{id}/activate", h.authorizer.Require(activateUserRule, h.ActivateUser)).Methods(http.MethodPost)
+	router.HandleFunc("/users/{id}/deactivate", h.authorizer.Require(deactivateUserRule, h.DeactivateUser)).Methods(http.MethodPost)
 }
 
 // CreateUser handles the request to create a user
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := callerTenantID(w, r)
+	if !ok {
+		return
+	}
+
 	var req CreateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	cmd := commands.CreateUserCommand{
-		Email:     req.Email,
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Role:      req.Role,
+		TenantID:   tenantID,
+		Email:      req.Email,
+		FirstName:  req.FirstName,
+		LastName:   req.LastName,
+		Role:       req.Role,
+		ExternalID: req.ExternalID,
 	}
 
 	user, err := h.createUserHandler.Handle(r.Context(), cmd)
 	if err != nil {
-		handleError(w, err)
+		handleError(w, r, err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, toUserResponse(user))
+	respondWithUser(w, http.StatusCreated, user)
+}
+
+// LinkAccount handles the request to link the caller's account to the user
+// of their organization registered with the email of their token. The email
+// must have been verified by the identity provider.
+func (h *UserHandler) LinkAccount(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := callerTenantID(w, r)
+	if !ok {
+		return
+	}
+
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	if !claims.EmailVerified || claims.Email == "" {
+		auth.Forbidden(w, r, "token email is not verified", "")
+		return
+	}
+
+	cmd := commands.LinkUserAccountCommand{
+		TenantID:   tenantID,
+		ExternalID: claims.Subject,
+		Email:      claims.Email,
+	}
+
+	user, err := h.linkUserAccountHandler.Handle(r.Context(), cmd)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	if user == nil {
+		problem.Error(w, r, http.StatusNotFound, "No user of the organization can be linked to the account")
+		return
+	}
+	if !user.Active {
+		auth.Forbidden(w, r, "account is deactivated", "")
+		return
+	}
+
+	respondWithUser(w, http.StatusOK, user)
 }
 
 // GetUser handles the request to get a user
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := callerTenantID(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	query := queries.GetUserByIDQuery{
-		ID: id,
+		TenantID: tenantID,
+		ID:       id,
 	}
 
 	user, err := h.getUserByIDHandler.Handle(r.Context(), query)
 	if err != nil {
-		handleError(w, err)
+		handleError(w, r, err)
 		return
 	}
 
 	if user == nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		problem.Error(w, r, http.StatusNotFound, "User not found")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, toUserResponse(user))
+	respondWithUser(w, http.StatusOK, user)
 }
 
 // UpdateUser handles the request to update a user
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := callerTenantID(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	expectedVersion, ok := ifMatchVersion(w, r, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
 	var req UpdateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		problem.Error(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
+	// Users updating themselves cannot change their own role or active state
+	if auth.IsSelfAccess(r.Context()) {
+		current, err := h.getUserByIDHandler.Handle(r.Context(), queries.GetUserByIDQuery{TenantID: tenantID, ID: id})
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+		if current != nil && current.Role != req.Role {
+			auth.Forbidden(w, r, "changing a role requires an admin role", auth.PermissionUsersWrite)
+			return
+		}
+		if current != nil && req.Active != nil && current.Active != *req.Active {
+			auth.Forbidden(w, r, "changing the active state requires an admin role", auth.PermissionUsersWrite)
+			return
+		}
+	}
+
 	cmd := commands.UpdateUserCommand{
+		TenantID:  tenantID,
 		ID:        id,
 		Email:     req.Email,
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
 		Role:      req.Role,
+
+		ExpectedVersion: expectedVersion,
 	}
 
 	user, err := h.updateUserHandler.Handle(r.Context(), cmd)
 	if err != nil {
-		handleError(w, err)
+		handleError(w, r, err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, toUserResponse(user))
+	if req.Active != nil && user.Active != *req.Active {
+		user, err = h.setActive(r, tenantID, id, *req.Active)
+		if err != nil {
+			handleError(w, r, err)
+			return
+		}
+	}
+
+	respondWithUser(w, http.StatusOK, user)
 }
 
 // DeleteUser handles the request to delete a user
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := callerTenantID(w, r)
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
+	expectedVersion, ok := ifMatchVersion(w, r, h.requireIfMatch)
+	if !ok {
+		return
+	}
+
 	cmd := commands.DeleteUserCommand{
-		ID: id,
+		TenantID:        tenantID,
+		ID:              id,
+		ExpectedVersion: expectedVersion,
 	}
 
 	err := h.deleteUserHandler.Handle(r.Context(), cmd)
 	if err != nil {
-		handleError(w, err)
+		handleError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ListUsers handles the request to list users
+// ActivateUser handles the request to activate a user
+func (h *UserHandler) ActivateUser(w http.ResponseWriter, r *http.Request) {
+	h.changeActive(w, r, true)
+}
+
+// DeactivateUser handles the request to deactivate a user
+func (h *UserHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	h.changeActive(w, r, false)
+}
+
+// changeActive activates or deactivates the user of the request
+func (h *UserHandler) changeActive(w http.ResponseWriter, r *http.Request, active bool) {
+	tenantID, ok := callerTenantID(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	user, err := h.setActive(r, tenantID, id, active)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	respondWithUser(w, http.StatusOK, user)
+}
+
+// setActive dispatches the command activating or deactivating a user
+func (h *UserHandler) setActive(r *http.Request, tenantID, id string, active bool) (*domain.User, error) {
+	if active {
+		return h.activateUserHandler.Handle(r.Context(), commands.ActivateUserCommand{
+			TenantID: tenantID,
+			ID:       id,
+		})
+	}
+	return h.deactivateUserHandler.Handle(r.Context(), commands.DeactivateUserCommand{
+		TenantID: tenantID,
+		ID:       id,
+	})
+}
+
+// ListUsersResponse represents a page of users
+type ListUsersResponse struct {
+	Users []UserResponse `json:"users"`
+	// NextCursor is passed as the cursor parameter to get the next page.
+	// It is omitted on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListUsers handles the request to list users.
+// Query parameters: role, active, q (prefix of the email, first or last
+// name), created_after and created_before (RFC 3339), sort (created_at, email
+// or last_name, prefixed with "-" for descending order; defaults to
+// -created_at), limit and cursor.
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	query := queries.ListUsersQuery{}
+	tenantID, ok := callerTenantID(w, r)
+	if !ok {
+		return
+	}
 
-	users, err := h.listUsersHandler.Handle(r.Context(), query)
+	query, err := parseListUsersQuery(r)
 	if err != nil {
-		handleError(w, err)
+		handleError(w, r, err)
 		return
 	}
+	query.TenantID = tenantID
 
-	var response []UserResponse
-	for _, user := range users {
-		response = append(response, toUserResponse(user))
+	result, err := h.listUsersHandler.Handle(r.Context(), query)
+	if err != nil {
+		handleError(w, r, err)
+		return
+	}
+
+	response := ListUsersResponse{
+		Users:      make([]UserResponse, 0, len(result.Users)),
+		NextCursor: result.NextCursor,
+	}
+	for _, user := range result.Users {
+		response.Users = append(response.Users, toUserResponse(user))
 	}
 
 	respondWithJSON(w, http.StatusOK, response)
 }
 
+// parseListUsersQuery reads the filters, sort order and page of a list
+// request, reporting every invalid parameter
+func parseListUsersQuery(r *http.Request) (queries.ListUsersQuery, error) {
+	params := r.URL.Query()
+	query := queries.ListUsersQuery{
+		Role:   params.Get("role"),
+		Search: params.Get("q"),
+		Cursor: params.Get("cursor"),
+	}
+	v := validation.New()
+
+	if value := params.Get("active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		v.Check(err == nil, "active", "must be true or false")
+		query.Active = &active
+	}
+
+	for _, param := range []struct {
+		name   string
+		target *time.Time
+	}{
+		{"created_after", &query.CreatedAfter},
+		{"created_before", &query.CreatedBefore},
+	} {
+		if value := params.Get(param.name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			v.Check(err == nil, param.name, "must be an RFC 3339 date")
+			*param.target = t
+		}
+	}
+
+	if value := params.Get("sort"); value != "" {
+		query.SortBy = strings.TrimPrefix(value, "-")
+		query.Ascending = !strings.HasPrefix(value, "-")
+	}
+
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		v.Check(err == nil && limit >= 1, "limit", "must be a positive integer")
+		query.Limit = limit
+	}
+
+	return query, v.Err()
+}
+
 // Helper functions
 
+// callerTenantID returns the organization of the authenticated caller.
+// Tokens that are not bound to an organization are rejected with a 403.
+func callerTenantID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok || claims.TenantID == "" {
+		auth.Forbidden(w, r, "token is not bound to an organization", "")
+		return "", false
+	}
+	return claims.TenantID, true
+}
+
 func toUserResponse(user *domain.User) UserResponse {
 	return UserResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		FirstName: user.FirstName,
-		LastName:  user.LastName,
-		Role:      user.Role,
-		Active:    user.Active,
-		CreatedAt: user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		UpdatedAt: user.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ID:         user.ID,
+		TenantID:   user.TenantID,
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Role:       user.Role,
+		Active:     user.Active,
+		CreatedAt:  user.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:  user.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		Version:    user.Version,
+		ExternalID: user.ExternalID,
 	}
 }
 
+// respondWithUser writes a user along with its ETag
+func respondWithUser(w http.ResponseWriter, status int, user *domain.User) {
+	w.Header().Set("ETag", etag(user.Version))
+	respondWithJSON(w, status, toUserResponse(user))
+}
+
 func respondWithJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
 
-func handleError(w http.ResponseWriter, err error) {
-	switch err {
-	case domain.ErrUserNotFound:
-		http.Error(w, err.Error(), http.StatusNotFound)
-	case domain.ErrUserAlreadyExists:
-		http.Error(w, err.Error(), http.StatusConflict)
-	case domain.ErrInvalidUserData:
-		http.Error(w, err.Error(), http.StatusBadRequest)
-	default:
-		// Check if it's a validation error
-		if _, ok := err.(domain.ValidationError); ok {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+// handleError writes the problem matching err, which may wrap a domain error
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrs domain.ValidationErrors
+	var validationErr domain.ValidationError
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := make([]problem.FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, problem.FieldError{Field: fieldErr.Field, Message: fieldErr.Message})
 		}
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, r, problem.Validation("The request has invalid fields", fields...))
+	case errors.As(err, &validationErr):
+		problem.Write(w, r, problem.Validation(validationErr.Error(), problem.FieldError{
+			Field:   validationErr.Field,
+			Message: validationErr.Message,
+		}))
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrOrganizationNotFound):
+		problem.Error(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, domain.ErrUserAlreadyExists), errors.Is(err, domain.ErrOrganizationAlreadyExists):
+		problem.Error(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, domain.ErrOrganizationSuspended), errors.Is(err, domain.ErrTenantRequired):
+		problem.Error(w, r, http.StatusForbidden, err.Error())
+	case errors.Is(err, domain.ErrInvalidUserData):
+		problem.Write(w, r, problem.Validation(err.Error()))
+	case errors.Is(err, domain.ErrVersionMismatch):
+		p := problem.New(http.StatusPreconditionFailed, "The user has changed since the version in If-Match")
+		p.Type = problem.TypeVersionMismatch
+		problem.Write(w, r, p)
+	default:
+		problem.Error(w, r, http.StatusInternalServerError, "Internal server error")
 	}
 }
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	"github.com/gorilla/mux"
+)
+
+// DaprAPITokenHeader carries the app API token the Dapr sidecar sends with
+// its calls to the service
+const DaprAPITokenHeader = "dapr-api-token"
+
+// DaprAppToken rejects the requests not coming from the Dapr sidecar,
+// identified by the app API token it sends. An empty token disables the check.
+func DaprAppToken(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(DaprAPITokenHeader)), []byte(token)) != 1 {
+				problem.Error(w, r, http.StatusUnauthorized, "Invalid Dapr app token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
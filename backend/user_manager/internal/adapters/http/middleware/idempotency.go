@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/idempotency"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+	"github.com/gorilla/mux"
+)
+
+// Headers of idempotent requests
+const (
+	IdempotencyKeyHeader     = idempotency.KeyHeader
+	IdempotentReplayedHeader = idempotency.ReplayedHeader
+)
+
+// MaxIdempotencyKeyLength is the longest Idempotency-Key accepted
+const MaxIdempotencyKeyLength = idempotency.MaxKeyLength
+
+// replayedHeaders are the response headers stored with the response
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// Idempotency makes the POST, PUT, PATCH and DELETE requests sent with an
+// Idempotency-Key safe to retry, as described by idempotency.Guard. Keys are
+// scoped to the caller and tenant of the token, so the middleware must run
+// after token validation.
+func Idempotency(store ports.IdempotencyStore, ttl, lease time.Duration) mux.MiddlewareFunc {
+	guard := idempotency.NewGuard(store, ttl, lease, replayedHeaders...)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var tenantID, subject string
+			if claims, ok := auth.ClaimsFromContext(r.Context()); ok {
+				tenantID, subject = claims.TenantID, claims.Subject
+			}
+
+			call, err := guard.Begin(w, r, tenantID, subject)
+			var rejection *idempotency.Rejection
+			switch {
+			case errors.As(err, &rejection):
+				if rejection.RetryAfter != "" {
+					w.Header().Set("Retry-After", rejection.RetryAfter)
+				}
+				problem.Error(w, r, rejection.Status, rejection.Detail)
+				return
+			case err != nil:
+				// Replayed
+				return
+			case call == nil:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			recorder := idempotency.NewRecorder(w)
+			defer call.Recover()
+			next.ServeHTTP(recorder, r)
+			call.Finish(recorder)
+		})
+	}
+}
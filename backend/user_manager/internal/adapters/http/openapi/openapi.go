@@ -0,0 +1,181 @@
+// Package openapi serves the OpenAPI document of the service, and validates
+// requests and responses against it.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// Path is where the document is served
+const Path = "/openapi.json"
+
+// document is the OpenAPI 3.1 document of the HTTP API. It is written by
+// hand: every route registered by the HTTP handlers must be described here.
+//
+//go:embed openapi.json
+var document []byte
+
+// Handler serves the OpenAPI document
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(document)
+}
+
+// Load parses the OpenAPI document and checks that it is well formed
+func Load(ctx context.Context) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
+
+// Validator checks the requests and responses of the documented operations
+// against the OpenAPI document. It is meant for tests, where it keeps the
+// document from drifting from the handlers.
+type Validator struct {
+	router  routers.Router
+	options *openapi3filter.Options
+}
+
+// NewValidator creates a validator for the OpenAPI document
+func NewValidator(ctx context.Context) (*Validator, error) {
+	doc, err := Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to route OpenAPI document: %w", err)
+	}
+
+	return &Validator{
+		router: router,
+		options: &openapi3filter.Options{
+			// Tokens are checked by the authentication middleware
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+			MultiError:            true,
+		},
+	}, nil
+}
+
+// Middleware validates the requests of documented operations, answering
+// the invalid ones with a validation problem, and replaces the responses
+// that do not match the document with a 500 problem. Requests matching no
+// operation are served as they are.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := v.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestInput := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    v.options,
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), requestInput); err != nil {
+			problem.Write(w, r, problem.Validation("The request does not match the API document", fieldErrors(err)...))
+			return
+		}
+
+		recorder := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: requestInput,
+			Status:                 recorder.status,
+			Header:                 recorder.header,
+			Options:                v.options,
+		}
+		responseInput.SetBodyBytes(recorder.body.Bytes())
+		if err := openapi3filter.ValidateResponse(r.Context(), responseInput); err != nil {
+			problem.Error(w, r, http.StatusInternalServerError, "The response does not match the API document: "+err.Error())
+			return
+		}
+
+		for key, values := range recorder.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(recorder.status)
+		w.Write(recorder.body.Bytes())
+	})
+}
+
+// fieldErrors lists the parameters and body fields of a request error
+func fieldErrors(err error) []problem.FieldError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var fields []problem.FieldError
+		for _, inner := range e {
+			fields = append(fields, fieldErrors(inner)...)
+		}
+		return fields
+	case *openapi3filter.RequestError:
+		if e.Parameter != nil {
+			return []problem.FieldError{{Field: e.Parameter.Name, Message: reason(e)}}
+		}
+		if fields := fieldErrors(e.Err); len(fields) > 0 {
+			return fields
+		}
+		return []problem.FieldError{{Field: "body", Message: reason(e)}}
+	case *openapi3.SchemaError:
+		field := strings.Join(e.JSONPointer(), ".")
+		if field == "" {
+			field = "body"
+		}
+		return []problem.FieldError{{Field: field, Message: e.Reason}}
+	}
+	return nil
+}
+
+// reason describes a request error without repeating the request
+func reason(e *openapi3filter.RequestError) string {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(e.Err, &schemaErr) {
+		return schemaErr.Reason
+	}
+	if e.Reason != "" {
+		return e.Reason
+	}
+	return e.Err.Error()
+}
+
+// responseRecorder holds a response until it is validated
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
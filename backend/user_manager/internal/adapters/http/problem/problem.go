@@ -0,0 +1,96 @@
+// Package problem writes error responses as RFC 7807 problem details
+// (application/problem+json).
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ContentType is the media type of problem details
+const ContentType = "application/problem+json"
+
+// Problem types, besides the ones derived from the status by New
+const (
+	TypeValidation      = "urn:saaster:problem:validation-error"
+	TypeVersionMismatch = "urn:saaster:problem:version-mismatch"
+)
+
+// Problem is the body of an error response
+type Problem struct {
+	// Type identifies the kind of problem
+	Type string `json:"type"`
+	// Title summarizes the kind of problem, and is the same for every
+	// occurrence of a type
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Detail explains this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed
+	Instance string `json:"instance,omitempty"`
+	// TraceID identifies the request in traces and logs
+	TraceID string `json:"trace_id,omitempty"`
+	// Errors lists the invalid fields of a validation problem
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is an invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// New creates a problem whose type and title are derived from the status
+func New(status int, detail string) *Problem {
+	title := http.StatusText(status)
+	return &Problem{
+		Type:   "urn:saaster:problem:" + strings.ReplaceAll(strings.ToLower(title), " ", "-"),
+		Title:  title,
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Validation creates a 400 problem listing the invalid fields
+func Validation(detail string, errors ...FieldError) *Problem {
+	p := New(http.StatusBadRequest, detail)
+	p.Type = TypeValidation
+	p.Title = "Validation failed"
+	p.Errors = errors
+	return p
+}
+
+// Write writes the problem as the response to r. The instance and trace ID
+// are taken from the request when they are not set.
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.TraceID == "" {
+		p.TraceID = TraceID(r)
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error writes a problem with status and detail, in place of http.Error
+func Error(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	Write(w, r, New(status, detail))
+}
+
+// traceparentPattern matches a W3C traceparent header, capturing the trace ID
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// TraceID returns the trace ID of the request, from its W3C traceparent
+// header, or else its X-Request-ID header
+func TraceID(r *http.Request) string {
+	if match := traceparentPattern.FindStringSubmatch(r.Header.Get("traceparent")); match != nil {
+		return match[1]
+	}
+	return r.Header.Get("X-Request-ID")
+}
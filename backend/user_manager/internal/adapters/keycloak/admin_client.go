@@ -0,0 +1,144 @@
+package keycloak
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// AdminConfig holds the settings needed to call the Keycloak admin API
+type AdminConfig struct {
+	// BaseURL is the Keycloak root URL, e.g. http://keycloak:8080
+	BaseURL string
+	// Realm is the realm the users belong to
+	Realm string
+	// ClientID and ClientSecret identify a confidential client whose service
+	// account holds the realm-management manage-users role
+	ClientID     string
+	ClientSecret string
+	// HTTPClient is used to call Keycloak
+	HTTPClient *http.Client
+}
+
+// AdminClient is a Keycloak implementation of the IdentityProvider interface
+type AdminClient struct {
+	baseURL      string
+	realm        string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewAdminClient creates a new AdminClient
+func NewAdminClient(cfg AdminConfig) ports.IdentityProvider {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &AdminClient{
+		baseURL:      strings.TrimSuffix(cfg.BaseURL, "/"),
+		realm:        cfg.Realm,
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		httpClient:   httpClient,
+	}
+}
+
+// SetUserEnabled enables or disables a Keycloak account, identified by the
+// subject of its tokens
+func (c *AdminClient) SetUserEnabled(ctx context.Context, externalID string, enabled bool) error {
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]bool{"enabled": enabled})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/admin/realms/%s/users/%s", c.baseURL, url.PathEscape(c.realm), url.PathEscape(externalID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update keycloak user: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return domain.ErrUserNotFound
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("failed to update keycloak user: unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// token returns a service account access token, requesting a new one with
+// the client credentials grant when the cached one is about to expire
+func (c *AdminClient) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && time.Now().Before(c.expiresAt) {
+		return c.accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.clientID},
+		"client_secret": {c.clientSecret},
+	}
+
+	endpoint := fmt.Sprintf("%s/realms/%s/protocol/openid-connect/token", c.baseURL, url.PathEscape(c.realm))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get keycloak admin token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get keycloak admin token: unexpected status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode keycloak admin token: %w", err)
+	}
+
+	// Renew the token a little before it actually expires
+	c.accessToken = token.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - 10*time.Second)
+
+	return c.accessToken, nil
+}
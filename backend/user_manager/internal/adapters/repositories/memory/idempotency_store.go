@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/idempotency"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// IdempotencyStore is an in-memory implementation of the IdempotencyStore interface
+type IdempotencyStore struct {
+	records map[string]ports.IdempotencyRecord
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+// NewIdempotencyStore creates a new in-memory IdempotencyStore. now defaults
+// to time.Now.
+func NewIdempotencyStore(now func() time.Time) *IdempotencyStore {
+	if now == nil {
+		now = time.Now
+	}
+	return &IdempotencyStore{
+		records: make(map[string]ports.IdempotencyRecord),
+		now:     now,
+	}
+}
+
+// Reserve records a key unless it is recorded and not expired
+func (s *IdempotencyStore) Reserve(ctx context.Context, record *ports.IdempotencyRecord) (*ports.IdempotencyRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if existing, ok := s.records[record.Key]; ok && s.now().Before(existing.ExpiresAt) {
+		return &existing, nil
+	}
+
+	s.records[record.Key] = *record
+	return nil, nil
+}
+
+// Complete stores the response of a key, provided the reservation of the
+// record is still the current one
+func (s *IdempotencyStore) Complete(ctx context.Context, record *ports.IdempotencyRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.holds(record) {
+		return idempotency.ErrReservationLost
+	}
+	s.records[record.Key] = *record
+	return nil
+}
+
+// Release forgets the reservation of the record, unless it was completed or
+// taken over
+func (s *IdempotencyStore) Release(ctx context.Context, record *ports.IdempotencyRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.holds(record) {
+		delete(s.records, record.Key)
+	}
+	return nil
+}
+
+// holds reports whether the key of record is reserved with its token
+func (s *IdempotencyStore) holds(record *ports.IdempotencyRecord) bool {
+	existing, ok := s.records[record.Key]
+	return ok && existing.Token == record.Token && !existing.Completed
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// OrganizationRepository is an in-memory implementation of the OrganizationRepository interface
+type OrganizationRepository struct {
+	organizations map[string]*domain.Organization
+	mutex         sync.RWMutex
+}
+
+// NewOrganizationRepository creates a new in-memory OrganizationRepository
+func NewOrganizationRepository() ports.OrganizationRepository {
+	return &OrganizationRepository{
+		organizations: make(map[string]*domain.Organization),
+	}
+}
+
+// Create creates a new organization in memory
+func (r *OrganizationRepository) Create(ctx context.Context, org *domain.Organization) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Check if an organization with the same ID or slug already exists
+	for id, existingOrg := range r.organizations {
+		if id == org.ID || existingOrg.Slug == org.Slug {
+			return domain.ErrOrganizationAlreadyExists
+		}
+	}
+
+	r.organizations[org.ID] = cloneOrganization(org)
+
+	return nil
+}
+
+// Update updates an organization in memory
+func (r *OrganizationRepository) Update(ctx context.Context, org *domain.Organization) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Check if organization exists
+	if _, exists := r.organizations[org.ID]; !exists {
+		return domain.ErrOrganizationNotFound
+	}
+
+	// Check if slug is already used by another organization
+	for id, existingOrg := range r.organizations {
+		if existingOrg.Slug == org.Slug && id != org.ID {
+			return domain.ErrOrganizationAlreadyExists
+		}
+	}
+
+	clonedOrg := cloneOrganization(org)
+	clonedOrg.UpdatedAt = time.Now()
+	r.organizations[org.ID] = clonedOrg
+
+	return nil
+}
+
+// GetByID retrieves an organization by ID from memory
+func (r *OrganizationRepository) GetByID(ctx context.Context, id string) (*domain.Organization, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	org, exists := r.organizations[id]
+	if !exists {
+		return nil, nil
+	}
+
+	return cloneOrganization(org), nil
+}
+
+// GetBySlug retrieves an organization by slug from memory
+func (r *OrganizationRepository) GetBySlug(ctx context.Context, slug string) (*domain.Organization, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, org := range r.organizations {
+		if org.Slug == slug {
+			return cloneOrganization(org), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Clear clears all organizations from memory (useful for testing)
+func (r *OrganizationRepository) Clear() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.organizations = make(map[string]*domain.Organization)
+}
+
+// Helper function to clone an organization
+func cloneOrganization(org *domain.Organization) *domain.Organization {
+	clone := *org
+	return &clone
+}
@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// outboxRecord is an event of the outbox with its delivery state
+type outboxRecord struct {
+	event       *domain.Event
+	attempts    int
+	lastError   string
+	nextAttempt time.Time
+	published   bool
+	publishedAt time.Time
+}
+
+// OutboxRepository is an in-memory implementation of the OutboxRepository interface
+type OutboxRepository struct {
+	records []*outboxRecord
+	mutex   sync.Mutex
+}
+
+// NewOutboxRepository creates a new in-memory OutboxRepository
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{}
+}
+
+// append adds events to the outbox. A nil outbox discards them.
+func (r *OutboxRepository) append(events []*domain.Event) {
+	if r == nil || len(events) == 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, event := range events {
+		r.records = append(r.records, &outboxRecord{event: event, nextAttempt: event.OccurredAt})
+	}
+}
+
+// Claim returns up to limit unpublished events due at now, oldest first
+func (r *OutboxRepository) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*ports.OutboxEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var due []*outboxRecord
+	for _, record := range r.records {
+		if !record.published && !record.nextAttempt.After(now) {
+			due = append(due, record)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].event.OccurredAt.Before(due[j].event.OccurredAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	entries := make([]*ports.OutboxEntry, 0, len(due))
+	for _, record := range due {
+		record.nextAttempt = leaseUntil
+		entries = append(entries, &ports.OutboxEntry{Event: record.event, Attempts: record.attempts})
+	}
+
+	return entries, nil
+}
+
+// MarkPublished marks an event as published
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if record := r.find(id); record != nil {
+		record.published = true
+		record.publishedAt = time.Now()
+		record.lastError = ""
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed attempt to publish an event
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id string, cause string, retryAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if record := r.find(id); record != nil && !record.published {
+		record.attempts++
+		record.lastError = cause
+		record.nextAttempt = retryAt
+	}
+
+	return nil
+}
+
+// PurgePublished deletes up to limit events published before the given time
+func (r *OutboxRepository) PurgePublished(ctx context.Context, before time.Time, limit int) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	kept := r.records[:0]
+	purged := 0
+	for _, record := range r.records {
+		if record.published && record.publishedAt.Before(before) && purged < limit {
+			purged++
+			continue
+		}
+		kept = append(kept, record)
+	}
+	r.records = kept
+
+	return purged, nil
+}
+
+// Events returns every event of the outbox, published or not, in the order they were written
+func (r *OutboxRepository) Events() []*domain.Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	events := make([]*domain.Event, 0, len(r.records))
+	for _, record := range r.records {
+		events = append(events, record.event)
+	}
+	return events
+}
+
+// find returns the record of an event, nil if there is none
+func (r *OutboxRepository) find(id string) *outboxRecord {
+	for _, record := range r.records {
+		if record.event.ID == id {
+			return record
+		}
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package memory
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,86 +11,134 @@ import (
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
 )
 
-// UserRepository is an in-memory implementation of the UserRepository interface
+// UserRepository is an in-memory implementation of the UserRepository interface.
+// Users of other tenants are treated as if they did not exist.
 type UserRepository struct {
-	users map[string]*domain.User
-	mutex sync.RWMutex
+	users  map[string]*domain.User
+	outbox *OutboxRepository
+	mutex  sync.RWMutex
 }
 
-// NewUserRepository creates a new in-memory UserRepository
-func NewUserRepository() ports.UserRepository {
+// NewUserRepository creates a new in-memory UserRepository writing its events to outbox
+func NewUserRepository(outbox *OutboxRepository) ports.UserRepository {
 	return &UserRepository{
-		users: make(map[string]*domain.User),
+		users:  make(map[string]*domain.User),
+		outbox: outbox,
 	}
 }
 
 // Create creates a new user in memory
-func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
+func (r *UserRepository) Create(ctx context.Context, user *domain.User, events ...*domain.Event) error {
+	if user.TenantID == "" {
+		return domain.ErrTenantRequired
+	}
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	// Check if user with the same email already exists
+	// IDs are global, so an ID taken in another tenant cannot be reused
+	if _, exists := r.users[user.ID]; exists {
+		return domain.ErrUserAlreadyExists
+	}
+
+	// Check if user with the same email already exists in the tenant, or is
+	// linked to the same account
 	for _, existingUser := range r.users {
-		if existingUser.Email == user.Email {
+		if existingUser.TenantID == user.TenantID && existingUser.Email == user.Email {
+			return domain.ErrUserAlreadyExists
+		}
+		if sameAccount(existingUser, user) {
 			return domain.ErrUserAlreadyExists
 		}
 	}
 
 	// Clone the user to avoid external modifications
+	user.Version = 1
 	clonedUser := cloneUser(user)
 	r.users[user.ID] = clonedUser
+	r.outbox.append(events)
 
 	return nil
 }
 
 // Update updates a user in memory
-func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
+func (r *UserRepository) Update(ctx context.Context, user *domain.User, events ...*domain.Event) error {
+	if user.TenantID == "" {
+		return domain.ErrTenantRequired
+	}
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	// Check if user exists
-	if _, exists := r.users[user.ID]; !exists {
+	// Check if user exists in the tenant, at the version that was read
+	stored, exists := r.find(user.TenantID, user.ID)
+	if !exists {
 		return domain.ErrUserNotFound
 	}
+	if stored.Version != user.Version {
+		return domain.ErrVersionMismatch
+	}
 
-	// Check if email is already used by another user
+	// Check if email is already used by another user of the tenant, or the
+	// account linked to another user
 	for id, existingUser := range r.users {
-		if existingUser.Email == user.Email && id != user.ID {
+		if id == user.ID {
+			continue
+		}
+		if existingUser.TenantID == user.TenantID && existingUser.Email == user.Email {
+			return domain.ErrUserAlreadyExists
+		}
+		if sameAccount(existingUser, user) {
 			return domain.ErrUserAlreadyExists
 		}
 	}
 
 	// Clone the user to avoid external modifications
+	user.Version++
 	clonedUser := cloneUser(user)
 	clonedUser.UpdatedAt = time.Now()
 	r.users[user.ID] = clonedUser
+	r.outbox.append(events)
 
 	return nil
 }
 
 // Delete deletes a user from memory
-func (r *UserRepository) Delete(ctx context.Context, id string) error {
+func (r *UserRepository) Delete(ctx context.Context, tenantID, id string, version int64, events ...*domain.Event) error {
+	if tenantID == "" {
+		return domain.ErrTenantRequired
+	}
+
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	// Check if user exists
-	if _, exists := r.users[id]; !exists {
+	// Check if user exists in the tenant, at the version that was read
+	stored, exists := r.find(tenantID, id)
+	if !exists {
 		return domain.ErrUserNotFound
 	}
+	if stored.Version != version {
+		return domain.ErrVersionMismatch
+	}
 
 	// Delete user
 	delete(r.users, id)
+	r.outbox.append(events)
 
 	return nil
 }
 
 // GetByID retrieves a user by ID from memory
-func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, tenantID, id string) (*domain.User, error) {
+	if tenantID == "" {
+		return nil, domain.ErrTenantRequired
+	}
+
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	// Check if user exists
-	user, exists := r.users[id]
+	// Check if user exists in the tenant
+	user, exists := r.find(tenantID, id)
 	if !exists {
 		return nil, nil
 	}
@@ -98,13 +148,36 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User,
 }
 
 // GetByEmail retrieves a user by email from memory
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, tenantID, email string) (*domain.User, error) {
+	if tenantID == "" {
+		return nil, domain.ErrTenantRequired
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	// Find user by email in the tenant
+	for _, user := range r.users {
+		if user.TenantID == tenantID && user.Email == email {
+			// Clone the user to avoid external modifications
+			return cloneUser(user), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// GetByExternalID retrieves the user linked to an identity provider account from memory
+func (r *UserRepository) GetByExternalID(ctx context.Context, tenantID, externalID string) (*domain.User, error) {
+	if tenantID == "" {
+		return nil, domain.ErrTenantRequired
+	}
+
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	// Find user by email
 	for _, user := range r.users {
-		if user.Email == email {
+		if user.TenantID == tenantID && user.ExternalID != "" && user.ExternalID == externalID {
 			// Clone the user to avoid external modifications
 			return cloneUser(user), nil
 		}
@@ -113,19 +186,87 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.
 	return nil, nil
 }
 
-// List retrieves all users from memory
-func (r *UserRepository) List(ctx context.Context) ([]*domain.User, error) {
+// sameAccount reports whether existing is linked to the account of user in the
+// same tenant. An account may be linked to one user in each organization.
+func sameAccount(existing, user *domain.User) bool {
+	return user.ExternalID != "" && existing.TenantID == user.TenantID && existing.ExternalID == user.ExternalID
+}
+
+// List retrieves a page of the tenant's users from memory
+func (r *UserRepository) List(ctx context.Context, tenantID string, opts domain.UserListOptions) (*domain.UserPage, error) {
+	if tenantID == "" {
+		return nil, domain.ErrTenantRequired
+	}
+
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	// Create a list of users
+	// Collect the tenant's users matching the filter
 	users := make([]*domain.User, 0, len(r.users))
 	for _, user := range r.users {
+		if user.TenantID != tenantID || !opts.Filter.Matches(user) {
+			continue
+		}
+		users = append(users, user)
+	}
+
+	// Order them by the sort field, then by ID
+	less := func(a, b *domain.UserCursor) bool {
+		c := compareCursors(a, b, opts.SortBy)
+		if opts.Descending {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return less(domain.NewUserCursor(users[i]), domain.NewUserCursor(users[j]))
+	})
+
+	// Skip the users of the previous pages
+	start := 0
+	if opts.After != nil {
+		start = sort.Search(len(users), func(i int) bool {
+			return less(opts.After, domain.NewUserCursor(users[i]))
+		})
+	}
+
+	page := &domain.UserPage{Users: make([]*domain.User, 0, opts.Limit)}
+	for i := start; i < len(users) && len(page.Users) < opts.Limit; i++ {
 		// Clone the user to avoid external modifications
-		users = append(users, cloneUser(user))
+		page.Users = append(page.Users, cloneUser(users[i]))
+	}
+	if start+len(page.Users) < len(users) && len(page.Users) > 0 {
+		page.Next = domain.NewUserCursor(page.Users[len(page.Users)-1])
+	}
+
+	return page, nil
+}
+
+// compareCursors compares the sort keys of two users, falling back to their IDs
+func compareCursors(a, b *domain.UserCursor, field domain.UserSortField) int {
+	var c int
+	switch field {
+	case domain.UserSortByEmail:
+		c = strings.Compare(a.Email, b.Email)
+	case domain.UserSortByLastName:
+		c = strings.Compare(a.LastName, b.LastName)
+	default:
+		c = a.CreatedAt.Compare(b.CreatedAt)
 	}
+	if c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
 
-	return users, nil
+// find returns the user with the given ID if it belongs to the tenant.
+// Callers must hold the mutex.
+func (r *UserRepository) find(tenantID, id string) (*domain.User, bool) {
+	user, exists := r.users[id]
+	if !exists || user.TenantID != tenantID {
+		return nil, false
+	}
+	return user, true
 }
 
 // Clear clears all users from memory (useful for testing)
@@ -140,6 +281,7 @@ func (r *UserRepository) Clear() {
 func cloneUser(user *domain.User) *domain.User {
 	return &domain.User{
 		ID:        user.ID,
+		TenantID:  user.TenantID,
 		Email:     user.Email,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
@@ -147,5 +289,8 @@ func cloneUser(user *domain.User) *domain.User {
 		Active:    user.Active,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+		Version:   user.Version,
+
+		ExternalID: user.ExternalID,
 	}
 }
@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// OrganizationRepository is a PostgreSQL implementation of the OrganizationRepository interface
+type OrganizationRepository struct {
+	db *sql.DB
+}
+
+// NewOrganizationRepository creates a new OrganizationRepository
+func NewOrganizationRepository(db *sql.DB) ports.OrganizationRepository {
+	return &OrganizationRepository{
+		db: db,
+	}
+}
+
+// Create creates a new organization in the database
+func (r *OrganizationRepository) Create(ctx context.Context, org *domain.Organization) error {
+	query := `
+		INSERT INTO organizations (id, name, slug, plan, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		org.ID,
+		org.Name,
+		org.Slug,
+		org.Plan,
+		org.Status,
+		org.CreatedAt,
+		org.UpdatedAt,
+	)
+
+	if isUniqueViolation(err) {
+		return fmt.Errorf("failed to create organization: %w", domain.ErrOrganizationAlreadyExists)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	return nil
+}
+
+// Update updates an organization in the database
+func (r *OrganizationRepository) Update(ctx context.Context, org *domain.Organization) error {
+	query := `
+		UPDATE organizations
+		SET name = $1, slug = $2, plan = $3, status = $4, updated_at = $5
+		WHERE id = $6
+	`
+
+	result, err := r.db.ExecContext(
+		ctx,
+		query,
+		org.Name,
+		org.Slug,
+		org.Plan,
+		org.Status,
+		org.UpdatedAt,
+		org.ID,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to update organization: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return domain.ErrOrganizationNotFound
+	}
+
+	return nil
+}
+
+// GetByID retrieves an organization by ID
+func (r *OrganizationRepository) GetByID(ctx context.Context, id string) (*domain.Organization, error) {
+	query := `
+		SELECT id, name, slug, plan, status, created_at, updated_at
+		FROM organizations
+		WHERE id = $1
+	`
+
+	org, err := scanOrganization(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Organization not found
+		}
+		return nil, fmt.Errorf("failed to get organization by ID: %w", err)
+	}
+
+	return org, nil
+}
+
+// GetBySlug retrieves an organization by slug
+func (r *OrganizationRepository) GetBySlug(ctx context.Context, slug string) (*domain.Organization, error) {
+	query := `
+		SELECT id, name, slug, plan, status, created_at, updated_at
+		FROM organizations
+		WHERE slug = $1
+	`
+
+	org, err := scanOrganization(r.db.QueryRowContext(ctx, query, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // Organization not found
+		}
+		return nil, fmt.Errorf("failed to get organization by slug: %w", err)
+	}
+
+	return org, nil
+}
+
+// scanOrganization scans an organizations row selected with the standard column list
+func scanOrganization(row rowScanner) (*domain.Organization, error) {
+	var org domain.Organization
+	err := row.Scan(
+		&org.ID,
+		&org.Name,
+		&org.Slug,
+		&org.Plan,
+		&org.Status,
+		&org.CreatedAt,
+		&org.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// OutboxRepository is a PostgreSQL implementation of the OutboxRepository
+// interface. The outbox is shared by every tenant: its statements run as the
+// service role, outside of tenant transactions.
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+// NewOutboxRepository creates a new OutboxRepository
+func NewOutboxRepository(db *sql.DB) ports.OutboxRepository {
+	return &OutboxRepository{
+		db: db,
+	}
+}
+
+// insertOutboxEvents appends events to the outbox within tx
+func insertOutboxEvents(ctx context.Context, tx *sql.Tx, events []*domain.Event) error {
+	query := `
+		INSERT INTO outbox_events (id, tenant_id, aggregate_id, event_type, payload, occurred_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+	`
+
+	for _, event := range events {
+		_, err := tx.ExecContext(
+			ctx,
+			query,
+			event.ID,
+			event.TenantID,
+			event.AggregateID,
+			string(event.Type),
+			[]byte(event.Data),
+			event.OccurredAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to write %s event to outbox: %w", event.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// Claim returns up to limit unpublished events due at now, oldest first.
+// Concurrent relays skip the rows locked by each other.
+func (r *OutboxRepository) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*ports.OutboxEntry, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE outbox_events
+			SET next_attempt_at = $2
+			WHERE id IN (
+				SELECT id FROM outbox_events
+				WHERE published_at IS NULL AND next_attempt_at <= $1
+				ORDER BY occurred_at
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, tenant_id, aggregate_id, event_type, payload, occurred_at, attempts
+		)
+		SELECT * FROM claimed ORDER BY occurred_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*ports.OutboxEntry
+	for rows.Next() {
+		var event domain.Event
+		var eventType string
+		var attempts int
+		if err := rows.Scan(
+			&event.ID,
+			&event.TenantID,
+			&event.AggregateID,
+			&eventType,
+			&event.Data,
+			&event.OccurredAt,
+			&attempts,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		event.Type = domain.EventType(eventType)
+		entries = append(entries, &ports.OutboxEntry{Event: &event, Attempts: attempts})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	return entries, nil
+}
+
+// MarkPublished marks an event as published
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id string) error {
+	query := `UPDATE outbox_events SET published_at = now(), last_error = NULL WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark outbox event as published: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed attempt to publish an event
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id string, cause string, retryAt time.Time) error {
+	query := `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $1 AND published_at IS NULL
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, id, cause, retryAt); err != nil {
+		return fmt.Errorf("failed to mark outbox event as failed: %w", err)
+	}
+
+	return nil
+}
+
+// PurgePublished deletes up to limit events published before the given time.
+// Concurrent relays skip the rows locked by each other.
+func (r *OutboxRepository) PurgePublished(ctx context.Context, before time.Time, limit int) (int, error) {
+	query := `
+		DELETE FROM outbox_events
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE published_at < $1
+			ORDER BY published_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge published outbox events: %w", err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge published outbox events: %w", err)
+	}
+
+	return int(purged), nil
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// tenantRole is the unprivileged role every user transaction runs as, so that
+// row-level security applies whatever role the service connects with
+const tenantRole = "user_manager_tenant"
+
+// inTenantTx runs fn in a transaction bound to tenantID. The tenant is exposed
+// to the row-level security policies through app.tenant_id, which only lives
+// as long as the transaction.
+func inTenantTx(ctx context.Context, db *sql.DB, tenantID string, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SET LOCAL ROLE "+tenantRole); err != nil {
+		return fmt.Errorf("failed to switch to tenant role: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenantID); err != nil {
+		return fmt.Errorf("failed to set tenant: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
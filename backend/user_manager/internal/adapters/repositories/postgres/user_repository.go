@@ -5,12 +5,17 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
 )
 
-// UserRepository is a PostgreSQL implementation of the UserRepository interface
+// UserRepository is a PostgreSQL implementation of the UserRepository interface.
+// Every statement filters on tenant_id and runs in a transaction bound to the
+// tenant, so the row-level security policy of the users table hides the users
+// of other tenants even if a filter is forgotten. The role of a user is the
+// role of their membership in the tenant, stored in the memberships table.
 type UserRepository struct {
 	db *sql.DB
 }
@@ -23,24 +28,46 @@ func NewUserRepository(db *sql.DB) ports.UserRepository {
 }
 
 // Create creates a new user in the database
-func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
+func (r *UserRepository) Create(ctx context.Context, user *domain.User, events ...*domain.Event) error {
+	if user.TenantID == "" {
+		return domain.ErrTenantRequired
+	}
+
 	query := `
-		INSERT INTO users (id, email, first_name, last_name, role, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO users (id, tenant_id, email, first_name, last_name, active, created_at, updated_at, version, external_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1, NULLIF($9, ''))
+		RETURNING version
 	`
 
-	_, err := r.db.ExecContext(
-		ctx,
-		query,
-		user.ID,
-		user.Email,
-		user.FirstName,
-		user.LastName,
-		user.Role,
-		user.Active,
-		user.CreatedAt,
-		user.UpdatedAt,
-	)
+	err := inTenantTx(ctx, r.db, user.TenantID, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			user.ID,
+			user.TenantID,
+			user.Email,
+			user.FirstName,
+			user.LastName,
+			user.Active,
+			user.CreatedAt,
+			user.UpdatedAt,
+			user.ExternalID,
+		).Scan(&user.Version)
+		if isUniqueViolation(err) {
+			return domain.ErrUserAlreadyExists
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO memberships (organization_id, user_id, role, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, user.TenantID, user.ID, user.Role, user.CreatedAt, user.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create membership: %w", err)
+		}
+		return insertOutboxEvents(ctx, tx, events)
+	})
 
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
@@ -50,82 +77,118 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 }
 
 // Update updates a user in the database
-func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
+func (r *UserRepository) Update(ctx context.Context, user *domain.User, events ...*domain.Event) error {
+	if user.TenantID == "" {
+		return domain.ErrTenantRequired
+	}
+
 	query := `
 		UPDATE users
-		SET email = $1, first_name = $2, last_name = $3, role = $4, active = $5, updated_at = $6
-		WHERE id = $7
+		SET email = $1, first_name = $2, last_name = $3, active = $4, updated_at = $5,
+			external_id = NULLIF($9, ''), version = version + 1
+		WHERE id = $6 AND tenant_id = $7 AND version = $8
+		RETURNING version
 	`
 
-	result, err := r.db.ExecContext(
-		ctx,
-		query,
-		user.Email,
-		user.FirstName,
-		user.LastName,
-		user.Role,
-		user.Active,
-		user.UpdatedAt,
-		user.ID,
-	)
+	err := inTenantTx(ctx, r.db, user.TenantID, func(tx *sql.Tx) error {
+		var version int64
+		err := tx.QueryRowContext(
+			ctx,
+			query,
+			user.Email,
+			user.FirstName,
+			user.LastName,
+			user.Active,
+			user.UpdatedAt,
+			user.ID,
+			user.TenantID,
+			user.Version,
+			user.ExternalID,
+		).Scan(&version)
+		// Roll back rather than announce a change that did not happen
+		if errors.Is(err, sql.ErrNoRows) {
+			return missingOrChanged(ctx, tx, user.TenantID, user.ID)
+		}
+		if isUniqueViolation(err) {
+			return domain.ErrUserAlreadyExists
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		user.Version = version
+		_, err = tx.ExecContext(ctx, `
+			UPDATE memberships SET role = $1, updated_at = $2
+			WHERE organization_id = $3 AND user_id = $4 AND role <> $1
+		`, user.Role, user.UpdatedAt, user.TenantID, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update membership: %w", err)
+		}
+		return insertOutboxEvents(ctx, tx, events)
+	})
 
-	if err != nil {
-		return fmt.Errorf("failed to update user: %w", err)
-	}
+	return err
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+// Delete deletes a user from the database
+func (r *UserRepository) Delete(ctx context.Context, tenantID, id string, version int64, events ...*domain.Event) error {
+	if tenantID == "" {
+		return domain.ErrTenantRequired
 	}
 
-	if rowsAffected == 0 {
-		return domain.ErrUserNotFound
-	}
+	query := `DELETE FROM users WHERE id = $1 AND tenant_id = $2 AND version = $3`
 
-	return nil
-}
+	err := inTenantTx(ctx, r.db, tenantID, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, id, tenantID, version)
+		if err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
 
-// Delete deletes a user from the database
-func (r *UserRepository) Delete(ctx context.Context, id string) error {
-	query := `DELETE FROM users WHERE id = $1`
-	
-	result, err := r.db.ExecContext(ctx, query, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
-	}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		// Roll back rather than announce a change that did not happen
+		if rowsAffected == 0 {
+			return missingOrChanged(ctx, tx, tenantID, id)
+		}
+		return insertOutboxEvents(ctx, tx, events)
+	})
 
-	rowsAffected, err := result.RowsAffected()
+	return err
+}
+
+// missingOrChanged explains why a conditional write matched no user: either
+// the user does not exist, or it is at another version
+func missingOrChanged(ctx context.Context, tx *sql.Tx, tenantID, id string) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND tenant_id = $2)", id, tenantID).Scan(&exists)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return fmt.Errorf("failed to check user: %w", err)
 	}
-
-	if rowsAffected == 0 {
+	if !exists {
 		return domain.ErrUserNotFound
 	}
-
-	return nil
+	return domain.ErrVersionMismatch
 }
 
 // GetByID retrieves a user by ID
-func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User, error) {
+func (r *UserRepository) GetByID(ctx context.Context, tenantID, id string) (*domain.User, error) {
+	if tenantID == "" {
+		return nil, domain.ErrTenantRequired
+	}
+
 	query := `
-		SELECT id, email, first_name, last_name, role, active, created_at, updated_at
-		FROM users
-		WHERE id = $1
+		SELECT ` + userColumns + `
+		FROM ` + usersWithMemberships + `
+		WHERE u.id = $1 AND u.tenant_id = $2
 	`
 
-	var user domain.User
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.FirstName,
-		&user.LastName,
-		&user.Role,
-		&user.Active,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
+	var user *domain.User
+	err := inTenantTx(ctx, r.db, tenantID, func(tx *sql.Tx) error {
+		var err error
+		user, err = scanUser(tx.QueryRowContext(ctx, query, id, tenantID))
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // User not found
@@ -133,29 +196,27 @@ func (r *UserRepository) GetByID(ctx context.Context, id string) (*domain.User,
 		return nil, fmt.Errorf("failed to get user by ID: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 // GetByEmail retrieves a user by email
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, tenantID, email string) (*domain.User, error) {
+	if tenantID == "" {
+		return nil, domain.ErrTenantRequired
+	}
+
 	query := `
-		SELECT id, email, first_name, last_name, role, active, created_at, updated_at
-		FROM users
-		WHERE email = $1
+		SELECT ` + userColumns + `
+		FROM ` + usersWithMemberships + `
+		WHERE u.email = $1 AND u.tenant_id = $2
 	`
 
-	var user domain.User
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.FirstName,
-		&user.LastName,
-		&user.Role,
-		&user.Active,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
+	var user *domain.User
+	err := inTenantTx(ctx, r.db, tenantID, func(tx *sql.Tx) error {
+		var err error
+		user, err = scanUser(tx.QueryRowContext(ctx, query, email, tenantID))
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil // User not found
@@ -163,45 +224,173 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-// List retrieves all users
-func (r *UserRepository) List(ctx context.Context) ([]*domain.User, error) {
+// GetByExternalID retrieves the user linked to an identity provider account
+func (r *UserRepository) GetByExternalID(ctx context.Context, tenantID, externalID string) (*domain.User, error) {
+	if tenantID == "" {
+		return nil, domain.ErrTenantRequired
+	}
+
 	query := `
-		SELECT id, email, first_name, last_name, role, active, created_at, updated_at
-		FROM users
-		ORDER BY created_at DESC
+		SELECT ` + userColumns + `
+		FROM ` + usersWithMemberships + `
+		WHERE u.external_id = $1 AND u.tenant_id = $2
 	`
 
-	rows, err := r.db.QueryContext(ctx, query)
+	var user *domain.User
+	err := inTenantTx(ctx, r.db, tenantID, func(tx *sql.Tx) error {
+		var err error
+		user, err = scanUser(tx.QueryRowContext(ctx, query, externalID, tenantID))
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list users: %w", err)
-	}
-	defer rows.Close()
-
-	var users []*domain.User
-	for rows.Next() {
-		var user domain.User
-		err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.FirstName,
-			&user.LastName,
-			&user.Role,
-			&user.Active,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // User not found
+		}
+		return nil, fmt.Errorf("failed to get user by external ID: %w", err)
+	}
+
+	return user, nil
+}
+
+// List retrieves a page of the tenant's users. Pages are read with keyset
+// pagination on the sort column and the ID, so deep pages stay cheap.
+func (r *UserRepository) List(ctx context.Context, tenantID string, opts domain.UserListOptions) (*domain.UserPage, error) {
+	if tenantID == "" {
+		return nil, domain.ErrTenantRequired
+	}
+
+	conditions := []string{"u.tenant_id = $1"}
+	args := []interface{}{tenantID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	filter := opts.Filter
+	if filter.Role != "" {
+		conditions = append(conditions, "m.role = "+arg(filter.Role))
+	}
+	if filter.Active != nil {
+		conditions = append(conditions, "u.active = "+arg(*filter.Active))
+	}
+	if filter.Search != "" {
+		pattern := arg(escapeLike(filter.Search) + "%")
+		conditions = append(conditions, fmt.Sprintf("(u.email ILIKE %[1]s OR u.first_name ILIKE %[1]s OR u.last_name ILIKE %[1]s)", pattern))
+	}
+	if !filter.CreatedAfter.IsZero() {
+		conditions = append(conditions, "u.created_at >= "+arg(filter.CreatedAfter))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		conditions = append(conditions, "u.created_at < "+arg(filter.CreatedBefore))
+	}
+
+	column := "u.created_at"
+	switch opts.SortBy {
+	case domain.UserSortByEmail:
+		column = "u.email"
+	case domain.UserSortByLastName:
+		column = "u.last_name"
+	}
+
+	direction, comparison := "ASC", ">"
+	if opts.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	// Resume right after the last user of the previous page
+	if after := opts.After; after != nil {
+		var value interface{} = after.CreatedAt
+		switch opts.SortBy {
+		case domain.UserSortByEmail:
+			value = after.Email
+		case domain.UserSortByLastName:
+			value = after.LastName
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, u.id) %s (%s, %s)", column, comparison, arg(value), arg(after.ID)))
+	}
+
+	// Read one extra row to know whether there is a next page
+	query := fmt.Sprintf(`
+		SELECT `+userColumns+`
+		FROM `+usersWithMemberships+`
+		WHERE %s
+		ORDER BY %s %s, u.id %s
+		LIMIT %s
+	`, strings.Join(conditions, " AND "), column, direction, direction, arg(opts.Limit+1))
+
+	users := make([]*domain.User, 0, opts.Limit+1)
+	err := inTenantTx(ctx, r.db, tenantID, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, args...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan user: %w", err)
+			return fmt.Errorf("failed to list users: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			user, err := scanUser(rows)
+			if err != nil {
+				return fmt.Errorf("failed to scan user: %w", err)
+			}
+			users = append(users, user)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating users: %w", err)
 		}
-		users = append(users, &user)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating users: %w", err)
+	page := &domain.UserPage{Users: users}
+	if opts.Limit > 0 && len(users) > opts.Limit {
+		page.Users = users[:opts.Limit]
+		page.Next = domain.NewUserCursor(page.Users[len(page.Users)-1])
 	}
 
-	return users, nil
+	return page, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// userColumns are the columns of a user, as scanned by scanUser, selected
+// from usersWithMemberships
+const userColumns = `u.id, u.tenant_id, u.email, u.first_name, u.last_name, m.role, u.active, u.created_at, u.updated_at, u.version, COALESCE(u.external_id, '')`
+
+// usersWithMemberships joins the users to their membership in their tenant
+const usersWithMemberships = `users u JOIN memberships m ON m.user_id = u.id AND m.organization_id = u.tenant_id`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser scans a users row selected with the standard column list
+func scanUser(row rowScanner) (*domain.User, error) {
+	var user domain.User
+	err := row.Scan(
+		&user.ID,
+		&user.TenantID,
+		&user.Email,
+		&user.FirstName,
+		&user.LastName,
+		&user.Role,
+		&user.Active,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Version,
+		&user.ExternalID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
@@ -0,0 +1,127 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// SyncUserStatusWorkflow is a workflow that applies the active state of a user
+// to their identity provider account
+type SyncUserStatusWorkflow struct {
+	userRepo         ports.UserRepository
+	identityProvider ports.IdentityProvider
+}
+
+// NewSyncUserStatusWorkflow creates a new SyncUserStatusWorkflow
+func NewSyncUserStatusWorkflow(userRepo ports.UserRepository, identityProvider ports.IdentityProvider) *SyncUserStatusWorkflow {
+	return &SyncUserStatusWorkflow{
+		userRepo:         userRepo,
+		identityProvider: identityProvider,
+	}
+}
+
+// SyncUserStatusWorkflowInput represents the input for the SyncUserStatusWorkflow
+type SyncUserStatusWorkflowInput struct {
+	TenantID string
+	UserID   string
+}
+
+// Execute executes the SyncUserStatusWorkflow
+func (w *SyncUserStatusWorkflow) Execute(ctx workflow.Context, input SyncUserStatusWorkflowInput) error {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("SyncUserStatusWorkflow started", "id", input.UserID)
+
+	// Keep retrying while the identity provider is unavailable
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	err := workflow.ExecuteActivity(ctx, w.SyncUserStatusActivity, input).Get(ctx, nil)
+	if err != nil {
+		logger.Error("SyncUserStatusActivity failed", "error", err)
+		return err
+	}
+
+	logger.Info("SyncUserStatusWorkflow completed", "id", input.UserID)
+	return nil
+}
+
+// SyncUserStatusActivity is an activity that enables or disables the identity
+// provider account of a user. The state is read when the activity runs rather
+// than passed in, so that concurrent changes converge to the latest one.
+func (w *SyncUserStatusWorkflow) SyncUserStatusActivity(ctx context.Context, input SyncUserStatusWorkflowInput) error {
+	logger := activity.GetLogger(ctx)
+
+	user, err := w.userRepo.GetByID(ctx, input.TenantID, input.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		logger.Warn("User no longer exists, skipping status sync", "id", input.UserID)
+		return nil
+	}
+
+	// Unlinked users have no account to update yet; linking them syncs it
+	if user.ExternalID == "" {
+		logger.Info("User is not linked to an identity provider account, skipping status sync", "id", input.UserID)
+		return nil
+	}
+
+	// A linked account that does not exist cannot be disabled: fail rather
+	// than leave the account of a deactivated user enabled unnoticed
+	err = w.identityProvider.SetUserEnabled(ctx, user.ExternalID, user.Active)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("identity provider account %s of user %s not found", user.ExternalID, user.ID),
+			"IdentityAccountNotFound", err)
+	}
+	return err
+}
+
+// UserStatusSynchronizer starts a SyncUserStatusWorkflow for every change of
+// the active state of a user
+type UserStatusSynchronizer struct {
+	client    client.Client
+	taskQueue string
+}
+
+// NewUserStatusSynchronizer creates a new UserStatusSynchronizer
+func NewUserStatusSynchronizer(c client.Client, taskQueue string) ports.UserStatusSynchronizer {
+	return &UserStatusSynchronizer{
+		client:    c,
+		taskQueue: taskQueue,
+	}
+}
+
+// SyncUserStatus starts the workflow propagating the state of user without waiting for it
+func (s *UserStatusSynchronizer) SyncUserStatus(ctx context.Context, user *domain.User) error {
+	options := client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("sync-user-status-%s-%d", user.ID, user.UpdatedAt.UnixNano()),
+		TaskQueue: s.taskQueue,
+	}
+
+	input := SyncUserStatusWorkflowInput{
+		TenantID: user.TenantID,
+		UserID:   user.ID,
+	}
+
+	if _, err := s.client.ExecuteWorkflow(ctx, options, "SyncUserStatusWorkflow", input); err != nil {
+		return fmt.Errorf("failed to start user status sync: %w", err)
+	}
+	return nil
+}
@@ -2,12 +2,13 @@ package temporal
 
 import (
 	"context"
+	"errors"
 	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/worker"
 	"time"
 
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/commands"
-	_ "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
@@ -26,15 +27,19 @@ func NewCreateUserWorkflow(createUserHandler *commands.CreateUserHandler) *Creat
 
 // CreateUserWorkflowInput represents the input for the CreateUserWorkflow
 type CreateUserWorkflowInput struct {
+	TenantID  string
 	Email     string
 	FirstName string
 	LastName  string
 	Role      string
+	// ExternalID optionally links the user to an identity provider account
+	ExternalID string
 }
 
 // CreateUserWorkflowOutput represents the output of the CreateUserWorkflow
 type CreateUserWorkflowOutput struct {
 	ID        string
+	TenantID  string
 	Email     string
 	FirstName string
 	LastName  string
@@ -77,21 +82,24 @@ func (w *CreateUserWorkflow) Execute(ctx workflow.Context, input CreateUserWorkf
 func (w *CreateUserWorkflow) CreateUserActivity(ctx context.Context, input CreateUserWorkflowInput) (*CreateUserWorkflowOutput, error) {
 	// Create command
 	cmd := commands.CreateUserCommand{
-		Email:     input.Email,
-		FirstName: input.FirstName,
-		LastName:  input.LastName,
-		Role:      input.Role,
+		TenantID:   input.TenantID,
+		Email:      input.Email,
+		FirstName:  input.FirstName,
+		LastName:   input.LastName,
+		Role:       input.Role,
+		ExternalID: input.ExternalID,
 	}
 
 	// Handle command
 	user, err := w.createUserHandler.Handle(ctx, cmd)
 	if err != nil {
-		return nil, err
+		return nil, activityError(err)
 	}
 
 	// Map to output
 	return &CreateUserWorkflowOutput{
 		ID:        user.ID,
+		TenantID:  user.TenantID,
 		Email:     user.Email,
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
@@ -102,16 +110,37 @@ func (w *CreateUserWorkflow) CreateUserActivity(ctx context.Context, input Creat
 	}, nil
 }
 
+// ValidationErrorType is the type of the application errors returned by
+// activities given invalid input. Their details are the invalid fields, as
+// domain.ValidationErrors.
+const ValidationErrorType = "ValidationError"
+
+// activityError makes the validation errors of an activity non-retryable,
+// since retrying cannot fix its input
+func activityError(err error) error {
+	var validationErrs domain.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return temporal.NewNonRetryableApplicationError(err.Error(), ValidationErrorType, err, validationErrs)
+	}
+	var validationErr domain.ValidationError
+	if errors.As(err, &validationErr) {
+		return temporal.NewNonRetryableApplicationError(err.Error(), ValidationErrorType, err, domain.ValidationErrors{validationErr})
+	}
+	return err
+}
+
 // Worker represents a Temporal worker
 type Worker struct {
-	createUserWorkflow *CreateUserWorkflow
+	createUserWorkflow     *CreateUserWorkflow
+	syncUserStatusWorkflow *SyncUserStatusWorkflow
 	// Add other workflows here
 }
 
 // NewWorker creates a new Worker
-func NewWorker(createUserWorkflow *CreateUserWorkflow) *Worker {
+func NewWorker(createUserWorkflow *CreateUserWorkflow, syncUserStatusWorkflow *SyncUserStatusWorkflow) *Worker {
 	return &Worker{
-		createUserWorkflow: createUserWorkflow,
+		createUserWorkflow:     createUserWorkflow,
+		syncUserStatusWorkflow: syncUserStatusWorkflow,
 	}
 }
 
@@ -121,6 +150,10 @@ func (w *Worker) RegisterWorkflows(registry worker.Registry) {
 		w.createUserWorkflow.Execute,
 		workflow.RegisterOptions{Name: "CreateUserWorkflow"},
 	)
+	registry.RegisterWorkflowWithOptions(
+		w.syncUserStatusWorkflow.Execute,
+		workflow.RegisterOptions{Name: "SyncUserStatusWorkflow"},
+	)
 }
 
 // RegisterActivities registers all activities
@@ -129,4 +162,8 @@ func (w *Worker) RegisterActivities(registry worker.Registry) {
 		w.createUserWorkflow.CreateUserActivity,
 		activity.RegisterOptions{Name: "CreateUserActivity"},
 	)
+	registry.RegisterActivityWithOptions(
+		w.syncUserStatusWorkflow.SyncUserStatusActivity,
+		activity.RegisterOptions{Name: "SyncUserStatusActivity"},
+	)
 }
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain/validation"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// ActivateUserCommand represents a command to activate a user
+type ActivateUserCommand struct {
+	TenantID string
+	ID       string
+}
+
+// ActivateUserHandler handles the ActivateUserCommand
+type ActivateUserHandler struct {
+	userRepo   ports.UserRepository
+	statusSync ports.UserStatusSynchronizer
+}
+
+// NewActivateUserHandler creates a new ActivateUserHandler. statusSync may be
+// nil, in which case the identity provider account is left untouched.
+func NewActivateUserHandler(userRepo ports.UserRepository, statusSync ports.UserStatusSynchronizer) *ActivateUserHandler {
+	return &ActivateUserHandler{
+		userRepo:   userRepo,
+		statusSync: statusSync,
+	}
+}
+
+// Handle handles the ActivateUserCommand
+func (h *ActivateUserHandler) Handle(ctx context.Context, cmd ActivateUserCommand) (*domain.User, error) {
+	// Validate command
+	if err := validateUserStatusCommand(cmd.TenantID, cmd.ID); err != nil {
+		return nil, err
+	}
+
+	return setUserActive(ctx, h.userRepo, h.statusSync, cmd.TenantID, cmd.ID, true)
+}
+
+// setUserActive changes the active state of a user and propagates it to the
+// identity provider. Users already in the requested state are left as is.
+func setUserActive(
+	ctx context.Context,
+	userRepo ports.UserRepository,
+	statusSync ports.UserStatusSynchronizer,
+	tenantID, id string,
+	active bool,
+) (*domain.User, error) {
+	// Get user
+	user, err := userRepo.GetByID(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	if user.Active == active {
+		return user, nil
+	}
+
+	if active {
+		user.Activate()
+	} else {
+		user.Deactivate()
+	}
+
+	// Save user along with its event
+	if err := userRepo.Update(ctx, user, domain.NewUserUpdatedEvent(user)); err != nil {
+		return nil, err
+	}
+
+	// Propagate the new state to the identity provider
+	if statusSync != nil {
+		if err := statusSync.SyncUserStatus(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// validateUserStatusCommand validates the commands changing the active state of a user
+func validateUserStatusCommand(tenantID, id string) error {
+	v := validation.New()
+	v.Required("tenant_id", tenantID)
+	v.Required("id", id)
+	return v.Err()
+}
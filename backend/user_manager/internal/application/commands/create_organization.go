@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain/validation"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+	"github.com/google/uuid"
+)
+
+// slugPattern matches lowercase, hyphen-separated slugs such as "acme-corp"
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// CreateOrganizationCommand represents a command to create an organization
+type CreateOrganizationCommand struct {
+	Name string
+	Slug string
+	Plan string
+}
+
+// CreateOrganizationHandler handles the CreateOrganizationCommand
+type CreateOrganizationHandler struct {
+	orgRepo ports.OrganizationRepository
+}
+
+// NewCreateOrganizationHandler creates a new CreateOrganizationHandler
+func NewCreateOrganizationHandler(orgRepo ports.OrganizationRepository) *CreateOrganizationHandler {
+	return &CreateOrganizationHandler{
+		orgRepo: orgRepo,
+	}
+}
+
+// Handle handles the CreateOrganizationCommand
+func (h *CreateOrganizationHandler) Handle(ctx context.Context, cmd CreateOrganizationCommand) (*domain.Organization, error) {
+	// Default to the free plan
+	if strings.TrimSpace(cmd.Plan) == "" {
+		cmd.Plan = domain.PlanFree
+	}
+
+	// Validate command
+	if err := validateCreateOrganizationCommand(cmd); err != nil {
+		return nil, err
+	}
+
+	// Check if the slug is already taken
+	existingOrg, err := h.orgRepo.GetBySlug(ctx, cmd.Slug)
+	if err != nil {
+		return nil, err
+	}
+	if existingOrg != nil {
+		return nil, domain.ErrOrganizationAlreadyExists
+	}
+
+	// Create organization
+	org := domain.NewOrganization(cmd.Name, cmd.Slug, cmd.Plan)
+	org.ID = uuid.New().String()
+
+	// Save organization
+	if err := h.orgRepo.Create(ctx, org); err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// validateCreateOrganizationCommand validates the CreateOrganizationCommand, reporting every invalid field
+func validateCreateOrganizationCommand(cmd CreateOrganizationCommand) error {
+	v := validation.New()
+	v.Required("name", cmd.Name)
+	v.MaxLength("name", cmd.Name, domain.MaxOrganizationNameLength)
+	v.Check(slugPattern.MatchString(cmd.Slug), "slug", "must contain only lowercase letters, digits and hyphens")
+	v.MaxLength("slug", cmd.Slug, domain.MaxSlugLength)
+	v.Check(domain.IsValidPlan(cmd.Plan), "plan", "must be one of free, pro, enterprise")
+	return v.Err()
+}
@@ -2,30 +2,36 @@ package commands
 
 import (
 	"context"
-	"strings"
 
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain/validation"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
 	"github.com/google/uuid"
 )
 
 // CreateUserCommand represents a command to create a user
 type CreateUserCommand struct {
+	TenantID  string
 	Email     string
 	FirstName string
 	LastName  string
 	Role      string
+	// ExternalID links the user to an existing identity provider account.
+	// Optional: users are otherwise linked when they first sign in.
+	ExternalID string
 }
 
 // CreateUserHandler handles the CreateUserCommand
 type CreateUserHandler struct {
 	userRepo ports.UserRepository
+	orgRepo  ports.OrganizationRepository
 }
 
 // NewCreateUserHandler creates a new CreateUserHandler
-func NewCreateUserHandler(userRepo ports.UserRepository) *CreateUserHandler {
+func NewCreateUserHandler(userRepo ports.UserRepository, orgRepo ports.OrganizationRepository) *CreateUserHandler {
 	return &CreateUserHandler{
 		userRepo: userRepo,
+		orgRepo:  orgRepo,
 	}
 }
 
@@ -36,8 +42,20 @@ func (h *CreateUserHandler) Handle(ctx context.Context, cmd CreateUserCommand) (
 		return nil, err
 	}
 
+	// Check that the organization can receive new members
+	org, err := h.orgRepo.GetByID(ctx, cmd.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, domain.ErrOrganizationNotFound
+	}
+	if !org.IsActive() {
+		return nil, domain.ErrOrganizationSuspended
+	}
+
 	// Check if user already exists
-	existingUser, err := h.userRepo.GetByEmail(ctx, cmd.Email)
+	existingUser, err := h.userRepo.GetByEmail(ctx, cmd.TenantID, cmd.Email)
 	if err != nil {
 		return nil, err
 	}
@@ -48,28 +66,22 @@ func (h *CreateUserHandler) Handle(ctx context.Context, cmd CreateUserCommand) (
 	// Create user
 	user := domain.NewUser(cmd.Email, cmd.FirstName, cmd.LastName, cmd.Role)
 	user.ID = uuid.New().String()
+	user.TenantID = cmd.TenantID
+	user.ExternalID = cmd.ExternalID
 
-	// Save user
-	if err := h.userRepo.Create(ctx, user); err != nil {
+	// Save user along with its event
+	if err := h.userRepo.Create(ctx, user, domain.NewUserCreatedEvent(user)); err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
 
-// validateCreateUserCommand validates the CreateUserCommand
+// validateCreateUserCommand validates the CreateUserCommand, reporting every invalid field
 func validateCreateUserCommand(cmd CreateUserCommand) error {
-	if strings.TrimSpace(cmd.Email) == "" {
-		return domain.NewValidationError("email", "email is required")
-	}
-	if strings.TrimSpace(cmd.FirstName) == "" {
-		return domain.NewValidationError("firstName", "first name is required")
-	}
-	if strings.TrimSpace(cmd.LastName) == "" {
-		return domain.NewValidationError("lastName", "last name is required")
-	}
-	if strings.TrimSpace(cmd.Role) == "" {
-		return domain.NewValidationError("role", "role is required")
-	}
-	return nil
+	v := validation.New()
+	v.Required("tenant_id", cmd.TenantID)
+	v.User(cmd.Email, cmd.FirstName, cmd.LastName, cmd.Role)
+	v.MaxLength("external_id", cmd.ExternalID, domain.MaxExternalIDLength)
+	return v.Err()
 }
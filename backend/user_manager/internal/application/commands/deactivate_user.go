@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// DeactivateUserCommand represents a command to deactivate a user.
+// Deactivated users keep their data but can no longer use the API, and their
+// identity provider account is disabled.
+type DeactivateUserCommand struct {
+	TenantID string
+	ID       string
+}
+
+// DeactivateUserHandler handles the DeactivateUserCommand
+type DeactivateUserHandler struct {
+	userRepo   ports.UserRepository
+	statusSync ports.UserStatusSynchronizer
+}
+
+// NewDeactivateUserHandler creates a new DeactivateUserHandler. statusSync may
+// be nil, in which case the identity provider account is left untouched.
+func NewDeactivateUserHandler(userRepo ports.UserRepository, statusSync ports.UserStatusSynchronizer) *DeactivateUserHandler {
+	return &DeactivateUserHandler{
+		userRepo:   userRepo,
+		statusSync: statusSync,
+	}
+}
+
+// Handle handles the DeactivateUserCommand
+func (h *DeactivateUserHandler) Handle(ctx context.Context, cmd DeactivateUserCommand) (*domain.User, error) {
+	// Validate command
+	if err := validateUserStatusCommand(cmd.TenantID, cmd.ID); err != nil {
+		return nil, err
+	}
+
+	return setUserActive(ctx, h.userRepo, h.statusSync, cmd.TenantID, cmd.ID, false)
+}
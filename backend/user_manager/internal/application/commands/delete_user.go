@@ -2,15 +2,18 @@ package commands
 
 import (
 	"context"
-	"strings"
 
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain/validation"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
 )
 
 // DeleteUserCommand represents a command to delete a user
 type DeleteUserCommand struct {
-	ID string
+	TenantID string
+	ID       string
+	// ExpectedVersion is the version the caller read; 0 skips the check
+	ExpectedVersion int64
 }
 
 // DeleteUserHandler handles the DeleteUserCommand
@@ -33,22 +36,25 @@ func (h *DeleteUserHandler) Handle(ctx context.Context, cmd DeleteUserCommand) e
 	}
 
 	// Check if user exists
-	user, err := h.userRepo.GetByID(ctx, cmd.ID)
+	user, err := h.userRepo.GetByID(ctx, cmd.TenantID, cmd.ID)
 	if err != nil {
 		return err
 	}
 	if user == nil {
 		return domain.ErrUserNotFound
 	}
+	if cmd.ExpectedVersion != 0 && user.Version != cmd.ExpectedVersion {
+		return domain.ErrVersionMismatch
+	}
 
-	// Delete user
-	return h.userRepo.Delete(ctx, cmd.ID)
+	// Delete user along with its event, unless it changed since it was read
+	return h.userRepo.Delete(ctx, cmd.TenantID, cmd.ID, user.Version, domain.NewUserDeletedEvent(user))
 }
 
 // validateDeleteUserCommand validates the DeleteUserCommand
 func validateDeleteUserCommand(cmd DeleteUserCommand) error {
-	if strings.TrimSpace(cmd.ID) == "" {
-		return domain.NewValidationError("id", "id is required")
-	}
-	return nil
+	v := validation.New()
+	v.Required("tenant_id", cmd.TenantID)
+	v.Required("id", cmd.ID)
+	return v.Err()
 }
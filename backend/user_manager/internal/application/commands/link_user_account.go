@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain/validation"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// LinkUserAccountCommand represents a command to link the user registered
+// with Email to the identity provider account ExternalID, at the request of
+// the account holder. The email must have been verified by the identity
+// provider.
+type LinkUserAccountCommand struct {
+	TenantID   string
+	ExternalID string
+	Email      string
+}
+
+// LinkUserAccountHandler handles the LinkUserAccountCommand
+type LinkUserAccountHandler struct {
+	userRepo   ports.UserRepository
+	statusSync ports.UserStatusSynchronizer
+}
+
+// NewLinkUserAccountHandler creates a new LinkUserAccountHandler. statusSync
+// may be nil, in which case the accounts of deactivated users are left
+// enabled at the identity provider.
+func NewLinkUserAccountHandler(userRepo ports.UserRepository, statusSync ports.UserStatusSynchronizer) *LinkUserAccountHandler {
+	return &LinkUserAccountHandler{
+		userRepo:   userRepo,
+		statusSync: statusSync,
+	}
+}
+
+// Handle handles the LinkUserAccountCommand. It returns the linked user, or
+// nil when no user of the tenant can be linked to the account: there is no
+// user with the email, or it is linked to another account.
+func (h *LinkUserAccountHandler) Handle(ctx context.Context, cmd LinkUserAccountCommand) (*domain.User, error) {
+	// Validate command
+	if err := validateLinkUserAccountCommand(cmd); err != nil {
+		return nil, err
+	}
+
+	user, err := h.userRepo.GetByEmail(ctx, cmd.TenantID, cmd.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || (user.ExternalID != "" && user.ExternalID != cmd.ExternalID) {
+		return nil, nil
+	}
+	if user.ExternalID == cmd.ExternalID {
+		return user, nil
+	}
+
+	// Save user along with its events
+	user.LinkAccount(cmd.ExternalID)
+	if err := h.userRepo.Update(ctx, user, domain.NewUserUpdatedEvent(user), domain.NewUserLinkedEvent(user)); err != nil {
+		return nil, err
+	}
+
+	// Users deactivated before they were linked have an enabled account
+	if !user.Active && h.statusSync != nil {
+		if err := h.statusSync.SyncUserStatus(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// validateLinkUserAccountCommand validates the LinkUserAccountCommand
+func validateLinkUserAccountCommand(cmd LinkUserAccountCommand) error {
+	v := validation.New()
+	v.Required("tenant_id", cmd.TenantID)
+	v.Required("external_id", cmd.ExternalID)
+	v.MaxLength("external_id", cmd.ExternalID, domain.MaxExternalIDLength)
+	v.Required("email", cmd.Email)
+	return v.Err()
+}
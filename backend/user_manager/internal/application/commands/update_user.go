@@ -2,19 +2,22 @@ package commands
 
 import (
 	"context"
-	"strings"
 
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain/validation"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
 )
 
 // UpdateUserCommand represents a command to update a user
 type UpdateUserCommand struct {
+	TenantID  string
 	ID        string
 	Email     string
 	FirstName string
 	LastName  string
 	Role      string
+	// ExpectedVersion is the version the caller read; 0 skips the check
+	ExpectedVersion int64
 }
 
 // UpdateUserHandler handles the UpdateUserCommand
@@ -37,17 +40,20 @@ func (h *UpdateUserHandler) Handle(ctx context.Context, cmd UpdateUserCommand) (
 	}
 
 	// Get user
-	user, err := h.userRepo.GetByID(ctx, cmd.ID)
+	user, err := h.userRepo.GetByID(ctx, cmd.TenantID, cmd.ID)
 	if err != nil {
 		return nil, err
 	}
 	if user == nil {
 		return nil, domain.ErrUserNotFound
 	}
+	if cmd.ExpectedVersion != 0 && user.Version != cmd.ExpectedVersion {
+		return nil, domain.ErrVersionMismatch
+	}
 
 	// Check if email is already used by another user
 	if user.Email != cmd.Email {
-		existingUser, err := h.userRepo.GetByEmail(ctx, cmd.Email)
+		existingUser, err := h.userRepo.GetByEmail(ctx, cmd.TenantID, cmd.Email)
 		if err != nil {
 			return nil, err
 		}
@@ -59,30 +65,19 @@ func (h *UpdateUserHandler) Handle(ctx context.Context, cmd UpdateUserCommand) (
 	// Update user
 	user.Update(cmd.Email, cmd.FirstName, cmd.LastName, cmd.Role)
 
-	// Save user
-	if err := h.userRepo.Update(ctx, user); err != nil {
+	// Save user along with its event, unless it changed since it was read
+	if err := h.userRepo.Update(ctx, user, domain.NewUserUpdatedEvent(user)); err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
 
-// validateUpdateUserCommand validates the UpdateUserCommand
+// validateUpdateUserCommand validates the UpdateUserCommand, reporting every invalid field
 func validateUpdateUserCommand(cmd UpdateUserCommand) error {
-	if strings.TrimSpace(cmd.ID) == "" {
-		return domain.NewValidationError("id", "id is required")
-	}
-	if strings.TrimSpace(cmd.Email) == "" {
-		return domain.NewValidationError("email", "email is required")
-	}
-	if strings.TrimSpace(cmd.FirstName) == "" {
-		return domain.NewValidationError("firstName", "first name is required")
-	}
-	if strings.TrimSpace(cmd.LastName) == "" {
-		return domain.NewValidationError("lastName", "last name is required")
-	}
-	if strings.TrimSpace(cmd.Role) == "" {
-		return domain.NewValidationError("role", "role is required")
-	}
-	return nil
+	v := validation.New()
+	v.Required("tenant_id", cmd.TenantID)
+	v.Required("id", cmd.ID)
+	v.User(cmd.Email, cmd.FirstName, cmd.LastName, cmd.Role)
+	return v.Err()
 }
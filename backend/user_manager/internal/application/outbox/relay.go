@@ -0,0 +1,165 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// RelayConfig holds the settings of a Relay. Zero values select the defaults.
+type RelayConfig struct {
+	// PollInterval is the delay between two checks of an empty outbox
+	PollInterval time.Duration
+	// BatchSize is the maximum number of events claimed at once
+	BatchSize int
+	// Lease is how long claimed events are hidden from other relays. It must
+	// exceed the time needed to publish a batch.
+	Lease time.Duration
+	// MinBackoff and MaxBackoff bound the delay before retrying an event,
+	// which doubles with each failed attempt
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Retention is how long published events are kept before they are
+	// purged, and PurgeInterval the delay between two purges
+	Retention     time.Duration
+	PurgeInterval time.Duration
+	// Now returns the current time
+	Now func() time.Time
+}
+
+// Relay publishes the events of the outbox. Events are only marked as
+// published once the publisher accepted them, so they are delivered at least
+// once; failed events are retried with an exponential backoff. Published
+// events are purged once their retention period is over.
+type Relay struct {
+	outbox    ports.OutboxRepository
+	publisher ports.EventPublisher
+	cfg       RelayConfig
+}
+
+// NewRelay creates a new Relay
+func NewRelay(outbox ports.OutboxRepository, publisher ports.EventPublisher, cfg RelayConfig) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 30 * time.Second
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = cfg.MinBackoff
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 7 * 24 * time.Hour
+	}
+	if cfg.PurgeInterval <= 0 {
+		cfg.PurgeInterval = 10 * time.Minute
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Relay{
+		outbox:    outbox,
+		publisher: publisher,
+		cfg:       cfg,
+	}
+}
+
+// Run publishes events, and purges the published ones, until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	var lastPurge time.Time
+	for {
+		if now := r.cfg.Now(); now.Sub(lastPurge) >= r.cfg.PurgeInterval {
+			lastPurge = now
+			purged, err := r.PurgePublished(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to purge published outbox events", "error", err)
+			}
+			if purged > 0 {
+				slog.InfoContext(ctx, "Purged published outbox events", "count", purged)
+			}
+		}
+
+		published, err := r.PublishPending(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to relay outbox events", "error", err)
+		}
+
+		// Keep going while the outbox is backed up
+		delay := r.cfg.PollInterval
+		if err == nil && published == r.cfg.BatchSize {
+			delay = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// PublishPending publishes one batch of due events and returns the number of
+// events claimed. Events failing to publish are scheduled for a retry.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	now := r.cfg.Now()
+	entries, err := r.outbox.Claim(ctx, now, now.Add(r.cfg.Lease), r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		if err := r.publisher.Publish(ctx, entry.Event); err != nil {
+			slog.WarnContext(ctx, "Failed to publish event", "event.type", entry.Event.Type, "event.id", entry.Event.ID, "attempt", entry.Attempts+1, "error", err)
+			retryAt := r.cfg.Now().Add(r.backoff(entry.Attempts + 1))
+			if err := r.outbox.MarkFailed(ctx, entry.Event.ID, err.Error(), retryAt); err != nil {
+				return len(entries), err
+			}
+			continue
+		}
+
+		// A failure here republishes the event once its lease expires
+		if err := r.outbox.MarkPublished(ctx, entry.Event.ID); err != nil {
+			return len(entries), err
+		}
+	}
+
+	return len(entries), nil
+}
+
+// PurgePublished deletes the events published more than Retention ago, one
+// batch at a time, and returns the number of events deleted
+func (r *Relay) PurgePublished(ctx context.Context) (int, error) {
+	before := r.cfg.Now().Add(-r.cfg.Retention)
+	total := 0
+	for {
+		purged, err := r.outbox.PurgePublished(ctx, before, r.cfg.BatchSize)
+		total += purged
+		if err != nil || purged < r.cfg.BatchSize {
+			return total, err
+		}
+	}
+}
+
+// backoff returns the delay before retrying an event after its nth failed attempt
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.cfg.MinBackoff
+	for i := 1; i < attempts && delay < r.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.cfg.MaxBackoff {
+		delay = r.cfg.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,37 @@
+package queries
+
+import (
+	"context"
+	"strings"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// GetOrganizationQuery represents a query to get the caller's organization
+type GetOrganizationQuery struct {
+	TenantID string
+}
+
+// GetOrganizationHandler handles the GetOrganizationQuery
+type GetOrganizationHandler struct {
+	orgRepo ports.OrganizationRepository
+}
+
+// NewGetOrganizationHandler creates a new GetOrganizationHandler
+func NewGetOrganizationHandler(orgRepo ports.OrganizationRepository) *GetOrganizationHandler {
+	return &GetOrganizationHandler{
+		orgRepo: orgRepo,
+	}
+}
+
+// Handle handles the GetOrganizationQuery
+func (h *GetOrganizationHandler) Handle(ctx context.Context, query GetOrganizationQuery) (*domain.Organization, error) {
+	// Validate query
+	if strings.TrimSpace(query.TenantID) == "" {
+		return nil, domain.NewValidationError("tenantId", "tenant id is required")
+	}
+
+	// Get organization
+	return h.orgRepo.GetByID(ctx, query.TenantID)
+}
@@ -10,7 +10,8 @@ import (
 
 // GetUserByIDQuery represents a query to get a user by ID
 type GetUserByIDQuery struct {
-	ID string
+	TenantID string
+	ID       string
 }
 
 // GetUserByIDHandler handles the GetUserByIDQuery
@@ -33,11 +34,14 @@ func (h *GetUserByIDHandler) Handle(ctx context.Context, query GetUserByIDQuery)
 	}
 
 	// Get user
-	return h.userRepo.GetByID(ctx, query.ID)
+	return h.userRepo.GetByID(ctx, query.TenantID, query.ID)
 }
 
 // validateGetUserByIDQuery validates the GetUserByIDQuery
 func validateGetUserByIDQuery(query GetUserByIDQuery) error {
+	if strings.TrimSpace(query.TenantID) == "" {
+		return domain.NewValidationError("tenantId", "tenant id is required")
+	}
 	if strings.TrimSpace(query.ID) == "" {
 		return domain.NewValidationError("id", "id is required")
 	}
@@ -46,7 +50,8 @@ func validateGetUserByIDQuery(query GetUserByIDQuery) error {
 
 // GetUserByEmailQuery represents a query to get a user by email
 type GetUserByEmailQuery struct {
-	Email string
+	TenantID string
+	Email    string
 }
 
 // GetUserByEmailHandler handles the GetUserByEmailQuery
@@ -69,13 +74,57 @@ func (h *GetUserByEmailHandler) Handle(ctx context.Context, query GetUserByEmail
 	}
 
 	// Get user
-	return h.userRepo.GetByEmail(ctx, query.Email)
+	return h.userRepo.GetByEmail(ctx, query.TenantID, query.Email)
 }
 
 // validateGetUserByEmailQuery validates the GetUserByEmailQuery
 func validateGetUserByEmailQuery(query GetUserByEmailQuery) error {
+	if strings.TrimSpace(query.TenantID) == "" {
+		return domain.NewValidationError("tenantId", "tenant id is required")
+	}
 	if strings.TrimSpace(query.Email) == "" {
 		return domain.NewValidationError("email", "email is required")
 	}
 	return nil
 }
+
+// GetUserByExternalIDQuery represents a query to get the user linked to an
+// identity provider account
+type GetUserByExternalIDQuery struct {
+	TenantID   string
+	ExternalID string
+}
+
+// GetUserByExternalIDHandler handles the GetUserByExternalIDQuery
+type GetUserByExternalIDHandler struct {
+	userRepo ports.UserRepository
+}
+
+// NewGetUserByExternalIDHandler creates a new GetUserByExternalIDHandler
+func NewGetUserByExternalIDHandler(userRepo ports.UserRepository) *GetUserByExternalIDHandler {
+	return &GetUserByExternalIDHandler{
+		userRepo: userRepo,
+	}
+}
+
+// Handle handles the GetUserByExternalIDQuery
+func (h *GetUserByExternalIDHandler) Handle(ctx context.Context, query GetUserByExternalIDQuery) (*domain.User, error) {
+	// Validate query
+	if err := validateGetUserByExternalIDQuery(query); err != nil {
+		return nil, err
+	}
+
+	// Get user
+	return h.userRepo.GetByExternalID(ctx, query.TenantID, query.ExternalID)
+}
+
+// validateGetUserByExternalIDQuery validates the GetUserByExternalIDQuery
+func validateGetUserByExternalIDQuery(query GetUserByExternalIDQuery) error {
+	if strings.TrimSpace(query.TenantID) == "" {
+		return domain.NewValidationError("tenantId", "tenant id is required")
+	}
+	if strings.TrimSpace(query.ExternalID) == "" {
+		return domain.NewValidationError("externalId", "external id is required")
+	}
+	return nil
+}
@@ -2,14 +2,48 @@ package queries
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
 
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+	"github.com/google/uuid"
+)
+
+// Page sizes of user listings
+const (
+	DefaultListUsersLimit = 50
+	MaxListUsersLimit     = 200
 )
 
 // ListUsersQuery represents a query to list users
 type ListUsersQuery struct {
-	// Add filters here if needed
+	TenantID string
+
+	// Filters; zero values match every user
+	Role          string
+	Active        *bool
+	Search        string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// SortBy defaults to created_at, newest first unless Ascending is set
+	SortBy    string
+	Ascending bool
+
+	// Limit defaults to DefaultListUsersLimit
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// ListUsersResult is a page of users
+type ListUsersResult struct {
+	Users []*domain.User
+	// NextCursor is the opaque cursor of the next page, empty on the last page
+	NextCursor string
 }
 
 // ListUsersHandler handles the ListUsersQuery
@@ -25,7 +59,128 @@ func NewListUsersHandler(userRepo ports.UserRepository) *ListUsersHandler {
 }
 
 // Handle handles the ListUsersQuery
-func (h *ListUsersHandler) Handle(ctx context.Context, query ListUsersQuery) ([]*domain.User, error) {
+func (h *ListUsersHandler) Handle(ctx context.Context, query ListUsersQuery) (*ListUsersResult, error) {
+	// Validate query
+	opts, err := listUsersOptions(query)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get users
-	return h.userRepo.List(ctx)
+	page, err := h.userRepo.List(ctx, query.TenantID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ListUsersResult{Users: page.Users}
+	if page.Next != nil {
+		result.NextCursor = encodeUserCursor(opts, page.Next)
+	}
+
+	return result, nil
+}
+
+// listUsersOptions validates the ListUsersQuery and converts it to repository options
+func listUsersOptions(query ListUsersQuery) (domain.UserListOptions, error) {
+	opts := domain.UserListOptions{
+		Filter: domain.UserFilter{
+			Role:          strings.TrimSpace(query.Role),
+			Active:        query.Active,
+			Search:        strings.TrimSpace(query.Search),
+			CreatedAfter:  query.CreatedAfter,
+			CreatedBefore: query.CreatedBefore,
+		},
+		SortBy:     domain.UserSortField(query.SortBy),
+		Descending: !query.Ascending,
+		Limit:      query.Limit,
+	}
+
+	if strings.TrimSpace(query.TenantID) == "" {
+		return opts, domain.NewValidationError("tenant_id", "tenant id is required")
+	}
+	if opts.SortBy == "" {
+		opts.SortBy = domain.UserSortByCreatedAt
+	}
+	if !domain.IsValidUserSortField(opts.SortBy) {
+		return opts, domain.NewValidationError("sort", "users can be sorted by created_at, email or last_name")
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultListUsersLimit
+	}
+	if opts.Limit < 0 || opts.Limit > MaxListUsersLimit {
+		return opts, domain.NewValidationError("limit", "limit must be between 1 and 200")
+	}
+	if !opts.Filter.CreatedAfter.IsZero() && !opts.Filter.CreatedBefore.IsZero() &&
+		!opts.Filter.CreatedAfter.Before(opts.Filter.CreatedBefore) {
+		return opts, domain.NewValidationError("created_before", "must be after created_after")
+	}
+
+	if query.Cursor != "" {
+		after, err := decodeUserCursor(opts, query.Cursor)
+		if err != nil {
+			return opts, err
+		}
+		opts.After = after
+	}
+
+	return opts, nil
+}
+
+// userCursorToken is the content of an opaque cursor. It records the sort
+// order it was issued for, so that it cannot be replayed with another one.
+type userCursorToken struct {
+	SortBy     domain.UserSortField `json:"s"`
+	Descending bool                 `json:"d,omitempty"`
+	ID         string               `json:"id"`
+	CreatedAt  time.Time            `json:"c"`
+	Email      string               `json:"e,omitempty"`
+	LastName   string               `json:"l,omitempty"`
+}
+
+// encodeUserCursor returns the opaque form of a cursor
+func encodeUserCursor(opts domain.UserListOptions, cursor *domain.UserCursor) string {
+	token := userCursorToken{
+		SortBy:     opts.SortBy,
+		Descending: opts.Descending,
+		ID:         cursor.ID,
+		CreatedAt:  cursor.CreatedAt,
+	}
+	switch opts.SortBy {
+	case domain.UserSortByEmail:
+		token.Email = cursor.Email
+	case domain.UserSortByLastName:
+		token.LastName = cursor.LastName
+	}
+
+	data, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserCursor parses an opaque cursor issued for the same sort order
+func decodeUserCursor(opts domain.UserListOptions, cursor string) (*domain.UserCursor, error) {
+	invalid := domain.NewValidationError("cursor", "cursor is invalid")
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var token userCursorToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, invalid
+	}
+	// The ID is compared with the UUID column of the users
+	if _, err := uuid.Parse(token.ID); err != nil {
+		return nil, invalid
+	}
+	if token.SortBy != opts.SortBy || token.Descending != opts.Descending {
+		return nil, domain.NewValidationError("cursor", "cursor was issued for another sort order")
+	}
+
+	return &domain.UserCursor{
+		ID:        token.ID,
+		CreatedAt: token.CreatedAt,
+		Email:     token.Email,
+		LastName:  token.LastName,
+	}, nil
 }
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// contextKey is the type used for values stored in the request context
+type contextKey struct{}
+
+// claimsContextKey is the context key holding the validated token claims
+var claimsContextKey = contextKey{}
+
+// RoleClaim represents the roles section of a Keycloak token
+type RoleClaim struct {
+	Roles []string `json:"roles"`
+}
+
+// Claims represents the claims of a Keycloak access token
+type Claims struct {
+	jwt.RegisteredClaims
+	Email             string               `json:"email"`
+	EmailVerified     bool                 `json:"email_verified"`
+	PreferredUsername string               `json:"preferred_username"`
+	Name              string               `json:"name"`
+	GivenName         string               `json:"given_name"`
+	FamilyName        string               `json:"family_name"`
+	RealmAccess       RoleClaim            `json:"realm_access"`
+	ResourceAccess    map[string]RoleClaim `json:"resource_access"`
+	// TenantID is the ID of the caller's organization, added by a Keycloak user attribute mapper
+	TenantID string `json:"tenant_id"`
+}
+
+// RealmRoles returns the realm roles granted to the token subject
+func (c *Claims) RealmRoles() []string {
+	return c.RealmAccess.Roles
+}
+
+// ClientRoles returns the roles granted to the token subject for the given client
+func (c *Claims) ClientRoles(clientID string) []string {
+	return c.ResourceAccess[clientID].Roles
+}
+
+// HasRealmRole reports whether the token subject holds the given realm role
+func (c *Claims) HasRealmRole(role string) bool {
+	return containsRole(c.RealmAccess.Roles, role)
+}
+
+// HasClientRole reports whether the token subject holds the given client role
+func (c *Claims) HasClientRole(clientID, role string) bool {
+	return containsRole(c.ResourceAccess[clientID].Roles, role)
+}
+
+// WithClaims returns a copy of ctx carrying the given claims
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+// ClaimsFromContext returns the claims stored in ctx by the token validation middleware
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok && claims != nil
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
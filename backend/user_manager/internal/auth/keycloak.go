@@ -2,144 +2,237 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
-	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/config"
+	"github.com/b-fontaine/saaster_kit/backend/platform/jwks"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+// Authentication errors
+var (
+	ErrMissingToken = errors.New("token is required")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Config holds the settings needed to validate Keycloak tokens
+type Config struct {
+	// IssuerURL is the expected "iss" claim, e.g. http://localhost:8080/realms/saaster
+	IssuerURL string
+	// JWKSURL is where the realm keys are fetched from. Defaults to the issuer's certs endpoint.
+	JWKSURL string
+	// Audience is the expected "aud" claim. Audience validation is skipped when empty.
+	Audience string
+	// ClientID is the Keycloak client whose roles are read from resource_access
+	ClientID string
+	// JWKSCacheTTL is how long fetched keys are trusted before being refreshed
+	JWKSCacheTTL time.Duration
+	// Leeway is the clock skew tolerated when validating exp, nbf and iat
+	Leeway time.Duration
+	// HTTPClient is used to fetch the JWKS
+	HTTPClient *http.Client
+	// AccountStatus rejects the tokens of deactivated users, and marks the
+	// tokens of accounts not linked to a user yet. Optional.
+	AccountStatus AccountStatusChecker
+}
+
+// AccountStatus is the state of the account behind a token
+type AccountStatus int
+
+// Account states
+const (
+	// AccountActive accounts may use the API
+	AccountActive AccountStatus = iota
+	// AccountUnlinked accounts are bound to an organization without being
+	// linked to one of its users yet. They may only link their account.
+	AccountUnlinked
+	// AccountDeactivated accounts are linked to a deactivated user
+	AccountDeactivated
+)
+
+// AccountStatusChecker reports whether the account behind validated claims may
+// still use the API. Tokens outlive the deactivation of their subject, so the
+// local state of the user is checked on every request. Checks only read the
+// state of the user.
+type AccountStatusChecker interface {
+	AccountStatus(ctx context.Context, claims *Claims) (AccountStatus, error)
+}
+
+// AccountStatusCheckerFunc adapts a function to the AccountStatusChecker interface
+type AccountStatusCheckerFunc func(ctx context.Context, claims *Claims) (AccountStatus, error)
+
+// AccountStatus calls f(ctx, claims)
+func (f AccountStatusCheckerFunc) AccountStatus(ctx context.Context, claims *Claims) (AccountStatus, error) {
+	return f(ctx, claims)
+}
+
+// unlinkedAccountContextKey marks requests made with the token of an account
+// not linked to a user yet
+type unlinkedAccountContextKey struct{}
+
+// IsUnlinkedAccount reports whether the caller's account is not linked to a
+// user of their organization yet
+func IsUnlinkedAccount(ctx context.Context) bool {
+	unlinked, _ := ctx.Value(unlinkedAccountContextKey{}).(bool)
+	return unlinked
+}
+
 // KeycloakAuth handles Keycloak authentication
 type KeycloakAuth struct {
-	keycloakURL string
+	issuer   string
+	audience string
+	clientID string
+	keys     *jwks.Cache
+	parser   *jwt.Parser
+	status   AccountStatusChecker
 }
 
 // NewKeycloakAuth creates a new KeycloakAuth
-func NewKeycloakAuth(cfg config.DaprConfig) *KeycloakAuth {
+func NewKeycloakAuth(cfg Config) *KeycloakAuth {
+	issuer := strings.TrimSuffix(cfg.IssuerURL, "/")
+
+	jwksURL := cfg.JWKSURL
+	if jwksURL == "" {
+		jwksURL = issuer + "/protocol/openid-connect/certs"
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
 	return &KeycloakAuth{
-		keycloakURL: cfg.KeycloakURL,
+		issuer:   issuer,
+		audience: cfg.Audience,
+		clientID: cfg.ClientID,
+		keys:     jwks.NewCache(jwksURL, cfg.JWKSCacheTTL, cfg.HTTPClient),
+		parser:   jwt.NewParser(options...),
+		status:   cfg.AccountStatus,
+	}
+}
+
+// ClientID returns the Keycloak client whose roles are read from resource_access
+func (k *KeycloakAuth) ClientID() string {
+	return k.clientID
+}
+
+// ValidateToken verifies the token signature against the realm JWKS and
+// validates its issuer, audience, expiry and not-before claims
+func (k *KeycloakAuth) ValidateToken(ctx context.Context, token string) (*Claims, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+
+	claims := &Claims{}
+	_, err := k.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("token header has no kid")
+		}
+		return k.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	return claims, nil
 }
 
-// TokenValidationMiddleware validates JWT tokens from Keycloak
+// TokenValidationMiddleware validates JWT tokens from Keycloak and stores
+// the validated claims in the request context
 func (k *KeycloakAuth) TokenValidationMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract token from Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+			unauthorized(w, r, "Authorization header is required")
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Authorization header format must be Bearer {token}", http.StatusUnauthorized)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			unauthorized(w, r, "Authorization header format must be Bearer {token}")
 			return
 		}
 
-		token := parts[1]
-
-		// Validate token using Dapr middleware
-		// In a real implementation, we would use Dapr's middleware component
-		// Here we're just checking if the token is present
-		if token == "" {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		claims, err := k.ValidateToken(r.Context(), parts[1])
+		if err != nil {
+			unauthorized(w, r, "Invalid token")
 			return
 		}
 
-		// Call the next handler
-		next.ServeHTTP(w, r)
-	})
-}
-
-// UserInfo represents user information from Keycloak
-type UserInfo struct {
-	Sub               string   `json:"sub"`
-	Email             string   `json:"email"`
-	PreferredUsername string   `json:"preferred_username"`
-	Name              string   `json:"name"`
-	GivenName         string   `json:"given_name"`
-	FamilyName        string   `json:"family_name"`
-	RealmAccess       struct{} `json:"realm_access"`
-	ResourceAccess    struct{} `json:"resource_access"`
-}
-
-// GetUserInfo gets user information from Keycloak
-func (k *KeycloakAuth) GetUserInfo(ctx context.Context, token string) (*UserInfo, error) {
-	// In a real implementation, we would use Dapr to call Keycloak
-	// Here we're just returning a mock user info
-	if token == "" {
-		return nil, errors.New("token is required")
-	}
-
-	// Mock user info
-	userInfo := &UserInfo{
-		Sub:               "user-id",
-		Email:             "user@example.com",
-		PreferredUsername: "username",
-		Name:              "User Name",
-		GivenName:         "User",
-		FamilyName:        "Name",
-	}
-
-	return userInfo, nil
-}
+		ctx := WithClaims(r.Context(), claims)
 
-// DaprAuthMiddleware is a middleware that uses Dapr for authentication
-func DaprAuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// In a real implementation, we would use Dapr's middleware component
-		// Here we're just checking if the Dapr-API-Token header is present
-		daprToken := r.Header.Get("Dapr-API-Token")
-		if daprToken == "" {
-			// If no Dapr token, check for Authorization header
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Authentication required", http.StatusUnauthorized)
+		// Reject users deactivated since the token was issued. Unlinked
+		// accounts are left to the Authorizer, which only lets them link
+		// their account.
+		if k.status != nil {
+			status, err := k.status.AccountStatus(r.Context(), claims)
+			if err != nil {
+				problem.Error(w, r, http.StatusServiceUnavailable, "Unable to verify account status")
 				return
 			}
+			switch status {
+			case AccountDeactivated:
+				Forbidden(w, r, "account is deactivated", "")
+				return
+			case AccountUnlinked:
+				ctx = context.WithValue(ctx, unlinkedAccountContextKey{}, true)
+			}
 		}
 
-		// Call the next handler
-		next.ServeHTTP(w, r)
+		// Call the next handler with the claims in the request context
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// DaprClient is a client for Dapr
-type DaprClient struct {
-	appID    string
-	httpPort string
+// unauthorized writes a 401 response with a Bearer challenge
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	problem.Error(w, r, http.StatusUnauthorized, message)
 }
 
-// NewDaprClient creates a new DaprClient
-func NewDaprClient(cfg config.DaprConfig) *DaprClient {
-	return &DaprClient{
-		appID:    cfg.AppID,
-		httpPort: cfg.HttpPort,
-	}
-}
-
-// CallKeycloakService calls Keycloak service through Dapr
-func (c *DaprClient) CallKeycloakService(ctx context.Context, path string, method string, body interface{}) ([]byte, error) {
-	// In a real implementation, we would use Dapr's HTTP client
-	// Here we're just returning a mock response
-	mockResponse := map[string]interface{}{
-		"status": "success",
-		"data":   "mock data",
-	}
-
-	return json.Marshal(mockResponse)
+// UserInfo represents user information from Keycloak
+type UserInfo struct {
+	Sub               string               `json:"sub"`
+	Email             string               `json:"email"`
+	PreferredUsername string               `json:"preferred_username"`
+	Name              string               `json:"name"`
+	GivenName         string               `json:"given_name"`
+	FamilyName        string               `json:"family_name"`
+	RealmAccess       RoleClaim            `json:"realm_access"`
+	ResourceAccess    map[string]RoleClaim `json:"resource_access"`
 }
 
-// ValidateToken validates a token with Keycloak through Dapr
-func (c *DaprClient) ValidateToken(ctx context.Context, token string) (bool, error) {
-	// In a real implementation, we would use Dapr to call Keycloak
-	// Here we're just returning a mock response
-	if token == "" {
-		return false, fmt.Errorf("token is required")
+// GetUserInfo gets user information from a validated Keycloak token
+func (k *KeycloakAuth) GetUserInfo(ctx context.Context, token string) (*UserInfo, error) {
+	claims, err := k.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
 	}
 
-	return true, nil
+	return &UserInfo{
+		Sub:               claims.Subject,
+		Email:             claims.Email,
+		PreferredUsername: claims.PreferredUsername,
+		Name:              claims.Name,
+		GivenName:         claims.GivenName,
+		FamilyName:        claims.FamilyName,
+		RealmAccess:       claims.RealmAccess,
+		ResourceAccess:    claims.ResourceAccess,
+	}, nil
 }
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	"github.com/gorilla/mux"
+)
+
+// Permission represents an action a caller may perform on the user API
+type Permission string
+
+// Permissions granted by the policy
+const (
+	PermissionUsersRead   Permission = "users:read"
+	PermissionUsersWrite  Permission = "users:write"
+	PermissionUsersDelete Permission = "users:delete"
+
+	PermissionOrganizationsWrite Permission = "organizations:write"
+)
+
+// DefaultClientID is the Keycloak client whose roles are read by the default policy
+const DefaultClientID = "user-manager"
+
+// Policy maps Keycloak realm and client roles to permissions.
+// This is the single place where role-based access to the user API is declared.
+type Policy struct {
+	clientID        string
+	adminRoles      []string
+	rolePermissions map[string][]Permission
+}
+
+// DefaultPolicy returns the policy used by the service
+func DefaultPolicy(clientID string) *Policy {
+	return &Policy{
+		clientID:   clientID,
+		adminRoles: []string{"admin"},
+		rolePermissions: map[string][]Permission{
+			// Realm roles. Admins manage the users of their own organization;
+			// only platform admins may create organizations.
+			"admin":          {PermissionUsersRead, PermissionUsersWrite, PermissionUsersDelete},
+			"user-reader":    {PermissionUsersRead},
+			"user-editor":    {PermissionUsersRead, PermissionUsersWrite},
+			"platform-admin": {PermissionOrganizationsWrite},
+			// Client roles named after the permission they grant
+			string(PermissionUsersRead):          {PermissionUsersRead},
+			string(PermissionUsersWrite):         {PermissionUsersWrite},
+			string(PermissionUsersDelete):        {PermissionUsersDelete},
+			string(PermissionOrganizationsWrite): {PermissionOrganizationsWrite},
+		},
+	}
+}
+
+// roles returns the realm roles and the policy client's roles held by the caller
+func (p *Policy) roles(claims *Claims) []string {
+	roles := append([]string{}, claims.RealmRoles()...)
+	return append(roles, claims.ClientRoles(p.clientID)...)
+}
+
+// IsAdmin reports whether the caller holds one of the admin roles
+func (p *Policy) IsAdmin(claims *Claims) bool {
+	for _, role := range p.roles(claims) {
+		if containsRole(p.adminRoles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the caller's roles grant the given permission
+func (p *Policy) HasPermission(claims *Claims, permission Permission) bool {
+	for _, role := range p.roles(claims) {
+		for _, granted := range p.rolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Rule describes the access requirements of a route
+type Rule struct {
+	// Permission is required unless the caller is acting on themselves.
+	// An empty permission lets any authenticated caller through.
+	Permission Permission
+	// SelfParam is the route variable holding the target user ID. When set,
+	// callers the SelfChecker identifies as that user are allowed without the
+	// permission.
+	SelfParam string
+	// AllowUnlinked lets through the callers whose account is not linked to
+	// a user of their organization yet, who are rejected otherwise
+	AllowUnlinked bool
+}
+
+// SelfChecker reports whether the user userID is the caller. User IDs are
+// assigned by the service, so the user is matched to the caller through the
+// identity provider account it is linked to rather than compared with the
+// token subject.
+type SelfChecker interface {
+	IsSelf(ctx context.Context, claims *Claims, userID string) (bool, error)
+}
+
+// SelfCheckerFunc adapts a function to the SelfChecker interface
+type SelfCheckerFunc func(ctx context.Context, claims *Claims, userID string) (bool, error)
+
+// IsSelf calls f(ctx, claims, userID)
+func (f SelfCheckerFunc) IsSelf(ctx context.Context, claims *Claims, userID string) (bool, error) {
+	return f(ctx, claims, userID)
+}
+
+// selfAccessContextKey marks requests that were authorized through the self rule
+type selfAccessContextKey struct{}
+
+// IsSelfAccess reports whether the request was authorized only because the
+// caller is acting on their own user record
+func IsSelfAccess(ctx context.Context) bool {
+	self, _ := ctx.Value(selfAccessContextKey{}).(bool)
+	return self
+}
+
+// Authorizer enforces a Policy on HTTP routes
+type Authorizer struct {
+	policy *Policy
+	self   SelfChecker
+}
+
+// NewAuthorizer creates a new Authorizer. self identifies the callers acting
+// on their own user; when nil, the self rules grant nothing.
+func NewAuthorizer(policy *Policy, self SelfChecker) *Authorizer {
+	return &Authorizer{
+		policy: policy,
+		self:   self,
+	}
+}
+
+// Policy returns the policy enforced by the Authorizer
+func (a *Authorizer) Policy() *Policy {
+	return a.policy
+}
+
+// IsSelf reports whether the user userID is the caller
+func (a *Authorizer) IsSelf(ctx context.Context, claims *Claims, userID string) (bool, error) {
+	if a.self == nil || userID == "" {
+		return false, nil
+	}
+	return a.self.IsSelf(ctx, claims, userID)
+}
+
+// Require wraps a handler so that it only runs when the caller satisfies the rule
+func (a *Authorizer) Require(rule Rule, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			unauthorized(w, r, "Authentication required")
+			return
+		}
+
+		if IsUnlinkedAccount(r.Context()) && !rule.AllowUnlinked {
+			Forbidden(w, r, "account is not linked to a user of the organization", "")
+			return
+		}
+
+		if rule.Permission == "" || a.policy.HasPermission(claims, rule.Permission) {
+			next(w, r)
+			return
+		}
+
+		if rule.SelfParam != "" {
+			self, err := a.IsSelf(r.Context(), claims, mux.Vars(r)[rule.SelfParam])
+			if err != nil {
+				problem.Error(w, r, http.StatusServiceUnavailable, "Unable to verify the caller's user")
+				return
+			}
+			if self {
+				next(w, r.WithContext(context.WithValue(r.Context(), selfAccessContextKey{}, true)))
+				return
+			}
+		}
+
+		Forbidden(w, r, "missing permission "+string(rule.Permission), rule.Permission)
+	}
+}
+
+// ForbiddenResponse is the problem returned when access is denied
+type ForbiddenResponse struct {
+	problem.Problem
+	// RequiredPermission is the permission the caller is missing, if any
+	RequiredPermission Permission `json:"required_permission,omitempty"`
+}
+
+// Forbidden writes a 403 problem naming the missing permission
+func Forbidden(w http.ResponseWriter, r *http.Request, message string, permission Permission) {
+	response := ForbiddenResponse{
+		Problem:            *problem.New(http.StatusForbidden, message),
+		RequiredPermission: permission,
+	}
+	response.Instance = r.URL.Path
+	response.TraceID = problem.TraceID(r)
+
+	w.Header().Set("Content-Type", problem.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(response)
+}
@@ -3,6 +3,7 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Domain errors
@@ -10,6 +11,16 @@ var (
 	ErrUserNotFound      = errors.New("user not found")
 	ErrUserAlreadyExists = errors.New("user already exists")
 	ErrInvalidUserData   = errors.New("invalid user data")
+	// ErrVersionMismatch is returned when a user changed since the version
+	// the caller read
+	ErrVersionMismatch = errors.New("version mismatch")
+
+	ErrOrganizationNotFound      = errors.New("organization not found")
+	ErrOrganizationAlreadyExists = errors.New("organization already exists")
+	ErrOrganizationSuspended     = errors.New("organization is suspended")
+
+	// ErrTenantRequired is returned when an operation is attempted without a tenant
+	ErrTenantRequired = errors.New("tenant is required")
 )
 
 // ValidationError represents a validation error
@@ -30,3 +41,24 @@ func NewValidationError(field, message string) ValidationError {
 		Message: message,
 	}
 }
+
+// ValidationErrors lists every invalid field found by a validation
+type ValidationErrors []ValidationError
+
+// Error returns the messages of every invalid field
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Field+": "+err.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Unwrap returns the invalid fields, so that errors.As finds a ValidationError
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
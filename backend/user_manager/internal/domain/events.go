@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType is the type of a domain event
+type EventType string
+
+// User events, published to other services whenever a user changes
+const (
+	UserCreatedEvent EventType = "UserCreated"
+	UserUpdatedEvent EventType = "UserUpdated"
+	UserDeletedEvent EventType = "UserDeleted"
+	UserLinkedEvent  EventType = "UserLinked"
+)
+
+// Event is a domain event. Events are written to the outbox in the same
+// transaction as the change they describe, then published asynchronously, so
+// they are delivered at least once: consumers must deduplicate them by ID.
+type Event struct {
+	ID          string
+	Type        EventType
+	TenantID    string
+	AggregateID string
+	OccurredAt  time.Time
+	// Data is the JSON payload of the event
+	Data json.RawMessage
+}
+
+// UserEventData is the payload of user events. Deletions only carry the IDs
+// of the user, so that no personal data outlives it. ExternalID, the subject
+// of the user's access tokens, is how other services identify the user; it is
+// empty until the user is linked to their account.
+type UserEventData struct {
+	ID         string     `json:"id"`
+	TenantID   string     `json:"tenantId"`
+	ExternalID string     `json:"externalId,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	FirstName  string     `json:"firstName,omitempty"`
+	LastName   string     `json:"lastName,omitempty"`
+	Role       string     `json:"role,omitempty"`
+	Active     *bool      `json:"active,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt  *time.Time `json:"updatedAt,omitempty"`
+}
+
+// NewUserCreatedEvent returns the event announcing the creation of the user
+func NewUserCreatedEvent(user *User) *Event {
+	return newUserEvent(UserCreatedEvent, user, userSnapshot(user))
+}
+
+// NewUserUpdatedEvent returns the event announcing the new state of the user
+func NewUserUpdatedEvent(user *User) *Event {
+	return newUserEvent(UserUpdatedEvent, user, userSnapshot(user))
+}
+
+// NewUserDeletedEvent returns the event announcing the deletion of the user
+func NewUserDeletedEvent(user *User) *Event {
+	return newUserEvent(UserDeletedEvent, user, UserEventData{ID: user.ID, TenantID: user.TenantID, ExternalID: user.ExternalID})
+}
+
+// NewUserLinkedEvent returns the event announcing that a user created without
+// an account was linked to one
+func NewUserLinkedEvent(user *User) *Event {
+	return newUserEvent(UserLinkedEvent, user, userSnapshot(user))
+}
+
+// userSnapshot returns the payload describing the whole user
+func userSnapshot(user *User) UserEventData {
+	active := user.Active
+	createdAt := user.CreatedAt.UTC()
+	updatedAt := user.UpdatedAt.UTC()
+	return UserEventData{
+		ID:         user.ID,
+		TenantID:   user.TenantID,
+		ExternalID: user.ExternalID,
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Role:       user.Role,
+		Active:     &active,
+		CreatedAt:  &createdAt,
+		UpdatedAt:  &updatedAt,
+	}
+}
+
+func newUserEvent(eventType EventType, user *User, data UserEventData) *Event {
+	// UserEventData always encodes
+	payload, _ := json.Marshal(data)
+	return &Event{
+		ID:          uuid.New().String(),
+		Type:        eventType,
+		TenantID:    user.TenantID,
+		AggregateID: user.ID,
+		OccurredAt:  time.Now().UTC(),
+		Data:        payload,
+	}
+}
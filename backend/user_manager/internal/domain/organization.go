@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"time"
+)
+
+// Organization plans
+const (
+	PlanFree       = "free"
+	PlanPro        = "pro"
+	PlanEnterprise = "enterprise"
+)
+
+// Lengths of the organization fields, as limited by the organizations table
+const (
+	MaxOrganizationNameLength = 255
+	MaxSlugLength             = 100
+)
+
+// Organization statuses
+const (
+	OrganizationStatusActive    = "active"
+	OrganizationStatusSuspended = "suspended"
+)
+
+// Organization represents a customer company. It is the tenant every user belongs to.
+type Organization struct {
+	ID        string
+	Name      string
+	Slug      string
+	Plan      string
+	Status    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NewOrganization creates a new active organization
+func NewOrganization(name, slug, plan string) *Organization {
+	now := time.Now()
+	return &Organization{
+		Name:      name,
+		Slug:      slug,
+		Plan:      plan,
+		Status:    OrganizationStatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// IsActive reports whether the organization can be used
+func (o *Organization) IsActive() bool {
+	return o.Status == OrganizationStatusActive
+}
+
+// Suspend suspends the organization
+func (o *Organization) Suspend() {
+	o.Status = OrganizationStatusSuspended
+	o.UpdatedAt = time.Now()
+}
+
+// Reinstate reactivates a suspended organization
+func (o *Organization) Reinstate() {
+	o.Status = OrganizationStatusActive
+	o.UpdatedAt = time.Now()
+}
+
+// Update updates the organization's information
+func (o *Organization) Update(name, plan string) {
+	o.Name = name
+	o.Plan = plan
+	o.UpdatedAt = time.Now()
+}
+
+// IsValidPlan reports whether plan is one of the known plans
+func IsValidPlan(plan string) bool {
+	switch plan {
+	case PlanFree, PlanPro, PlanEnterprise:
+		return true
+	}
+	return false
+}
@@ -4,9 +4,25 @@ import (
 	"time"
 )
 
-// User represents a user entity in the domain
+// User roles within their organization
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Lengths of the user fields, as limited by the users table
+const (
+	MaxEmailLength      = 255
+	MaxNameLength       = 255
+	MaxExternalIDLength = 255
+)
+
+// User represents a user entity in the domain.
+// A user is a member of exactly one organization, and Role is the role of
+// their membership in it.
 type User struct {
 	ID        string
+	TenantID  string
 	Email     string
 	FirstName string
 	LastName  string
@@ -14,6 +30,13 @@ type User struct {
 	Active    bool
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// Version is incremented by every change of the user, starting at 1. The
+	// repositories only apply changes made to the current version.
+	Version int64
+
+	// ExternalID is the ID of the user's identity provider account, the
+	// subject of their access tokens. It is empty until the account is linked.
+	ExternalID string
 }
 
 // NewUser creates a new user with default values
@@ -47,6 +70,12 @@ func (u *User) Deactivate() {
 	u.UpdatedAt = time.Now()
 }
 
+// LinkAccount links the user to their identity provider account
+func (u *User) LinkAccount(externalID string) {
+	u.ExternalID = externalID
+	u.UpdatedAt = time.Now()
+}
+
 // Update updates the user's information
 func (u *User) Update(email, firstName, lastName, role string) {
 	u.Email = email
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// UserSortField is a field users can be listed by
+type UserSortField string
+
+// Fields users can be listed by. Users with the same value are ordered by ID,
+// so that listings are stable.
+const (
+	UserSortByCreatedAt UserSortField = "created_at"
+	UserSortByEmail     UserSortField = "email"
+	UserSortByLastName  UserSortField = "last_name"
+)
+
+// IsValidUserSortField reports whether users can be listed by the given field
+func IsValidUserSortField(field UserSortField) bool {
+	switch field {
+	case UserSortByCreatedAt, UserSortByEmail, UserSortByLastName:
+		return true
+	}
+	return false
+}
+
+// UserFilter restricts the users of a listing. Zero values match every user.
+type UserFilter struct {
+	Role   string
+	Active *bool
+	// Search is a case-insensitive prefix of the email, first name or last name
+	Search string
+	// CreatedAfter is inclusive, CreatedBefore is exclusive
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// Matches reports whether the user satisfies the filter
+func (f UserFilter) Matches(user *User) bool {
+	if f.Role != "" && user.Role != f.Role {
+		return false
+	}
+	if f.Active != nil && user.Active != *f.Active {
+		return false
+	}
+	if f.Search != "" {
+		search := strings.ToLower(f.Search)
+		if !strings.HasPrefix(strings.ToLower(user.Email), search) &&
+			!strings.HasPrefix(strings.ToLower(user.FirstName), search) &&
+			!strings.HasPrefix(strings.ToLower(user.LastName), search) {
+			return false
+		}
+	}
+	if !f.CreatedAfter.IsZero() && user.CreatedAt.Before(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !user.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// UserCursor holds the sort keys of the last user of a page. The next page
+// starts right after it.
+type UserCursor struct {
+	ID        string
+	CreatedAt time.Time
+	Email     string
+	LastName  string
+}
+
+// NewUserCursor returns the cursor pointing right after the given user
+func NewUserCursor(user *User) *UserCursor {
+	return &UserCursor{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		Email:     user.Email,
+		LastName:  user.LastName,
+	}
+}
+
+// UserListOptions describes a page of users to list
+type UserListOptions struct {
+	Filter     UserFilter
+	SortBy     UserSortField
+	Descending bool
+	// Limit is the maximum number of users of the page
+	Limit int
+	// After is the cursor of the previous page, nil for the first page
+	After *UserCursor
+}
+
+// UserPage is a page of users
+type UserPage struct {
+	Users []*User
+	// Next is the cursor of the next page, nil on the last page
+	Next *UserCursor
+}
@@ -0,0 +1,19 @@
+package validation
+
+import "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+
+// User checks the fields of a user, named after the JSON fields of the API
+func (v *Validator) User(email, firstName, lastName, role string) {
+	v.Required("email", email)
+	v.Email("email", email)
+	v.MaxLength("email", email, domain.MaxEmailLength)
+
+	v.Required("first_name", firstName)
+	v.MaxLength("first_name", firstName, domain.MaxNameLength)
+
+	v.Required("last_name", lastName)
+	v.MaxLength("last_name", lastName, domain.MaxNameLength)
+
+	v.Required("role", role)
+	v.OneOf("role", role, domain.RoleAdmin, domain.RoleUser)
+}
@@ -0,0 +1,33 @@
+// Package validation checks the fields of domain values, reporting every
+// invalid field at once rather than the first one. The checks themselves are
+// the ones of the platform validation package.
+package validation
+
+import (
+	platformvalidation "github.com/b-fontaine/saaster_kit/backend/platform/validation"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+)
+
+// Validator collects the invalid fields of the domain values
+type Validator struct {
+	*platformvalidation.Validator
+}
+
+// New creates a validator without errors
+func New() *Validator {
+	return &Validator{Validator: platformvalidation.New()}
+}
+
+// Err returns the invalid fields as domain.ValidationErrors, or nil when the
+// value is valid
+func (v *Validator) Err() error {
+	fields := v.Errors()
+	if len(fields) == 0 {
+		return nil
+	}
+	errs := make(domain.ValidationErrors, 0, len(fields))
+	for _, field := range fields {
+		errs = append(errs, domain.NewValidationError(field.Field, field.Message))
+	}
+	return errs
+}
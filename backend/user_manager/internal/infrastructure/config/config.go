@@ -1,107 +1,266 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"os"
-	"strconv"
 	"time"
+
+	platformconfig "github.com/b-fontaine/saaster_kit/backend/platform/config"
 )
 
-// Config holds all configuration for the service
+// Config holds all configuration for the service. It is layered by Load
+// from Defaults, the YAML file named by CONFIG_FILE, the environment
+// variables of the env tags and the Dapr secret store.
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Temporal TemporalConfig
-	Dapr     DaprConfig
+	Server   ServerConfig                     `yaml:"server"`
+	GRPC     GRPCConfig                       `yaml:"grpc"`
+	Database DatabaseConfig                   `yaml:"database"`
+	Temporal TemporalConfig                   `yaml:"temporal"`
+	Dapr     DaprConfig                       `yaml:"dapr"`
+	Keycloak KeycloakConfig                   `yaml:"keycloak"`
+	Outbox   OutboxConfig                     `yaml:"outbox"`
+	Tracing  TracingConfig                    `yaml:"tracing"`
+	Log      LogConfig                        `yaml:"log"`
+	Health   HealthConfig                     `yaml:"health"`
+	Shutdown ShutdownConfig                   `yaml:"shutdown"`
+	Secrets  platformconfig.SecretStoreConfig `yaml:"secrets"`
+
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
 }
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
-	Port         string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	Port         string        `yaml:"port" env:"SERVER_PORT" required:"true"`
+	ReadTimeout  time.Duration `yaml:"readTimeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"writeTimeout" env:"SERVER_WRITE_TIMEOUT"`
+	// RequireIfMatch rejects updates and deletions sent without If-Match. It
+	// is off by default, so that existing clients keep working until the
+	// deployment opts in.
+	RequireIfMatch bool `yaml:"requireIfMatch" env:"SERVER_REQUIRE_IF_MATCH"`
+}
+
+// GRPCConfig holds gRPC server configuration
+type GRPCConfig struct {
+	Port string `yaml:"port" env:"GRPC_PORT" required:"true"`
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host     string `yaml:"host" env:"DB_HOST" required:"true"`
+	Port     string `yaml:"port" env:"DB_PORT" required:"true"`
+	User     string `yaml:"user" env:"DB_USER" required:"true"`
+	Password string `yaml:"password" env:"DB_PASSWORD" required:"true" secret:"db-password"`
+	DBName   string `yaml:"name" env:"DB_NAME" required:"true"`
+	SSLMode  string `yaml:"sslMode" env:"DB_SSLMODE"`
 }
 
 // TemporalConfig holds Temporal configuration
 type TemporalConfig struct {
-	Address    string
-	Namespace  string
-	TaskQueue  string
-	WorkerName string
+	Address    string `yaml:"address" env:"TEMPORAL_ADDRESS" required:"true"`
+	Namespace  string `yaml:"namespace" env:"TEMPORAL_NAMESPACE" required:"true"`
+	TaskQueue  string `yaml:"taskQueue" env:"TEMPORAL_TASK_QUEUE" required:"true"`
+	WorkerName string `yaml:"workerName" env:"TEMPORAL_WORKER_NAME"`
 }
 
 // DaprConfig holds Dapr configuration
 type DaprConfig struct {
-	AppID       string
-	AppPort     string
-	GrpcPort    string
-	HttpPort    string
-	KeycloakURL string
+	// Pub/sub publishing of domain events through the sidecar
+	HTTPEndpoint string `yaml:"httpEndpoint" env:"DAPR_HTTP_ENDPOINT" required:"true"`
+	PubSubName   string `yaml:"pubSubName" env:"DAPR_PUBSUB_NAME" required:"true"`
+	APIToken     string `yaml:"apiToken" env:"DAPR_API_TOKEN" secret:"-"`
+	// AppAPIToken is expected from the sidecar on the /dapr routes, when set
+	AppAPIToken string `yaml:"appApiToken" env:"APP_API_TOKEN" secret:"app-api-token"`
 }
 
-// Load loads the configuration from environment variables
-func Load() (*Config, error) {
+// OutboxConfig holds the configuration of the outbox relay
+type OutboxConfig struct {
+	PollInterval time.Duration `yaml:"pollInterval" env:"OUTBOX_POLL_INTERVAL"`
+	BatchSize    int           `yaml:"batchSize" env:"OUTBOX_BATCH_SIZE"`
+	MinBackoff   time.Duration `yaml:"minBackoff" env:"OUTBOX_MIN_BACKOFF"`
+	MaxBackoff   time.Duration `yaml:"maxBackoff" env:"OUTBOX_MAX_BACKOFF"`
+	// Retention is how long published events are kept before they are purged
+	Retention time.Duration `yaml:"retention" env:"OUTBOX_RETENTION"`
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	// Endpoint is the base URL of the OTLP/HTTP collector the spans are
+	// exported to, e.g. http://jaeger:4318. Spans are not exported when it is
+	// empty, but the trace context is still propagated.
+	Endpoint    string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string `yaml:"serviceName" env:"OTEL_SERVICE_NAME" required:"true"`
+	// SampleRatio is the fraction of the traces started by the service that
+	// are recorded. Traces started by callers follow their sampling decision.
+	SampleRatio float64 `yaml:"sampleRatio" env:"OTEL_TRACES_SAMPLE_RATIO"`
+}
+
+// LogConfig holds logging configuration
+type LogConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string `yaml:"level" env:"LOG_LEVEL" oneof:"debug info warn error"`
+	// Format is json, for Elasticsearch, or text, for reading in a terminal
+	Format string `yaml:"format" env:"LOG_FORMAT" oneof:"json text"`
+}
+
+// HealthConfig holds the configuration of the readiness probe
+type HealthConfig struct {
+	// CheckTimeout bounds each dependency check
+	CheckTimeout time.Duration `yaml:"checkTimeout" env:"HEALTH_CHECK_TIMEOUT"`
+	// CacheTTL is how long the result of the checks is served
+	CacheTTL time.Duration `yaml:"cacheTTL" env:"HEALTH_CACHE_TTL"`
+}
+
+// ShutdownConfig holds the configuration of the graceful shutdown
+type ShutdownConfig struct {
+	// Timeout bounds the time given to the servers, the worker and the
+	// relay to drain their work on SIGTERM
+	Timeout time.Duration `yaml:"timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+// IdempotencyConfig holds the configuration of Idempotency-Key support
+type IdempotencyConfig struct {
+	// Store is where responses are stored: postgres or dapr
+	Store string `yaml:"store" env:"IDEMPOTENCY_STORE" oneof:"postgres dapr"`
+	// TTL is how long a response is replayed to the retries of its request
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL"`
+	// Lease is how long a request holds its key while it runs, so that the
+	// retries of a request that crashed or timed out are served once it ran
+	// out. It must exceed the write timeout of the server.
+	Lease time.Duration `yaml:"lease" env:"IDEMPOTENCY_LEASE"`
+	// StateStoreName is the Dapr state store component used by the dapr store
+	StateStoreName string `yaml:"stateStoreName" env:"DAPR_STATE_STORE_NAME"`
+}
+
+// KeycloakConfig holds Keycloak token validation configuration
+type KeycloakConfig struct {
+	IssuerURL    string        `yaml:"issuerUrl" env:"KEYCLOAK_ISSUER_URL" required:"true"`
+	JWKSURL      string        `yaml:"jwksUrl" env:"KEYCLOAK_JWKS_URL" required:"true"`
+	Audience     string        `yaml:"audience" env:"KEYCLOAK_AUDIENCE"`
+	ClientID     string        `yaml:"clientId" env:"KEYCLOAK_CLIENT_ID"`
+	JWKSCacheTTL time.Duration `yaml:"jwksCacheTTL" env:"KEYCLOAK_JWKS_CACHE_TTL"`
+	Leeway       time.Duration `yaml:"leeway" env:"KEYCLOAK_LEEWAY"`
+
+	// Admin API access, used to enable and disable user accounts
+	AdminURL     string `yaml:"adminUrl" env:"KEYCLOAK_ADMIN_URL"`
+	Realm        string `yaml:"realm" env:"KEYCLOAK_REALM"`
+	ClientSecret string `yaml:"clientSecret" env:"KEYCLOAK_CLIENT_SECRET" secret:"keycloak-client-secret"`
+}
+
+// Defaults returns the configuration used when nothing overrides it. The
+// database password has no default.
+func Defaults() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port:         getEnv("SERVER_PORT", "8080"),
-			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 5*time.Second),
-			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 10*time.Second),
+			Port:         "8080",
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		},
+		GRPC: GRPCConfig{
+			Port: "50051",
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
-			DBName:   getEnv("DB_NAME", "user_manager"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Host:    "localhost",
+			Port:    "5432",
+			User:    "postgres",
+			DBName:  "user_manager",
+			SSLMode: "disable",
 		},
 		Temporal: TemporalConfig{
-			Address:    getEnv("TEMPORAL_ADDRESS", "temporal:7233"),
-			Namespace:  getEnv("TEMPORAL_NAMESPACE", "default"),
-			TaskQueue:  getEnv("TEMPORAL_TASK_QUEUE", "user-manager-task-queue"),
-			WorkerName: getEnv("TEMPORAL_WORKER_NAME", "user-manager-worker"),
+			Address:    "temporal:7233",
+			Namespace:  "default",
+			TaskQueue:  "user-manager-task-queue",
+			WorkerName: "user-manager-worker",
 		},
 		Dapr: DaprConfig{
-			AppID:       getEnv("DAPR_APP_ID", "user-manager"),
-			AppPort:     getEnv("DAPR_APP_PORT", "8080"),
-			GrpcPort:    getEnv("DAPR_GRPC_PORT", "50001"),
-			HttpPort:    getEnv("DAPR_HTTP_PORT", "3500"),
-			KeycloakURL: getEnv("KEYCLOAK_URL", "http://keycloak:8080"),
+			HTTPEndpoint: "http://localhost:3500",
+			PubSubName:   "pubsub",
+		},
+		Keycloak: KeycloakConfig{
+			IssuerURL:    "http://localhost:8080/realms/saaster",
+			JWKSURL:      "http://keycloak:8080/realms/saaster/protocol/openid-connect/certs",
+			Audience:     "user-manager",
+			ClientID:     "user-manager",
+			JWKSCacheTTL: 10 * time.Minute,
+			Leeway:       30 * time.Second,
+			AdminURL:     "http://keycloak:8080",
+			Realm:        "saaster",
+		},
+		Outbox: OutboxConfig{
+			PollInterval: time.Second,
+			BatchSize:    100,
+			MinBackoff:   time.Second,
+			MaxBackoff:   5 * time.Minute,
+			Retention:    7 * 24 * time.Hour,
+		},
+		Tracing: TracingConfig{
+			ServiceName: "user-manager",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
 		},
-	}, nil
+		Health: HealthConfig{
+			CheckTimeout: 2 * time.Second,
+			CacheTTL:     5 * time.Second,
+		},
+		Shutdown: ShutdownConfig{
+			Timeout: 20 * time.Second,
+		},
+		Secrets: platformconfig.SecretStoreConfig{
+			WaitTimeout: 30 * time.Second,
+		},
+		Idempotency: IdempotencyConfig{
+			Store:          "postgres",
+			TTL:            24 * time.Hour,
+			Lease:          30 * time.Second,
+			StateStoreName: "postgres-state",
+		},
+	}
 }
 
-// Helper functions to get environment variables with defaults
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	return defaultValue
+// Load loads the configuration over Defaults and validates it. The
+// configuration is returned along with the report of its problems, if any,
+// so that it can still be printed.
+func Load() (*Config, error) {
+	cfg := Defaults()
+	err := platformconfig.Load(context.Background(), cfg, platformconfig.Options{File: os.Getenv("CONFIG_FILE")})
+	return cfg, err
 }
 
-func getIntEnv(key string, defaultValue int) int {
-	if value, exists := os.LookupEnv(key); exists {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
-	}
-	return defaultValue
+// SecretStore returns the Dapr secret store named by SECRET_STORE_NAME, if
+// any, behind the sidecar of the service
+func (c *Config) SecretStore() platformconfig.SecretStore {
+	return c.Secrets.Store(c.Dapr.HTTPEndpoint, c.Dapr.APIToken)
 }
 
-func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
-	if value, exists := os.LookupEnv(key); exists {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
-		}
+// Validate checks the rules the field tags cannot express
+func (c *Config) Validate() error {
+	var errs platformconfig.Errors
+	if c.Outbox.BatchSize <= 0 {
+		errs = append(errs, errors.New("outbox.batchSize (OUTBOX_BATCH_SIZE): must be positive"))
+	}
+	if c.Outbox.Retention <= 0 {
+		errs = append(errs, errors.New("outbox.retention (OUTBOX_RETENTION): must be positive"))
+	}
+	if c.Outbox.MinBackoff > c.Outbox.MaxBackoff {
+		errs = append(errs, errors.New("outbox.minBackoff (OUTBOX_MIN_BACKOFF): must not exceed outbox.maxBackoff (OUTBOX_MAX_BACKOFF)"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sampleRatio (OTEL_TRACES_SAMPLE_RATIO): must be between 0 and 1"))
+	}
+	if c.Idempotency.Store == "dapr" && c.Idempotency.StateStoreName == "" {
+		errs = append(errs, errors.New("idempotency.stateStoreName (DAPR_STATE_STORE_NAME): is required by the dapr store"))
+	}
+	if c.Idempotency.Lease <= c.Server.WriteTimeout {
+		errs = append(errs, errors.New("idempotency.lease (IDEMPOTENCY_LEASE): must exceed server.writeTimeout (SERVER_WRITE_TIMEOUT)"))
+	}
+	if c.Idempotency.Lease > c.Idempotency.TTL {
+		errs = append(errs, errors.New("idempotency.lease (IDEMPOTENCY_LEASE): must not exceed idempotency.ttl (IDEMPOTENCY_TTL)"))
+	}
+	if len(errs) > 0 {
+		return errs
 	}
-	return defaultValue
+	return nil
 }
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/migrations"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// MigrationLockTimeout is how long a migration waits for the migrations of
+// another replica to complete
+const MigrationLockTimeout = 5 * time.Minute
+
+// Migrator applies the migrations embedded in the binary. Migrations hold a
+// Postgres advisory lock while they run, so replicas starting together apply
+// each migration once.
+type Migrator struct {
+	migrate *migrate.Migrate
+}
+
+// NewMigrator creates a migrator over a connection of db. Close releases the
+// connection, leaving db open.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration connection: %w", err)
+	}
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("failed to create migration instance: %w", err)
+	}
+	m.LockTimeout = MigrationLockTimeout
+	m.Log = migrationLogger{}
+
+	return &Migrator{migrate: m}, nil
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up() error {
+	if err := m.migrate.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	return nil
+}
+
+// Down reverts the last steps applied migrations
+func (m *Migrator) Down(steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("steps must be at least 1, got %d", steps)
+	}
+	if err := m.migrate.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to revert migrations: %w", err)
+	}
+	return nil
+}
+
+// Version returns the version of the schema, and whether its last migration
+// failed halfway. It returns 0 when no migration was applied.
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.migrate.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// Force records version as the version of the schema and clears the dirty
+// flag, without running any migration. It is used to recover from a failed
+// migration once the schema was repaired by hand.
+func (m *Migrator) Force(version int) error {
+	if err := m.migrate.Force(version); err != nil {
+		return fmt.Errorf("failed to force schema version: %w", err)
+	}
+	return nil
+}
+
+// Close releases the connection of the migrator
+func (m *Migrator) Close() error {
+	sourceErr, dbErr := m.migrate.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+// RunMigrations applies every pending migration
+func RunMigrations(db *sql.DB) error {
+	m, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Up()
+}
+
+// migrationLogger reports the applied migrations through the default logger
+type migrationLogger struct{}
+
+func (migrationLogger) Printf(format string, v ...interface{}) {
+	slog.Info("migrate: " + strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (migrationLogger) Verbose() bool {
+	return false
+}
@@ -4,18 +4,24 @@ import (
 	"database/sql"
 	"fmt"
 
+	"github.com/b-fontaine/saaster_kit/backend/platform/tracing"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/config"
 	_ "github.com/lib/pq"
+	"github.com/uptrace/opentelemetry-go-extra/otelsql"
 )
 
-// NewPostgresDB creates a new PostgreSQL database connection
+// NewPostgresDB creates a new PostgreSQL database connection, tracing the
+// statements run within a trace
 func NewPostgresDB(cfg config.DatabaseConfig) (*sql.DB, error) {
 	connStr := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
 	)
 
-	db, err := sql.Open("postgres", connStr)
+	db, err := otelsql.Open("postgres", connStr,
+		otelsql.WithDBSystem("postgresql"),
+		otelsql.WithDBName(cfg.DBName),
+		otelsql.WithTracerProvider(tracing.SQLTracerProvider()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
@@ -26,25 +32,3 @@ func NewPostgresDB(cfg config.DatabaseConfig) (*sql.DB, error) {
 
 	return db, nil
 }
-
-// RunMigrations runs database migrations
-func RunMigrations(db *sql.DB) error {
-	// Create users table if it doesn't exist
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id UUID PRIMARY KEY,
-			email VARCHAR(255) NOT NULL UNIQUE,
-			first_name VARCHAR(255) NOT NULL,
-			last_name VARCHAR(255) NOT NULL,
-			role VARCHAR(50) NOT NULL,
-			active BOOLEAN NOT NULL DEFAULT true,
-			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create users table: %w", err)
-	}
-
-	return nil
-}
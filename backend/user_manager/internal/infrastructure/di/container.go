@@ -1,62 +1,228 @@
 package di
 
 import (
+	"context"
 	"database/sql"
 
+	grpcadapter "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/grpc"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/handlers"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/memory"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/postgres"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/commands"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/queries"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/metrics"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/tracing"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+	"github.com/google/uuid"
 )
 
 // Container is a dependency injection container
 type Container struct {
 	// Repositories
-	UserRepository ports.UserRepository
+	UserRepository         ports.UserRepository
+	OrganizationRepository ports.OrganizationRepository
+	OutboxRepository       ports.OutboxRepository
 
 	// Command Handlers
 	CreateUserHandler *commands.CreateUserHandler
 	UpdateUserHandler *commands.UpdateUserHandler
 	DeleteUserHandler *commands.DeleteUserHandler
 
+	ActivateUserHandler   *commands.ActivateUserHandler
+	DeactivateUserHandler *commands.DeactivateUserHandler
+
+	CreateOrganizationHandler *commands.CreateOrganizationHandler
+
+	LinkUserAccountHandler *commands.LinkUserAccountHandler
+
 	// Query Handlers
 	GetUserByIDHandler *queries.GetUserByIDHandler
 	ListUsersHandler   *queries.ListUsersHandler
 
+	GetOrganizationHandler *queries.GetOrganizationHandler
+
+	GetUserByExternalIDHandler *queries.GetUserByExternalIDHandler
+
+	// Authorization
+	Authorizer    *auth.Authorizer
+	AccountStatus auth.AccountStatusChecker
+
 	// HTTP Handlers
-	UserHandler *handlers.UserHandler
+	UserHandler         *handlers.UserHandler
+	OrganizationHandler *handlers.OrganizationHandler
+	DaprHandler         *handlers.DaprHandler
+
+	// gRPC Services
+	UserServer *grpcadapter.UserServer
 }
 
-// NewContainer creates a new dependency injection container
-func NewContainer(db *sql.DB, useInMemoryRepo bool) *Container {
+// Option customizes the construction of a Container
+type Option func(*options)
+
+type options struct {
+	metrics  *metrics.Metrics
+	tracing  bool
+	clientID string
+}
+
+// WithMetrics records the duration of the repository operations
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithTracing starts a span for the user and organization repository operations
+func WithTracing() Option {
+	return func(o *options) {
+		o.tracing = true
+	}
+}
+
+// WithClientID reads the client roles of the Keycloak client clientID rather
+// than of auth.DefaultClientID. An empty clientID keeps the default.
+func WithClientID(clientID string) Option {
+	return func(o *options) {
+		if clientID != "" {
+			o.clientID = clientID
+		}
+	}
+}
+
+// NewContainer creates a new dependency injection container. statusSync
+// propagates activations and deactivations to the identity provider; it may be
+// nil when no identity provider is available, e.g. in tests.
+func NewContainer(db *sql.DB, useInMemoryRepo bool, statusSync ports.UserStatusSynchronizer, opts ...Option) *Container {
 	container := &Container{}
 
+	o := options{clientID: auth.DefaultClientID}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// Initialize repositories
 	if useInMemoryRepo {
-		container.UserRepository = memory.NewUserRepository()
+		outbox := memory.NewOutboxRepository()
+		container.UserRepository = memory.NewUserRepository(outbox)
+		container.OrganizationRepository = memory.NewOrganizationRepository()
+		container.OutboxRepository = outbox
 	} else {
 		container.UserRepository = postgres.NewUserRepository(db)
+		container.OrganizationRepository = postgres.NewOrganizationRepository(db)
+		container.OutboxRepository = postgres.NewOutboxRepository(db)
+	}
+	if o.metrics != nil {
+		container.UserRepository = o.metrics.UserRepository(container.UserRepository)
+		container.OrganizationRepository = o.metrics.OrganizationRepository(container.OrganizationRepository)
+		container.OutboxRepository = o.metrics.OutboxRepository(container.OutboxRepository)
+	}
+	if o.tracing {
+		container.UserRepository = tracing.UserRepository(container.UserRepository)
+		container.OrganizationRepository = tracing.OrganizationRepository(container.OrganizationRepository)
 	}
 
 	// Initialize command handlers
-	container.CreateUserHandler = commands.NewCreateUserHandler(container.UserRepository)
+	container.CreateUserHandler = commands.NewCreateUserHandler(container.UserRepository, container.OrganizationRepository)
 	container.UpdateUserHandler = commands.NewUpdateUserHandler(container.UserRepository)
 	container.DeleteUserHandler = commands.NewDeleteUserHandler(container.UserRepository)
+	container.ActivateUserHandler = commands.NewActivateUserHandler(container.UserRepository, statusSync)
+	container.DeactivateUserHandler = commands.NewDeactivateUserHandler(container.UserRepository, statusSync)
+	container.CreateOrganizationHandler = commands.NewCreateOrganizationHandler(container.OrganizationRepository)
+	container.LinkUserAccountHandler = commands.NewLinkUserAccountHandler(container.UserRepository, statusSync)
 
 	// Initialize query handlers
 	container.GetUserByIDHandler = queries.NewGetUserByIDHandler(container.UserRepository)
 	container.ListUsersHandler = queries.NewListUsersHandler(container.UserRepository)
+	container.GetOrganizationHandler = queries.NewGetOrganizationHandler(container.OrganizationRepository)
+	container.GetUserByExternalIDHandler = queries.NewGetUserByExternalIDHandler(container.UserRepository)
+
+	// Initialize authorization
+	container.Authorizer = auth.NewAuthorizer(auth.DefaultPolicy(o.clientID), newSelfChecker(container.GetUserByIDHandler))
+	container.AccountStatus = newAccountStatusChecker(container.GetUserByExternalIDHandler)
 
 	// Initialize HTTP handlers
 	container.UserHandler = handlers.NewUserHandler(
 		container.CreateUserHandler,
 		container.UpdateUserHandler,
 		container.DeleteUserHandler,
+		container.ActivateUserHandler,
+		container.DeactivateUserHandler,
+		container.GetUserByIDHandler,
+		container.ListUsersHandler,
+		container.LinkUserAccountHandler,
+		container.Authorizer,
+	)
+	container.OrganizationHandler = handlers.NewOrganizationHandler(
+		container.CreateOrganizationHandler,
+		container.GetOrganizationHandler,
+		container.CreateUserHandler,
+		container.Authorizer,
+	)
+	container.DaprHandler = handlers.NewDaprHandler()
+
+	// Initialize gRPC services
+	container.UserServer = grpcadapter.NewUserServer(
+		container.CreateUserHandler,
+		container.UpdateUserHandler,
+		container.DeleteUserHandler,
+		container.ActivateUserHandler,
+		container.DeactivateUserHandler,
 		container.GetUserByIDHandler,
 		container.ListUsersHandler,
+		container.Authorizer,
 	)
 
 	return container
 }
+
+// newSelfChecker returns the checker matching users to the caller through
+// the identity provider account they are linked to
+func newSelfChecker(getUserByIDHandler *queries.GetUserByIDHandler) auth.SelfChecker {
+	return auth.SelfCheckerFunc(func(ctx context.Context, claims *auth.Claims, userID string) (bool, error) {
+		// IDs that are not UUIDs cannot be the ID of a user
+		if claims.TenantID == "" || claims.Subject == "" || uuid.Validate(userID) != nil {
+			return false, nil
+		}
+
+		user, err := getUserByIDHandler.Handle(ctx, queries.GetUserByIDQuery{
+			TenantID: claims.TenantID,
+			ID:       userID,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		return user != nil && user.ExternalID == claims.Subject, nil
+	})
+}
+
+// newAccountStatusChecker returns the checker rejecting the tokens of
+// deactivated users. The caller's user is the one linked to the token
+// subject; callers bound to an organization without a user linked to their
+// account are reported as unlinked, until they link it. Callers without an
+// organization, such as platform admins or service accounts, are not
+// affected.
+func newAccountStatusChecker(getUserByExternalIDHandler *queries.GetUserByExternalIDHandler) auth.AccountStatusChecker {
+	return auth.AccountStatusCheckerFunc(func(ctx context.Context, claims *auth.Claims) (auth.AccountStatus, error) {
+		if claims.TenantID == "" {
+			return auth.AccountActive, nil
+		}
+
+		user, err := getUserByExternalIDHandler.Handle(ctx, queries.GetUserByExternalIDQuery{
+			TenantID:   claims.TenantID,
+			ExternalID: claims.Subject,
+		})
+		if err != nil {
+			return auth.AccountActive, err
+		}
+
+		switch {
+		case user == nil:
+			return auth.AccountUnlinked, nil
+		case !user.Active:
+			return auth.AccountDeactivated, nil
+		}
+		return auth.AccountActive, nil
+	})
+}
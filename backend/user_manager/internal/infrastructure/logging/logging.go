@@ -0,0 +1,25 @@
+// Package logging logs the HTTP requests and gRPC calls of the service. The
+// records are written by the platform logging package, which reads the
+// tenant and user of their correlation from the claims of the caller once
+// this package is imported.
+package logging
+
+import (
+	"context"
+
+	platformlogging "github.com/b-fontaine/saaster_kit/backend/platform/logging"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+)
+
+func init() {
+	platformlogging.SetCorrelationSource(correlationOfCaller)
+}
+
+// correlationOfCaller completes correlation with the organization and the
+// subject of the caller of the request of ctx
+func correlationOfCaller(ctx context.Context, correlation *platformlogging.Correlation) {
+	if claims, ok := auth.ClaimsFromContext(ctx); ok {
+		correlation.TenantID = claims.TenantID
+		correlation.UserID = claims.Subject
+	}
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	platformlogging "github.com/b-fontaine/saaster_kit/backend/platform/logging"
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Middleware gives every request an ID, the one of its X-Request-ID header
+// when it is valid or a new one, echoes it in the response and logs the
+// request once answered
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := platformlogging.RequestIDOrNew(r.Header.Get(platformlogging.RequestIDHeader))
+		w.Header().Set(platformlogging.RequestIDHeader, requestID)
+		ctx := platformlogging.WithRequestID(r.Context(), requestID)
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		// The authentication middleware adds the caller to the request of the
+		// handlers, so the request is logged with the context they saw
+		var served *http.Request
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(ctx, servedRequestKey{}, &served)))
+		if served != nil {
+			ctx = served.Context()
+		}
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(ctx, level, "request completed",
+			slog.String("http.request.method", r.Method),
+			slog.String("http.route", route),
+			slog.String("url.path", r.URL.Path),
+			slog.Int("http.response.status_code", recorder.status),
+			slog.Int64("event.duration", time.Since(start).Nanoseconds()))
+	})
+}
+
+// servedRequestKey holds where CaptureRequest stores the request it saw
+type servedRequestKey struct{}
+
+// CaptureRequest records the request reaching the handlers, after the
+// authentication middleware, so that Middleware logs it with its caller
+func CaptureRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if served, ok := r.Context().Value(servedRequestKey{}).(**http.Request); ok {
+			*served = r
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryServerInterceptor gives every gRPC call an ID, the one of its
+// x-request-id metadata when it is valid or a new one, and logs the call
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var requestID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(platformlogging.RequestIDHeader); len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+		requestID = platformlogging.RequestIDOrNew(requestID)
+		ctx = platformlogging.WithRequestID(ctx, requestID)
+		grpc.SetHeader(ctx, metadata.Pairs(platformlogging.RequestIDHeader, requestID))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(ctx, level, "call completed",
+			slog.String("rpc.method", info.FullMethod),
+			slog.String("rpc.grpc.status_code", status.Code(err).String()),
+			slog.Int64("event.duration", time.Since(start).Nanoseconds()))
+		return resp, err
+	}
+}
+
+// statusRecorder captures the status of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
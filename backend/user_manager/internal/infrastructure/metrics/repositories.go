@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// UserRepository records the duration of the operations of repo
+func (m *Metrics) UserRepository(repo ports.UserRepository) ports.UserRepository {
+	return &userRepository{next: repo, metrics: m}
+}
+
+// OrganizationRepository records the duration of the operations of repo
+func (m *Metrics) OrganizationRepository(repo ports.OrganizationRepository) ports.OrganizationRepository {
+	return &organizationRepository{next: repo, metrics: m}
+}
+
+// OutboxRepository records the duration of the operations of repo
+func (m *Metrics) OutboxRepository(repo ports.OutboxRepository) ports.OutboxRepository {
+	return &outboxRepository{next: repo, metrics: m}
+}
+
+type userRepository struct {
+	next    ports.UserRepository
+	metrics *Metrics
+}
+
+func (r *userRepository) Create(ctx context.Context, user *domain.User, events ...*domain.Event) (err error) {
+	defer r.metrics.observeRepository("user", "create", time.Now(), &err)
+	return r.next.Create(ctx, user, events...)
+}
+
+func (r *userRepository) Update(ctx context.Context, user *domain.User, events ...*domain.Event) (err error) {
+	defer r.metrics.observeRepository("user", "update", time.Now(), &err)
+	return r.next.Update(ctx, user, events...)
+}
+
+func (r *userRepository) Delete(ctx context.Context, tenantID, id string, version int64, events ...*domain.Event) (err error) {
+	defer r.metrics.observeRepository("user", "delete", time.Now(), &err)
+	return r.next.Delete(ctx, tenantID, id, version, events...)
+}
+
+func (r *userRepository) GetByID(ctx context.Context, tenantID, id string) (_ *domain.User, err error) {
+	defer r.metrics.observeRepository("user", "get_by_id", time.Now(), &err)
+	return r.next.GetByID(ctx, tenantID, id)
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, tenantID, email string) (_ *domain.User, err error) {
+	defer r.metrics.observeRepository("user", "get_by_email", time.Now(), &err)
+	return r.next.GetByEmail(ctx, tenantID, email)
+}
+
+func (r *userRepository) GetByExternalID(ctx context.Context, tenantID, externalID string) (_ *domain.User, err error) {
+	defer r.metrics.observeRepository("user", "get_by_external_id", time.Now(), &err)
+	return r.next.GetByExternalID(ctx, tenantID, externalID)
+}
+
+func (r *userRepository) List(ctx context.Context, tenantID string, opts domain.UserListOptions) (_ *domain.UserPage, err error) {
+	defer r.metrics.observeRepository("user", "list", time.Now(), &err)
+	return r.next.List(ctx, tenantID, opts)
+}
+
+type organizationRepository struct {
+	next    ports.OrganizationRepository
+	metrics *Metrics
+}
+
+func (r *organizationRepository) Create(ctx context.Context, org *domain.Organization) (err error) {
+	defer r.metrics.observeRepository("organization", "create", time.Now(), &err)
+	return r.next.Create(ctx, org)
+}
+
+func (r *organizationRepository) Update(ctx context.Context, org *domain.Organization) (err error) {
+	defer r.metrics.observeRepository("organization", "update", time.Now(), &err)
+	return r.next.Update(ctx, org)
+}
+
+func (r *organizationRepository) GetByID(ctx context.Context, id string) (_ *domain.Organization, err error) {
+	defer r.metrics.observeRepository("organization", "get_by_id", time.Now(), &err)
+	return r.next.GetByID(ctx, id)
+}
+
+func (r *organizationRepository) GetBySlug(ctx context.Context, slug string) (_ *domain.Organization, err error) {
+	defer r.metrics.observeRepository("organization", "get_by_slug", time.Now(), &err)
+	return r.next.GetBySlug(ctx, slug)
+}
+
+type outboxRepository struct {
+	next    ports.OutboxRepository
+	metrics *Metrics
+}
+
+func (r *outboxRepository) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) (_ []*ports.OutboxEntry, err error) {
+	defer r.metrics.observeRepository("outbox", "claim", time.Now(), &err)
+	return r.next.Claim(ctx, now, leaseUntil, limit)
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, id string) (err error) {
+	defer r.metrics.observeRepository("outbox", "mark_published", time.Now(), &err)
+	return r.next.MarkPublished(ctx, id)
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, id string, cause string, retryAt time.Time) (err error) {
+	defer r.metrics.observeRepository("outbox", "mark_failed", time.Now(), &err)
+	return r.next.MarkFailed(ctx, id, cause, retryAt)
+}
+
+func (r *outboxRepository) PurgePublished(ctx context.Context, before time.Time, limit int) (_ int, err error) {
+	defer r.metrics.observeRepository("outbox", "purge_published", time.Now(), &err)
+	return r.next.PurgePublished(ctx, before, limit)
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	userv1 "github.com/b-fontaine/saaster_kit/backend/user_manager/api/user/v1"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// GRPCServer represents the gRPC server. Besides the UserService, it serves
+// the standard health service and server reflection.
+type GRPCServer struct {
+	addr   string
+	server *grpc.Server
+	health *health.Server
+}
+
+// NewGRPCServer creates a new gRPC server.
+// interceptors run on every call, in order; the authentication interceptor
+// is expected to let the health and reflection services through.
+func NewGRPCServer(cfg config.GRPCConfig, users userv1.UserServiceServer, interceptors ...grpc.UnaryServerInterceptor) *GRPCServer {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	healthServer := health.NewServer()
+
+	userv1.RegisterUserServiceServer(server, users)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	healthServer.SetServingStatus(userv1.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	return &GRPCServer{
+		addr:   ":" + cfg.Port,
+		server: server,
+		health: healthServer,
+	}
+}
+
+// Start starts the gRPC server
+func (s *GRPCServer) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve serves the calls accepted by listener
+func (s *GRPCServer) Serve(listener net.Listener) error {
+	slog.Info("Starting gRPC server", "address", listener.Addr().String())
+	return s.server.Serve(listener)
+}
+
+// Stop reports the services as not serving, then waits for the pending
+// calls. The calls still running when ctx is done are cancelled.
+func (s *GRPCServer) Stop(ctx context.Context) error {
+	slog.Info("Stopping gRPC server")
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
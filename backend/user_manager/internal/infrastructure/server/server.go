@@ -2,27 +2,34 @@ package server
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/openapi"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/config"
 	"github.com/gorilla/mux"
 )
 
 // Server represents the HTTP server
 type Server struct {
-	router   *mux.Router
-	server   *http.Server
-	handlers *handlers.UserHandler
+	router      *mux.Router
+	server      *http.Server
+	handlers    *handlers.UserHandler
+	orgHandlers *handlers.OrganizationHandler
+	daprHandler *handlers.DaprHandler
+	dapr        *mux.Router
+	authMW      mux.MiddlewareFunc
+	apiMWs      []mux.MiddlewareFunc
 }
 
-// NewServer creates a new HTTP server
-func NewServer(cfg config.ServerConfig, handlers *handlers.UserHandler) *Server {
+// NewServer creates a new HTTP server.
+// authMiddleware protects the API routes; health and Dapr endpoints stay public.
+// apiMiddlewares run on the API routes after authMiddleware, in order.
+func NewServer(cfg config.ServerConfig, handlers *handlers.UserHandler, orgHandlers *handlers.OrganizationHandler, daprHandler *handlers.DaprHandler, authMiddleware mux.MiddlewareFunc, apiMiddlewares ...mux.MiddlewareFunc) *Server {
 	router := mux.NewRouter()
-	
+
 	server := &Server{
 		router: router,
 		server: &http.Server{
@@ -31,7 +38,11 @@ func NewServer(cfg config.ServerConfig, handlers *handlers.UserHandler) *Server
 			ReadTimeout:  cfg.ReadTimeout,
 			WriteTimeout: cfg.WriteTimeout,
 		},
-		handlers: handlers,
+		handlers:    handlers,
+		orgHandlers: orgHandlers,
+		daprHandler: daprHandler,
+		authMW:      authMiddleware,
+		apiMWs:      apiMiddlewares,
 	}
 
 	// Register routes
@@ -42,41 +53,57 @@ func NewServer(cfg config.ServerConfig, handlers *handlers.UserHandler) *Server
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	log.Printf("Starting server on %s", s.server.Addr)
+	slog.Info("Starting server", "address", s.server.Addr)
 	return s.server.ListenAndServe()
 }
 
 // Stop stops the HTTP server
 func (s *Server) Stop(ctx context.Context) error {
-	log.Println("Stopping server")
+	slog.Info("Stopping server")
 	return s.server.Shutdown(ctx)
 }
 
+// Use adds middlewares running on every route, before the authentication middleware
+func (s *Server) Use(middlewares ...mux.MiddlewareFunc) {
+	s.router.Use(middlewares...)
+}
+
+// UseDapr adds middlewares running on the routes called by the Dapr sidecar,
+// such as the check of its app API token
+func (s *Server) UseDapr(middlewares ...mux.MiddlewareFunc) {
+	s.dapr.Use(middlewares...)
+}
+
+// Handle registers a public GET route, such as the metrics endpoint
+func (s *Server) Handle(path string, handler http.Handler) {
+	s.router.Handle(path, handler).Methods(http.MethodGet)
+}
+
 // registerRoutes registers all HTTP routes
 func (s *Server) registerRoutes() {
-	// Health check
-	s.router.HandleFunc("/health", s.healthHandler).Methods(http.MethodGet)
+	// API document
+	s.router.HandleFunc(openapi.Path, openapi.Handler).Methods(http.MethodGet)
 
 	// API routes
 	api := s.router.PathPrefix("/api/v1").Subrouter()
-	
-	// Register user handlers
-	s.handlers.RegisterRoutes(api)
+	if s.authMW != nil {
+		api.Use(s.authMW)
+	}
+	api.Use(s.apiMWs...)
 
-	// Dapr subscription endpoints
-	s.router.HandleFunc("/dapr/subscribe", s.daprSubscriptionHandler).Methods(http.MethodGet)
-}
+	// Register user and organization handlers
+	s.handlers.RegisterRoutes(api)
+	s.orgHandlers.RegisterRoutes(api)
 
-// healthHandler handles health check requests
-func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"UP","time":"%s"}`, time.Now().Format(time.RFC3339))
-}
+	// Dapr sidecar endpoints
+	s.dapr = s.router.PathPrefix("/dapr").Subrouter()
+	s.daprHandler.RegisterRoutes(s.dapr)
 
-// daprSubscriptionHandler handles Dapr subscription requests
-func (s *Server) daprSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `[{"pubsubname":"pubsub","topic":"user-created","route":"/api/v1/events/user-created"}]`)
+	// Unknown routes are answered with problem details too
+	s.router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Error(w, r, http.StatusNotFound, "No route matches "+r.URL.Path)
+	})
+	s.router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Error(w, r, http.StatusMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+	})
 }
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+)
+
+// UserRepository starts a span for the operations of repo
+func UserRepository(repo ports.UserRepository) ports.UserRepository {
+	return &userRepository{next: repo}
+}
+
+// OrganizationRepository starts a span for the operations of repo
+func OrganizationRepository(repo ports.OrganizationRepository) ports.OrganizationRepository {
+	return &organizationRepository{next: repo}
+}
+
+type userRepository struct {
+	next ports.UserRepository
+}
+
+func (r *userRepository) Create(ctx context.Context, user *domain.User, events ...*domain.Event) (err error) {
+	ctx, end := startSpan(ctx, "UserRepository.Create")
+	defer end(&err)
+	return r.next.Create(ctx, user, events...)
+}
+
+func (r *userRepository) Update(ctx context.Context, user *domain.User, events ...*domain.Event) (err error) {
+	ctx, end := startSpan(ctx, "UserRepository.Update")
+	defer end(&err)
+	return r.next.Update(ctx, user, events...)
+}
+
+func (r *userRepository) Delete(ctx context.Context, tenantID, id string, version int64, events ...*domain.Event) (err error) {
+	ctx, end := startSpan(ctx, "UserRepository.Delete")
+	defer end(&err)
+	return r.next.Delete(ctx, tenantID, id, version, events...)
+}
+
+func (r *userRepository) GetByID(ctx context.Context, tenantID, id string) (_ *domain.User, err error) {
+	ctx, end := startSpan(ctx, "UserRepository.GetByID")
+	defer end(&err)
+	return r.next.GetByID(ctx, tenantID, id)
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, tenantID, email string) (_ *domain.User, err error) {
+	ctx, end := startSpan(ctx, "UserRepository.GetByEmail")
+	defer end(&err)
+	return r.next.GetByEmail(ctx, tenantID, email)
+}
+
+func (r *userRepository) GetByExternalID(ctx context.Context, tenantID, externalID string) (_ *domain.User, err error) {
+	ctx, end := startSpan(ctx, "UserRepository.GetByExternalID")
+	defer end(&err)
+	return r.next.GetByExternalID(ctx, tenantID, externalID)
+}
+
+func (r *userRepository) List(ctx context.Context, tenantID string, opts domain.UserListOptions) (_ *domain.UserPage, err error) {
+	ctx, end := startSpan(ctx, "UserRepository.List")
+	defer end(&err)
+	return r.next.List(ctx, tenantID, opts)
+}
+
+type organizationRepository struct {
+	next ports.OrganizationRepository
+}
+
+func (r *organizationRepository) Create(ctx context.Context, org *domain.Organization) (err error) {
+	ctx, end := startSpan(ctx, "OrganizationRepository.Create")
+	defer end(&err)
+	return r.next.Create(ctx, org)
+}
+
+func (r *organizationRepository) Update(ctx context.Context, org *domain.Organization) (err error) {
+	ctx, end := startSpan(ctx, "OrganizationRepository.Update")
+	defer end(&err)
+	return r.next.Update(ctx, org)
+}
+
+func (r *organizationRepository) GetByID(ctx context.Context, id string) (_ *domain.Organization, err error) {
+	ctx, end := startSpan(ctx, "OrganizationRepository.GetByID")
+	defer end(&err)
+	return r.next.GetByID(ctx, id)
+}
+
+func (r *organizationRepository) GetBySlug(ctx context.Context, slug string) (_ *domain.Organization, err error) {
+	ctx, end := startSpan(ctx, "OrganizationRepository.GetBySlug")
+	defer end(&err)
+	return r.next.GetBySlug(ctx, slug)
+}
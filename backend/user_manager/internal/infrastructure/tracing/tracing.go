@@ -0,0 +1,104 @@
+// Package tracing sets up the OpenTelemetry tracing of the service: the spans
+// of the HTTP and gRPC requests, of the Temporal workflows and activities, of
+// the repository operations and of the SQL statements, linked by the W3C
+// trace context received from callers and passed on to Temporal and Dapr.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	platformtracing "github.com/b-fontaine/saaster_kit/backend/platform/tracing"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// instrumentationName names the tracer of the spans started by the service
+const instrumentationName = "github.com/b-fontaine/saaster_kit/backend/user_manager"
+
+// tracer returns the tracer of the global provider
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Middleware starts a span for the requests of the routes of a router,
+// continuing the trace of the traceparent header if any. Spans are named
+// after the template of the matched route rather than its path.
+func Middleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.request",
+		otelhttp.WithPropagators(platformtracing.Propagator),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil {
+					return r.Method + " " + template
+				}
+			}
+			return r.Method
+		}),
+	)
+}
+
+// UnaryServerInterceptor starts a span for the gRPC calls, continuing the
+// trace of the traceparent metadata if any
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = platformtracing.Propagator.Extract(ctx, metadataCarrier(md))
+
+		ctx, span := tracer().Start(ctx, strings.TrimPrefix(info.FullMethod, "/"),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.RPCSystemGRPC))
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+		code := status.Code(err)
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return resp, err
+	}
+}
+
+// metadataCarrier reads the trace context from gRPC metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// startSpan starts an internal span, ended by the returned function with the
+// error of the traced operation
+func startSpan(ctx context.Context, name string) (context.Context, func(err *error)) {
+	ctx, span := tracer().Start(ctx, name)
+	return ctx, func(err *error) {
+		if *err != nil {
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
@@ -2,19 +2,69 @@ package ports
 
 import (
 	"context"
+	"time"
 
 	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
 )
 
-// UserRepository defines the interface for user repository operations
+// UserRepository defines the interface for user repository operations.
+// Every operation is scoped to a tenant: implementations must never read or
+// write users of another tenant, and must reject an empty tenant ID with
+// domain.ErrTenantRequired.
+//
+// Write operations append the given events to the outbox atomically with the
+// change: either both are stored or neither is.
+//
+// Writes are conditional on the version of the user: Update and Delete return
+// domain.ErrVersionMismatch, changing nothing, when the stored user is not at
+// the given version. Create stores the user at version 1 and Update increments
+// user.Version.
 type UserRepository interface {
 	// Command methods (write operations)
-	Create(ctx context.Context, user *domain.User) error
-	Update(ctx context.Context, user *domain.User) error
-	Delete(ctx context.Context, id string) error
+	Create(ctx context.Context, user *domain.User, events ...*domain.Event) error
+	Update(ctx context.Context, user *domain.User, events ...*domain.Event) error
+	Delete(ctx context.Context, tenantID, id string, version int64, events ...*domain.Event) error
 
 	// Query methods (read operations)
-	GetByID(ctx context.Context, id string) (*domain.User, error)
-	GetByEmail(ctx context.Context, email string) (*domain.User, error)
-	List(ctx context.Context) ([]*domain.User, error)
+	GetByID(ctx context.Context, tenantID, id string) (*domain.User, error)
+	GetByEmail(ctx context.Context, tenantID, email string) (*domain.User, error)
+	// GetByExternalID returns the user linked to an identity provider account
+	GetByExternalID(ctx context.Context, tenantID, externalID string) (*domain.User, error)
+	// List returns a page of the tenant's users matching opts.Filter, ordered
+	// by opts.SortBy then by ID
+	List(ctx context.Context, tenantID string, opts domain.UserListOptions) (*domain.UserPage, error)
+}
+
+// OrganizationRepository defines the interface for organization repository operations
+type OrganizationRepository interface {
+	// Command methods (write operations)
+	Create(ctx context.Context, org *domain.Organization) error
+	Update(ctx context.Context, org *domain.Organization) error
+
+	// Query methods (read operations)
+	GetByID(ctx context.Context, id string) (*domain.Organization, error)
+	GetBySlug(ctx context.Context, slug string) (*domain.Organization, error)
+}
+
+// OutboxEntry is an event of the outbox waiting to be published
+type OutboxEntry struct {
+	Event *domain.Event
+	// Attempts is the number of failed attempts to publish the event
+	Attempts int
+}
+
+// OutboxRepository gives access to the events waiting to be published, across
+// every tenant. Events are written by the UserRepository.
+type OutboxRepository interface {
+	// Claim returns up to limit unpublished events due at now, oldest first,
+	// and hides them from other claims until leaseUntil. An event whose
+	// publisher crashes is claimed again once its lease expires.
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*OutboxEntry, error)
+	// MarkPublished removes an event from the events to publish
+	MarkPublished(ctx context.Context, id string) error
+	// MarkFailed records a failed attempt and schedules the next one
+	MarkFailed(ctx context.Context, id string, cause string, retryAt time.Time) error
+	// PurgePublished deletes up to limit events published before the given
+	// time, oldest first, and returns the number of events deleted
+	PurgePublished(ctx context.Context, before time.Time, limit int) (int, error)
 }
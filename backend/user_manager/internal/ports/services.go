@@ -8,16 +8,36 @@ import (
 
 // UserCommandService defines the interface for user command operations
 type UserCommandService interface {
-	CreateUser(ctx context.Context, email, firstName, lastName, role string) (*domain.User, error)
-	UpdateUser(ctx context.Context, id, email, firstName, lastName, role string) (*domain.User, error)
-	DeleteUser(ctx context.Context, id string) error
-	ActivateUser(ctx context.Context, id string) error
-	DeactivateUser(ctx context.Context, id string) error
+	CreateUser(ctx context.Context, tenantID, email, firstName, lastName, role string) (*domain.User, error)
+	UpdateUser(ctx context.Context, tenantID, id, email, firstName, lastName, role string) (*domain.User, error)
+	DeleteUser(ctx context.Context, tenantID, id string) error
+	ActivateUser(ctx context.Context, tenantID, id string) error
+	DeactivateUser(ctx context.Context, tenantID, id string) error
 }
 
 // UserQueryService defines the interface for user query operations
 type UserQueryService interface {
-	GetUserByID(ctx context.Context, id string) (*domain.User, error)
-	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
-	ListUsers(ctx context.Context) ([]*domain.User, error)
+	GetUserByID(ctx context.Context, tenantID, id string) (*domain.User, error)
+	GetUserByEmail(ctx context.Context, tenantID, email string) (*domain.User, error)
+	ListUsers(ctx context.Context, tenantID string, opts domain.UserListOptions) (*domain.UserPage, error)
+}
+
+// UserStatusSynchronizer propagates the active state of a user to the identity
+// provider. Implementations run asynchronously and read the state of the user
+// when they apply it, so the latest state always wins.
+type UserStatusSynchronizer interface {
+	SyncUserStatus(ctx context.Context, user *domain.User) error
+}
+
+// IdentityProvider manages the accounts users sign in with. Accounts are
+// identified by the external ID of the user they are linked to.
+type IdentityProvider interface {
+	// SetUserEnabled enables or disables an account. It returns
+	// domain.ErrUserNotFound when there is no such account.
+	SetUserEnabled(ctx context.Context, externalID string, enabled bool) error
+}
+
+// EventPublisher publishes domain events to other services
+type EventPublisher interface {
+	Publish(ctx context.Context, event *domain.Event) error
 }
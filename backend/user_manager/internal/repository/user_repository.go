@@ -1,201 +0,0 @@
-package repository
-
-import (
-	"context"
-	"database/sql"
-	"errors"
-	"time"
-
-	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/models"
-	"github.com/google/uuid"
-)
-
-// UserRepository handles database operations for users
-type UserRepository struct {
-	db *sql.DB
-}
-
-// NewUserRepository creates a new UserRepository
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
-}
-
-// CreateUser creates a new user in the database
-func (r *UserRepository) CreateUser(ctx context.Context, req models.CreateUserRequest) (*models.User, error) {
-	user := &models.User{
-		ID:        uuid.New().String(),
-		Email:     req.Email,
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
-		Role:      req.Role,
-		Active:    true,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	query := `
-		INSERT INTO users (id, email, first_name, last_name, role, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, email, first_name, last_name, role, active, created_at, updated_at
-	`
-
-	err := r.db.QueryRowContext(
-		ctx,
-		query,
-		user.ID,
-		user.Email,
-		user.FirstName,
-		user.LastName,
-		user.Role,
-		user.Active,
-		user.CreatedAt,
-		user.UpdatedAt,
-	).Scan(
-		&user.ID,
-		&user.Email,
-		&user.FirstName,
-		&user.LastName,
-		&user.Role,
-		&user.Active,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return user, nil
-}
-
-// GetUserByID retrieves a user by ID
-func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
-	query := `
-		SELECT id, email, first_name, last_name, role, active, created_at, updated_at
-		FROM users
-		WHERE id = $1
-	`
-
-	user := &models.User{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.FirstName,
-		&user.LastName,
-		&user.Role,
-		&user.Active,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil // User not found
-		}
-		return nil, err
-	}
-
-	return user, nil
-}
-
-// GetUserByEmail retrieves a user by email
-func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := `
-		SELECT id, email, first_name, last_name, role, active, created_at, updated_at
-		FROM users
-		WHERE email = $1
-	`
-
-	user := &models.User{}
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.FirstName,
-		&user.LastName,
-		&user.Role,
-		&user.Active,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil // User not found
-		}
-		return nil, err
-	}
-
-	return user, nil
-}
-
-// ListUsers retrieves all users
-func (r *UserRepository) ListUsers(ctx context.Context) ([]*models.User, error) {
-	query := `
-		SELECT id, email, first_name, last_name, role, active, created_at, updated_at
-		FROM users
-		ORDER BY created_at DESC
-	`
-
-	rows, err := r.db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var users []*models.User
-	for rows.Next() {
-		user := &models.User{}
-		err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.FirstName,
-			&user.LastName,
-			&user.Role,
-			&user.Active,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, user)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return users, nil
-}
-
-// UpdateUser updates a user in the database
-func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
-	user.UpdatedAt = time.Now()
-
-	query := `
-		UPDATE users
-		SET email = $1, first_name = $2, last_name = $3, role = $4, active = $5, updated_at = $6
-		WHERE id = $7
-	`
-
-	_, err := r.db.ExecContext(
-		ctx,
-		query,
-		user.Email,
-		user.FirstName,
-		user.LastName,
-		user.Role,
-		user.Active,
-		user.UpdatedAt,
-		user.ID,
-	)
-
-	return err
-}
-
-// DeleteUser deletes a user from the database
-func (r *UserRepository) DeleteUser(ctx context.Context, id string) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
-	return err
-}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS users_tenant_id_email_key;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,5 @@
+-- The role is shared by the databases of the cluster and is kept
+DROP POLICY IF EXISTS tenant_isolation ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+REVOKE ALL ON users FROM user_manager_tenant;
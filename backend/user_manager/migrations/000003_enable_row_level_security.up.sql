@@ -0,0 +1,20 @@
+-- Repositories switch to this role for each transaction, so that row-level
+-- security applies even when the service connects as a superuser.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'user_manager_tenant') THEN
+        CREATE ROLE user_manager_tenant NOLOGIN;
+    END IF;
+END
+$$;
+GRANT user_manager_tenant TO CURRENT_USER;
+GRANT SELECT, INSERT, UPDATE, DELETE ON users TO user_manager_tenant;
+
+-- Only the users of the tenant set in app.tenant_id are visible or writable.
+-- Without the setting the policy matches nothing.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON users;
+CREATE POLICY tenant_isolation ON users
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
@@ -0,0 +1,2 @@
+-- Serves the default listing order of the users of a tenant
+CREATE INDEX IF NOT EXISTS users_tenant_id_created_at_id_idx ON users (tenant_id, created_at, id);
@@ -0,0 +1,18 @@
+-- Domain events are written to the outbox in the same transaction as the
+-- users they describe, then published by the outbox relay. Tenant
+-- transactions may only append to it; the relay reads it as the service.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id UUID PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    aggregate_id UUID NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    published_at TIMESTAMP WITH TIME ZONE
+);
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx
+    ON outbox_events (next_attempt_at) WHERE published_at IS NULL;
+GRANT INSERT ON outbox_events TO user_manager_tenant;
@@ -0,0 +1,3 @@
+-- Version of each user, incremented by every change, so that concurrent
+-- updates based on the same version do not overwrite each other
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
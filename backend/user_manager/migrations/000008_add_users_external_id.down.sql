@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_external_id_key;
+ALTER TABLE users DROP COLUMN IF EXISTS external_id;
@@ -0,0 +1,4 @@
+-- ID of the identity provider account of each user, the subject of their
+-- access tokens. An account is linked to at most one user.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+CREATE UNIQUE INDEX IF NOT EXISTS users_external_id_key ON users (external_id);
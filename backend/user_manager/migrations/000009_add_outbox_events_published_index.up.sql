@@ -0,0 +1,4 @@
+-- The relay purges the events published before the retention period, oldest
+-- first, without scanning the events waiting to be published.
+CREATE INDEX IF NOT EXISTS outbox_events_published_idx
+    ON outbox_events (published_at) WHERE published_at IS NOT NULL;
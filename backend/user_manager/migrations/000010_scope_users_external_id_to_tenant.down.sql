@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_tenant_id_external_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_external_id_key ON users (external_id);
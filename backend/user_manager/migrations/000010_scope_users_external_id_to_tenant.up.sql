@@ -0,0 +1,4 @@
+-- Accounts are linked per organization, like the emails: the same identity
+-- provider account may belong to one user in each organization.
+DROP INDEX IF EXISTS users_external_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_tenant_id_external_id_key ON users (tenant_id, external_id);
@@ -0,0 +1,4 @@
+-- Token of the reservation of each key, so that only the request holding the
+-- reservation completes or releases it, and not a request that outlived its
+-- lease after a retry took the key over
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS token VARCHAR(64);
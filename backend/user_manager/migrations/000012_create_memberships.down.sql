@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(50);
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE memberships NO FORCE ROW LEVEL SECURITY;
+UPDATE users SET role = memberships.role
+FROM memberships
+WHERE memberships.user_id = users.id AND memberships.organization_id = users.tenant_id;
+UPDATE users SET role = 'user' WHERE role IS NULL;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+ALTER TABLE users ALTER COLUMN role SET NOT NULL;
+
+DROP TABLE IF EXISTS memberships;
@@ -0,0 +1,31 @@
+-- Users are members of their organization, and their role is the role of
+-- their membership rather than an attribute of the user. Users without a
+-- tenant, which no tenant can see, have no membership and lose their role.
+CREATE TABLE IF NOT EXISTS memberships (
+    organization_id UUID NOT NULL REFERENCES organizations(id),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (organization_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS memberships_user_id_idx ON memberships (user_id);
+
+-- The users of every tenant are copied, which the forced row-level security
+-- would hide from the owner of the table
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+INSERT INTO memberships (organization_id, user_id, role, created_at, updated_at)
+SELECT tenant_id, id, role, created_at, updated_at FROM users WHERE tenant_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+-- Only the memberships of the tenant set in app.tenant_id are visible or
+-- writable, as for the users
+GRANT SELECT, INSERT, UPDATE, DELETE ON memberships TO user_manager_tenant;
+ALTER TABLE memberships ENABLE ROW LEVEL SECURITY;
+ALTER TABLE memberships FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON memberships;
+CREATE POLICY tenant_isolation ON memberships
+    USING (organization_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+    WITH CHECK (organization_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
@@ -0,0 +1,10 @@
+// Package migrations embeds the versioned SQL migrations of the service, so
+// that the binary carries the schema it expects.
+package migrations
+
+import "embed"
+
+// FS holds the up and down migrations, named <version>_<title>.<up|down>.sql
+//
+//go:embed *.sql
+var FS embed.FS
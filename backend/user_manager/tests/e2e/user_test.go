@@ -1,7 +1,6 @@
 package e2e
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -12,12 +11,12 @@ import (
 
 // APIFeature holds the state for the API tests
 type APIFeature struct {
-	client      *http.Client
-	baseURL     string
-	response    *http.Response
+	client       *http.Client
+	baseURL      string
+	response     *http.Response
 	responseBody []byte
-	userID      string
-	authToken   string
+	userID       string
+	authToken    string
 }
 
 // NewAPIFeature creates a new APIFeature
@@ -62,7 +61,7 @@ func InitializeScenario(ctx *godog.ScenarioContext) {
 
 	ctx.Step(`^the system is running$`, api.theSystemIsRunning)
 	ctx.Step(`^I am authenticated as an administrator$`, api.iAmAuthenticatedAsAnAdministrator)
-	
+
 	// Add more step definitions here for the Gherkin scenarios
 	// For example:
 	// ctx.Step(`^I create a user with the following details:$`, api.iCreateAUserWithTheFollowingDetails)
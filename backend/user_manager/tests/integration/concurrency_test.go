@@ -0,0 +1,78 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/postgres"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUserRepository_Version tests that concurrent updates of the same version are applied once
+func TestUserRepository_Version(t *testing.T) {
+	// Skip if not running integration tests
+	if os.Getenv("INTEGRATION_TESTS") != "true" {
+		t.Skip("Skipping integration test. Set INTEGRATION_TESTS=true to run")
+	}
+
+	db := setupRLSDB(t)
+	ctx := context.Background()
+
+	orgRepo := postgres.NewOrganizationRepository(db)
+	userRepo := postgres.NewUserRepository(db)
+
+	org := domain.NewOrganization("Acme", "acme-"+uuid.NewString()[:8], domain.PlanFree)
+	require.NoError(t, orgRepo.Create(ctx, org))
+
+	user := domain.NewUser("version-"+uuid.NewString()[:8]+"@example.com", "Jane", "Doe", "user")
+	user.ID = uuid.NewString()
+	user.TenantID = org.ID
+	require.NoError(t, userRepo.Create(ctx, user))
+	assert.Equal(t, int64(1), user.Version)
+
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM users WHERE id = $1", user.ID)
+		assert.NoError(t, err)
+		_, err = db.Exec("DELETE FROM organizations WHERE id = $1", org.ID)
+		assert.NoError(t, err)
+	})
+
+	// Every writer read version 1; only one of them wins
+	const writers = 5
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stale := *user
+			stale.FirstName = uuid.NewString()[:8]
+			errs <- userRepo.Update(ctx, &stale)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	applied := 0
+	for err := range errs {
+		if err == nil {
+			applied++
+			continue
+		}
+		assert.ErrorIs(t, err, domain.ErrVersionMismatch)
+	}
+	assert.Equal(t, 1, applied)
+
+	stored, err := userRepo.GetByID(ctx, org.ID, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stored.Version)
+
+	assert.ErrorIs(t, userRepo.Delete(ctx, org.ID, user.ID, 1), domain.ErrVersionMismatch)
+	assert.ErrorIs(t, userRepo.Delete(ctx, org.ID, uuid.NewString(), 1), domain.ErrUserNotFound)
+	assert.NoError(t, userRepo.Delete(ctx, org.ID, user.ID, stored.Version))
+}
@@ -0,0 +1,90 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/idempotency"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIdempotencyStore tests that keys are reserved once, completed, released and taken over once expired
+func TestIdempotencyStore(t *testing.T) {
+	// Skip if not running integration tests
+	if os.Getenv("INTEGRATION_TESTS") != "true" {
+		t.Skip("Skipping integration test. Set INTEGRATION_TESTS=true to run")
+	}
+
+	db := setupRLSDB(t)
+	ctx := context.Background()
+	store := idempotency.NewPostgresStore(db)
+
+	key := uuid.NewString()
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM idempotency_keys WHERE key = $1", key)
+		assert.NoError(t, err)
+	})
+
+	record := &ports.IdempotencyRecord{Key: key, RequestHash: "hash", Token: "first", ExpiresAt: time.Now().Add(time.Hour)}
+	existing, err := store.Reserve(ctx, record)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	existing, err = store.Reserve(ctx, &ports.IdempotencyRecord{Key: key, RequestHash: "other", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.False(t, existing.Completed)
+
+	record.Completed = true
+	record.StatusCode = http.StatusCreated
+	record.Header = map[string]string{"Content-Type": "application/json"}
+	record.Body = []byte(`{"id":"1"}`)
+	require.NoError(t, store.Complete(ctx, record))
+
+	// Completed keys are not released
+	require.NoError(t, store.Release(ctx, record))
+	existing, err = store.Reserve(ctx, &ports.IdempotencyRecord{Key: key, RequestHash: "hash", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, http.StatusCreated, existing.StatusCode)
+	assert.Equal(t, "application/json", existing.Header["Content-Type"])
+	assert.Equal(t, `{"id":"1"}`, string(existing.Body))
+
+	// Expired keys are taken over
+	_, err = db.Exec("UPDATE idempotency_keys SET expires_at = now() - interval '1 minute' WHERE key = $1", key)
+	require.NoError(t, err)
+	existing, err = store.Reserve(ctx, &ports.IdempotencyRecord{Key: key, RequestHash: "new", Token: "second", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// So are the reservations whose lease ran out, left by a request that
+	// crashed before it could release them
+	crashed := &ports.IdempotencyRecord{Key: uuid.NewString(), RequestHash: "hash", Token: "first", ExpiresAt: time.Now().Add(-time.Minute)}
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM idempotency_keys WHERE key = $1", crashed.Key)
+		assert.NoError(t, err)
+	})
+	existing, err = store.Reserve(ctx, crashed)
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+	existing, err = store.Reserve(ctx, &ports.IdempotencyRecord{Key: crashed.Key, RequestHash: "hash", Token: "second", ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// The request that lost its reservation can neither complete nor
+	// release it
+	crashed.Completed, crashed.StatusCode = true, http.StatusCreated
+	assert.ErrorIs(t, store.Complete(ctx, crashed), idempotency.ErrReservationLost)
+	require.NoError(t, store.Release(ctx, crashed))
+	existing, err = store.Reserve(ctx, &ports.IdempotencyRecord{Key: crashed.Key, RequestHash: "hash", Token: "third", ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, "second", existing.Token)
+	assert.False(t, existing.Completed)
+}
@@ -0,0 +1,76 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/postgres"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUserRepository_ListPagination tests the filters and keyset pagination of the Postgres repository
+func TestUserRepository_ListPagination(t *testing.T) {
+	// Skip if not running integration tests
+	if os.Getenv("INTEGRATION_TESTS") != "true" {
+		t.Skip("Skipping integration test. Set INTEGRATION_TESTS=true to run")
+	}
+
+	db := setupRLSDB(t)
+	ctx := context.Background()
+
+	orgRepo := postgres.NewOrganizationRepository(db)
+	userRepo := postgres.NewUserRepository(db)
+
+	org := domain.NewOrganization("Acme", "acme-"+uuid.NewString()[:8], domain.PlanFree)
+	require.NoError(t, orgRepo.Create(ctx, org))
+
+	epoch := time.Now().Truncate(time.Second)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		user := domain.NewUser(fmt.Sprintf("user%d-%s@example.com", i, org.Slug), "User", "Test", "user")
+		user.ID = uuid.NewString()
+		user.TenantID = org.ID
+		// Two users share a creation time to exercise the ID tie-breaker
+		user.CreatedAt = epoch.Add(time.Duration(i/2) * time.Second)
+		require.NoError(t, userRepo.Create(ctx, user))
+		ids = append(ids, user.ID)
+	}
+
+	t.Cleanup(func() {
+		for _, id := range ids {
+			assert.NoError(t, userRepo.Delete(ctx, org.ID, id, 1))
+		}
+		_, err := db.Exec("DELETE FROM organizations WHERE id = $1", org.ID)
+		assert.NoError(t, err)
+	})
+
+	opts := domain.UserListOptions{SortBy: domain.UserSortByCreatedAt, Limit: 2}
+	var seen []string
+	for {
+		page, err := userRepo.List(ctx, org.ID, opts)
+		require.NoError(t, err)
+		for _, user := range page.Users {
+			seen = append(seen, user.ID)
+		}
+		if page.Next == nil {
+			break
+		}
+		opts.After = page.Next
+	}
+	assert.ElementsMatch(t, ids, seen)
+	assert.Len(t, seen, len(ids))
+
+	page, err := userRepo.List(ctx, org.ID, domain.UserListOptions{
+		Filter: domain.UserFilter{Search: "USER0"},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, ids[0], page.Users[0].ID)
+}
@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/database"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// latestMigration returns the number of migrations embedded in the binary
+func latestMigration(t *testing.T) uint {
+	ups, err := fs.Glob(migrations.FS, "*.up.sql")
+	require.NoError(t, err)
+	downs, err := fs.Glob(migrations.FS, "*.down.sql")
+	require.NoError(t, err)
+	require.Len(t, downs, len(ups), "every migration needs a down migration")
+	return uint(len(ups))
+}
+
+// TestMigrations tests that the schema can be migrated down and up again
+func TestMigrations(t *testing.T) {
+	// Skip if not running integration tests
+	if os.Getenv("INTEGRATION_TESTS") != "true" {
+		t.Skip("Skipping integration test. Set INTEGRATION_TESTS=true to run")
+	}
+
+	db := setupRLSDB(t)
+	latest := latestMigration(t)
+
+	migrator, err := database.NewMigrator(db)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	version, dirty, err := migrator.Version()
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+	assert.False(t, dirty)
+
+	require.NoError(t, migrator.Down(1))
+	version, _, err = migrator.Version()
+	require.NoError(t, err)
+	assert.Equal(t, latest-1, version)
+
+	require.NoError(t, migrator.Up())
+	version, _, err = migrator.Version()
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+
+	// The migrator leaves the database usable
+	require.NoError(t, db.Ping())
+}
+
+// TestMigrations_ConcurrentReplicas tests that replicas starting together do not race
+func TestMigrations_ConcurrentReplicas(t *testing.T) {
+	// Skip if not running integration tests
+	if os.Getenv("INTEGRATION_TESTS") != "true" {
+		t.Skip("Skipping integration test. Set INTEGRATION_TESTS=true to run")
+	}
+
+	db := setupRLSDB(t)
+
+	migrator, err := database.NewMigrator(db)
+	require.NoError(t, err)
+	require.NoError(t, migrator.Down(1))
+	require.NoError(t, migrator.Close())
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = database.RunMigrations(db)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,96 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/postgres"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOutboxRepository tests that events are written with their change and claimed once
+func TestOutboxRepository(t *testing.T) {
+	// Skip if not running integration tests
+	if os.Getenv("INTEGRATION_TESTS") != "true" {
+		t.Skip("Skipping integration test. Set INTEGRATION_TESTS=true to run")
+	}
+
+	db := setupRLSDB(t)
+	ctx := context.Background()
+
+	orgRepo := postgres.NewOrganizationRepository(db)
+	userRepo := postgres.NewUserRepository(db)
+	outboxRepo := postgres.NewOutboxRepository(db)
+
+	org := domain.NewOrganization("Acme", "acme-"+uuid.NewString()[:8], domain.PlanFree)
+	require.NoError(t, orgRepo.Create(ctx, org))
+
+	user := domain.NewUser("outbox-"+uuid.NewString()[:8]+"@example.com", "Jane", "Doe", "user")
+	user.ID = uuid.NewString()
+	user.TenantID = org.ID
+	created := domain.NewUserCreatedEvent(user)
+	require.NoError(t, userRepo.Create(ctx, user, created))
+
+	// A failed change rolls its event back
+	missing := *user
+	missing.ID = uuid.NewString()
+	lost := domain.NewUserUpdatedEvent(&missing)
+	assert.ErrorIs(t, userRepo.Update(ctx, &missing, lost), domain.ErrUserNotFound)
+
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM outbox_events WHERE aggregate_id IN ($1, $2)", user.ID, missing.ID)
+		assert.NoError(t, err)
+		assert.NoError(t, userRepo.Delete(ctx, org.ID, user.ID, user.Version))
+		_, err = db.Exec("DELETE FROM organizations WHERE id = $1", org.ID)
+		assert.NoError(t, err)
+	})
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM outbox_events WHERE id = $1", lost.ID).Scan(&count))
+	assert.Zero(t, count)
+
+	// Events of earlier runs may be due as well
+	now := time.Now().Add(time.Second)
+	claimed := func(at time.Time) bool {
+		entries, err := outboxRepo.Claim(ctx, at, at.Add(time.Minute), 1000)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			if entry.Event.ID == created.ID {
+				assert.Equal(t, domain.UserCreatedEvent, entry.Event.Type)
+				assert.Equal(t, org.ID, entry.Event.TenantID)
+				assert.JSONEq(t, string(created.Data), string(entry.Event.Data))
+				return true
+			}
+		}
+		return false
+	}
+
+	require.True(t, claimed(now))
+	// The lease hides the event from other relays until it expires
+	assert.False(t, claimed(now))
+
+	require.NoError(t, outboxRepo.MarkFailed(ctx, created.ID, "pubsub unavailable", now.Add(time.Second)))
+	assert.False(t, claimed(now))
+	require.True(t, claimed(now.Add(time.Second)))
+
+	require.NoError(t, outboxRepo.MarkPublished(ctx, created.ID))
+	assert.False(t, claimed(now.Add(time.Hour)))
+
+	// Published events are purged once their retention period is over
+	stored := func() bool {
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM outbox_events WHERE id = $1", created.ID).Scan(&count))
+		return count == 1
+	}
+	_, err := outboxRepo.PurgePublished(ctx, time.Now().Add(-time.Hour), 1000)
+	require.NoError(t, err)
+	assert.True(t, stored())
+	purged, err := outboxRepo.PurgePublished(ctx, time.Now().Add(time.Minute), 1000)
+	require.NoError(t, err)
+	assert.Positive(t, purged)
+	assert.False(t, stored())
+}
@@ -0,0 +1,142 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/postgres"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	infraconfig "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/config"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRLSDB connects to the test database and applies the migrations of the service
+func setupRLSDB(t *testing.T) *sql.DB {
+	db, err := database.NewPostgresDB(infraconfig.DatabaseConfig{
+		Host:     getEnvOrDefault("TEST_DB_HOST", "localhost"),
+		Port:     getEnvOrDefault("TEST_DB_PORT", "5432"),
+		User:     getEnvOrDefault("TEST_DB_USER", "user_manager"),
+		Password: getEnvOrDefault("TEST_DB_PASSWORD", "password"),
+		DBName:   getEnvOrDefault("TEST_DB_NAME", "user_db_test"),
+		SSLMode:  "disable",
+	})
+	require.NoError(t, err, "Failed to connect to test database")
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, database.RunMigrations(db), "Failed to run migrations")
+
+	return db
+}
+
+// TestRowLevelSecurity_Users tests that Postgres itself hides the users of other tenants
+func TestRowLevelSecurity_Users(t *testing.T) {
+	// Skip if not running integration tests
+	if os.Getenv("INTEGRATION_TESTS") != "true" {
+		t.Skip("Skipping integration test. Set INTEGRATION_TESTS=true to run")
+	}
+
+	db := setupRLSDB(t)
+	ctx := context.Background()
+
+	orgRepo := postgres.NewOrganizationRepository(db)
+	userRepo := postgres.NewUserRepository(db)
+
+	acme := domain.NewOrganization("Acme", "acme-"+uuid.NewString()[:8], domain.PlanFree)
+	globex := domain.NewOrganization("Globex", "globex-"+uuid.NewString()[:8], domain.PlanFree)
+	require.NoError(t, orgRepo.Create(ctx, acme))
+	require.NoError(t, orgRepo.Create(ctx, globex))
+
+	user := domain.NewUser("rls-"+uuid.NewString()[:8]+"@example.com", "Jane", "Doe", "user")
+	user.TenantID = acme.ID
+	require.NoError(t, userRepo.Create(ctx, user))
+
+	t.Cleanup(func() {
+		assert.NoError(t, userRepo.Delete(ctx, acme.ID, user.ID, user.Version))
+		_, err := db.Exec("DELETE FROM organizations WHERE id IN ($1, $2)", acme.ID, globex.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("QueryWithoutTenantSettingReturnsNothing", func(t *testing.T) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		_, err = tx.Exec("SET LOCAL ROLE user_manager_tenant")
+		require.NoError(t, err)
+
+		var count int
+		require.NoError(t, tx.QueryRow("SELECT COUNT(*) FROM users").Scan(&count))
+		assert.Zero(t, count)
+	})
+
+	t.Run("QueryForgettingTenantFilterStaysInTenant", func(t *testing.T) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		_, err = tx.Exec("SET LOCAL ROLE user_manager_tenant")
+		require.NoError(t, err)
+		_, err = tx.Exec("SELECT set_config('app.tenant_id', $1, true)", globex.ID)
+		require.NoError(t, err)
+
+		var count int
+		require.NoError(t, tx.QueryRow("SELECT COUNT(*) FROM users WHERE id = $1", user.ID).Scan(&count))
+		assert.Zero(t, count)
+	})
+
+	t.Run("MembershipsStayInTenant", func(t *testing.T) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		_, err = tx.Exec("SET LOCAL ROLE user_manager_tenant")
+		require.NoError(t, err)
+		_, err = tx.Exec("SELECT set_config('app.tenant_id', $1, true)", globex.ID)
+		require.NoError(t, err)
+
+		var count int
+		require.NoError(t, tx.QueryRow("SELECT COUNT(*) FROM memberships WHERE user_id = $1", user.ID).Scan(&count))
+		assert.Zero(t, count)
+
+		_, err = tx.Exec("INSERT INTO memberships (organization_id, user_id, role, created_at, updated_at) VALUES ($1, $2, 'admin', now(), now())", acme.ID, user.ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("OwnTenant", func(t *testing.T) {
+		found, err := userRepo.GetByID(ctx, acme.ID, user.ID)
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, acme.ID, found.TenantID)
+		assert.Equal(t, "user", found.Role)
+
+		// The role is the one of the membership in the tenant
+		found.Role = domain.RoleAdmin
+		require.NoError(t, userRepo.Update(ctx, found))
+		user.Version = found.Version
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+		_, err = tx.Exec("SET LOCAL ROLE user_manager_tenant")
+		require.NoError(t, err)
+		_, err = tx.Exec("SELECT set_config('app.tenant_id', $1, true)", acme.ID)
+		require.NoError(t, err)
+		var role string
+		require.NoError(t, tx.QueryRow("SELECT role FROM memberships WHERE user_id = $1", user.ID).Scan(&role))
+		assert.Equal(t, domain.RoleAdmin, role)
+	})
+
+	t.Run("OtherTenant", func(t *testing.T) {
+		found, err := userRepo.GetByID(ctx, globex.ID, user.ID)
+		require.NoError(t, err)
+		assert.Nil(t, found)
+
+		page, err := userRepo.List(ctx, globex.ID, domain.UserListOptions{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, page.Users)
+	})
+}
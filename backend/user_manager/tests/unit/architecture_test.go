@@ -0,0 +1,97 @@
+package unit
+
+import (
+	"go/build"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// modulePath is the import path of the service module, found at moduleDir
+const (
+	modulePath = "github.com/b-fontaine/saaster_kit/backend/user_manager"
+	moduleDir  = "../.."
+)
+
+// forbiddenDomainImports are the packages the domain must not depend on
+var forbiddenDomainImports = []string{
+	modulePath + "/internal/adapters",
+	modulePath + "/internal/infrastructure",
+}
+
+// TestArchitecture_DomainIsolation tests that the packages under
+// internal/domain depend on neither the adapters nor the infrastructure,
+// directly or through other packages of the module
+func TestArchitecture_DomainIsolation(t *testing.T) {
+	var domainPackages []string
+	err := filepath.WalkDir(filepath.Join(moduleDir, "internal", "domain"), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(moduleDir, path)
+		if err != nil {
+			return err
+		}
+		domainPackages = append(domainPackages, modulePath+"/"+filepath.ToSlash(rel))
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, domainPackages)
+
+	imports := moduleImports(t)
+	for _, pkg := range domainPackages {
+		if chain := forbiddenImportChain(imports, pkg, map[string]bool{}); chain != nil {
+			assert.Failf(t, "domain package depends on an outer layer", "%s", strings.Join(chain, "\n  -> "))
+		}
+	}
+}
+
+// moduleImports returns the imports of the packages of the module that are
+// in the module too, by package, leaving the tests out
+func moduleImports(t *testing.T) func(pkg string) []string {
+	cache := map[string][]string{}
+	return func(pkg string) []string {
+		if imports, ok := cache[pkg]; ok {
+			return imports
+		}
+		dir := filepath.Join(moduleDir, filepath.FromSlash(strings.TrimPrefix(pkg, modulePath)))
+		buildPkg, err := build.ImportDir(dir, 0)
+		if _, ok := err.(*build.NoGoError); err != nil && !ok {
+			require.NoError(t, err, "Failed to read package %s", pkg)
+		}
+
+		var imports []string
+		for _, imported := range buildPkg.Imports {
+			if strings.HasPrefix(imported, modulePath+"/") {
+				imports = append(imports, imported)
+			}
+		}
+		cache[pkg] = imports
+		return imports
+	}
+}
+
+// forbiddenImportChain returns the chain of imports leading from pkg to a
+// forbidden package, if any
+func forbiddenImportChain(imports func(pkg string) []string, pkg string, visited map[string]bool) []string {
+	for _, forbidden := range forbiddenDomainImports {
+		if pkg == forbidden || strings.HasPrefix(pkg, forbidden+"/") {
+			return []string{pkg}
+		}
+	}
+	if visited[pkg] {
+		return nil
+	}
+	visited[pkg] = true
+
+	for _, imported := range imports(pkg) {
+		if chain := forbiddenImportChain(imports, imported, visited); chain != nil {
+			return append([]string{pkg}, chain...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package unit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "http://keycloak.test/realms/saaster"
+	testAudience = "user-manager"
+)
+
+// jwksStandIn is a local stand-in for the Keycloak certs endpoint
+type jwksStandIn struct {
+	mutex    sync.Mutex
+	keys     map[string]*rsa.PrivateKey
+	requests int
+	server   *httptest.Server
+}
+
+func newJWKSStandIn(t *testing.T) *jwksStandIn {
+	s := &jwksStandIn{keys: make(map[string]*rsa.PrivateKey)}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.requests++
+
+		keys := make([]map[string]string, 0, len(s.keys))
+		for kid, key := range s.keys {
+			keys = append(keys, map[string]string{
+				"kid": kid,
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *jwksStandIn) addKey(t *testing.T, kid string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[kid] = key
+	return key
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   testIssuer,
+		"aud":   []string{testAudience, "account"},
+		"sub":   "9b2c7f0e-5f3a-4c57-9f4e-2b0d4c7c1a11",
+		"iat":   now.Unix(),
+		"nbf":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"email": "jane@example.com",
+		"realm_access": map[string]interface{}{
+			"roles": []string{"admin", "offline_access"},
+		},
+		"resource_access": map[string]interface{}{
+			"user-manager": map[string]interface{}{"roles": []string{"users:read"}},
+		},
+	}
+}
+
+func newTestKeycloakAuth(jwks *jwksStandIn) *auth.KeycloakAuth {
+	return auth.NewKeycloakAuth(auth.Config{
+		IssuerURL: testIssuer,
+		JWKSURL:   jwks.server.URL,
+		Audience:  testAudience,
+		ClientID:  "user-manager",
+	})
+}
+
+// TestKeycloakAuth_ValidateToken tests token validation against a JWKS stand-in
+func TestKeycloakAuth_ValidateToken(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	key := jwks.addKey(t, "key-1")
+	keycloakAuth := newTestKeycloakAuth(jwks)
+
+	t.Run("ValidToken", func(t *testing.T) {
+		claims, err := keycloakAuth.ValidateToken(context.Background(), signToken(t, key, "key-1", validClaims()))
+		require.NoError(t, err)
+		assert.Equal(t, "9b2c7f0e-5f3a-4c57-9f4e-2b0d4c7c1a11", claims.Subject)
+		assert.Equal(t, "jane@example.com", claims.Email)
+		assert.True(t, claims.HasRealmRole("admin"))
+		assert.Equal(t, []string{"users:read"}, claims.ClientRoles("user-manager"))
+	})
+
+	invalid := map[string]func(jwt.MapClaims){
+		"Expired":       func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"NotYetValid":   func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"WrongIssuer":   func(c jwt.MapClaims) { c["iss"] = "http://evil.test/realms/saaster" },
+		"WrongAudience": func(c jwt.MapClaims) { c["aud"] = "another-client" },
+		"MissingExpiry": func(c jwt.MapClaims) { delete(c, "exp") },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			claims := validClaims()
+			mutate(claims)
+			_, err := keycloakAuth.ValidateToken(context.Background(), signToken(t, key, "key-1", claims))
+			assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		})
+	}
+
+	t.Run("ForeignSignature", func(t *testing.T) {
+		foreignKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		_, err = keycloakAuth.ValidateToken(context.Background(), signToken(t, foreignKey, "key-1", validClaims()))
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("UnsignedToken", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+		_, err = keycloakAuth.ValidateToken(context.Background(), signed)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
+
+// TestKeycloakAuth_KeyRotation tests that an unknown key ID triggers a JWKS refresh
+func TestKeycloakAuth_KeyRotation(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	oldKey := jwks.addKey(t, "key-1")
+	keycloakAuth := newTestKeycloakAuth(jwks)
+
+	_, err := keycloakAuth.ValidateToken(context.Background(), signToken(t, oldKey, "key-1", validClaims()))
+	require.NoError(t, err)
+
+	// Validating again is served from the cache
+	_, err = keycloakAuth.ValidateToken(context.Background(), signToken(t, oldKey, "key-1", validClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, 1, jwks.requests)
+
+	newKey := jwks.addKey(t, "key-2")
+	_, err = keycloakAuth.ValidateToken(context.Background(), signToken(t, newKey, "key-2", validClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, 2, jwks.requests)
+}
+
+// TestKeycloakAuth_TokenValidationMiddleware tests that the middleware exposes the claims
+func TestKeycloakAuth_TokenValidationMiddleware(t *testing.T) {
+	jwks := newJWKSStandIn(t)
+	key := jwks.addKey(t, "key-1")
+	keycloakAuth := newTestKeycloakAuth(jwks)
+
+	var received *auth.Claims
+	handler := keycloakAuth.TokenValidationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = auth.ClaimsFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("MissingHeader", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		req.Header.Set("Authorization", "Bearer not-a-jwt")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("ValidToken", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		req.Header.Set("Authorization", "Bearer "+signToken(t, key, "key-1", validClaims()))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotNil(t, received)
+		assert.Equal(t, "jane@example.com", received.Email)
+		assert.Equal(t, []string{"admin", "offline_access"}, received.RealmRoles())
+	})
+}
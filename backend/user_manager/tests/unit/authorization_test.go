@@ -0,0 +1,188 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthorizedRouter returns a router serving the user API to a caller with the given claims
+func newAuthorizedRouter(t *testing.T, claims *auth.Claims) (*mux.Router, *di.Container) {
+	container := di.NewContainer(nil, true, nil)
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if claims != nil {
+				r = r.WithContext(auth.WithClaims(r.Context(), claims))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	container.UserHandler.RegisterRoutes(api)
+	container.OrganizationHandler.RegisterRoutes(api)
+	return router, container
+}
+
+func callerClaims(subject string, realmRoles ...string) *auth.Claims {
+	return &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+		RealmAccess:      auth.RoleClaim{Roles: realmRoles},
+		TenantID:         tenantID,
+	}
+}
+
+func seedUser(t *testing.T, container *di.Container, id, email, role string) {
+	seedTenantUser(t, container, tenantID, id, email, role)
+}
+
+func seedTenantUser(t *testing.T, container *di.Container, tenant, id, email, role string) {
+	seedAccount(t, container, tenant, id, "", email, role)
+}
+
+// seedLinkedUser seeds a user linked to the identity provider account subject
+func seedLinkedUser(t *testing.T, container *di.Container, id, subject, email, role string) {
+	seedAccount(t, container, tenantID, id, subject, email, role)
+}
+
+func seedAccount(t *testing.T, container *di.Container, tenant, id, subject, email, role string) {
+	user := domain.NewUser(email, "Test", "User", role)
+	user.ID = id
+	user.TenantID = tenant
+	user.ExternalID = subject
+	require.NoError(t, container.UserRepository.Create(context.Background(), user))
+}
+
+func serve(router http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+const (
+	selfID  = "11111111-1111-1111-1111-111111111111"
+	otherID = "22222222-2222-2222-2222-222222222222"
+
+	// selfSubject and otherSubject are the identity provider accounts of the
+	// users, distinct from their IDs
+	selfSubject  = "33333333-3333-3333-3333-333333333333"
+	otherSubject = "44444444-4444-4444-4444-444444444444"
+
+	tenantID      = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	otherTenantID = "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+)
+
+// TestAuthorization_Admin tests that admins can use every route
+func TestAuthorization_Admin(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedUser(t, container, otherID, "other@example.com", "user")
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/users", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/users/"+otherID, nil).Code)
+	assert.Equal(t, http.StatusNoContent, serve(router, http.MethodDelete, "/api/v1/users/"+otherID, nil).Code)
+}
+
+// TestAuthorization_RegularUser tests that regular users are limited to their own record
+func TestAuthorization_RegularUser(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfSubject, "user"))
+	seedLinkedUser(t, container, selfID, selfSubject, "self@example.com", "user")
+	seedUser(t, container, otherID, "other@example.com", "user")
+
+	t.Run("ReadSelf", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/users/"+selfID, nil).Code)
+	})
+
+	t.Run("UpdateSelf", func(t *testing.T) {
+		rec := serve(router, http.MethodPut, "/api/v1/users/"+selfID, map[string]string{
+			"email": "self@example.com", "first_name": "New", "last_name": "Name", "role": "user",
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("PromoteSelf", func(t *testing.T) {
+		rec := serve(router, http.MethodPut, "/api/v1/users/"+selfID, map[string]string{
+			"email": "self@example.com", "first_name": "New", "last_name": "Name", "role": "admin",
+		})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("ReadOther", func(t *testing.T) {
+		rec := serve(router, http.MethodGet, "/api/v1/users/"+otherID, nil)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+
+		var body auth.ForbiddenResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		assert.Equal(t, http.StatusForbidden, body.Status)
+		assert.Equal(t, "Forbidden", body.Title)
+		assert.Equal(t, "/api/v1/users/"+otherID, body.Instance)
+		assert.Equal(t, auth.PermissionUsersRead, body.RequiredPermission)
+	})
+
+	t.Run("ReadUnlinked", func(t *testing.T) {
+		// The user whose ID is the token subject is not the caller
+		seedUser(t, container, selfSubject, "subject@example.com", "user")
+		assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, "/api/v1/users/"+selfSubject, nil).Code)
+		assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, "/api/v1/users/not-a-uuid", nil).Code)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, "/api/v1/users", nil).Code)
+	})
+
+	t.Run("DeleteSelf", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(router, http.MethodDelete, "/api/v1/users/"+selfID, nil).Code)
+	})
+}
+
+// TestAuthorization_ClientRoles tests that client roles grant the permission they are named after
+func TestAuthorization_ClientRoles(t *testing.T) {
+	claims := callerClaims(selfSubject)
+	claims.ResourceAccess = map[string]auth.RoleClaim{
+		auth.DefaultClientID: {Roles: []string{string(auth.PermissionUsersRead)}},
+	}
+	router, _ := newAuthorizedRouter(t, claims)
+
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, "/api/v1/users", nil).Code)
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodPost, "/api/v1/users", map[string]string{
+		"email": "new@example.com", "first_name": "New", "last_name": "User", "role": "user",
+	}).Code)
+
+	t.Run("ConfiguredClient", func(t *testing.T) {
+		claims := callerClaims(selfSubject)
+		claims.ResourceAccess = map[string]auth.RoleClaim{
+			"saaster-api":        {Roles: []string{string(auth.PermissionUsersRead)}},
+			auth.DefaultClientID: {Roles: []string{string(auth.PermissionUsersWrite)}},
+		}
+		container := di.NewContainer(nil, true, nil, di.WithClientID("saaster-api"))
+		policy := container.Authorizer.Policy()
+
+		assert.True(t, policy.HasPermission(claims, auth.PermissionUsersRead))
+		assert.False(t, policy.HasPermission(claims, auth.PermissionUsersWrite))
+	})
+}
+
+// TestAuthorization_Unauthenticated tests that requests without claims are rejected
+func TestAuthorization_Unauthenticated(t *testing.T) {
+	router, _ := newAuthorizedRouter(t, nil)
+
+	assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet, "/api/v1/users", nil).Code)
+}
@@ -0,0 +1,129 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveIfMatch sends a request with an If-Match header, omitted when empty
+func serveIfMatch(router http.Handler, method, path, ifMatch string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func renameBody(firstName string) map[string]string {
+	return map[string]string{"email": "user@example.com", "first_name": firstName, "last_name": "User", "role": "user"}
+}
+
+// TestConcurrency_ETag tests that users are served with the ETag of their version
+func TestConcurrency_ETag(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedUser(t, container, otherID, "user@example.com", "user")
+
+	rec := serve(router, http.MethodGet, "/api/v1/users/"+otherID, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+
+	var user handlers.UserResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+	assert.Equal(t, int64(1), user.Version)
+
+	rec = serveIfMatch(router, http.MethodPut, "/api/v1/users/"+otherID, `"1"`, renameBody("Jane"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+
+	rec = serve(router, http.MethodPost, "/api/v1/users/"+otherID+"/deactivate", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+}
+
+// TestConcurrency_IfMatch tests that updates and deletions based on a stale version are rejected
+func TestConcurrency_IfMatch(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedUser(t, container, otherID, "user@example.com", "user")
+	path := "/api/v1/users/" + otherID
+
+	// Both admins read version 1; the second update would overwrite the first
+	rec := serveIfMatch(router, http.MethodPut, path, `"1"`, renameBody("Jane"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = serveIfMatch(router, http.MethodPut, path, `"1"`, renameBody("Janet"))
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	rec = serveIfMatch(router, http.MethodDelete, path, `"1"`, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+	for _, ifMatch := range []string{`W/"2"`, "2", `"abc"`} {
+		rec = serveIfMatch(router, http.MethodPut, path, ifMatch, renameBody("Janet"))
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code, ifMatch)
+	}
+
+	user, err := container.UserRepository.GetByID(context.Background(), tenantID, otherID)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", user.FirstName)
+
+	rec = serveIfMatch(router, http.MethodPut, path, "*", renameBody("Janet"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = serveIfMatch(router, http.MethodDelete, path, `"3"`, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+// TestConcurrency_RequireIfMatch tests that If-Match can be made mandatory
+func TestConcurrency_RequireIfMatch(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedUser(t, container, otherID, "user@example.com", "user")
+	path := "/api/v1/users/" + otherID
+
+	rec := serve(router, http.MethodPut, path, renameBody("Jane"))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	container.UserHandler.RequireIfMatch(true)
+	rec = serve(router, http.MethodPut, path, renameBody("Janet"))
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+	rec = serve(router, http.MethodDelete, path, nil)
+	assert.Equal(t, http.StatusPreconditionRequired, rec.Code)
+
+	rec = serveIfMatch(router, http.MethodDelete, path, `"2"`, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+// TestConcurrency_Repository tests that the repository only applies changes made to the current version
+func TestConcurrency_Repository(t *testing.T) {
+	_, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedUser(t, container, otherID, "user@example.com", "user")
+	ctx := context.Background()
+
+	first, err := container.UserRepository.GetByID(ctx, tenantID, otherID)
+	require.NoError(t, err)
+	second, err := container.UserRepository.GetByID(ctx, tenantID, otherID)
+	require.NoError(t, err)
+
+	first.FirstName = "Jane"
+	require.NoError(t, container.UserRepository.Update(ctx, first))
+	assert.Equal(t, int64(2), first.Version)
+
+	second.FirstName = "Janet"
+	assert.ErrorIs(t, container.UserRepository.Update(ctx, second), domain.ErrVersionMismatch)
+	assert.ErrorIs(t, container.UserRepository.Delete(ctx, tenantID, otherID, second.Version), domain.ErrVersionMismatch)
+	assert.NoError(t, container.UserRepository.Delete(ctx, tenantID, otherID, first.Version))
+}
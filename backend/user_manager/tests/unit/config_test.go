@@ -0,0 +1,53 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConfig_Load tests that the file named by CONFIG_FILE is applied over
+// the defaults and the environment over the file
+func TestConfig_Load(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+database:
+  host: user_db
+  password: from-file
+outbox:
+  batchSize: 50
+`), 0o600))
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("OUTBOX_BATCH_SIZE", "20")
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "user_db", cfg.Database.Host)
+	assert.Equal(t, "from-file", cfg.Database.Password)
+	assert.Equal(t, 20, cfg.Outbox.BatchSize)
+	assert.Equal(t, 5*time.Minute, cfg.Outbox.MaxBackoff)
+	assert.Nil(t, cfg.SecretStore())
+	// Existing clients are not required to send If-Match until opted in
+	assert.False(t, cfg.Server.RequireIfMatch)
+}
+
+// TestConfig_Validate tests that every invalid field is reported at once
+func TestConfig_Validate(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_PASSWORD", "")
+	t.Setenv("OUTBOX_MIN_BACKOFF", "10m")
+	t.Setenv("IDEMPOTENCY_STORE", "redis")
+	t.Setenv("OTEL_TRACES_SAMPLE_RATIO", "2")
+
+	_, err := config.Load()
+	assert.EqualError(t, err, `invalid configuration:
+  - database.password (DB_PASSWORD): is required
+  - idempotency.store (IDEMPOTENCY_STORE): "redis" is not one of postgres, dapr
+  - outbox.minBackoff (OUTBOX_MIN_BACKOFF): must not exceed outbox.maxBackoff (OUTBOX_MAX_BACKOFF)
+  - tracing.sampleRatio (OTEL_TRACES_SAMPLE_RATIO): must be between 0 and 1`)
+}
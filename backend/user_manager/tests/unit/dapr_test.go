@@ -0,0 +1,59 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/middleware"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDaprRouter returns a router serving the Dapr routes behind the app token check
+func newDaprRouter(token string, subscriptions ...handlers.DaprSubscription) *mux.Router {
+	router := mux.NewRouter()
+	dapr := router.PathPrefix("/dapr").Subrouter()
+	dapr.Use(middleware.DaprAppToken(token))
+	handlers.NewDaprHandler(subscriptions...).RegisterRoutes(dapr)
+	return router
+}
+
+// TestDapr_Subscribe tests that the sidecar reads the subscriptions of the service
+func TestDapr_Subscribe(t *testing.T) {
+	rec := serve(newDaprRouter(""), http.MethodGet, "/dapr/subscribe", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[]`, rec.Body.String())
+
+	subscription := handlers.DaprSubscription{PubSubName: "pubsub", Topic: "organization-created", Route: "/dapr/events/organization-created"}
+	rec = serve(newDaprRouter("", subscription), http.MethodGet, "/dapr/subscribe", nil)
+	var subscriptions []handlers.DaprSubscription
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&subscriptions))
+	assert.Equal(t, []handlers.DaprSubscription{subscription}, subscriptions)
+}
+
+// TestDapr_AppToken tests that only the sidecar, sending the app API token,
+// may call the Dapr routes
+func TestDapr_AppToken(t *testing.T) {
+	router := newDaprRouter("app-token")
+
+	rec := serve(router, http.MethodGet, "/dapr/subscribe", nil)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+	req := httptest.NewRequest(http.MethodGet, "/dapr/subscribe", nil)
+	req.Header.Set(middleware.DaprAPITokenHeader, "other-token")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/dapr/subscribe", nil)
+	req.Header.Set(middleware.DaprAPITokenHeader, "app-token")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
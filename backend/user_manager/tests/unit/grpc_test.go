@@ -0,0 +1,307 @@
+package unit
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	userv1 "github.com/b-fontaine/saaster_kit/backend/user_manager/api/user/v1"
+	grpcadapter "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/grpc"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/config"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/server"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// tokenClaims validates the tokens it maps to claims, and rejects the others
+type tokenClaims map[string]*auth.Claims
+
+func (t tokenClaims) ValidateToken(ctx context.Context, token string) (*auth.Claims, error) {
+	claims, ok := t[token]
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// newGRPCConn serves the gRPC API of a new in-memory container over an
+// in-process connection, authenticating calls with the given tokens
+func newGRPCConn(t *testing.T, tokens tokenClaims) (*grpc.ClientConn, *di.Container) {
+	container := di.NewContainer(nil, true, nil)
+	grpcServer := server.NewGRPCServer(config.GRPCConfig{}, container.UserServer,
+		grpcadapter.Authenticate(tokens, container.AccountStatus))
+
+	listener := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(listener)
+	t.Cleanup(func() { grpcServer.Stop(context.Background()) })
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn, container
+}
+
+// withToken returns a context sending token as a bearer token
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// fieldViolations returns the fields listed in the BadRequest detail of err
+func fieldViolations(err error) []string {
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.GetFieldViolations() {
+				fields = append(fields, violation.GetField())
+			}
+		}
+	}
+	return fields
+}
+
+// TestGRPC_Users tests every call of the UserService
+func TestGRPC_Users(t *testing.T) {
+	conn, container := newGRPCConn(t, tokenClaims{"admin": callerClaims(selfSubject, "admin")})
+	seedOrganization(t, container, tenantID, "acme")
+	seedLinkedUser(t, container, selfID, selfSubject, "self@example.com", "admin")
+	client := userv1.NewUserServiceClient(conn)
+	ctx := withToken("admin")
+
+	created, err := client.CreateUser(ctx, &userv1.CreateUserRequest{
+		Email: "jane@example.com", FirstName: "Jane", LastName: "Doe", Role: "user", ExternalId: otherSubject,
+	})
+	require.NoError(t, err)
+	user := created.GetUser()
+	assert.Equal(t, tenantID, user.GetTenantId())
+	assert.Equal(t, otherSubject, user.GetExternalId())
+	assert.Equal(t, int64(1), user.GetVersion())
+	assert.True(t, user.GetActive())
+
+	_, err = client.CreateUser(ctx, &userv1.CreateUserRequest{
+		Email: "jane@example.com", FirstName: "Jane", LastName: "Doe", Role: "user",
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	got, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: user.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", got.GetUser().GetEmail())
+
+	_, err = client.GetUser(ctx, &userv1.GetUserRequest{Id: otherID})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	batch, err := client.BatchGetUsers(ctx, &userv1.BatchGetUsersRequest{Ids: []string{user.GetId(), otherID, selfID}})
+	require.NoError(t, err)
+	require.Len(t, batch.GetUsers(), 2)
+	assert.Equal(t, user.GetId(), batch.GetUsers()[0].GetId())
+	assert.Equal(t, selfID, batch.GetUsers()[1].GetId())
+	assert.Equal(t, []string{otherID}, batch.GetMissingIds())
+
+	active := true
+	list, err := client.ListUsers(ctx, &userv1.ListUsersRequest{Role: "user", Active: &active, Sort: "email", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, list.GetUsers(), 1)
+	assert.Equal(t, user.GetId(), list.GetUsers()[0].GetId())
+	assert.Empty(t, list.GetNextCursor())
+
+	updated, err := client.UpdateUser(ctx, &userv1.UpdateUserRequest{
+		Id: user.GetId(), Email: "jane@example.com", FirstName: "Janet", LastName: "Doe", Role: "user", Version: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Janet", updated.GetUser().GetFirstName())
+	assert.Equal(t, int64(2), updated.GetUser().GetVersion())
+
+	_, err = client.UpdateUser(ctx, &userv1.UpdateUserRequest{
+		Id: user.GetId(), Email: "jane@example.com", FirstName: "Jenny", LastName: "Doe", Role: "user", Version: 1,
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	deactivated, err := client.DeactivateUser(ctx, &userv1.DeactivateUserRequest{Id: user.GetId()})
+	require.NoError(t, err)
+	assert.False(t, deactivated.GetUser().GetActive())
+
+	activated, err := client.ActivateUser(ctx, &userv1.ActivateUserRequest{Id: user.GetId()})
+	require.NoError(t, err)
+	assert.True(t, activated.GetUser().GetActive())
+
+	_, err = client.DeleteUser(ctx, &userv1.DeleteUserRequest{Id: user.GetId(), Version: 1})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	_, err = client.DeleteUser(ctx, &userv1.DeleteUserRequest{Id: user.GetId(), Version: activated.GetUser().GetVersion()})
+	require.NoError(t, err)
+	_, err = client.GetUser(ctx, &userv1.GetUserRequest{Id: user.GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// TestGRPC_Authentication tests that calls without a valid token are rejected
+func TestGRPC_Authentication(t *testing.T) {
+	conn, container := newGRPCConn(t, tokenClaims{
+		"admin":       callerClaims(selfSubject, "admin"),
+		"deactivated": callerClaims(otherSubject, "admin"),
+		"unknown":     callerClaims(uuid.NewString(), "admin"),
+	})
+	seedLinkedUser(t, container, selfID, selfSubject, "self@example.com", "admin")
+	seedLinkedUser(t, container, otherID, otherSubject, "other@example.com", "admin")
+	client := userv1.NewUserServiceClient(conn)
+
+	_, err := client.DeactivateUser(withToken("admin"), &userv1.DeactivateUserRequest{Id: otherID})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{"MissingToken", context.Background(), codes.Unauthenticated},
+		{"InvalidToken", withToken("forged"), codes.Unauthenticated},
+		{"MalformedMetadata", metadata.AppendToOutgoingContext(context.Background(), "authorization", "admin"), codes.Unauthenticated},
+		{"DeactivatedAccount", withToken("deactivated"), codes.PermissionDenied},
+		{"UnknownAccount", withToken("unknown"), codes.PermissionDenied},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.GetUser(tc.ctx, &userv1.GetUserRequest{Id: selfID})
+			assert.Equal(t, tc.code, status.Code(err))
+		})
+	}
+}
+
+// TestGRPC_Authorization tests that calls are authorized with the permissions of the HTTP routes
+func TestGRPC_Authorization(t *testing.T) {
+	withoutTenant := callerClaims(selfSubject, "admin")
+	withoutTenant.TenantID = ""
+	conn, container := newGRPCConn(t, tokenClaims{
+		"user":           callerClaims(selfSubject, "user"),
+		"reader":         callerClaims(selfSubject, "user-reader"),
+		"without-tenant": withoutTenant,
+	})
+	seedLinkedUser(t, container, selfID, selfSubject, "self@example.com", "user")
+	seedUser(t, container, otherID, "other@example.com", "user")
+	client := userv1.NewUserServiceClient(conn)
+
+	t.Run("SelfAccess", func(t *testing.T) {
+		got, err := client.GetUser(withToken("user"), &userv1.GetUserRequest{Id: selfID})
+		require.NoError(t, err)
+		assert.Equal(t, selfID, got.GetUser().GetId())
+
+		_, err = client.UpdateUser(withToken("user"), &userv1.UpdateUserRequest{
+			Id: selfID, Email: "self@example.com", FirstName: "Self", LastName: "User", Role: "user",
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("SelfRoleChange", func(t *testing.T) {
+		_, err := client.UpdateUser(withToken("user"), &userv1.UpdateUserRequest{
+			Id: selfID, Email: "self@example.com", FirstName: "Self", LastName: "User", Role: "admin",
+		})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("MissingPermission", func(t *testing.T) {
+		_, err := client.GetUser(withToken("user"), &userv1.GetUserRequest{Id: otherID})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		var info *errdetails.ErrorInfo
+		for _, detail := range status.Convert(err).Details() {
+			if errorInfo, ok := detail.(*errdetails.ErrorInfo); ok {
+				info = errorInfo
+			}
+		}
+		require.NotNil(t, info)
+		assert.Equal(t, string(auth.PermissionUsersRead), info.GetMetadata()["required_permission"])
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		_, err := client.BatchGetUsers(withToken("reader"), &userv1.BatchGetUsersRequest{Ids: []string{otherID}})
+		require.NoError(t, err)
+
+		_, err = client.DeleteUser(withToken("reader"), &userv1.DeleteUserRequest{Id: otherID})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("TokenWithoutTenant", func(t *testing.T) {
+		_, err := client.ListUsers(withToken("without-tenant"), &userv1.ListUsersRequest{})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+// TestGRPC_Validation tests that invalid requests list their invalid fields
+func TestGRPC_Validation(t *testing.T) {
+	conn, container := newGRPCConn(t, tokenClaims{"admin": callerClaims(selfSubject, "admin")})
+	seedOrganization(t, container, tenantID, "acme")
+	seedLinkedUser(t, container, selfID, selfSubject, "self@example.com", "admin")
+	client := userv1.NewUserServiceClient(conn)
+
+	_, err := client.CreateUser(withToken("admin"), &userv1.CreateUserRequest{Email: "not-an-email", FirstName: "Jane", Role: "owner"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.ElementsMatch(t, []string{"email", "last_name", "role"}, fieldViolations(err))
+
+	_, err = client.ListUsers(withToken("admin"), &userv1.ListUsersRequest{Sort: "name"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, []string{"sort"}, fieldViolations(err))
+
+	_, err = client.BatchGetUsers(withToken("admin"), &userv1.BatchGetUsersRequest{Ids: make([]string, grpcadapter.MaxBatchGetUsers+1)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, []string{"ids"}, fieldViolations(err))
+}
+
+// TestGRPC_RequireVersion tests that updates and deletions without a version are rejected when versions are required
+func TestGRPC_RequireVersion(t *testing.T) {
+	conn, container := newGRPCConn(t, tokenClaims{"admin": callerClaims(selfSubject, "admin")})
+	container.UserServer.RequireVersion(true)
+	seedLinkedUser(t, container, selfID, selfSubject, "self@example.com", "admin")
+	seedUser(t, container, otherID, "other@example.com", "user")
+	client := userv1.NewUserServiceClient(conn)
+
+	_, err := client.UpdateUser(withToken("admin"), &userv1.UpdateUserRequest{
+		Id: otherID, Email: "other@example.com", FirstName: "Other", LastName: "User", Role: "user",
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.DeleteUser(withToken("admin"), &userv1.DeleteUserRequest{Id: otherID})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.DeleteUser(withToken("admin"), &userv1.DeleteUserRequest{Id: otherID, Version: 1})
+	assert.NoError(t, err)
+}
+
+// TestGRPC_HealthAndReflection tests that the health and reflection services are served without a token
+func TestGRPC_HealthAndReflection(t *testing.T) {
+	conn, _ := newGRPCConn(t, tokenClaims{})
+
+	health, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: userv1.UserService_ServiceDesc.ServiceName,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, health.GetStatus())
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	response, err := stream.Recv()
+	require.NoError(t, err)
+
+	var services []string
+	for _, service := range response.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	assert.Contains(t, services, userv1.UserService_ServiceDesc.ServiceName)
+	assert.Contains(t, services, healthpb.Health_ServiceDesc.ServiceName)
+}
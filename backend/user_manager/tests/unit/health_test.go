@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/platform/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probe calls a probe handler and decodes its report
+func probe(t *testing.T, handler http.Handler, path string) (int, health.Report) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var report health.Report
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	return rec.Code, report
+}
+
+// TestHealth_Probes tests that readiness reports every dependency and fails
+// when one is down, while liveness does not depend on them
+func TestHealth_Probes(t *testing.T) {
+	probes := health.NewRegistry(health.Config{Timeout: time.Second})
+	probes.Register("postgres", func(ctx context.Context) error { return nil })
+	probes.Register("dapr", func(ctx context.Context) error { return errors.New("connection refused") })
+	probes.Register("temporal", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, health.WithTimeout(10*time.Millisecond))
+
+	status, report := probe(t, probes.ReadyHandler(), health.ReadyPath)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, health.StatusDown, report.Status)
+	require.Len(t, report.Checks, 3)
+	assert.Equal(t, health.CheckResult{Name: "postgres", Status: health.StatusUp, Duration: report.Checks[0].Duration}, report.Checks[0])
+	assert.Equal(t, "dapr", report.Checks[1].Name)
+	assert.Equal(t, health.StatusDown, report.Checks[1].Status)
+	assert.Equal(t, "connection refused", report.Checks[1].Error)
+	assert.Equal(t, "temporal", report.Checks[2].Name)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[2].Error)
+
+	status, report = probe(t, probes.LiveHandler(), health.LivePath)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Empty(t, report.Checks)
+}
+
+// TestHealth_Cache tests that the checks run again only once the last report
+// expired
+func TestHealth_Cache(t *testing.T) {
+	var calls atomic.Int32
+	probes := health.NewRegistry(health.Config{Timeout: time.Second, CacheTTL: 50 * time.Millisecond})
+	probes.Register("postgres", func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+
+	for i := 0; i < 3; i++ {
+		status, report := probe(t, probes.ReadyHandler(), health.ReadyPath)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, health.StatusUp, report.Status)
+	}
+	assert.EqualValues(t, 1, calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	probes.Ready(context.Background())
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+// TestHealth_HTTPChecks tests the checks of the Dapr sidecar and of the
+// Keycloak keys
+func TestHealth_HTTPChecks(t *testing.T) {
+	var daprToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.0/metadata":
+			daprToken = r.Header.Get("dapr-api-token")
+			w.Write([]byte(`{"id":"user-manager"}`))
+		case "/realms/saaster/protocol/openid-connect/certs":
+			w.Write([]byte(`{"keys":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, health.DaprSidecar(server.Client(), server.URL, "token")(ctx))
+	assert.Equal(t, "token", daprToken)
+	assert.NoError(t, health.JWKS(server.Client(), server.URL+"/realms/saaster/protocol/openid-connect/certs")(ctx))
+
+	err := health.JWKS(server.Client(), server.URL+"/realms/other/protocol/openid-connect/certs")(ctx)
+	assert.ErrorContains(t, err, "unexpected status 404")
+}
@@ -0,0 +1,252 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/middleware"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/memory"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newIdempotentRouter serves the API routes of the container for the caller, with Idempotency-Key support
+func newIdempotentRouter(container *di.Container, claims *auth.Claims, store ports.IdempotencyStore) *mux.Router {
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		})
+	}, middleware.Idempotency(store, time.Hour, time.Minute))
+	container.UserHandler.RegisterRoutes(api)
+	container.OrganizationHandler.RegisterRoutes(api)
+	return router
+}
+
+// serveIdempotent sends a request with an Idempotency-Key
+func serveIdempotent(router http.Handler, method, path, key string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set(middleware.IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func newUserBody(email string) map[string]string {
+	return map[string]string{"email": email, "first_name": "Jane", "last_name": "Doe", "role": "user"}
+}
+
+func countUsers(t *testing.T, container *di.Container) int {
+	page, err := container.UserRepository.List(context.Background(), tenantID, domain.UserListOptions{Limit: 100})
+	require.NoError(t, err)
+	return len(page.Users)
+}
+
+// TestIdempotency_CreateUser tests that a retried creation returns the first user instead of a new one
+func TestIdempotency_CreateUser(t *testing.T) {
+	container := di.NewContainer(nil, true, nil)
+	seedOrganization(t, container, tenantID, "acme")
+	router := newIdempotentRouter(container, callerClaims(selfID, "admin"), memory.NewIdempotencyStore(nil))
+
+	first := serveIdempotent(router, http.MethodPost, "/api/v1/users", "create-jane", newUserBody("jane@example.com"))
+	require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+	assert.Empty(t, first.Header().Get(middleware.IdempotentReplayedHeader))
+
+	retry := serveIdempotent(router, http.MethodPost, "/api/v1/users", "create-jane", newUserBody("jane@example.com"))
+	require.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 1, countUsers(t, container))
+
+	// The same key with another payload is a client bug
+	rec := serveIdempotent(router, http.MethodPost, "/api/v1/users", "create-jane", newUserBody("john@example.com"))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, 1, countUsers(t, container))
+
+	// Without a key, nothing is replayed
+	rec = serve(router, http.MethodPost, "/api/v1/users", newUserBody("jane@example.com"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+// TestIdempotency_ScopedToCaller tests that callers cannot replay each other's responses
+func TestIdempotency_ScopedToCaller(t *testing.T) {
+	container := di.NewContainer(nil, true, nil)
+	seedOrganization(t, container, tenantID, "acme")
+	store := memory.NewIdempotencyStore(nil)
+
+	rec := serveIdempotent(newIdempotentRouter(container, callerClaims(selfID, "admin"), store),
+		http.MethodPost, "/api/v1/users", "create-jane", newUserBody("jane@example.com"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = serveIdempotent(newIdempotentRouter(container, callerClaims(otherID, "admin"), store),
+		http.MethodPost, "/api/v1/users", "create-jane", newUserBody("jane@example.com"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Empty(t, rec.Header().Get(middleware.IdempotentReplayedHeader))
+}
+
+// TestIdempotency_Expiry tests that keys are forgotten after their TTL
+func TestIdempotency_Expiry(t *testing.T) {
+	container := di.NewContainer(nil, true, nil)
+	seedOrganization(t, container, tenantID, "acme")
+	now := time.Now()
+	store := memory.NewIdempotencyStore(func() time.Time { return now })
+	router := newIdempotentRouter(container, callerClaims(selfID, "admin"), store)
+
+	rec := serveIdempotent(router, http.MethodPost, "/api/v1/users", "create-jane", newUserBody("jane@example.com"))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	now = now.Add(2 * time.Hour)
+	rec = serveIdempotent(router, http.MethodPost, "/api/v1/users", "create-jane", newUserBody("jane@example.com"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Empty(t, rec.Header().Get(middleware.IdempotentReplayedHeader))
+}
+
+// TestIdempotency_Middleware tests how the middleware treats failures and concurrent retries
+func TestIdempotency_Middleware(t *testing.T) {
+	var mutex sync.Mutex
+	calls := 0
+	status := http.StatusInternalServerError
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := middleware.Idempotency(memory.NewIdempotencyStore(nil), time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls++
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "call %d", calls)
+	}))
+
+	t.Run("ServerErrorsAreNotStored", func(t *testing.T) {
+		rec := serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+		mutex.Lock()
+		status = http.StatusCreated
+		mutex.Unlock()
+		rec = serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "call 2", rec.Body.String())
+
+		rec = serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+		assert.Equal(t, "call 2", rec.Body.String())
+	})
+
+	t.Run("ConcurrentRetry", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- serveIdempotent(handler, http.MethodPost, "/slow", "slow-key", nil) }()
+		<-started
+
+		rec := serveIdempotent(handler, http.MethodPost, "/slow", "slow-key", nil)
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+		close(release)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+	})
+
+	t.Run("ReadsAreNotAffected", func(t *testing.T) {
+		first := serveIdempotent(handler, http.MethodGet, "/things", "read-key", nil)
+		second := serveIdempotent(handler, http.MethodGet, "/things", "read-key", nil)
+		assert.NotEqual(t, first.Body.String(), second.Body.String())
+	})
+
+	t.Run("KeyTooLong", func(t *testing.T) {
+		rec := serveIdempotent(handler, http.MethodPost, "/things", strings.Repeat("k", middleware.MaxIdempotencyKeyLength+1), nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+// unreleasedStore forgets to release the keys, as a request that crashed
+// before it could
+type unreleasedStore struct {
+	ports.IdempotencyStore
+}
+
+func (s unreleasedStore) Release(ctx context.Context, record *ports.IdempotencyRecord) error {
+	return nil
+}
+
+// TestIdempotency_Lease tests that the key of a request that crashed is only
+// held for the lease, while its response is kept for the TTL
+func TestIdempotency_Lease(t *testing.T) {
+	now := time.Now()
+	store := memory.NewIdempotencyStore(func() time.Time { return now })
+	calls := 0
+	handler := middleware.Idempotency(unreleasedStore{store}, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "call %d", calls)
+	}))
+
+	rec := serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	rec = serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	now = now.Add(2 * time.Minute)
+	rec = serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "call 2", rec.Body.String())
+
+	now = now.Add(30 * time.Minute)
+	rec = serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+	assert.Equal(t, "true", rec.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, "call 2", rec.Body.String())
+}
+
+// TestIdempotency_LostReservation tests that a request outliving its lease
+// does not overwrite the response of the retry that took its key over
+func TestIdempotency_LostReservation(t *testing.T) {
+	now := time.Now()
+	store := memory.NewIdempotencyStore(func() time.Time { return now })
+	calls := 0
+	var handler http.Handler
+	handler = middleware.Idempotency(store, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		call := calls
+		if call == 1 {
+			// The first request is still running when its lease runs out,
+			// and its retry takes the key over
+			now = now.Add(2 * time.Minute)
+			rec := serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+			assert.Equal(t, "call 2", rec.Body.String())
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "call %d", call)
+	}))
+
+	rec := serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+	assert.Equal(t, "call 1", rec.Body.String())
+
+	rec = serveIdempotent(handler, http.MethodPost, "/things", "key", nil)
+	assert.Equal(t, "true", rec.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, "call 2", rec.Body.String())
+}
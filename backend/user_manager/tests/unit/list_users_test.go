@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/queries"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listingEpoch is the creation time of the first seeded user
+var listingEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// seedListingUsers creates users created one hour apart, in the given order
+func seedListingUsers(t *testing.T, container *di.Container, users ...*domain.User) {
+	for i, user := range users {
+		user.ID = fmt.Sprintf("00000000-0000-0000-0000-%012d", i+1)
+		user.TenantID = tenantID
+		user.CreatedAt = listingEpoch.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, container.UserRepository.Create(context.Background(), user))
+	}
+}
+
+func listUsers(t *testing.T, router *mux.Router, params url.Values) handlers.ListUsersResponse {
+	rec := serve(router, http.MethodGet, "/api/v1/users?"+params.Encode(), nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var page handlers.ListUsersResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	return page
+}
+
+func emails(page handlers.ListUsersResponse) []string {
+	result := make([]string, 0, len(page.Users))
+	for _, user := range page.Users {
+		result = append(result, user.Email)
+	}
+	return result
+}
+
+// TestListUsers_Filters tests the filters of the user listing
+func TestListUsers_Filters(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+
+	inactive := domain.NewUser("bob@example.com", "Bob", "Martin", "user")
+	inactive.Deactivate()
+	seedListingUsers(t, container,
+		domain.NewUser("alice@example.com", "Alice", "Smith", "admin"),
+		inactive,
+		domain.NewUser("carol@example.com", "Carol", "Smithson", "user"),
+		domain.NewUser("dave@example.com", "Dave", "Jones", "user"),
+	)
+
+	assert.Equal(t,
+		[]string{"dave@example.com", "carol@example.com", "bob@example.com", "alice@example.com"},
+		emails(listUsers(t, router, url.Values{})))
+	assert.Equal(t,
+		[]string{"alice@example.com"},
+		emails(listUsers(t, router, url.Values{"role": {"admin"}})))
+	assert.Equal(t,
+		[]string{"bob@example.com"},
+		emails(listUsers(t, router, url.Values{"active": {"false"}})))
+	assert.Equal(t,
+		[]string{"carol@example.com", "alice@example.com"},
+		emails(listUsers(t, router, url.Values{"q": {"SMITH"}})))
+	assert.Equal(t,
+		[]string{"bob@example.com", "carol@example.com"},
+		emails(listUsers(t, router, url.Values{
+			"created_after":  {listingEpoch.Add(time.Hour).Format(time.RFC3339)},
+			"created_before": {listingEpoch.Add(3 * time.Hour).Format(time.RFC3339)},
+			"sort":           {"created_at"},
+		})))
+	assert.Equal(t,
+		[]string{"dave@example.com", "bob@example.com", "alice@example.com", "carol@example.com"},
+		emails(listUsers(t, router, url.Values{"sort": {"last_name"}})))
+}
+
+// TestListUsers_EmptyPage tests that an empty listing is an empty array
+func TestListUsers_EmptyPage(t *testing.T) {
+	router, _ := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+
+	rec := serve(router, http.MethodGet, "/api/v1/users", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"users": []}`, rec.Body.String())
+}
+
+// TestListUsers_Pagination tests that cursors walk through every user exactly once
+func TestListUsers_Pagination(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+
+	// Users sharing a creation time are still ordered by ID
+	var users []*domain.User
+	for i := 0; i < 7; i++ {
+		users = append(users, domain.NewUser(fmt.Sprintf("user%d@example.com", i), "User", "Test", "user"))
+	}
+	seedListingUsers(t, container, users...)
+	users[5].CreatedAt = users[4].CreatedAt
+	require.NoError(t, container.UserRepository.Update(context.Background(), users[5]))
+
+	for _, sort := range []string{"created_at", "-created_at", "email", "-last_name"} {
+		t.Run(sort, func(t *testing.T) {
+			params := url.Values{"sort": {sort}, "limit": {"3"}}
+
+			var seen []string
+			pages := 0
+			for {
+				page := listUsers(t, router, params)
+				seen = append(seen, emails(page)...)
+				pages++
+				if page.NextCursor == "" {
+					break
+				}
+				params.Set("cursor", page.NextCursor)
+			}
+
+			assert.Equal(t, 3, pages)
+			assert.Len(t, seen, 7)
+			assert.Equal(t, emails(listUsers(t, router, url.Values{"sort": {sort}, "limit": {"7"}})), seen)
+		})
+	}
+}
+
+// TestListUsers_InvalidParameters tests that malformed listings are rejected
+func TestListUsers_InvalidParameters(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedListingUsers(t, container,
+		domain.NewUser("alice@example.com", "Alice", "Smith", "admin"),
+		domain.NewUser("bob@example.com", "Bob", "Martin", "user"),
+	)
+
+	page := listUsers(t, router, url.Values{"limit": {"1"}})
+	require.NotEmpty(t, page.NextCursor)
+
+	for name, params := range map[string]url.Values{
+		"Active":        {"active": {"maybe"}},
+		"CreatedAfter":  {"created_after": {"yesterday"}},
+		"Sort":          {"sort": {"password"}},
+		"Limit":         {"limit": {"0"}},
+		"LimitTooLarge": {"limit": {fmt.Sprint(queries.MaxListUsersLimit + 1)}},
+		"Cursor":        {"cursor": {"not-a-cursor"}},
+		// A well-formed cursor whose ID is not a UUID
+		"CursorID": {"cursor": {base64.RawURLEncoding.EncodeToString([]byte(`{"s":"created_at","d":true,"id":"1' OR '1'='1","c":"2024-01-01T00:00:00Z"}`))}},
+		"CursorOfOtherSort": {
+			"cursor": {page.NextCursor},
+			"sort":   {"email"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := serve(router, http.MethodGet, "/api/v1/users?"+params.Encode(), nil)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	platformlogging "github.com/b-fontaine/saaster_kit/backend/platform/logging"
+	temporaladapter "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/logging"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// captureLogs makes the default logger write JSON records to the returned
+// buffer for the duration of the test
+func captureLogs(t *testing.T) (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger, err := platformlogging.New(platformlogging.Config{Level: "debug", Format: "json"}, "user-manager", &buf)
+	require.NoError(t, err)
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return logger, &buf
+}
+
+// logRecords decodes the JSON records of buf
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var record map[string]interface{}
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+	return records
+}
+
+// findRecord returns the first record with the given message
+func findRecord(records []map[string]interface{}, message string) map[string]interface{} {
+	for _, record := range records {
+		if record["message"] == message {
+			return record
+		}
+	}
+	return nil
+}
+
+// headerMap writes and reads a Temporal header
+type headerMap map[string]*commonpb.Payload
+
+func (h headerMap) Set(key string, value *commonpb.Payload) { h[key] = value }
+
+func TestLogging_New(t *testing.T) {
+	_, err := platformlogging.New(platformlogging.Config{Level: "verbose", Format: "json"}, "user-manager", &bytes.Buffer{})
+	assert.Error(t, err)
+	_, err = platformlogging.New(platformlogging.Config{Level: "info", Format: "xml"}, "user-manager", &bytes.Buffer{})
+	assert.Error(t, err)
+
+	// Records use Elastic Common Schema field names
+	var buf bytes.Buffer
+	logger, err := platformlogging.New(platformlogging.Config{Level: "info", Format: "json"}, "user-manager", &buf)
+	require.NoError(t, err)
+	logger.Debug("dropped")
+	logger.Warn("kept", "error", "boom")
+	records := logRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "kept", records[0]["message"])
+	assert.Equal(t, "warn", records[0]["log.level"])
+	assert.Equal(t, "user-manager", records[0]["service.name"])
+	assert.NotEmpty(t, records[0]["@timestamp"])
+}
+
+// TestLogging_RequestID tests that requests are given an ID, echoed in the
+// response and logged with the request and its caller
+func TestLogging_RequestID(t *testing.T) {
+	_, buf := captureLogs(t)
+
+	container := di.NewContainer(nil, true, nil)
+	seedUser(t, container, otherID, "other@example.com", "user")
+	router := mux.NewRouter()
+	router.Use(logging.Middleware)
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), callerClaims(selfID, "admin"))))
+		})
+	}, logging.CaptureRequest)
+	container.UserHandler.RegisterRoutes(api)
+
+	get := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+otherID, nil)
+		if requestID != "" {
+			req.Header.Set(platformlogging.RequestIDHeader, requestID)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return rec
+	}
+
+	// The ID of the caller is kept
+	assert.Equal(t, "req-1", get("req-1").Header().Get(platformlogging.RequestIDHeader))
+
+	// Missing and unsafe IDs are replaced
+	generated := get("").Header().Get(platformlogging.RequestIDHeader)
+	_, err := uuid.Parse(generated)
+	assert.NoError(t, err)
+	replaced := get("bad id\n").Header().Get(platformlogging.RequestIDHeader)
+	assert.NotEqual(t, "bad id\n", replaced)
+
+	record := findRecord(logRecords(t, buf), "request completed")
+	require.NotNil(t, record)
+	assert.Equal(t, "req-1", record[platformlogging.RequestIDKey])
+	assert.Equal(t, tenantID, record[platformlogging.TenantIDKey])
+	assert.Equal(t, selfID, record[platformlogging.UserIDKey])
+	assert.Equal(t, "/api/v1/users/{id}", record["http.route"])
+	assert.EqualValues(t, http.StatusOK, record["http.response.status_code"])
+}
+
+// TestLogging_Workflow tests that the logs of a workflow and of its
+// activities carry the correlation of the request that started it
+func TestLogging_Workflow(t *testing.T) {
+	logger, buf := captureLogs(t)
+
+	// The header the propagator of the client writes for the request
+	ctx := platformlogging.WithRequestID(auth.WithClaims(context.Background(), callerClaims(selfID, "admin")), "req-1")
+	header := headerMap{}
+	require.NoError(t, platformlogging.TemporalPropagator().Inject(ctx, header))
+
+	// The user no longer exists, which the activity logs
+	container := di.NewContainer(nil, true, nil)
+	workflows := temporaladapter.NewWorker(
+		temporaladapter.NewCreateUserWorkflow(container.CreateUserHandler),
+		temporaladapter.NewSyncUserStatusWorkflow(container.UserRepository, &fakeIdentityProvider{enabled: map[string]bool{}}),
+	)
+	var suite testsuite.WorkflowTestSuite
+	suite.SetLogger(platformlogging.TemporalLogger(logger))
+	suite.SetContextPropagators([]workflow.ContextPropagator{platformlogging.TemporalPropagator()})
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{platformlogging.TemporalInterceptor()}})
+	env.SetHeader(&commonpb.Header{Fields: header})
+	workflows.RegisterWorkflows(env)
+	workflows.RegisterActivities(env)
+	env.ExecuteWorkflow("SyncUserStatusWorkflow", temporaladapter.SyncUserStatusWorkflowInput{TenantID: tenantID, UserID: otherID})
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	records := logRecords(t, buf)
+	for _, message := range []string{"SyncUserStatusWorkflow started", "User no longer exists, skipping status sync"} {
+		record := findRecord(records, message)
+		require.NotNil(t, record, message)
+		assert.Equal(t, "req-1", record[platformlogging.RequestIDKey], message)
+		assert.Equal(t, tenantID, record[platformlogging.TenantIDKey], message)
+		assert.Equal(t, selfID, record[platformlogging.UserIDKey], message)
+	}
+}
@@ -0,0 +1,105 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scrape returns the metrics as served to Prometheus
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metrics.Path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return rec.Body.String()
+}
+
+// TestMetrics_HTTP tests that requests are counted by route template and status
+func TestMetrics_HTTP(t *testing.T) {
+	m := metrics.New()
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	router.Use(m.Middleware)
+	seedUser(t, container, selfID, "self@example.com", "admin")
+
+	serve(router, http.MethodGet, "/api/v1/users/"+selfID, nil)
+	serve(router, http.MethodGet, "/api/v1/users/"+otherID, nil)
+	serve(router, http.MethodGet, "/api/v1/users/"+otherID, nil)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `user_manager_http_requests_total{method="GET",route="/api/v1/users/{id}",status="200"} 1`)
+	assert.Contains(t, body, `user_manager_http_requests_total{method="GET",route="/api/v1/users/{id}",status="404"} 2`)
+	assert.Contains(t, body, `user_manager_http_request_duration_seconds_count{method="GET",route="/api/v1/users/{id}",status="404"} 2`)
+	assert.NotContains(t, body, otherID)
+}
+
+// TestMetrics_GRPC tests that gRPC calls are counted by method and status code
+func TestMetrics_GRPC(t *testing.T) {
+	m := metrics.New()
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/saaster.user.v1.UserService/GetUser"}
+
+	interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	})
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `user_manager_grpc_requests_total{code="OK",method="/saaster.user.v1.UserService/GetUser"} 1`)
+	assert.Contains(t, body, `user_manager_grpc_requests_total{code="NotFound",method="/saaster.user.v1.UserService/GetUser"} 1`)
+}
+
+// TestMetrics_Repositories tests that repository operations are timed by operation and outcome
+func TestMetrics_Repositories(t *testing.T) {
+	m := metrics.New()
+	container := di.NewContainer(nil, true, nil, di.WithMetrics(m))
+	ctx := context.Background()
+
+	user := domain.NewUser("jane@example.com", "Jane", "Doe", domain.RoleUser)
+	user.ID = selfID
+	user.TenantID = tenantID
+	require.NoError(t, container.UserRepository.Create(ctx, user))
+	assert.Error(t, container.UserRepository.Create(ctx, user))
+	_, err := container.UserRepository.GetByID(ctx, tenantID, selfID)
+	require.NoError(t, err)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `user_manager_repository_operation_duration_seconds_count{operation="create",outcome="ok",repository="user"} 1`)
+	assert.Contains(t, body, `user_manager_repository_operation_duration_seconds_count{operation="create",outcome="error",repository="user"} 1`)
+	assert.Contains(t, body, `user_manager_repository_operation_duration_seconds_count{operation="get_by_id",outcome="ok",repository="user"} 1`)
+}
+
+// TestMetrics_Temporal tests that the metrics of the Temporal SDK are exported with a fixed set of labels
+func TestMetrics_Temporal(t *testing.T) {
+	m := metrics.New()
+	handler := m.TemporalHandler().WithTags(map[string]string{
+		"activity_type": "CreateUserActivity",
+		"task_queue":    "user-manager-task-queue",
+		"unknown_tag":   "dropped",
+	})
+
+	handler.Counter("temporal_activity_execution_failed").Inc(2)
+	handler.Timer("temporal_activity_execution_latency").Record(30 * time.Millisecond)
+	handler.WithTags(map[string]string{"worker_type": "ActivityWorker"}).Gauge("temporal_worker_task_slots_available").Update(5)
+	// A second handler reports to the same collectors
+	m.TemporalHandler().Counter("temporal_activity_execution_failed").Inc(1)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `temporal_activity_execution_failed_total{activity_type="CreateUserActivity",cause="",client_name="",failure_reason="",namespace="",operation="",poller_type="",task_queue="user-manager-task-queue",worker_type="",workflow_type=""} 2`)
+	assert.Contains(t, body, `temporal_activity_execution_failed_total{activity_type="",cause="",client_name="",failure_reason="",namespace="",operation="",poller_type="",task_queue="",worker_type="",workflow_type=""} 1`)
+	assert.Contains(t, body, `temporal_activity_execution_latency_seconds_count{activity_type="CreateUserActivity"`)
+	assert.Contains(t, body, `temporal_worker_task_slots_available{activity_type="CreateUserActivity",cause="",client_name="",failure_reason="",namespace="",operation="",poller_type="",task_queue="user-manager-task-queue",worker_type="ActivityWorker",workflow_type=""} 5`)
+	assert.NotContains(t, body, "unknown_tag")
+}
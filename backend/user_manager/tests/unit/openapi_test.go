@@ -0,0 +1,160 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/openapi"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newValidatedRouter is newAuthorizedRouter with the requests and responses
+// validated against the OpenAPI document; responses that do not match it
+// are turned into 500 problems
+func newValidatedRouter(t *testing.T, claims *auth.Claims) (*mux.Router, *di.Container) {
+	router, container := newAuthorizedRouter(t, claims)
+	validator, err := openapi.NewValidator(context.Background())
+	require.NoError(t, err)
+	router.Use(validator.Middleware)
+	return router, container
+}
+
+// TestOpenAPI_Document tests that the served document is a valid OpenAPI 3.1 document
+func TestOpenAPI_Document(t *testing.T) {
+	doc, err := openapi.Load(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+
+	rec := httptest.NewRecorder()
+	openapi.Handler(rec, httptest.NewRequest(http.MethodGet, openapi.Path, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var served map[string]interface{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&served))
+	assert.Equal(t, "3.1.0", served["openapi"])
+}
+
+// TestOpenAPI_Routes tests that the document describes exactly the registered API routes
+func TestOpenAPI_Routes(t *testing.T) {
+	doc, err := openapi.Load(context.Background())
+	require.NoError(t, err)
+	router, _ := newAuthorizedRouter(t, nil)
+
+	registered := map[string]bool{}
+	err = router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			registered[method+" "+path] = true
+			pathItem := doc.Paths.Value(path)
+			if assert.NotNil(t, pathItem, "%s is not documented", path) {
+				assert.NotNil(t, pathItem.GetOperation(method), "%s %s is not documented", method, path)
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	for path, pathItem := range doc.Paths.Map() {
+		if !strings.HasPrefix(path, "/api/") {
+			continue
+		}
+		for method := range pathItem.Operations() {
+			assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", method, path)
+		}
+	}
+}
+
+// TestOpenAPI_Conformance tests that the responses of every operation match the document
+func TestOpenAPI_Conformance(t *testing.T) {
+	router, container := newValidatedRouter(t, callerClaims(selfID, "admin"))
+	seedOrganization(t, container, tenantID, "acme")
+	seedUser(t, container, selfID, "self@example.com", "admin")
+
+	rec := serve(router, http.MethodPost, "/api/v1/users", newUserBody("jane@example.com"))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created map[string]interface{}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&created))
+	userPath := "/api/v1/users/" + created["id"].(string)
+
+	for _, step := range []struct {
+		name    string
+		method  string
+		path    string
+		ifMatch string
+		body    interface{}
+		status  int
+	}{
+		{"CreateConflict", http.MethodPost, "/api/v1/users", "", newUserBody("jane@example.com"), http.StatusConflict},
+		{"List", http.MethodGet, "/api/v1/users?role=user&active=true&sort=email&limit=1", "", nil, http.StatusOK},
+		{"Get", http.MethodGet, userPath, "", nil, http.StatusOK},
+		{"GetMissing", http.MethodGet, "/api/v1/users/" + otherID, "", nil, http.StatusNotFound},
+		{"Update", http.MethodPut, userPath, `"1"`, renameBody("Janet"), http.StatusOK},
+		{"UpdateStale", http.MethodPut, userPath, `"1"`, renameBody("Jenny"), http.StatusPreconditionFailed},
+		{"Deactivate", http.MethodPost, userPath + "/deactivate", "", nil, http.StatusOK},
+		{"Activate", http.MethodPost, userPath + "/activate", "", nil, http.StatusOK},
+		{"Delete", http.MethodDelete, userPath, "", nil, http.StatusNoContent},
+		{"CreateOrganization", http.MethodPost, "/api/v1/organizations", "", map[string]string{"name": "Globex", "slug": "globex"}, http.StatusForbidden},
+		{"GetCurrentOrganization", http.MethodGet, "/api/v1/organizations/current", "", nil, http.StatusOK},
+	} {
+		t.Run(step.name, func(t *testing.T) {
+			rec := serveIfMatch(router, step.method, step.path, step.ifMatch, step.body)
+			assert.Equal(t, step.status, rec.Code, rec.Body.String())
+		})
+	}
+
+	t.Run("CreateOrganizationAsPlatformAdmin", func(t *testing.T) {
+		router, _ := newValidatedRouter(t, callerClaims(selfID, "platform-admin"))
+		rec := serve(router, http.MethodPost, "/api/v1/organizations", map[string]string{"name": "Globex", "slug": "globex", "plan": "pro"})
+		assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	})
+}
+
+// TestOpenAPI_RequestValidation tests that requests not matching the document are rejected with their invalid fields
+func TestOpenAPI_RequestValidation(t *testing.T) {
+	router, container := newValidatedRouter(t, callerClaims(selfID, "admin"))
+	seedOrganization(t, container, tenantID, "acme")
+
+	rec := serve(router, http.MethodPost, "/api/v1/users", map[string]interface{}{"email": "jane@example.com", "first_name": 42, "role": "owner"})
+	body := decodeProblem(t, rec, http.StatusBadRequest)
+	assert.Equal(t, problem.TypeValidation, body.Type)
+	assert.Contains(t, problemFields(body.Errors), "first_name")
+	assert.Contains(t, problemFields(body.Errors), "role")
+
+	rec = serve(router, http.MethodGet, "/api/v1/users?limit=500", nil)
+	body = decodeProblem(t, rec, http.StatusBadRequest)
+	assert.Equal(t, []problem.FieldError{{Field: "limit", Message: "number must be at most 200"}}, body.Errors)
+}
+
+// TestOpenAPI_ResponseValidation tests that responses drifting from the document are reported
+func TestOpenAPI_ResponseValidation(t *testing.T) {
+	validator, err := openapi.NewValidator(context.Background())
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Use(validator.Middleware)
+	router.HandleFunc("/api/v1/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": selfID, "firstName": "Jane"})
+	}).Methods(http.MethodGet)
+
+	rec := serve(router, http.MethodGet, "/api/v1/users/"+selfID, nil)
+	body := decodeProblem(t, rec, http.StatusInternalServerError)
+	assert.Contains(t, body.Detail, "does not match the API document")
+}
@@ -0,0 +1,262 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/dapr"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/memory"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/outbox"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// publishedEvent is a CloudEvent received by the Dapr stand-in
+type publishedEvent struct {
+	topic       string
+	contentType string
+	apiToken    string
+	event       dapr.CloudEvent
+}
+
+// daprStandIn serves the publish endpoint of a Dapr sidecar. It rejects the
+// first failures requests, then accepts every event.
+type daprStandIn struct {
+	server    *httptest.Server
+	mutex     sync.Mutex
+	failures  int
+	attempts  int
+	published []publishedEvent
+}
+
+func newDaprStandIn(t *testing.T, failures int) *daprStandIn {
+	s := &daprStandIn{failures: failures}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1.0/publish/pubsub/", func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		s.attempts++
+		if s.attempts <= s.failures {
+			http.Error(w, "pubsub unavailable", http.StatusInternalServerError)
+			return
+		}
+
+		var event dapr.CloudEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.published = append(s.published, publishedEvent{
+			topic:       r.URL.Path[len("/v1.0/publish/pubsub/"):],
+			contentType: r.Header.Get("Content-Type"),
+			apiToken:    r.Header.Get("dapr-api-token"),
+			event:       event,
+		})
+		w.WriteHeader(http.StatusNoContent)
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *daprStandIn) events() []publishedEvent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]publishedEvent(nil), s.published...)
+}
+
+// testClock is a clock the tests move forward by hand
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time                 { return c.now }
+func (c *testClock) Advance(duration time.Duration) { c.now = c.now.Add(duration) }
+
+func newTestRelay(outboxRepo *memory.OutboxRepository, standIn *daprStandIn, clock *testClock) *outbox.Relay {
+	publisher := dapr.NewPublisher(dapr.PublisherConfig{
+		BaseURL:    standIn.server.URL,
+		PubSubName: "pubsub",
+		APIToken:   "sidecar-token",
+	})
+	return outbox.NewRelay(outboxRepo, publisher, outbox.RelayConfig{
+		MinBackoff: time.Second,
+		MaxBackoff: 4 * time.Second,
+		Now:        clock.Now,
+	})
+}
+
+// TestOutbox_UserEvents tests that user changes are published as CloudEvents
+func TestOutbox_UserEvents(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedOrganization(t, container, tenantID, "acme")
+	outboxRepo := container.OutboxRepository.(*memory.OutboxRepository)
+
+	rec := serve(router, http.MethodPost, "/api/v1/users", map[string]string{
+		"email": "jane@example.com", "first_name": "Jane", "last_name": "Doe", "role": "user", "external_id": otherSubject,
+	})
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var user handlers.UserResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+
+	rec = serve(router, http.MethodPut, "/api/v1/users/"+user.ID, map[string]string{
+		"email": "jane@example.com", "first_name": "Janet", "last_name": "Doe", "role": "user",
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = serve(router, http.MethodDelete, "/api/v1/users/"+user.ID, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	// Nothing is published before the relay runs
+	written := outboxRepo.Events()
+	require.Len(t, written, 3)
+
+	standIn := newDaprStandIn(t, 0)
+	relay := newTestRelay(outboxRepo, standIn, &testClock{now: time.Now().Add(time.Second)})
+	published, err := relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, published)
+
+	events := standIn.events()
+	require.Len(t, events, 3)
+	for i, expected := range []struct{ topic, eventType string }{
+		{"user-created", "com.saaster.user.created"},
+		{"user-updated", "com.saaster.user.updated"},
+		{"user-deleted", "com.saaster.user.deleted"},
+	} {
+		got := events[i]
+		assert.Equal(t, expected.topic, got.topic)
+		assert.Equal(t, "application/cloudevents+json", got.contentType)
+		assert.Equal(t, "sidecar-token", got.apiToken)
+		assert.Equal(t, "1.0", got.event.SpecVersion)
+		assert.Equal(t, written[i].ID, got.event.ID)
+		assert.Equal(t, expected.eventType, got.event.Type)
+		assert.Equal(t, dapr.EventSource, got.event.Source)
+		assert.Equal(t, user.ID, got.event.Subject)
+		assert.Equal(t, tenantID, got.event.TenantID)
+	}
+
+	var created domain.UserEventData
+	require.NoError(t, json.Unmarshal(events[0].event.Data, &created))
+	assert.Equal(t, otherSubject, created.ExternalID)
+
+	var updated domain.UserEventData
+	require.NoError(t, json.Unmarshal(events[1].event.Data, &updated))
+	assert.Equal(t, "Janet", updated.FirstName)
+	require.NotNil(t, updated.Active)
+	assert.True(t, *updated.Active)
+
+	// Deletions carry no personal data
+	assert.JSONEq(t, `{"id": "`+user.ID+`", "tenantId": "`+tenantID+`", "externalId": "`+otherSubject+`"}`, string(events[2].event.Data))
+
+	// Published events are not published again
+	published, err = relay.PublishPending(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, published)
+}
+
+// TestOutbox_Retries tests that events are retried with a backoff until they are published
+func TestOutbox_Retries(t *testing.T) {
+	ctx := context.Background()
+	outboxRepo := memory.NewOutboxRepository()
+	repo := memory.NewUserRepository(outboxRepo)
+
+	user := domain.NewUser("jane@example.com", "Jane", "Doe", "user")
+	user.ID = selfID
+	user.TenantID = tenantID
+	require.NoError(t, repo.Create(ctx, user, domain.NewUserCreatedEvent(user)))
+
+	standIn := newDaprStandIn(t, 2)
+	clock := &testClock{now: time.Now().Add(time.Second)}
+	relay := newTestRelay(outboxRepo, standIn, clock)
+
+	publish := func() int {
+		published, err := relay.PublishPending(ctx)
+		require.NoError(t, err)
+		return published
+	}
+
+	// The first attempt fails and is retried after a second
+	assert.Equal(t, 1, publish())
+	assert.Zero(t, publish())
+	clock.Advance(time.Second)
+
+	// The second attempt fails and is retried after two seconds
+	assert.Equal(t, 1, publish())
+	clock.Advance(time.Second)
+	assert.Zero(t, publish())
+	clock.Advance(time.Second)
+
+	assert.Equal(t, 1, publish())
+	clock.Advance(time.Hour)
+	assert.Zero(t, publish())
+
+	events := standIn.events()
+	require.Len(t, events, 1)
+	assert.Equal(t, outboxRepo.Events()[0].ID, events[0].event.ID)
+}
+
+// TestOutbox_Purge tests that published events are deleted once their
+// retention period is over, and events waiting to be published are kept
+func TestOutbox_Purge(t *testing.T) {
+	ctx := context.Background()
+	outboxRepo := memory.NewOutboxRepository()
+	repo := memory.NewUserRepository(outboxRepo)
+
+	user := domain.NewUser("jane@example.com", "Jane", "Doe", "user")
+	user.ID = selfID
+	user.TenantID = tenantID
+	require.NoError(t, repo.Create(ctx, user, domain.NewUserCreatedEvent(user)))
+
+	clock := &testClock{now: time.Now().Add(time.Second)}
+	relay := newTestRelay(outboxRepo, newDaprStandIn(t, 0), clock)
+	published, err := relay.PublishPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, published)
+
+	user.FirstName = "Janet"
+	require.NoError(t, repo.Update(ctx, user, domain.NewUserUpdatedEvent(user)))
+	updated := outboxRepo.Events()[1]
+
+	clock.Advance(24 * time.Hour)
+	purged, err := relay.PurgePublished(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	assert.Len(t, outboxRepo.Events(), 2)
+
+	clock.Advance(7 * 24 * time.Hour)
+	purged, err = relay.PurgePublished(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.Equal(t, []*domain.Event{updated}, outboxRepo.Events())
+}
+
+// TestOutbox_FailedChangesHaveNoEvent tests that events are only written with their change
+func TestOutbox_FailedChangesHaveNoEvent(t *testing.T) {
+	ctx := context.Background()
+	outboxRepo := memory.NewOutboxRepository()
+	repo := memory.NewUserRepository(outboxRepo)
+
+	user := domain.NewUser("jane@example.com", "Jane", "Doe", "user")
+	user.ID = selfID
+	user.TenantID = tenantID
+
+	assert.ErrorIs(t, repo.Update(ctx, user, domain.NewUserUpdatedEvent(user)), domain.ErrUserNotFound)
+	assert.ErrorIs(t, repo.Delete(ctx, tenantID, selfID, 1, domain.NewUserDeletedEvent(user)), domain.ErrUserNotFound)
+
+	require.NoError(t, repo.Create(ctx, user, domain.NewUserCreatedEvent(user)))
+	duplicate := *user
+	duplicate.ID = otherID
+	assert.ErrorIs(t, repo.Create(ctx, &duplicate, domain.NewUserCreatedEvent(&duplicate)), domain.ErrUserAlreadyExists)
+
+	events := outboxRepo.Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, domain.UserCreatedEvent, events[0].Type)
+}
@@ -0,0 +1,147 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/commands"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/queries"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/ports"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeProblem checks that a response is a problem with status, and returns it
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder, status int) problem.Problem {
+	require.Equal(t, status, rec.Code, rec.Body.String())
+	assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+	var body problem.Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, status, body.Status)
+	assert.NotEmpty(t, body.Type)
+	assert.NotEmpty(t, body.Title)
+	return body
+}
+
+// racingUserRepository wraps its errors the way the PostgreSQL repository
+// does, and fails the writes as if another request changed the user first
+type racingUserRepository struct {
+	ports.UserRepository
+}
+
+func (r racingUserRepository) Create(ctx context.Context, user *domain.User, events ...*domain.Event) error {
+	return fmt.Errorf("failed to create user: %w", domain.ErrUserAlreadyExists)
+}
+
+func (r racingUserRepository) Delete(ctx context.Context, tenantID, id string, version int64, events ...*domain.Event) error {
+	return fmt.Errorf("failed to delete user: %w", domain.ErrVersionMismatch)
+}
+
+// TestProblem_Responses tests that errors are answered with problem details
+func TestProblem_Responses(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedOrganization(t, container, tenantID, "acme")
+	seedUser(t, container, selfID, "self@example.com", "admin")
+
+	t.Run("Validation", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users?"+url.Values{"limit": {"0"}}.Encode(), nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		body := decodeProblem(t, rec, http.StatusBadRequest)
+		assert.Equal(t, problem.TypeValidation, body.Type)
+		assert.Equal(t, "/api/v1/users", body.Instance)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", body.TraceID)
+		assert.Equal(t, []problem.FieldError{{Field: "limit", Message: "must be a positive integer"}}, body.Errors)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+otherID, nil)
+		req.Header.Set("X-Request-ID", "request-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		body := decodeProblem(t, rec, http.StatusNotFound)
+		assert.Equal(t, "Not Found", body.Title)
+		assert.Equal(t, "/api/v1/users/"+otherID, body.Instance)
+		assert.Equal(t, "request-1", body.TraceID)
+	})
+
+	t.Run("InvalidBody", func(t *testing.T) {
+		rec := serve(router, http.MethodPost, "/api/v1/users", "not an object")
+		body := decodeProblem(t, rec, http.StatusBadRequest)
+		assert.Equal(t, "Invalid request body", body.Detail)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		rec := serve(router, http.MethodPost, "/api/v1/users", newUserBody("self@example.com"))
+		decodeProblem(t, rec, http.StatusConflict)
+	})
+
+	t.Run("VersionMismatch", func(t *testing.T) {
+		rec := serveIfMatch(router, http.MethodPut, "/api/v1/users/"+selfID, `"7"`, renameBody("Janet"))
+		body := decodeProblem(t, rec, http.StatusPreconditionFailed)
+		assert.Equal(t, problem.TypeVersionMismatch, body.Type)
+	})
+}
+
+// TestProblem_WrappedErrors tests that wrapped repository errors are mapped to their status
+func TestProblem_WrappedErrors(t *testing.T) {
+	_, container := newAuthorizedRouter(t, nil)
+	seedOrganization(t, container, tenantID, "acme")
+	seedUser(t, container, otherID, "other@example.com", "user")
+	repo := racingUserRepository{UserRepository: container.UserRepository}
+
+	handler := handlers.NewUserHandler(
+		commands.NewCreateUserHandler(repo, container.OrganizationRepository),
+		commands.NewUpdateUserHandler(repo),
+		commands.NewDeleteUserHandler(repo),
+		commands.NewActivateUserHandler(repo, nil),
+		commands.NewDeactivateUserHandler(repo, nil),
+		queries.NewGetUserByIDHandler(repo),
+		queries.NewListUsersHandler(repo),
+		commands.NewLinkUserAccountHandler(repo, nil),
+		auth.NewAuthorizer(auth.DefaultPolicy(auth.DefaultClientID), nil),
+	)
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), callerClaims(selfID, "admin"))))
+		})
+	})
+	handler.RegisterRoutes(api)
+
+	rec := serve(router, http.MethodPost, "/api/v1/users", newUserBody("new@example.com"))
+	decodeProblem(t, rec, http.StatusConflict)
+
+	rec = serve(router, http.MethodDelete, "/api/v1/users/"+otherID, nil)
+	body := decodeProblem(t, rec, http.StatusPreconditionFailed)
+	assert.Equal(t, problem.TypeVersionMismatch, body.Type)
+}
+
+// TestProblem_Forbidden tests that denied requests name the missing permission
+func TestProblem_Forbidden(t *testing.T) {
+	router, _ := newAuthorizedRouter(t, callerClaims(selfID))
+
+	rec := serve(router, http.MethodDelete, "/api/v1/users/"+otherID, nil)
+	assert.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+	var body auth.ForbiddenResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, http.StatusForbidden, body.Status)
+	assert.Equal(t, "missing permission users:delete", body.Detail)
+	assert.Equal(t, auth.PermissionUsersDelete, body.RequiredPermission)
+}
@@ -0,0 +1,184 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/memory"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedOrganization(t *testing.T, container *di.Container, id, slug string) {
+	org := domain.NewOrganization(slug, slug, domain.PlanFree)
+	org.ID = id
+	require.NoError(t, container.OrganizationRepository.Create(context.Background(), org))
+}
+
+// TestTenancy_UserRepository tests that the in-memory repository never crosses tenants
+func TestTenancy_UserRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewUserRepository(memory.NewOutboxRepository())
+
+	user := domain.NewUser("jane@example.com", "Jane", "Doe", "user")
+	user.ID = selfID
+	user.TenantID = tenantID
+	require.NoError(t, repo.Create(ctx, user))
+
+	found, err := repo.GetByID(ctx, otherTenantID, selfID)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	found, err = repo.GetByEmail(ctx, otherTenantID, "jane@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	page, err := repo.List(ctx, otherTenantID, domain.UserListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Users)
+
+	user.TenantID = otherTenantID
+	assert.Equal(t, domain.ErrUserNotFound, repo.Update(ctx, user))
+	assert.Equal(t, domain.ErrUserNotFound, repo.Delete(ctx, otherTenantID, selfID, user.Version))
+
+	// The same email may be used in another organization
+	twin := domain.NewUser("jane@example.com", "Jane", "Twin", "user")
+	twin.ID = otherID
+	twin.TenantID = otherTenantID
+	assert.NoError(t, repo.Create(ctx, twin))
+
+	// An account may be linked to one user in each organization
+	user.TenantID = tenantID
+	user.ExternalID = selfSubject
+	require.NoError(t, repo.Update(ctx, user))
+	twin.ExternalID = selfSubject
+	assert.NoError(t, repo.Update(ctx, twin))
+
+	sibling := domain.NewUser("sibling@example.com", "Jane", "Sibling", "user")
+	sibling.TenantID = tenantID
+	sibling.ExternalID = selfSubject
+	assert.Equal(t, domain.ErrUserAlreadyExists, repo.Create(ctx, sibling))
+
+	_, err = repo.List(ctx, "", domain.UserListOptions{Limit: 10})
+	assert.Equal(t, domain.ErrTenantRequired, err)
+}
+
+// TestTenancy_CrossTenantAccess tests that admins only see users of their own organization
+func TestTenancy_CrossTenantAccess(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedUser(t, container, selfID, "self@example.com", "admin")
+	seedTenantUser(t, container, otherTenantID, otherID, "other@example.com", "user")
+
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodGet, "/api/v1/users/"+otherID, nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(router, http.MethodDelete, "/api/v1/users/"+otherID, nil).Code)
+
+	rec := serve(router, http.MethodGet, "/api/v1/users", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var page handlers.ListUsersResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&page))
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, selfID, page.Users[0].ID)
+}
+
+// TestTenancy_CreateUser tests that users are created in the caller's organization
+func TestTenancy_CreateUser(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedOrganization(t, container, tenantID, "acme")
+
+	rec := serve(router, http.MethodPost, "/api/v1/users", map[string]string{
+		"email": "new@example.com", "first_name": "New", "last_name": "User", "role": "user",
+	})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var user handlers.UserResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+	assert.Equal(t, tenantID, user.TenantID)
+}
+
+// TestTenancy_TokenWithoutTenant tests that tokens without an organization are rejected
+func TestTenancy_TokenWithoutTenant(t *testing.T) {
+	claims := callerClaims(selfID, "admin")
+	claims.TenantID = ""
+	router, _ := newAuthorizedRouter(t, claims)
+
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, "/api/v1/users", nil).Code)
+}
+
+// TestTenancy_Organizations tests the organization routes
+func TestTenancy_Organizations(t *testing.T) {
+	t.Run("PlatformAdminCreates", func(t *testing.T) {
+		router, _ := newAuthorizedRouter(t, callerClaims(selfID, "platform-admin"))
+
+		rec := serve(router, http.MethodPost, "/api/v1/organizations", map[string]string{"name": "Acme", "slug": "acme"})
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		var org handlers.OrganizationResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&org))
+		assert.Equal(t, "acme", org.Slug)
+		assert.Equal(t, domain.PlanFree, org.Plan)
+		assert.Equal(t, domain.OrganizationStatusActive, org.Status)
+
+		rec = serve(router, http.MethodPost, "/api/v1/organizations", map[string]string{"name": "Acme 2", "slug": "acme"})
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		rec = serve(router, http.MethodPost, "/api/v1/organizations", map[string]string{"name": "Bad", "slug": "Not A Slug"})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("PlatformAdminCreatesFirstUser", func(t *testing.T) {
+		claims := callerClaims(selfID, "platform-admin")
+		claims.TenantID = ""
+		router, _ := newAuthorizedRouter(t, claims)
+
+		rec := serve(router, http.MethodPost, "/api/v1/organizations", map[string]string{"name": "Acme", "slug": "acme"})
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var org handlers.OrganizationResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&org))
+
+		rec = serve(router, http.MethodPost, "/api/v1/organizations/"+org.ID+"/users", map[string]string{
+			"email": "owner@example.com", "first_name": "Olive", "last_name": "Owner", "role": "admin",
+			"external_id": selfSubject,
+		})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var user handlers.UserResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+		assert.Equal(t, org.ID, user.TenantID)
+		assert.Equal(t, selfSubject, user.ExternalID)
+
+		rec = serve(router, http.MethodPost, "/api/v1/organizations/"+otherTenantID+"/users", map[string]string{
+			"email": "owner@example.com", "first_name": "Olive", "last_name": "Owner", "role": "admin",
+		})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("TenantAdminCannotCreate", func(t *testing.T) {
+		router, _ := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+
+		rec := serve(router, http.MethodPost, "/api/v1/organizations", map[string]string{"name": "Acme", "slug": "acme"})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = serve(router, http.MethodPost, "/api/v1/organizations/"+otherTenantID+"/users", map[string]string{
+			"email": "intruder@example.com", "first_name": "Ian", "last_name": "Truder", "role": "admin",
+		})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("MemberReadsCurrent", func(t *testing.T) {
+		router, container := newAuthorizedRouter(t, callerClaims(selfID, "user"))
+		seedOrganization(t, container, tenantID, "acme")
+		seedOrganization(t, container, otherTenantID, "globex")
+
+		rec := serve(router, http.MethodGet, "/api/v1/organizations/current", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var org handlers.OrganizationResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&org))
+		assert.Equal(t, tenantID, org.ID)
+	})
+}
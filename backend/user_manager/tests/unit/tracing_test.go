@@ -0,0 +1,167 @@
+package unit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	platformtracing "github.com/b-fontaine/saaster_kit/backend/platform/tracing"
+	temporaladapter "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/tracing"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// temporalFrontendStandIn is the part of the Temporal frontend used to start
+// workflows. It records the start requests instead of running the workflows.
+type temporalFrontendStandIn struct {
+	workflowservice.UnimplementedWorkflowServiceServer
+
+	mutex   sync.Mutex
+	started []*workflowservice.StartWorkflowExecutionRequest
+}
+
+func (s *temporalFrontendStandIn) GetSystemInfo(ctx context.Context, req *workflowservice.GetSystemInfoRequest) (*workflowservice.GetSystemInfoResponse, error) {
+	return &workflowservice.GetSystemInfoResponse{Capabilities: &workflowservice.GetSystemInfoResponse_Capabilities{}}, nil
+}
+
+func (s *temporalFrontendStandIn) StartWorkflowExecution(ctx context.Context, req *workflowservice.StartWorkflowExecutionRequest) (*workflowservice.StartWorkflowExecutionResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.started = append(s.started, req)
+	return &workflowservice.StartWorkflowExecutionResponse{RunId: "run-1"}, nil
+}
+
+// newTracedTemporalClient connects a Temporal client with the tracing
+// interceptor to the stand-in frontend
+func newTracedTemporalClient(t *testing.T, frontend *temporalFrontendStandIn) client.Client {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	workflowservice.RegisterWorkflowServiceServer(server, frontend)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	temporalClient, err := client.NewLazyClient(client.Options{
+		HostPort:  "bufnet",
+		Namespace: "default",
+		ConnectionOptions: client.ConnectionOptions{
+			DialOptions: []grpc.DialOption{
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return listener.DialContext(ctx)
+				}),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+			},
+		},
+		Interceptors: []interceptor.ClientInterceptor{platformtracing.TemporalInterceptor()},
+	})
+	require.NoError(t, err)
+	t.Cleanup(temporalClient.Close)
+	return temporalClient
+}
+
+// TestTracing_RequestToRepository tests that a request, the workflow it
+// starts, the activity of the workflow and the repository operations of the
+// activity are spans of a single trace, continuing the trace of the caller
+func TestTracing_RequestToRepository(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	// The deactivate route starts a SyncUserStatusWorkflow
+	frontend := &temporalFrontendStandIn{}
+	statusSync := temporaladapter.NewUserStatusSynchronizer(newTracedTemporalClient(t, frontend), "user-manager-task-queue")
+	container := di.NewContainer(nil, true, statusSync, di.WithTracing())
+	seedLinkedUser(t, container, otherID, otherSubject, "other@example.com", "user")
+	exporter.Reset()
+
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware)
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), callerClaims(selfID, "admin"))))
+		})
+	})
+	container.UserHandler.RegisterRoutes(api)
+
+	const callerTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/"+otherID+"/deactivate", nil)
+	req.Header.Set("traceparent", "00-"+callerTraceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	// Run the started workflow as a worker would, with the Temporal header
+	// written by the client
+	require.Len(t, frontend.started, 1)
+	started := frontend.started[0]
+	var input temporaladapter.SyncUserStatusWorkflowInput
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayloads(started.Input, &input))
+
+	identityProvider := &fakeIdentityProvider{enabled: map[string]bool{otherSubject: true}}
+	workflows := temporaladapter.NewWorker(
+		temporaladapter.NewCreateUserWorkflow(container.CreateUserHandler),
+		temporaladapter.NewSyncUserStatusWorkflow(container.UserRepository, identityProvider),
+	)
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{platformtracing.TemporalInterceptor()}})
+	env.SetHeader(started.Header)
+	workflows.RegisterWorkflows(env)
+	workflows.RegisterActivities(env)
+	env.ExecuteWorkflow(started.WorkflowType.Name, input)
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+	assert.False(t, identityProvider.enabled[otherSubject])
+
+	// Every span belongs to the trace of the caller
+	spans := exporter.GetSpans()
+	byID := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		assert.Equal(t, callerTraceID, span.SpanContext.TraceID().String(), span.Name)
+		byID[span.SpanContext.SpanID().String()] = span
+	}
+
+	// The repository operation of the activity descends from the request
+	var ancestry []string
+	for _, span := range spans {
+		if span.Name != "UserRepository.GetByID" {
+			continue
+		}
+		var names []string
+		for current, ok := span, true; ok; current, ok = byID[current.Parent.SpanID().String()] {
+			names = append(names, current.Name)
+		}
+		if len(names) > len(ancestry) {
+			ancestry = names
+		}
+	}
+	assert.Equal(t, []string{
+		"UserRepository.GetByID",
+		"RunActivity:SyncUserStatusActivity",
+		"StartActivity:SyncUserStatusActivity",
+		"RunWorkflow:SyncUserStatusWorkflow",
+		"StartWorkflow:SyncUserStatusWorkflow",
+		"POST /api/v1/users/{id}/deactivate",
+	}, ancestry)
+}
@@ -0,0 +1,391 @@
+package unit
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/handlers"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/keycloak"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/repositories/memory"
+	temporaladapter "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/commands"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/auth"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/infrastructure/di"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// recordingStatusSync records the users whose state was propagated
+type recordingStatusSync struct {
+	synced []domain.User
+}
+
+func (s *recordingStatusSync) SyncUserStatus(ctx context.Context, user *domain.User) error {
+	s.synced = append(s.synced, *user)
+	return nil
+}
+
+// fakeIdentityProvider records the account states applied by the workflow
+type fakeIdentityProvider struct {
+	mutex   sync.Mutex
+	enabled map[string]bool
+}
+
+func (p *fakeIdentityProvider) SetUserEnabled(ctx context.Context, externalID string, enabled bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.enabled[externalID]; !ok {
+		return domain.ErrUserNotFound
+	}
+	p.enabled[externalID] = enabled
+	return nil
+}
+
+// TestUserStatus_Commands tests that state changes are saved and propagated once
+func TestUserStatus_Commands(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewUserRepository(memory.NewOutboxRepository())
+	statusSync := &recordingStatusSync{}
+
+	user := domain.NewUser("jane@example.com", "Jane", "Doe", "user")
+	user.ID = uuid.NewString()
+	user.TenantID = tenantID
+	user.ExternalID = selfSubject
+	require.NoError(t, repo.Create(ctx, user))
+	id := user.ID
+
+	deactivate := commands.NewDeactivateUserHandler(repo, statusSync)
+	activate := commands.NewActivateUserHandler(repo, statusSync)
+
+	updated, err := deactivate.Handle(ctx, commands.DeactivateUserCommand{TenantID: tenantID, ID: id})
+	require.NoError(t, err)
+	assert.False(t, updated.Active)
+
+	stored, err := repo.GetByID(ctx, tenantID, id)
+	require.NoError(t, err)
+	assert.False(t, stored.Active)
+
+	// Deactivating twice changes nothing
+	_, err = deactivate.Handle(ctx, commands.DeactivateUserCommand{TenantID: tenantID, ID: id})
+	require.NoError(t, err)
+	require.Len(t, statusSync.synced, 1)
+	assert.False(t, statusSync.synced[0].Active)
+
+	updated, err = activate.Handle(ctx, commands.ActivateUserCommand{TenantID: tenantID, ID: id})
+	require.NoError(t, err)
+	assert.True(t, updated.Active)
+	require.Len(t, statusSync.synced, 2)
+	assert.True(t, statusSync.synced[1].Active)
+
+	_, err = activate.Handle(ctx, commands.ActivateUserCommand{TenantID: otherTenantID, ID: id})
+	assert.Equal(t, domain.ErrUserNotFound, err)
+
+	_, err = activate.Handle(ctx, commands.ActivateUserCommand{TenantID: tenantID})
+	assert.ErrorAs(t, err, new(domain.ValidationError))
+}
+
+// TestUserStatus_Routes tests the activate and deactivate routes
+func TestUserStatus_Routes(t *testing.T) {
+	t.Run("AdminDeactivatesAndActivates", func(t *testing.T) {
+		router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+		seedUser(t, container, otherID, "other@example.com", "user")
+
+		rec := serve(router, http.MethodPost, "/api/v1/users/"+otherID+"/deactivate", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var user handlers.UserResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+		assert.False(t, user.Active)
+
+		rec = serve(router, http.MethodPost, "/api/v1/users/"+otherID+"/activate", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+		assert.True(t, user.Active)
+
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodPost, "/api/v1/users/"+selfID+"/deactivate", nil).Code)
+	})
+
+	t.Run("UpdateAppliesActive", func(t *testing.T) {
+		router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+		seedUser(t, container, otherID, "other@example.com", "user")
+
+		rec := serve(router, http.MethodPut, "/api/v1/users/"+otherID, map[string]interface{}{
+			"email": "other@example.com", "first_name": "Test", "last_name": "User", "role": "user", "active": false,
+		})
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var user handlers.UserResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+		assert.False(t, user.Active)
+	})
+
+	t.Run("UsersCannotDeactivateThemselves", func(t *testing.T) {
+		router, container := newAuthorizedRouter(t, callerClaims(selfSubject, "user"))
+		seedLinkedUser(t, container, selfID, selfSubject, "self@example.com", "user")
+
+		assert.Equal(t, http.StatusForbidden, serve(router, http.MethodPost, "/api/v1/users/"+selfID+"/deactivate", nil).Code)
+
+		rec := serve(router, http.MethodPut, "/api/v1/users/"+selfID, map[string]interface{}{
+			"email": "self@example.com", "first_name": "Test", "last_name": "User", "role": "user", "active": false,
+		})
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+// newStatusCheckedHandler returns the API routes accepting the tokens of
+// active users, and the key signing them
+func newStatusCheckedHandler(t *testing.T, container *di.Container) (http.Handler, *rsa.PrivateKey) {
+	jwks := newJWKSStandIn(t)
+	key := jwks.addKey(t, "key-1")
+
+	keycloakAuth := auth.NewKeycloakAuth(auth.Config{
+		IssuerURL:     testIssuer,
+		JWKSURL:       jwks.server.URL,
+		Audience:      testAudience,
+		ClientID:      "user-manager",
+		AccountStatus: container.AccountStatus,
+	})
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(keycloakAuth.TokenValidationMiddleware)
+	container.UserHandler.RegisterRoutes(api)
+	container.OrganizationHandler.RegisterRoutes(api)
+	return router, key
+}
+
+// serveToken lists the users with token through handler
+func serveToken(handler http.Handler, token string) int {
+	return serveTokenRequest(handler, http.MethodGet, "/api/v1/users", token).Code
+}
+
+// serveTokenRequest serves a request authenticated by token to handler
+func serveTokenRequest(handler http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestUserStatus_DeactivatedUserRejected tests that tokens of deactivated users are refused
+func TestUserStatus_DeactivatedUserRejected(t *testing.T) {
+	container := di.NewContainer(nil, true, nil)
+	handler, key := newStatusCheckedHandler(t, container)
+
+	claims := validClaims()
+	claims["tenant_id"] = tenantID
+	id := uuid.NewString()
+	seedLinkedUser(t, container, id, claims["sub"].(string), "jane@example.com", "admin")
+	token := signToken(t, key, "key-1", claims)
+
+	assert.Equal(t, http.StatusOK, serveToken(handler, token))
+
+	_, err := container.DeactivateUserHandler.Handle(context.Background(), commands.DeactivateUserCommand{
+		TenantID: tenantID,
+		ID:       id,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusForbidden, serveToken(handler, token))
+}
+
+// TestUserStatus_AccountLinking tests that tokens are matched to users
+// through the identity provider account they are linked to
+func TestUserStatus_AccountLinking(t *testing.T) {
+	t.Run("UnknownAccountRejected", func(t *testing.T) {
+		container := di.NewContainer(nil, true, nil)
+		handler, key := newStatusCheckedHandler(t, container)
+		seedUser(t, container, uuid.NewString(), "jane@example.com", "admin")
+
+		claims := validClaims()
+		claims["tenant_id"] = tenantID
+		assert.Equal(t, http.StatusForbidden, serveToken(handler, signToken(t, key, "key-1", claims)))
+	})
+
+	t.Run("LinkedAtCreation", func(t *testing.T) {
+		router, container := newAuthorizedRouter(t, callerClaims(selfSubject, "admin"))
+		seedOrganization(t, container, tenantID, "acme")
+		handler, key := newStatusCheckedHandler(t, container)
+
+		claims := validClaims()
+		claims["tenant_id"] = tenantID
+		rec := serve(router, http.MethodPost, "/api/v1/users", map[string]string{
+			"email": "jane@example.com", "first_name": "Jane", "last_name": "Doe", "role": "user",
+			"external_id": claims["sub"].(string),
+		})
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		var user handlers.UserResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&user))
+		assert.NotEqual(t, claims["sub"], user.ID)
+		assert.Equal(t, claims["sub"], user.ExternalID)
+		assert.Equal(t, http.StatusOK, serveToken(handler, signToken(t, key, "key-1", claims)))
+	})
+
+	t.Run("LinkedByVerifiedEmail", func(t *testing.T) {
+		container := di.NewContainer(nil, true, nil)
+		handler, key := newStatusCheckedHandler(t, container)
+		id := uuid.NewString()
+		seedUser(t, container, id, "jane@example.com", "admin")
+
+		claims := validClaims()
+		claims["tenant_id"] = tenantID
+		claims["email_verified"] = true
+		token := signToken(t, key, "key-1", claims)
+
+		// Requests do not link the account on their own
+		assert.Equal(t, http.StatusForbidden, serveToken(handler, token))
+		user, err := container.UserRepository.GetByID(context.Background(), tenantID, id)
+		require.NoError(t, err)
+		assert.Empty(t, user.ExternalID)
+
+		rec := serveTokenRequest(handler, http.MethodPost, "/api/v1/users/me/link", token)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var linkedUser handlers.UserResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&linkedUser))
+		assert.Equal(t, id, linkedUser.ID)
+		assert.Equal(t, claims["sub"], linkedUser.ExternalID)
+		assert.Equal(t, http.StatusOK, serveToken(handler, token))
+
+		// Linking again is a no-op
+		assert.Equal(t, http.StatusOK, serveTokenRequest(handler, http.MethodPost, "/api/v1/users/me/link", token).Code)
+
+		// Other services learn the account of the user from the linked event
+		events := container.OutboxRepository.(*memory.OutboxRepository).Events()
+		require.NotEmpty(t, events)
+		linked := events[len(events)-1]
+		assert.Equal(t, domain.UserLinkedEvent, linked.Type)
+		var data domain.UserEventData
+		require.NoError(t, json.Unmarshal(linked.Data, &data))
+		assert.Equal(t, claims["sub"], data.ExternalID)
+
+		// The user stays linked to the first account
+		claims["sub"] = uuid.NewString()
+		token = signToken(t, key, "key-1", claims)
+		assert.Equal(t, http.StatusNotFound, serveTokenRequest(handler, http.MethodPost, "/api/v1/users/me/link", token).Code)
+		assert.Equal(t, http.StatusForbidden, serveToken(handler, token))
+	})
+
+	t.Run("UnverifiedEmailNotLinked", func(t *testing.T) {
+		container := di.NewContainer(nil, true, nil)
+		handler, key := newStatusCheckedHandler(t, container)
+		seedUser(t, container, uuid.NewString(), "jane@example.com", "admin")
+
+		claims := validClaims()
+		claims["tenant_id"] = tenantID
+		token := signToken(t, key, "key-1", claims)
+		assert.Equal(t, http.StatusForbidden, serveTokenRequest(handler, http.MethodPost, "/api/v1/users/me/link", token).Code)
+		assert.Equal(t, http.StatusForbidden, serveToken(handler, token))
+	})
+
+	t.Run("DeactivatedUserSyncedWhenLinked", func(t *testing.T) {
+		statusSync := &recordingStatusSync{}
+		container := di.NewContainer(nil, true, statusSync)
+		handler, key := newStatusCheckedHandler(t, container)
+		id := uuid.NewString()
+		seedUser(t, container, id, "jane@example.com", "admin")
+		_, err := container.DeactivateUserHandler.Handle(context.Background(), commands.DeactivateUserCommand{TenantID: tenantID, ID: id})
+		require.NoError(t, err)
+
+		claims := validClaims()
+		claims["tenant_id"] = tenantID
+		claims["email_verified"] = true
+		token := signToken(t, key, "key-1", claims)
+		assert.Equal(t, http.StatusForbidden, serveTokenRequest(handler, http.MethodPost, "/api/v1/users/me/link", token).Code)
+		require.Len(t, statusSync.synced, 2)
+		assert.Equal(t, claims["sub"], statusSync.synced[1].ExternalID)
+		assert.Equal(t, http.StatusForbidden, serveToken(handler, token))
+	})
+}
+
+// TestUserStatus_SyncWorkflow tests that the workflow applies the current state to the identity provider
+func TestUserStatus_SyncWorkflow(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewUserRepository(memory.NewOutboxRepository())
+
+	user := domain.NewUser("jane@example.com", "Jane", "Doe", "user")
+	user.ID = uuid.NewString()
+	user.TenantID = tenantID
+	user.ExternalID = selfSubject
+	user.Deactivate()
+	require.NoError(t, repo.Create(ctx, user))
+
+	unlinked := domain.NewUser("john@example.com", "John", "Doe", "user")
+	unlinked.ID = uuid.NewString()
+	unlinked.TenantID = tenantID
+	unlinked.Deactivate()
+	require.NoError(t, repo.Create(ctx, unlinked))
+
+	provider := &fakeIdentityProvider{enabled: map[string]bool{selfSubject: true}}
+	workflow := temporaladapter.NewSyncUserStatusWorkflow(repo, provider)
+
+	var suite testsuite.WorkflowTestSuite
+	run := func(userID string) error {
+		env := suite.NewTestWorkflowEnvironment()
+		env.RegisterActivity(workflow.SyncUserStatusActivity)
+		env.ExecuteWorkflow(workflow.Execute, temporaladapter.SyncUserStatusWorkflowInput{TenantID: tenantID, UserID: userID})
+		require.True(t, env.IsWorkflowCompleted())
+		return env.GetWorkflowError()
+	}
+
+	require.NoError(t, run(user.ID))
+	assert.False(t, provider.enabled[selfSubject])
+
+	// Users not linked to an account yet are skipped
+	require.NoError(t, run(unlinked.ID))
+	assert.Len(t, provider.enabled, 1)
+
+	// A linked account missing from the identity provider fails the workflow
+	// without retries
+	delete(provider.enabled, selfSubject)
+	err := run(user.ID)
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "IdentityAccountNotFound", appErr.Type())
+	assert.True(t, appErr.NonRetryable())
+}
+
+// TestUserStatus_KeycloakAdminClient tests the calls made to the Keycloak admin API
+func TestUserStatus_KeycloakAdminClient(t *testing.T) {
+	var tokenRequests int
+	var updated map[string]bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/realms/saaster/protocol/openid-connect/token":
+			tokenRequests++
+			assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+			assert.Equal(t, "secret", r.FormValue("client_secret"))
+			json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "admin-token", "expires_in": 300})
+		case r.Method == http.MethodPut && r.URL.Path == "/admin/realms/saaster/users/"+selfSubject:
+			assert.Equal(t, "Bearer admin-token", r.Header.Get("Authorization"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&updated))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := keycloak.NewAdminClient(keycloak.AdminConfig{
+		BaseURL:      server.URL,
+		Realm:        "saaster",
+		ClientID:     "user-manager",
+		ClientSecret: "secret",
+	})
+
+	require.NoError(t, client.SetUserEnabled(context.Background(), selfSubject, false))
+	assert.Equal(t, map[string]bool{"enabled": false}, updated)
+
+	assert.Equal(t, domain.ErrUserNotFound, client.SetUserEnabled(context.Background(), otherSubject, true))
+	assert.Equal(t, 1, tokenRequests)
+}
@@ -0,0 +1,148 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/http/problem"
+	temporaladapter "github.com/b-fontaine/saaster_kit/backend/user_manager/internal/adapters/temporal"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/application/commands"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain"
+	"github.com/b-fontaine/saaster_kit/backend/user_manager/internal/domain/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+// fields returns the invalid fields of a validation error, by name
+func fields(t *testing.T, err error) map[string]string {
+	var validationErrs domain.ValidationErrors
+	require.ErrorAs(t, err, &validationErrs)
+
+	byField := make(map[string]string, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		byField[fieldErr.Field] = fieldErr.Message
+	}
+	return byField
+}
+
+// TestValidation_User tests that every invalid field of a user is reported
+func TestValidation_User(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		v := validation.New()
+		v.User("jane@example.com", "Jane", "Doe", domain.RoleUser)
+		assert.NoError(t, v.Err())
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		v := validation.New()
+		v.User("", " ", "", "")
+		assert.Equal(t, map[string]string{
+			"email":      "is required",
+			"first_name": "is required",
+			"last_name":  "is required",
+			"role":       "is required",
+		}, fields(t, v.Err()))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		v := validation.New()
+		v.User("Jane <jane@example.com>", strings.Repeat("é", domain.MaxNameLength+1), "Doe", "owner")
+		assert.Equal(t, map[string]string{
+			"email":      "must be an email address",
+			"first_name": "must be at most 255 characters",
+			"role":       "must be one of admin, user",
+		}, fields(t, v.Err()))
+	})
+
+	t.Run("NamesCountCharacters", func(t *testing.T) {
+		v := validation.New()
+		v.User("jane@example.com", strings.Repeat("é", domain.MaxNameLength), "Doe", domain.RoleAdmin)
+		assert.NoError(t, v.Err())
+	})
+}
+
+// TestValidation_Commands tests that commands report every invalid field at once
+func TestValidation_Commands(t *testing.T) {
+	_, container := newAuthorizedRouter(t, nil)
+	seedOrganization(t, container, tenantID, "acme")
+	ctx := context.Background()
+
+	_, err := container.CreateUserHandler.Handle(ctx, commands.CreateUserCommand{
+		TenantID:  tenantID,
+		Email:     "not-an-email",
+		FirstName: "Jane",
+		Role:      "owner",
+	})
+	assert.Equal(t, map[string]string{
+		"email":     "must be an email address",
+		"last_name": "is required",
+		"role":      "must be one of admin, user",
+	}, fields(t, err))
+
+	_, err = container.UpdateUserHandler.Handle(ctx, commands.UpdateUserCommand{TenantID: tenantID})
+	assert.Len(t, fields(t, err), 5)
+
+	_, err = container.CreateOrganizationHandler.Handle(ctx, commands.CreateOrganizationCommand{Slug: "Not A Slug", Plan: "gold"})
+	assert.Equal(t, map[string]string{
+		"name": "is required",
+		"slug": "must contain only lowercase letters, digits and hyphens",
+		"plan": "must be one of free, pro, enterprise",
+	}, fields(t, err))
+}
+
+// TestValidation_Route tests that the invalid fields of a request are listed in the problem
+func TestValidation_Route(t *testing.T) {
+	router, container := newAuthorizedRouter(t, callerClaims(selfID, "admin"))
+	seedOrganization(t, container, tenantID, "acme")
+
+	rec := serve(router, http.MethodPost, "/api/v1/users", map[string]string{"email": "jane", "role": "owner"})
+	body := decodeProblem(t, rec, http.StatusBadRequest)
+	assert.ElementsMatch(t, []string{"email", "first_name", "last_name", "role"}, problemFields(body.Errors))
+
+	rec = serve(router, http.MethodGet, "/api/v1/users?limit=0&active=maybe&created_after=yesterday", nil)
+	body = decodeProblem(t, rec, http.StatusBadRequest)
+	assert.ElementsMatch(t, []string{"limit", "active", "created_after"}, problemFields(body.Errors))
+
+	// The fields checked past parsing are named after the query parameters too
+	rec = serve(router, http.MethodGet, "/api/v1/users?created_after=2024-02-01T00:00:00Z&created_before=2024-01-01T00:00:00Z", nil)
+	body = decodeProblem(t, rec, http.StatusBadRequest)
+	assert.Equal(t, []string{"created_before"}, problemFields(body.Errors))
+}
+
+// TestValidation_Activity tests that the CreateUser activity does not retry invalid input
+func TestValidation_Activity(t *testing.T) {
+	_, container := newAuthorizedRouter(t, nil)
+	seedOrganization(t, container, tenantID, "acme")
+	workflow := temporaladapter.NewCreateUserWorkflow(container.CreateUserHandler)
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(workflow.CreateUserActivity)
+
+	_, err := env.ExecuteActivity(workflow.CreateUserActivity, temporaladapter.CreateUserWorkflowInput{
+		TenantID: tenantID,
+		Email:    "jane@example.com",
+		Role:     domain.RoleUser,
+	})
+	var applicationErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &applicationErr), "%v", err)
+	assert.Equal(t, temporaladapter.ValidationErrorType, applicationErr.Type())
+	assert.True(t, applicationErr.NonRetryable())
+
+	var details domain.ValidationErrors
+	require.NoError(t, applicationErr.Details(&details))
+	assert.Equal(t, map[string]string{"first_name": "is required", "last_name": "is required"}, fields(t, details))
+}
+
+func problemFields(fieldErrs []problem.FieldError) []string {
+	names := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		names = append(names, fieldErr.Field)
+	}
+	return names
+}